@@ -1,16 +1,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
-	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	// Registers the gzip compressor so clients can opt into compressed streams
+	_ "google.golang.org/grpc/encoding/gzip"
 
 	"github.com/quickr-dev/quic/internal/agent"
 	"github.com/quickr-dev/quic/internal/auth"
@@ -27,31 +30,66 @@ func main() {
 }
 
 func runDaemon() error {
-	// Initialize database
+	// Initialize database. Tokens are checked against it per request, so
+	// quicd keeps running without it and authentication resumes once it's fixed.
 	database, err := db.InitDB()
+	switch {
+	case errors.Is(err, db.ErrCorrupt):
+		log.Printf("Warning: %v. Requests will fail authentication until %s is restored from a backup", err, db.DBPath)
+	case err != nil:
+		log.Printf("Warning: failed to initialize user database: %v. Requests will fail authentication until it's available", err)
+	default:
+		defer database.Close()
+		log.Println("✓ Init Database")
+	}
+
+	// Load agent config
+	agentConfig, err := agent.LoadAgentConfig(agent.AgentConfigPath)
 	if err != nil {
-		return fmt.Errorf("failed to initialize database: %w", err)
+		return fmt.Errorf("failed to load agent config: %w", err)
+	}
+	agent.ApplyPrivilegeMode(agentConfig.Privilege)
+	agent.ApplyPostgresConfig(agentConfig.Postgres)
+	agent.ApplyStorageConfig(agentConfig.Storage)
+	agent.ApplyFirewallConfig(agentConfig.Firewall)
+	agent.ApplyStartTimeoutConfig(agentConfig.StartTimeout)
+	if err := agent.ApplyLogConfig(agentConfig.Log); err != nil {
+		return err
 	}
-	defer database.Close()
 
-	log.Println("✓ Init Database")
+	missingCore, missingOptional := agent.CheckBinaries()
+	if len(missingOptional) > 0 {
+		log.Printf("Warning: some operations are unavailable, missing: %s", strings.Join(missingOptional, ", "))
+	}
+	if len(missingCore) > 0 {
+		return fmt.Errorf("required binaries not found: %s. Run `quic host setup` to install them", strings.Join(missingCore, ", "))
+	}
 
 	// Load TLS credentials
-	creds, err := credentials.NewServerTLSFromFile(
-		"/etc/quic/certs/server.crt",
-		"/etc/quic/certs/server.key",
-	)
+	tlsConfig, err := agentConfig.TLS.ServerConfig(agent.TLSCertFile, agent.TLSKeyFile)
 	if err != nil {
 		return fmt.Errorf("failed to load TLS credentials: %w", err)
 	}
+	creds := credentials.NewTLS(tlsConfig)
 
 	// Create agent service
 	agentService := agent.NewCheckoutService()
+	agentService.SetConfig(agentConfig)
+	agentService.StartSnapshotGC()
+	agentService.RecoverStaleBranches()
+	agentService.CheckPortConflicts()
+	if err := agentService.StartMetrics(agentConfig.Metrics); err != nil {
+		return err
+	}
+	if err := agent.CheckFirewall(); err != nil {
+		log.Printf("Warning: branches can't be created: %v", err)
+	}
 
 	// Create gRPC server with TLS and auth interceptor
 	grpcServer := grpc.NewServer(
 		grpc.Creds(creds),
 		grpc.UnaryInterceptor(auth.UnaryAuthInterceptor()),
+		grpc.StreamInterceptor(auth.StreamAuthInterceptor()),
 	)
 
 	// Register our service
@@ -70,6 +108,16 @@ func runDaemon() error {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Reload the config on SIGHUP (systemctl reload quicd), keeping the
+	// listener and running operations
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			reloadAgentConfig(agentService)
+		}
+	}()
+
 	// Start server in a goroutine
 	go func() {
 		if err := grpcServer.Serve(lis); err != nil {
@@ -83,7 +131,7 @@ func runDaemon() error {
 
 	// First, shutdown checkout service (wait for active checkouts)
 	log.Println("Waiting for active checkouts to complete...")
-	if err := agentService.Shutdown(5 * time.Minute); err != nil {
+	if err := agentService.Shutdown(agentService.Config().ShutdownTimeout()); err != nil {
 		log.Printf("Checkout service shutdown failed: %v", err)
 	} else {
 		log.Println("All active checkouts completed")
@@ -94,3 +142,19 @@ func runDaemon() error {
 	log.Println("Quicd server stopped")
 	return nil
 }
+
+// reloadAgentConfig re-reads the config file. An invalid file is logged and
+// the running config kept.
+func reloadAgentConfig(agentService *agent.AgentService) {
+	cfg, err := agent.LoadAgentConfig(agent.AgentConfigPath)
+	if err != nil {
+		log.Printf("Config reload failed, keeping the current config: %v", err)
+		return
+	}
+
+	needRestart := agentService.ReloadConfig(cfg)
+	log.Println("✓ Reloaded config")
+	if len(needRestart) > 0 {
+		log.Printf("Changes to %s only take effect after restarting quicd", strings.Join(needRestart, ", "))
+	}
+}
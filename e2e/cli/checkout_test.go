@@ -1,11 +1,19 @@
 package e2e_cli
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/quickr-dev/quic/internal/agent"
 )
 
 func TestQuicCheckout(t *testing.T) {
@@ -56,7 +64,15 @@ func TestQuicCheckout(t *testing.T) {
 		// Verify pg_hba.conf allows admin user access
 		pgHbaConfPath := fmt.Sprintf("%s/pg_hba.conf", clonePath)
 		pgHbaConfOutput := runInVM(t, QuicCheckoutVM, "sudo cat", pgHbaConfPath)
-		require.Contains(t, pgHbaConfOutput, "host    all             admin           0.0.0.0/0               md5", "should allow admin user from any IP")
+		require.Contains(t, pgHbaConfOutput, "host    all             admin           0.0.0.0/0               scram-sha-256", "should allow admin user from any IP")
+	})
+
+	t.Run("ValidateAdminPasswordScramHashed", func(t *testing.T) {
+		output := psqlBranch(t, templateName, branchName, "SELECT rolpassword FROM pg_authid WHERE rolname = 'admin'")
+		require.True(t, strings.HasPrefix(strings.TrimSpace(output), "SCRAM-SHA-256$"), "admin password should be stored as a SCRAM hash, got: %s", output)
+
+		postgresqlConfOutput := runInVM(t, QuicCheckoutVM, "sudo cat", fmt.Sprintf("/opt/quic/%s/%s/postgresql.conf", templateName, branchName))
+		require.Contains(t, postgresqlConfOutput, "password_encryption = 'scram-sha-256'")
 	})
 
 	t.Run("ValidatePostgreSQLService", func(t *testing.T) {
@@ -65,6 +81,10 @@ func TestQuicCheckout(t *testing.T) {
 		serviceStatusOutput := runInVM(t, QuicCheckoutVM, "sudo systemctl is-active", serviceName)
 		require.Contains(t, serviceStatusOutput, "active", "PostgreSQL clone service should be active")
 
+		// The default branch start timeout of 5m
+		unit := runInVM(t, QuicCheckoutVM, "sudo systemctl cat", serviceName)
+		require.Contains(t, unit, "TimeoutStartSec=300")
+
 		// Verify postmaster.pid exists and contains correct information
 		clonePath := fmt.Sprintf("/opt/quic/%s/%s", templateName, branchName)
 		postmasterPidPath := fmt.Sprintf("%s/postmaster.pid", clonePath)
@@ -123,4 +143,835 @@ func TestQuicCheckout(t *testing.T) {
 		portRule := fmt.Sprintf("%s/tcp", portPart)
 		require.Contains(t, ufwOutput, portRule, "UFW should contain rule for checkout port")
 	})
+
+	t.Run("CheckoutRetriesWhileQuicdRestarts", func(t *testing.T) {
+		runInVM(t, QuicCheckoutVM, "sudo systemctl stop quicd")
+		started := make(chan error, 1)
+		go func() {
+			time.Sleep(2 * time.Second)
+			started <- exec.Command("multipass", "exec", QuicCheckoutVM, "--", "sudo", "systemctl", "start", "quicd").Run()
+		}()
+
+		output, err := runQuic(t, "checkout", "retried", "--template", templateName, "--retries", "5")
+		require.NoError(t, <-started)
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", "retried", "--template", templateName)
+		require.Contains(t, output, "retrying in")
+		require.Contains(t, output, "postgresql://admin")
+
+		output, err = runQuic(t, "checkout", "retried", "--template", templateName, "--retries", "-1")
+		require.Error(t, err, output)
+	})
+
+	t.Run("CheckoutFailsWithInactiveFirewall", func(t *testing.T) {
+		runInVM(t, QuicCheckoutVM, "sudo", "ufw", "disable")
+		defer runInVM(t, QuicCheckoutVM, "sudo", "ufw", "--force", "enable")
+
+		output, err := runQuic(t, "checkout", "no-firewall", "--template", templateName)
+		require.Error(t, err, output)
+		require.Contains(t, output, "ufw is inactive, so branch ports wouldn't be firewalled")
+	})
+
+	t.Run("CheckoutRejectsExternalTablespace", func(t *testing.T) {
+		tablespaceDir := "/var/lib/postgresql/external_tblspc"
+		tablespaceLink := fmt.Sprintf("/opt/quic/%s/_restore/pg_tblspc/99999", templateName)
+		runInVM(t, QuicCheckoutVM, "sudo mkdir -p", tablespaceDir)
+		runInVM(t, QuicCheckoutVM, "sudo ln -sfn", tablespaceDir, tablespaceLink)
+		defer runInVM(t, QuicCheckoutVM, "sudo rm -f", tablespaceLink)
+
+		tablespaceBranch := fmt.Sprintf("tblspc-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", tablespaceBranch, "--template", templateName)
+		require.Error(t, err, output)
+		require.Contains(t, output, "tablespaces not supported")
+		require.Contains(t, output, tablespaceDir)
+	})
+
+	t.Run("CheckoutWithSettings", func(t *testing.T) {
+		settingsBranch := fmt.Sprintf("settings-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", settingsBranch, "--template", templateName, "--set", "statement_timeout=30s", "--set", "log_statement=all")
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", settingsBranch, "--template", templateName)
+
+		timeout := psqlBranch(t, templateName, settingsBranch, "SHOW statement_timeout")
+		require.Equal(t, "30s", strings.TrimSpace(timeout))
+
+		output, err = runQuic(t, "branch", "info", settingsBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Settings:    log_statement=all, statement_timeout=30s")
+	})
+
+	t.Run("CheckoutWithSuppliedAdminPassword", func(t *testing.T) {
+		// Quotes and dollar quotes must not break out of the role SQL
+		password := "O'Brien-$$-Str0ng-secret"
+		passwordBranch := fmt.Sprintf("password-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", passwordBranch, "--template", templateName, "--admin-password", password)
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", passwordBranch, "--template", templateName)
+
+		connectionString := strings.TrimSpace(output)
+		require.Contains(t, connectionString, "O%27Brien-$$-Str0ng-secret")
+		loginOutput := runInVM(t, QuicCheckoutVM, fmt.Sprintf("psql '%s' --tuples-only -c 'SELECT current_user'", connectionString))
+		require.Contains(t, loginOutput, "admin")
+
+		output, err = runQuic(t, "checkout", passwordBranch, "--template", templateName, "--admin-password", "Another-Str0ng-secret")
+		require.Error(t, err, "Expected a different password for an existing branch to be refused")
+		require.Contains(t, output, "already exists with a different admin password")
+
+		auditOutput := runInVM(t, QuicCheckoutVM, fmt.Sprintf("sudo grep -c Str0ng-secret %s || true", agent.AuditFile))
+		require.Equal(t, "0", strings.TrimSpace(auditOutput), "audit log should only contain a masked password")
+
+		output, err = runQuic(t, "checkout", "weak-password-branch", "--template", templateName, "--admin-password", "short")
+		require.Error(t, err, output)
+		require.Contains(t, output, "admin password must be at least 16 characters")
+	})
+
+	t.Run("CheckoutWithSafeWALReset", func(t *testing.T) {
+		safeBranch := fmt.Sprintf("safe-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", safeBranch, "--template", templateName, "--wal-reset", "safe")
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", safeBranch, "--template", templateName)
+
+		usersOutput := psqlBranch(t, templateName, safeBranch, "SELECT COUNT(*) FROM users")
+		require.Contains(t, usersOutput, "5", "Should have 5 users after crash recovery")
+
+		output, err = runQuic(t, "branch", "info", safeBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "WAL reset:   safe")
+		require.Regexp(t, `Data as of:  \d{4}-\d{2}-\d{2}T`, output, "branch should record how recent the template's data is")
+
+		output, err = runQuic(t, "checkout", "bad-reset-branch", "--template", templateName, "--wal-reset", "sometimes")
+		require.Error(t, err)
+		require.Contains(t, output, "Use fast or safe")
+	})
+
+	t.Run("DeleteKeepDataAndAttach", func(t *testing.T) {
+		detachedBranch := fmt.Sprintf("detached-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", detachedBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", detachedBranch, "--template", templateName)
+
+		output, err = runQuic(t, "delete", detachedBranch, "--template", templateName, "--keep-data")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Detached branch")
+
+		// Data and metadata stay, the service is gone
+		runInVM(t, QuicCheckoutVM, "sudo zfs list", fmt.Sprintf("tank/%s/%s", templateName, detachedBranch))
+		metadata := runInVM(t, QuicCheckoutVM, "sudo cat", fmt.Sprintf("/opt/quic/%s/%s/.quic-meta.json", templateName, detachedBranch))
+		require.Contains(t, metadata, `"detached": true`)
+		serviceStatus := runShell(t, "multipass", "exec", QuicCheckoutVM, "--", "bash", "-c",
+			fmt.Sprintf("systemctl is-active quic-%s-%s || true", templateName, detachedBranch))
+		require.NotEqual(t, "active", strings.TrimSpace(serviceStatus), "service should be removed")
+
+		output, err = runQuic(t, "branch", "info", detachedBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Detached:")
+
+		// The detached branch's port stays reserved for attach
+		detachedPort := runInVM(t, QuicCheckoutVM, fmt.Sprintf(`sudo grep -o '"port": "[0-9]*"' /opt/quic/%s/%s/.quic-meta.json | grep -o '[0-9][0-9]*'`, templateName, detachedBranch))
+		nextBranch := fmt.Sprintf("after-detach-%d", time.Now().UnixNano())
+		output, err = runQuic(t, "checkout", nextBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		nextPort := runInVM(t, QuicCheckoutVM, fmt.Sprintf(`sudo grep -o '"port": "[0-9]*"' /opt/quic/%s/%s/.quic-meta.json | grep -o '[0-9][0-9]*'`, templateName, nextBranch))
+		require.NotEqual(t, strings.TrimSpace(detachedPort), strings.TrimSpace(nextPort), "Expected a new branch to skip the detached branch's port")
+
+		output, err = runQuic(t, "checkout", detachedBranch, "--template", templateName)
+		require.Error(t, err, "checking out a detached branch should fail")
+		require.Contains(t, output, "is detached")
+
+		output, err = runQuic(t, "branch", "attach", detachedBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.True(t, strings.HasPrefix(strings.TrimSpace(output), "postgresql://admin:"), output)
+
+		usersOutput := psqlBranch(t, templateName, detachedBranch, "SELECT COUNT(*) FROM users")
+		require.Contains(t, usersOutput, "5", "attached branch should serve its kept data")
+
+		output, err = runQuic(t, "branch", "info", detachedBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.NotContains(t, output, "Detached:")
+		require.Contains(t, output, "Ready:       yes")
+	})
+
+	t.Run("AttachMovesOffTakenPort", func(t *testing.T) {
+		movedBranch := fmt.Sprintf("taken-port-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", movedBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		output, err = runQuic(t, "delete", movedBranch, "--template", templateName, "--keep-data")
+		require.NoError(t, err, output)
+
+		// Something outside quic listens on the detached branch's port
+		metadataPath := fmt.Sprintf("/opt/quic/%s/%s/.quic-meta.json", templateName, movedBranch)
+		port := strings.TrimSpace(runInVM(t, QuicCheckoutVM, fmt.Sprintf(`sudo grep -o '"port": "[0-9]*"' %s | grep -o '[0-9][0-9]*'`, metadataPath)))
+		runInVM(t, QuicCheckoutVM, "sudo systemd-run --unit=hold-port-"+port, "python3 -m http.server", port)
+		time.Sleep(time.Second)
+
+		output, err = runQuic(t, "branch", "attach", movedBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, fmt.Sprintf("Port %s is taken", port))
+		require.NotContains(t, output, ":"+port+"/", "Expected the connection string to use the new port")
+
+		newPort := strings.TrimSpace(runInVM(t, QuicCheckoutVM, fmt.Sprintf(`sudo grep -o '"port": "[0-9]*"' %s | grep -o '[0-9][0-9]*'`, metadataPath)))
+		require.NotEqual(t, port, newPort, "Expected the metadata to record the new port")
+		require.Contains(t, psqlBranch(t, templateName, movedBranch, "SELECT COUNT(*) FROM users"), "5")
+	})
+
+	t.Run("AttachRemovesStalePostmasterPid", func(t *testing.T) {
+		staleBranch := fmt.Sprintf("stale-pid-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", staleBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", staleBranch, "--template", templateName)
+
+		// Like after a reboot: the server is gone, and its recorded PID now
+		// belongs to a process that isn't PostgreSQL
+		branchPath := fmt.Sprintf("/opt/quic/%s/%s", templateName, staleBranch)
+		runInVM(t, QuicCheckoutVM, "sudo systemctl stop", fmt.Sprintf("quic-%s-%s", templateName, staleBranch))
+		runShell(t, "multipass", "exec", QuicCheckoutVM, "--", "bash", "-c",
+			fmt.Sprintf("printf '1\\n%s\\n0\\n5432\\n' | sudo -u postgres tee %s/postmaster.pid", branchPath, branchPath))
+
+		output, err = runQuic(t, "branch", "attach", staleBranch, "--template", templateName)
+		require.NoError(t, err, output)
+
+		usersOutput := psqlBranch(t, templateName, staleBranch, "SELECT COUNT(*) FROM users")
+		require.Contains(t, usersOutput, "5", "branch should start despite the stale postmaster.pid")
+
+		pidFile := runInVM(t, QuicCheckoutVM, "sudo head -1", branchPath+"/postmaster.pid")
+		require.NotEqual(t, "1", strings.TrimSpace(pidFile))
+	})
+
+	t.Run("AttachUnknownBranch", func(t *testing.T) {
+		output, err := runQuic(t, "branch", "attach", "no-such-branch", "--template", templateName)
+		require.Error(t, err)
+		require.Contains(t, output, "not found")
+	})
+
+	t.Run("CheckoutWithMaxConnections", func(t *testing.T) {
+		poolBranch := fmt.Sprintf("pool-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", poolBranch, "--template", templateName, "--max-connections", "120")
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", poolBranch, "--template", templateName)
+
+		require.Contains(t, psqlBranch(t, templateName, poolBranch, "SHOW max_connections"), "120")
+
+		output, err = runQuic(t, "branch", "info", poolBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Regexp(t, `Connections: \d+ of 120 max`, output)
+
+		output, err = runQuic(t, "checkout", fmt.Sprintf("bad-pool-%d", time.Now().UnixNano()), "--template", templateName, "--max-connections", "2")
+		require.Error(t, err)
+		require.Contains(t, output, "max connections must be between 5 and 1000")
+	})
+
+	t.Run("CheckoutWithCustomMountpoint", func(t *testing.T) {
+		mountBranch := fmt.Sprintf("mount-branch-%d", time.Now().UnixNano())
+		mountpoint := "/srv/" + mountBranch
+
+		// Host setup ran with --mountpoint-root /srv, granting quicd sudo rules for it
+		sudoers := runInVM(t, QuicCheckoutVM, "sudo cat /etc/sudoers.d/quic-agent")
+		require.Contains(t, sudoers, "/usr/bin/tee /srv/*")
+		require.Contains(t, sudoers, "/bin/rmdir /srv/*")
+
+		output, err := runQuic(t, "checkout", mountBranch, "--template", templateName, "--mountpoint", mountpoint)
+		require.NoError(t, err, output)
+
+		zfsMountpoint := runInVM(t, QuicCheckoutVM, "sudo zfs get -H -o value mountpoint", fmt.Sprintf("tank/%s/%s", templateName, mountBranch))
+		require.Equal(t, mountpoint, strings.TrimSpace(zfsMountpoint))
+		runInVM(t, QuicCheckoutVM, "sudo test -f", mountpoint+"/.quic-meta.json")
+		unit := runInVM(t, QuicCheckoutVM, "sudo systemctl cat", fmt.Sprintf("quic-%s-%s", templateName, mountBranch))
+		require.Contains(t, unit, "--pgdata="+mountpoint)
+
+		output, err = runQuic(t, "branch", "info", mountBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Mountpoint:  "+mountpoint)
+		require.Contains(t, output, "Ready:       yes")
+
+		output, err = runQuic(t, "checkout", mountBranch+"-twin", "--template", templateName, "--mountpoint", mountpoint)
+		require.Error(t, err)
+		require.Contains(t, output, "already used by dataset")
+
+		output, err = runQuic(t, "checkout", mountBranch+"-inside", "--template", templateName, "--mountpoint", "/opt/quic/elsewhere")
+		require.Error(t, err)
+		require.Contains(t, output, "inside /opt/quic")
+
+		output, err = runQuic(t, "checkout", mountBranch+"-outside", "--template", templateName, "--mountpoint", "/mnt/"+mountBranch)
+		require.Error(t, err)
+		require.Contains(t, output, "is outside /srv")
+
+		output, err = runQuic(t, "delete", mountBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		runInVM(t, QuicCheckoutVM, "sudo test ! -e", mountpoint)
+	})
+
+	t.Run("CheckoutForPerformanceTesting", func(t *testing.T) {
+		perfBranch := fmt.Sprintf("perf-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", perfBranch, "--template", templateName, "--profile", "performance", "--analyze")
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", perfBranch, "--template", templateName)
+
+		require.Contains(t, psqlBranch(t, templateName, perfBranch, "SHOW autovacuum"), "on")
+		analyzed := psqlBranch(t, templateName, perfBranch, "SELECT count(*) FROM pg_stat_user_tables WHERE relname = 'users' AND last_analyze IS NOT NULL")
+		require.Contains(t, analyzed, "1")
+
+		output, err = runQuic(t, "branch", "info", perfBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Profile:     performance")
+		require.Contains(t, output, "Analyzed:    yes")
+
+		// ANALYZE runs after the checkout lock is released, its timing is still recorded
+		output, err = runQuic(t, "branch", "info", perfBranch, "--template", templateName, "--last-timing")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "  analyze ")
+
+		require.Contains(t, psqlBranch(t, templateName, branchName, "SHOW autovacuum"), "off", "default branches keep autovacuum off")
+	})
+
+	t.Run("CheckoutNamedAfterGitBranch", func(t *testing.T) {
+		bin, err := filepath.Abs("../../bin/quic")
+		require.NoError(t, err)
+		configPath, err := filepath.Abs("quic.json")
+		require.NoError(t, err)
+
+		quicIn := func(dir string, args ...string) (string, error) {
+			cmd := exec.Command(bin, args...)
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "QUIC_CONFIG="+configPath)
+			output, err := cmd.CombinedOutput()
+			return string(output), err
+		}
+
+		repo := t.TempDir()
+		runShell(t, "git", "-C", repo, "init", "--initial-branch", "Feature/Git_Named")
+		output, err := quicIn(repo, "checkout", "--template", templateName)
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", "feature-git_named", "--template", templateName)
+		require.Contains(t, output, "Using branch name 'feature-git_named' from git branch 'Feature/Git_Named'")
+
+		output, err = runQuic(t, "branch", "info", "feature-git_named", "--template", templateName)
+		require.NoError(t, err, output)
+
+		output, err = quicIn(t.TempDir(), "checkout", "--template", templateName)
+		require.Error(t, err)
+		require.Contains(t, output, "not inside a git repository")
+	})
+
+	t.Run("SelfTest", func(t *testing.T) {
+		output, err := runQuic(t, "self-test", "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "✓ checkout")
+		require.Contains(t, output, "✓ query")
+		require.Contains(t, output, "✓ delete")
+		require.Contains(t, output, "Self-test passed")
+
+		zfsOutput := runInVM(t, QuicCheckoutVM, "zfs list")
+		require.NotContains(t, zfsOutput, "selftest-", "self-test should leave no branch behind")
+
+		// Started within the same second, they must not share a branch
+		type result struct {
+			output string
+			err    error
+		}
+		results := make(chan result, 2)
+		for range 2 {
+			go func() {
+				output, err := runQuic(t, "self-test", "--template", templateName)
+				results <- result{output, err}
+			}()
+		}
+		for range 2 {
+			r := <-results
+			require.NoError(t, r.err, r.output)
+			require.Contains(t, r.output, "Self-test passed")
+		}
+
+		output, err = runQuic(t, "self-test", "--template", templateName, "--host", "no-such-host")
+		require.Error(t, err)
+		require.Contains(t, output, "host 'no-such-host' not found")
+	})
+
+	t.Run("CheckoutRejectsDisallowedSetting", func(t *testing.T) {
+		output, err := runQuic(t, "checkout", fmt.Sprintf("bad-setting-%d", time.Now().UnixNano()), "--template", templateName, "--set", "shared_buffers=64GB")
+		require.Error(t, err, output)
+		require.Contains(t, output, "setting 'shared_buffers' is not allowed")
+	})
+
+	t.Run("CheckoutRejectsInvalidSettingValue", func(t *testing.T) {
+		branch := fmt.Sprintf("bad-value-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", branch, "--template", templateName, "--set", "work_mem=lots")
+		require.Error(t, err, output)
+		require.Contains(t, output, "invalid branch settings")
+		require.Contains(t, output, "work_mem")
+
+		output, err = runQuic(t, "ls")
+		require.NoError(t, err, output)
+		require.NotContains(t, output, branch, "Expected the refused branch to be removed")
+	})
+
+	t.Run("EventsStream", func(t *testing.T) {
+		var output bytes.Buffer
+		events := exec.Command("../../bin/quic", "events")
+		events.Stdout = &output
+		events.Stderr = &output
+		require.NoError(t, events.Start())
+		time.Sleep(2 * time.Second)
+
+		eventsBranch := fmt.Sprintf("events-branch-%d", time.Now().UnixNano())
+		checkoutOutput, err := runQuic(t, "checkout", eventsBranch, "--template", templateName)
+		require.NoError(t, err, checkoutOutput)
+		deleteOutput, err := runQuic(t, "delete", eventsBranch, "--template", templateName)
+		require.NoError(t, err, deleteOutput)
+		time.Sleep(time.Second)
+
+		events.Process.Kill()
+		events.Wait()
+
+		target := templateName + "/" + eventsBranch
+		require.Regexp(t, `branch_create\s+`+target, output.String())
+		require.Regexp(t, `branch_delete\s+`+target, output.String())
+	})
+
+	t.Run("BranchInfo", func(t *testing.T) {
+		output, err := runQuic(t, "branch", "info", branchName, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Service:     active")
+		require.Contains(t, output, "Ready:       yes")
+		require.Contains(t, output, "Recovery:    no")
+		require.Contains(t, output, "WAL reset:   fast", "a running template is checkpointed, so fast is the default")
+		require.Regexp(t, `Connections: \d+ of 50 max`, output)
+		require.Contains(t, output, "Encryption:")
+		require.Contains(t, output, "Compression: lz4")
+	})
+
+	t.Run("BranchInfoLastTiming", func(t *testing.T) {
+		output, err := runQuic(t, "branch", "info", branchName, "--template", templateName, "--last-timing")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Timing:")
+		for _, step := range []string{"snapshot", "clone", "prepare", "service_start", "firewall", "admin_user", "total"} {
+			require.Contains(t, output, "  "+step+" ")
+		}
+	})
+
+	t.Run("CheckoutJSONOutput", func(t *testing.T) {
+		// Checking out an existing branch returns its recorded timings
+		output, err := runQuic(t, "checkout", branchName, "--template", templateName, "--output", "json")
+		require.NoError(t, err, output)
+
+		var result struct {
+			ConnectionString string `json:"connection_string"`
+			Timings          []struct {
+				Step       string `json:"step"`
+				DurationMs int64  `json:"duration_ms"`
+			} `json:"timings"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(output), &result), output)
+		require.True(t, strings.HasPrefix(result.ConnectionString, "postgresql://admin:"))
+		require.NotEmpty(t, result.Timings)
+		require.Equal(t, "snapshot", result.Timings[0].Step)
+	})
+
+	t.Run("CheckoutVerifyConnection", func(t *testing.T) {
+		output, err := runQuic(t, "checkout", branchName, "--template", templateName, "--verify-connection")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "✓ Connected from this machine")
+
+		output, err = runQuic(t, "checkout", branchName, "--template", templateName, "--verify-connection", "--output", "json")
+		require.NoError(t, err, output)
+		var result struct {
+			ConnectionCheck struct {
+				OK bool `json:"ok"`
+			} `json:"connection_check"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(output), &result), output)
+		require.True(t, result.ConnectionCheck.OK, output)
+	})
+
+	t.Run("BranchURL", func(t *testing.T) {
+		output, err := runQuic(t, "branch", "url", branchName, "--template", templateName)
+		require.NoError(t, err, output)
+
+		url := strings.TrimSpace(output)
+		require.NotContains(t, url, "\n", "branch url should print only the connection string")
+		require.True(t, strings.HasPrefix(url, "postgresql://admin:"), url)
+		require.Contains(t, checkoutOutput, url, "branch url should match the checkout connection string")
+
+		output, err = runQuic(t, "branch", "url", branchName, "--template", templateName, "--database", "postgres")
+		require.NoError(t, err, output)
+		require.True(t, strings.HasSuffix(strings.TrimSpace(output), "/postgres"), output)
+
+		output, err = runQuic(t, "branch", "url", "no-such-branch", "--template", templateName)
+		require.Error(t, err)
+		require.Contains(t, output, "not found")
+	})
+
+	t.Run("SnapshotAndRollback", func(t *testing.T) {
+		psqlBranch(t, templateName, branchName, "CREATE TABLE checkpoint_test (id int)")
+
+		output, err := runQuic(t, "branch", "snapshot", branchName, "--template", templateName, "--label", "before-migration")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Checkpoint 'before-migration' saved")
+
+		output, err = runQuic(t, "branch", "info", branchName, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "before-migration")
+
+		psqlBranch(t, templateName, branchName, "DROP TABLE checkpoint_test")
+
+		output, err = runQuic(t, "branch", "rollback", branchName, "--template", templateName, "--to", "before-migration")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "rolled back to checkpoint 'before-migration'")
+
+		tableOutput := psqlBranch(t, templateName, branchName, "SELECT count(*) FROM pg_tables WHERE tablename = 'checkpoint_test'")
+		require.Contains(t, tableOutput, "1", "table dropped after the checkpoint should be back")
+	})
+
+	t.Run("FreezeAndUnfreeze", func(t *testing.T) {
+		frozenBranch := fmt.Sprintf("frozen-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", frozenBranch, "--template", templateName)
+		require.NoError(t, err, output)
+
+		output, err = runQuic(t, "branch", "freeze", frozenBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Branch '"+frozenBranch+"' frozen")
+		require.Contains(t, psqlBranch(t, templateName, frozenBranch, "SHOW default_transaction_read_only"), "on")
+
+		output, err = runQuic(t, "branch", "info", frozenBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Frozen:      yes")
+
+		output, err = runQuic(t, "delete", frozenBranch, "--template", templateName)
+		require.Error(t, err)
+		require.Contains(t, output, "is frozen")
+
+		output, err = runQuic(t, "branch", "unfreeze", frozenBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Branch '"+frozenBranch+"' unfrozen")
+		require.Contains(t, psqlBranch(t, templateName, frozenBranch, "SHOW default_transaction_read_only"), "off")
+
+		output, err = runQuic(t, "branch", "freeze", frozenBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		output, err = runQuic(t, "delete", frozenBranch, "--template", templateName, "--force")
+		require.NoError(t, err, output)
+	})
+
+	t.Run("SetSettingsOnRunningBranch", func(t *testing.T) {
+		output, err := runQuic(t, "branch", "set", branchName, "work_mem=64MB", "log_statement=all", "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Applied immediately: log_statement, work_mem")
+		require.Contains(t, psqlBranch(t, templateName, branchName, "SHOW work_mem"), "64MB")
+
+		output, err = runQuic(t, "branch", "set", branchName, "shared_buffers=1GB", "--template", templateName)
+		require.Error(t, err)
+		require.Contains(t, output, "setting 'shared_buffers' is not allowed")
+
+		output, err = runQuic(t, "branch", "set", branchName, "work_mem=lots", "--template", templateName)
+		require.Error(t, err, "Expected an invalid value to be refused")
+		require.Contains(t, output, "invalid branch settings")
+		require.Contains(t, psqlBranch(t, templateName, branchName, "SHOW work_mem"), "64MB")
+		autoConf := runInVM(t, QuicCheckoutVM, fmt.Sprintf("sudo cat /opt/quic/%s/%s/postgresql.auto.conf", templateName, branchName))
+		require.NotContains(t, autoConf, "lots", "Expected the previous postgresql.auto.conf to be restored")
+	})
+
+	t.Run("VerifyReportsDuplicatePorts", func(t *testing.T) {
+		first := fmt.Sprintf("port-a-%d", time.Now().UnixNano())
+		second := fmt.Sprintf("port-b-%d", time.Now().UnixNano())
+		for _, name := range []string{first, second} {
+			output, err := runQuic(t, "checkout", name, "--template", templateName)
+			require.NoError(t, err, output)
+		}
+
+		// Record the first branch's port in the second's metadata, like a
+		// crash during the port race would
+		firstPort := runInVM(t, QuicCheckoutVM, fmt.Sprintf(`sudo grep -o '"port": "[0-9]*"' /opt/quic/%s/%s/.quic-meta.json | grep -o '[0-9][0-9]*'`, templateName, first))
+		secondMeta := fmt.Sprintf("/opt/quic/%s/%s/.quic-meta.json", templateName, second)
+		runInVM(t, QuicCheckoutVM, fmt.Sprintf(`sudo sed -i 's/"port": "[0-9]*"/"port": "%s"/' %s`, strings.TrimSpace(firstPort), secondMeta))
+		// Metadata edited behind quicd's back is only picked up at startup
+		runInVM(t, QuicCheckoutVM, "sudo systemctl restart quicd && sleep 3")
+
+		output, err := runQuic(t, "host", "verify", getVMIP(t, QuicCheckoutVM))
+		require.Error(t, err)
+		require.Contains(t, output, "✗ branch ports: "+strings.TrimSpace(firstPort)+" used by")
+		require.Contains(t, output, templateName+"/"+second)
+		require.Regexp(t, `✓ clock: [\d.]+m?s (ahead of|behind) this machine`, output)
+
+		// And no longer reported once resolved
+		output, err = runQuic(t, "delete", second, "--template", templateName)
+		require.NoError(t, err, output)
+		output, _ = runQuic(t, "host", "verify", getVMIP(t, QuicCheckoutVM))
+		require.Contains(t, output, "✓ branch ports: no conflicts")
+	})
+
+	t.Run("ReloadConfig", func(t *testing.T) {
+		runInVM(t, QuicCheckoutVM, "sudo cp /etc/quic/quicd.json /tmp/quicd.json.bak")
+		defer runInVM(t, QuicCheckoutVM, "sudo cp /tmp/quicd.json.bak /etc/quic/quicd.json && sudo systemctl reload quicd")
+
+		runInVM(t, QuicCheckoutVM, `echo '{"postgres": {"version": "16"}, "admins": ["reload-test"], "startTimeout": {"branch": "6m"}}' | sudo tee /etc/quic/quicd.json`)
+		pid := runInVM(t, QuicCheckoutVM, "systemctl show -p MainPID --value quicd")
+		runInVM(t, QuicCheckoutVM, "sudo systemctl reload quicd && sleep 1")
+		require.Equal(t, pid, runInVM(t, QuicCheckoutVM, "systemctl show -p MainPID --value quicd"), "reload should keep quicd running")
+
+		logs := runInVM(t, QuicCheckoutVM, "sudo journalctl -u quicd --since '-1 min' --no-pager")
+		require.Contains(t, logs, "Reloaded config")
+		require.Contains(t, logs, "Changes to startTimeout only take effect after restarting quicd")
+
+		// A misspelled key is reported rather than ignored
+		runInVM(t, QuicCheckoutVM, `echo '{"postgres": {"version": "16"}, "drainTimout": "1m"}' | sudo tee /etc/quic/quicd.json`)
+		runInVM(t, QuicCheckoutVM, "sudo systemctl reload quicd && sleep 1")
+		logs = runInVM(t, QuicCheckoutVM, "sudo journalctl -u quicd --since '-10 sec' --no-pager")
+		require.Contains(t, logs, "Config reload failed, keeping the current config")
+		require.Contains(t, logs, `unknown field "drainTimout"`)
+
+		output, err := runQuic(t, "whoami")
+		require.NoError(t, err, output)
+	})
+
+	t.Run("BranchDiff", func(t *testing.T) {
+		psqlBranch(t, templateName, branchName, "CREATE TABLE diff_test AS SELECT generate_series(1, 100000) AS id")
+
+		output, err := runQuic(t, "branch", "diff", branchName, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, agent.GetSnapshotName(templateName, branchName))
+		require.Contains(t, output, "Written:")
+		require.NotContains(t, output, "Written:   0B")
+		require.Regexp(t, `quic_test\s+\S+\s+\S+\s+\+`, output, "branch database should have grown")
+	})
+
+	t.Run("BranchDescribe", func(t *testing.T) {
+		psqlBranch(t, templateName, branchName, "CREATE TABLE describe_test AS SELECT generate_series(1, 1000) AS id; ANALYZE describe_test")
+
+		output, err := runQuic(t, "branch", "describe", branchName, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Regexp(t, `quic_test\s+\S+`, output)
+		require.Regexp(t, `public\.describe_test\s+1000\s`, output)
+
+		output, err = runQuic(t, "branch", "describe", branchName, "--template", templateName, "--limit", "1", "--output", "json")
+		require.NoError(t, err, output)
+		var description map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(output), &description), output)
+		require.Equal(t, "quic_test", description["database"])
+		require.Len(t, description["tables"], 1)
+	})
+
+	t.Run("BranchWithMissingTemplate", func(t *testing.T) {
+		// Hide the template's setup metadata, as if its data had been removed by hand
+		templateMeta := fmt.Sprintf("/opt/quic/%s/_restore/.quic-init-meta.json", templateName)
+		runInVM(t, QuicCheckoutVM, fmt.Sprintf("sudo mv %s %s.bak", templateMeta, templateMeta))
+		defer runInVM(t, QuicCheckoutVM, fmt.Sprintf("sudo mv %s.bak %s", templateMeta, templateMeta))
+
+		output, err := runQuic(t, "branch", "info", branchName, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Template:    missing")
+
+		output, err = runQuic(t, "ls", "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "(template missing)")
+
+		output, err = runQuic(t, "branch", "diff", branchName, "--template", templateName)
+		require.Error(t, err)
+		require.Contains(t, output, "template '"+templateName+"' of branch '"+branchName+"' no longer exists")
+	})
+
+	t.Run("OutputFormats", func(t *testing.T) {
+		output, err := runQuic(t, "ls", "--template", templateName, "--output", "json")
+		require.NoError(t, err, output)
+		var branches []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(output), &branches), output)
+		require.NotEmpty(t, branches)
+		require.Contains(t, branches[0], "used_bytes")
+
+		output, err = runQuic(t, "branch", "info", branchName, "--template", templateName, "--output", "json")
+		require.NoError(t, err, output)
+		var info map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(output), &info), output)
+		require.Equal(t, branchName, info["branch"])
+		require.Equal(t, true, info["ready"])
+
+		output, err = runQuic(t, "branch", "info", branchName, "--template", templateName, "--output", "yaml")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "branch: "+branchName+"\n")
+
+		output, err = runQuic(t, "branch", "info", branchName, "--template", templateName, "--output", "json", "--watch")
+		require.Error(t, err)
+		require.Contains(t, output, "--watch only works with --output table")
+
+		output, err = runQuic(t, "ls", "--output", "xml")
+		require.Error(t, err)
+		require.Contains(t, output, "invalid output format 'xml'. Use table, json or yaml")
+	})
+
+	t.Run("ReadReplica", func(t *testing.T) {
+		parentBranch := "replica-parent"
+		output, err := runQuic(t, "checkout", parentBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		defer runQuic(t, "delete", parentBranch, "--template", templateName)
+
+		output, err = runQuic(t, "branch", "replica", parentBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "postgresql://admin:")
+		require.Contains(t, output, "Replica '"+parentBranch+"-replica-1'")
+		require.Contains(t, output, "was restarted to enable replication")
+		replicaBranch := parentBranch + "-replica-1"
+
+		require.Equal(t, "t", strings.TrimSpace(psqlBranch(t, templateName, replicaBranch, "SELECT pg_is_in_recovery()")))
+
+		// Changes on the parent stream to the replica
+		psqlBranch(t, templateName, parentBranch, "CREATE TABLE replica_test AS SELECT 1 AS id")
+		require.Eventually(t, func() bool {
+			output := psqlBranch(t, templateName, replicaBranch, "SELECT count(*) FROM pg_tables WHERE tablename = 'replica_test'")
+			return strings.TrimSpace(output) == "1"
+		}, 30*time.Second, time.Second)
+
+		output, err = runQuic(t, "branch", "info", parentBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Replicas:    "+replicaBranch)
+
+		output, err = runQuic(t, "ls", "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "(replica of "+parentBranch+")")
+
+		output, err = runQuic(t, "delete", parentBranch, "--template", templateName, "--dry-run")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Replicas that would also be deleted:\n  "+replicaBranch)
+
+		output, err = runQuic(t, "delete", parentBranch, "--template", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Deleted replicas of '"+parentBranch+"': "+replicaBranch)
+		runInVM(t, QuicCheckoutVM, fmt.Sprintf("! sudo zfs list tank/%s/%s", templateName, replicaBranch))
+	})
+
+	t.Run("HostOpsIdle", func(t *testing.T) {
+		output, err := runQuic(t, "host", "ops", getVMIP(t, QuicCheckoutVM))
+		require.NoError(t, err, output)
+		require.Contains(t, output, "No operations in progress.")
+	})
+
+	t.Run("HostAudit", func(t *testing.T) {
+		output, err := runQuic(t, "host", "audit", getVMIP(t, QuicCheckoutVM), "--since", "1d")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "checkout_create")
+		require.Contains(t, output, "branch_delete")
+
+		output, err = runQuic(t, "host", "audit", getVMIP(t, QuicCheckoutVM), "--since", "1d", "--output", "json")
+		require.NoError(t, err, output)
+		var entries []map[string]any
+		require.NoError(t, json.Unmarshal([]byte(output), &entries), output)
+		require.NotEmpty(t, entries)
+		require.Contains(t, entries[0], "details")
+
+		output, err = runQuic(t, "host", "audit", getVMIP(t, QuicCheckoutVM), "--since", "yesterday")
+		require.Error(t, err, output)
+		require.Contains(t, output, "invalid --since")
+	})
+
+	t.Run("HostCancelUnknownOperation", func(t *testing.T) {
+		output, err := runQuic(t, "host", "cancel", "999999", "--host", getVMIP(t, QuicCheckoutVM))
+		require.Error(t, err, output)
+		require.Contains(t, output, "operation not found")
+	})
+
+	t.Run("WhoAmI", func(t *testing.T) {
+		output, err := runQuic(t, "whoami")
+		require.NoError(t, err, output)
+		require.Contains(t, output, templateName)
+		require.Contains(t, output, "Token:     set")
+		require.Contains(t, output, "User:      Test User")
+		require.Contains(t, output, "quicd:")
+	})
+
+	t.Run("CheckoutWithExtensions", func(t *testing.T) {
+		extBranchName := fmt.Sprintf("ext-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", extBranchName, "--template", templateName, "--extensions", "pg_stat_statements")
+		require.NoError(t, err, output)
+
+		preloadOutput := psqlBranch(t, templateName, extBranchName, "SHOW shared_preload_libraries")
+		require.Contains(t, preloadOutput, "pg_stat_statements", "should preload pg_stat_statements")
+
+		extOutput := psqlBranch(t, templateName, extBranchName, "SELECT extname FROM pg_extension")
+		require.Contains(t, extOutput, "pg_stat_statements", "should create pg_stat_statements extension")
+	})
+
+	t.Run("CheckoutWithDisallowedExtension", func(t *testing.T) {
+		output, err := runQuic(t, "checkout", "bad-ext-branch", "--template", templateName, "--extensions", "plpython3u")
+		require.Error(t, err, "should reject extensions outside the allowlist")
+		require.Contains(t, output, "extension 'plpython3u' is not allowed")
+	})
+
+	t.Run("ExportBranch", func(t *testing.T) {
+		outputPath := filepath.Join(t.TempDir(), branchName+".sql")
+		output, err := runQuic(t, "branch", "export", branchName, "--template", templateName, "--format", "plain", "-f", outputPath)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Exported branch")
+
+		dump, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+		require.Contains(t, string(dump), "CREATE TABLE public.users", "dump should contain the users table")
+
+		cmd := fmt.Sprintf("sudo tail -n 1 %s", agent.AuditFile)
+		auditEntry, err := agent.ParseAuditEntry(strings.TrimSpace(runInVM(t, QuicCheckoutVM, cmd)))
+		require.NoError(t, err)
+		require.Equal(t, "branch_export", auditEntry["event_type"])
+	})
+
+	t.Run("ExportRejectsConnectionStrings", func(t *testing.T) {
+		outputPath := filepath.Join(t.TempDir(), branchName+".dump")
+		for _, database := range []string{"host=/var/run/postgresql port=5432 dbname=postgres", "postgresql://localhost:5432/postgres"} {
+			output, err := runQuic(t, "branch", "export", branchName, "--template", templateName, "--database", database, "-f", outputPath)
+			require.Error(t, err)
+			require.Contains(t, output, "invalid database name")
+		}
+	})
+
+	t.Run("ImportBranchFromDump", func(t *testing.T) {
+		dumpPath := filepath.Join(t.TempDir(), branchName+".dump")
+		output, err := runQuic(t, "branch", "export", branchName, "--template", templateName, "-f", dumpPath)
+		require.NoError(t, err, output)
+
+		importedName := fmt.Sprintf("imported-%d", time.Now().UnixNano())
+		output, err = runQuic(t, "branch", "import", importedName, "--template", templateName, "--from", dumpPath)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "postgresql://admin")
+
+		count := psqlBranch(t, templateName, importedName, "SELECT count(*) FROM users")
+		require.NotEqual(t, "0", strings.TrimSpace(count), "imported branch should contain the dump's rows")
+
+		metadata := runInVM(t, QuicCheckoutVM, "sudo cat", agent.GetBranchMountpoint(templateName, importedName)+"/.quic-meta.json")
+		require.Contains(t, metadata, `"source": "import"`)
+
+		cmd := fmt.Sprintf("sudo tail -n 1 %s", agent.AuditFile)
+		auditEntry, err := agent.ParseAuditEntry(strings.TrimSpace(runInVM(t, QuicCheckoutVM, cmd)))
+		require.NoError(t, err)
+		require.Equal(t, "branch_import", auditEntry["event_type"])
+
+		output, err = runQuic(t, "delete", importedName, "--template", templateName)
+		require.NoError(t, err, output)
+		zfsOutput := runInVM(t, QuicCheckoutVM, "zfs list")
+		require.NotContains(t, zfsOutput, agent.GetBranchDataset(templateName, importedName))
+	})
+
+	t.Run("ImportBranchFailedRestoreCleansUp", func(t *testing.T) {
+		dumpPath := filepath.Join(t.TempDir(), "broken.sql")
+		require.NoError(t, os.WriteFile(dumpPath, []byte("CREATE TABLE broken (;\n"), 0644))
+
+		brokenName := fmt.Sprintf("broken-import-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "branch", "import", brokenName, "--template", templateName, "--from", dumpPath)
+		require.Error(t, err, output)
+		require.Contains(t, output, "restoring dump")
+
+		zfsOutput := runInVM(t, QuicCheckoutVM, "zfs list")
+		require.NotContains(t, zfsOutput, agent.GetBranchDataset(templateName, brokenName))
+		runInVM(t, QuicCheckoutVM, "! systemctl cat", agent.GetBranchServiceName(templateName, brokenName))
+
+		output, err = runQuic(t, "ls", "--template", templateName)
+		require.NoError(t, err, output)
+		require.NotContains(t, output, brokenName)
+	})
+
+	t.Run("CheckoutWithAppRoleMode", func(t *testing.T) {
+		appBranchName := fmt.Sprintf("app-branch-%d", time.Now().UnixNano())
+		output, err := runQuic(t, "checkout", appBranchName, "--template", templateName, "--role-mode", "app")
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Role mode: app")
+
+		superuserOutput := psqlBranch(t, templateName, appBranchName, "SELECT rolsuper FROM pg_roles WHERE rolname = 'admin'")
+		require.Contains(t, superuserOutput, "f", "admin should not be a superuser in app mode")
+
+		grantOutput := psqlBranch(t, templateName, appBranchName, "SELECT has_table_privilege('admin', 'users', 'SELECT')")
+		require.Contains(t, grantOutput, "t", "admin should be able to read tables in app mode")
+	})
 }
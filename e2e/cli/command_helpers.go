@@ -106,7 +106,8 @@ func setupQuicCheckout(t *testing.T, vmName string) (checkoutOutput string, temp
 	// Setup host
 	rmConfigFiles(t)
 	runQuic(t, "host", "new", vmIP, "--devices", VMDevices)
-	hostSetupOutput := runQuicHostSetupWithAck(t, []string{vmName})
+	// Lets checkout tests mount branches under /srv with --mountpoint
+	hostSetupOutput := runQuicHostSetupWithAck(t, []string{vmName}, "--mountpoint-root", "/srv")
 	t.Log(hostSetupOutput)
 
 	// Create user and login
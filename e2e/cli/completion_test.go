@@ -0,0 +1,24 @@
+package e2e_cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuicCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			output, err := runQuic(t, "completion", shell)
+			require.NoError(t, err, output)
+			require.Contains(t, output, "quic", "completion script should reference quic")
+			require.NotContains(t, output, "A newer version is available", "completion script should not include update notices")
+		})
+	}
+
+	t.Run("UnsupportedShell", func(t *testing.T) {
+		output, err := runQuic(t, "completion", "tcsh")
+		require.Error(t, err, output)
+		require.Contains(t, output, "unsupported shell")
+	})
+}
@@ -1,7 +1,9 @@
 package e2e_cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"testing"
 
@@ -16,6 +18,18 @@ func TestQuicDelete(t *testing.T) {
 	require.NoError(t, err, "checkout setup should succeed")
 	require.Contains(t, checkoutOutput, "postgresql://admin", "checkout should return connection string")
 
+	t.Run("DryRunKeepsBranch", func(t *testing.T) {
+		output, err := runQuic(t, "delete", branchName, "--dry-run")
+		require.NoError(t, err, output)
+		require.Contains(t, output, agent.GetBranchDataset(templateName, branchName))
+		require.Contains(t, output, agent.GetSnapshotName(templateName, branchName))
+		require.Contains(t, output, agent.GetBranchServiceName(templateName, branchName))
+		require.Contains(t, output, "Dry run: nothing was deleted.")
+
+		zfsOutput := runInVM(t, QuicDeleteVM, "zfs list")
+		require.Contains(t, zfsOutput, agent.GetBranchDataset(templateName, branchName))
+	})
+
 	// Execute the quic delete CLI command
 	deleteOutput, err := runQuic(t, "delete", branchName)
 	require.NoError(t, err, "quic delete should succeed\nOutput: %s", deleteOutput)
@@ -80,6 +94,140 @@ func TestQuicDelete(t *testing.T) {
 		require.Equal(t, "branch_delete", auditEntry["event_type"], "Event type should be checkout_delete")
 	})
 
+	t.Run("TemplateGCDestroysOrphanedSnapshots", func(t *testing.T) {
+		// A snapshot left behind by a failed branch deletion, and ones quic
+		// didn't create, including names that are valid branch names
+		orphan := agent.GetSnapshotName(templateName, "orphaned-branch")
+		runInVM(t, QuicDeleteVM, fmt.Sprintf("sudo zfs snapshot -o %s=%s %s", agent.SnapshotKindProperty, agent.SnapshotKindBranch, orphan))
+		var foreign []string
+		for _, name := range []string{"Manual.Backup", "pre-upgrade", "zfs-auto-snap_daily-2026-10-16-0000"} {
+			snapshot := agent.GetTemplateDataset(templateName) + "@" + name
+			runInVM(t, QuicDeleteVM, "sudo zfs snapshot "+snapshot)
+			foreign = append(foreign, snapshot)
+		}
+
+		output, err := runQuic(t, "template", "gc", templateName, "--dry-run")
+		require.NoError(t, err, output)
+		require.Contains(t, output, orphan)
+		for _, snapshot := range foreign {
+			require.NotContains(t, output, snapshot)
+		}
+		require.Contains(t, output, "Dry run: 1 snapshots would be destroyed")
+
+		output, err = runQuic(t, "template", "gc", templateName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Destroyed 1 snapshots")
+
+		snapshots := runInVM(t, QuicDeleteVM, "zfs list -t snapshot")
+		require.NotContains(t, snapshots, orphan)
+		for _, snapshot := range foreign {
+			require.Contains(t, snapshots, snapshot)
+			runInVM(t, QuicDeleteVM, "sudo zfs destroy "+snapshot)
+		}
+	})
+
+	t.Run("DeletePromotedBranches", func(t *testing.T) {
+		older, promoted := "promote-older", "promote-target"
+		for _, branch := range []string{older, promoted} {
+			output, err := runQuic(t, "checkout", branch, "--template", templateName)
+			require.NoError(t, err, output)
+		}
+
+		output, err := runQuic(t, "branch", "promote", promoted, "--template", templateName)
+		require.NoError(t, err, output)
+
+		// Promoting moves the older branch's snapshot onto the promoted branch
+		movedSnapshot := agent.GetBranchDataset(templateName, promoted) + "@" + older
+		require.Contains(t, runInVM(t, QuicDeleteVM, "zfs list -H -o name -t snapshot"), movedSnapshot)
+
+		output, err = runQuic(t, "delete", older, "--template", templateName)
+		require.NoError(t, err, output)
+		snapshots := runInVM(t, QuicDeleteVM, "zfs list -H -o name -t snapshot")
+		require.NotContains(t, snapshots, "@"+older)
+		require.NotContains(t, snapshots, movedSnapshot)
+
+		output, err = runQuic(t, "delete", promoted, "--template", templateName)
+		require.NoError(t, err, output)
+		require.NotContains(t, runInVM(t, QuicDeleteVM, "zfs list"), agent.GetBranchDataset(templateName, promoted))
+		require.NotContains(t, runInVM(t, QuicDeleteVM, "zfs list -H -o name -t snapshot"), agent.GetSnapshotName(templateName, promoted))
+
+		// The template owns its data again
+		origin := runInVM(t, QuicDeleteVM, "zfs get -H -o value origin "+agent.GetTemplateDataset(templateName))
+		require.Equal(t, "-", strings.TrimSpace(origin))
+	})
+
+	t.Run("DeleteSeveralInParallel", func(t *testing.T) {
+		branches := []string{"bulk-a", "bulk-b", "bulk-c"}
+		for _, branch := range branches {
+			output, err := runQuic(t, "checkout", branch, "--template", templateName)
+			require.NoError(t, err, output)
+		}
+
+		args := append([]string{"delete"}, branches...)
+		output, err := runQuic(t, append(args, "--template", templateName, "--parallel", "3")...)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Deleted 3 of 3 branches")
+
+		zfsOutput := runInVM(t, QuicDeleteVM, "zfs list")
+		for _, branch := range branches {
+			require.NotContains(t, zfsOutput, agent.GetBranchDataset(templateName, branch))
+		}
+
+		output, err = runQuic(t, "delete", "_restore", "bulk-d", "--template", templateName, "--parallel", "2")
+		require.Error(t, err)
+		require.Contains(t, output, "Deleted 0 of 2 branches")
+		require.Contains(t, output, "Not found: bulk-d")
+		require.Contains(t, output, "_restore:")
+
+		output, err = runQuic(t, "delete", "bulk-a", "--parallel", "0")
+		require.Error(t, err)
+		require.Contains(t, output, "--parallel must be at least 1")
+	})
+
+	t.Run("DeleteAmbiguousBranchNeedsTemplate", func(t *testing.T) {
+		cloneName := templateName + "-clone"
+
+		configContent, err := os.ReadFile("quic.json")
+		require.NoError(t, err)
+		var config map[string]interface{}
+		require.NoError(t, json.Unmarshal(configContent, &config))
+		config["templates"] = append(config["templates"].([]interface{}), map[string]interface{}{
+			"name":      cloneName,
+			"pgVersion": "16",
+			"database":  "quic_test",
+			"source":    templateName,
+		})
+		configContent, err = json.MarshalIndent(config, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile("quic.json", configContent, 0644))
+
+		output, err := runQuic(t, "template", "setup", "--delta")
+		require.NoError(t, err, output)
+
+		for _, template := range []string{templateName, cloneName} {
+			output, err := runQuic(t, "checkout", "shared", "--template", template)
+			require.NoError(t, err, output)
+		}
+
+		output, err = runQuic(t, "delete", "shared")
+		require.Error(t, err)
+		require.Contains(t, output, "branch 'shared' exists in several templates")
+		require.Contains(t, output, templateName+"/shared")
+		require.Contains(t, output, cloneName+"/shared")
+		require.Contains(t, output, "Use --template to pick one")
+
+		zfsOutput := runInVM(t, QuicDeleteVM, "zfs list")
+		require.Contains(t, zfsOutput, agent.GetBranchDataset(templateName, "shared"))
+		require.Contains(t, zfsOutput, agent.GetBranchDataset(cloneName, "shared"))
+
+		output, err = runQuic(t, "delete", "shared", "--template", cloneName)
+		require.NoError(t, err, output)
+
+		zfsOutput = runInVM(t, QuicDeleteVM, "zfs list")
+		require.Contains(t, zfsOutput, agent.GetBranchDataset(templateName, "shared"))
+		require.NotContains(t, zfsOutput, agent.GetBranchDataset(cloneName, "shared"))
+	})
+
 	t.Run("DeleteNonExistentBranch", func(t *testing.T) {
 		deleteOutput, err := runQuic(t, "delete", "non-existent-branch")
 		require.NoError(t, err, deleteOutput)
@@ -94,6 +242,12 @@ func TestQuicDeleteInvalidBranchNames(t *testing.T) {
 		require.Contains(t, output, "branch name '_restore' is reserved")
 	})
 
+	t.Run("UnderscorePrefix", func(t *testing.T) {
+		output, err := runQuic(t, "delete", "_restore2")
+		require.Error(t, err, "Should reject names starting with an underscore")
+		require.Contains(t, output, "names starting with '_' are for internal use")
+	})
+
 	t.Run("InvalidCharacters", func(t *testing.T) {
 		output, err := runQuic(t, "delete", "test@invalid")
 		require.Error(t, err, "Should reject names with invalid characters")
@@ -3,6 +3,8 @@ package e2e_cli
 import (
 	"encoding/json"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -35,13 +37,101 @@ func TestQuicHostNew(t *testing.T) {
 		require.Equal(t, "default", host["alias"], "Expected alias 'default', got %s", host["alias"])
 	})
 
+	t.Run("host addition with JSON output", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		// Stdout only, progress and notes go to stderr
+		stdout, err := exec.Command("../../bin/quic", "host", "new", vmIP, "--devices", VMDevices, "--alias", "json-host", "--output", "json").Output()
+		require.NoError(t, err)
+
+		var result struct {
+			Action string `json:"action"`
+			Host   struct {
+				IP      string   `json:"ip"`
+				Alias   string   `json:"alias"`
+				Devices []string `json:"devices"`
+			} `json:"host"`
+		}
+		require.NoError(t, json.Unmarshal(stdout, &result), "stdout should be JSON: %s", stdout)
+		require.Equal(t, "added", result.Action)
+		require.Equal(t, vmIP, result.Host.IP)
+		require.Equal(t, "json-host", result.Host.Alias)
+		require.NotEmpty(t, result.Host.Devices)
+
+		output, err := runQuic(t, "host", "new", vmIP, "--output", "json")
+		require.Error(t, err)
+		require.Contains(t, output, "--output json needs --devices")
+	})
+
 	t.Run("invalid IP address", func(t *testing.T) {
 		rmConfigFiles(t)
 
 		output, err := runQuic(t, "host", "new", "invalid-ip")
 
 		require.Error(t, err, "Expected command to fail with invalid IP")
-		require.Contains(t, output, "failed to connect", "Expected connection failure message in output")
+		require.Contains(t, output, "cannot resolve host 'invalid-ip'", "Expected resolution failure message in output")
+	})
+
+	t.Run("host address with a port is rejected", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		output, err := runQuic(t, "host", "new", "http://"+vmIP+":8443", "--devices", VMDevices)
+
+		require.Error(t, err, "Expected command to fail with a port")
+		require.Contains(t, output, "must not include a port", "Expected port error in output")
+		require.Contains(t, output, "pass just "+vmIP)
+	})
+
+	t.Run("host address URL scheme is stripped", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		output, err := runQuic(t, "host", "new", "https://"+vmIP+"/", "--devices", VMDevices)
+		require.NoError(t, err, "quic host new should succeed\nOutput: %s", output)
+
+		configContent, err := os.ReadFile("quic.json")
+		require.NoError(t, err, "Failed to read quic.json")
+
+		var config map[string]interface{}
+		require.NoError(t, json.Unmarshal(configContent, &config), "Failed to parse quic.json")
+
+		host := config["hosts"].([]interface{})[0].(map[string]interface{})
+		require.Equal(t, vmIP, host["ip"], "Expected scheme to be stripped from the IP")
+	})
+
+	t.Run("host new --update changes devices and alias", func(t *testing.T) {
+		rmConfigFiles(t)
+		output, err := runQuic(t, "host", "new", vmIP, "--devices", "/dev/loop101")
+		require.NoError(t, err, "quic host new should succeed\nOutput: %s", output)
+
+		// Stands in for the fingerprint recorded by host setup
+		configContent, err := os.ReadFile("quic.json")
+		require.NoError(t, err, "Failed to read quic.json")
+		var config map[string]interface{}
+		require.NoError(t, json.Unmarshal(configContent, &config), "Failed to parse quic.json")
+		config["hosts"].([]interface{})[0].(map[string]interface{})["certificateFingerprint"] = "abc123"
+		configContent, err = json.MarshalIndent(config, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile("quic.json", configContent, 0644))
+
+		output, err = runQuic(t, "host", "new", vmIP, "--devices", VMDevices)
+		require.Error(t, err, "Expected duplicate host to fail without --update")
+		require.Contains(t, output, "--update")
+
+		output, err = runQuic(t, "host", "new", vmIP, "--devices", VMDevices, "--alias", "primary", "--update")
+		require.NoError(t, err, "quic host new --update should succeed\nOutput: %s", output)
+		require.Contains(t, output, "Updated host 'primary'")
+
+		configContent, err = os.ReadFile("quic.json")
+		require.NoError(t, err, "Failed to read quic.json")
+		config = map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(configContent, &config), "Failed to parse quic.json")
+
+		hosts := config["hosts"].([]interface{})
+		require.Len(t, hosts, 1)
+		host := hosts[0].(map[string]interface{})
+		require.Equal(t, "primary", host["alias"])
+		require.Equal(t, []interface{}{"/dev/loop101", "/dev/loop102"}, host["devices"])
+		require.Equal(t, "abc123", host["certificateFingerprint"], "Expected fingerprint to be preserved")
 	})
 
 	t.Run("host new requires IP argument", func(t *testing.T) {
@@ -50,4 +140,57 @@ func TestQuicHostNew(t *testing.T) {
 		require.Error(t, err, "Expected command to fail without IP argument")
 		require.Contains(t, output, "accepts 1 arg(s), received 0", "Expected argument requirement message in output")
 	})
+
+	t.Run("host expand requires a configured host", func(t *testing.T) {
+		rmConfigFiles(t)
+		output, err := runQuic(t, "host", "new", vmIP, "--devices", VMDevices)
+		require.NoError(t, err, "quic host new should succeed\nOutput: %s", output)
+
+		output, err = runQuic(t, "host", "expand", "10.0.0.254", "--devices", "/dev/loop103")
+
+		require.Error(t, err, "Expected expand of an unknown host to fail")
+		require.Contains(t, output, "not found in quic.json")
+	})
+
+	t.Run("ping reports unknown hosts", func(t *testing.T) {
+		rmConfigFiles(t)
+		output, err := runQuic(t, "host", "new", vmIP, "--devices", VMDevices)
+		require.NoError(t, err, "quic host new should succeed\nOutput: %s", output)
+
+		output, _ = runQuic(t, "ping", "no-such-host")
+		require.Contains(t, output, "Host 'no-such-host' not found in quic.json.")
+	})
+
+	t.Run("--config and QUIC_CONFIG choose the quic.json", func(t *testing.T) {
+		rmConfigFiles(t)
+		configPath := filepath.Join(t.TempDir(), "quic.json")
+
+		output, err := runQuic(t, "--config", configPath, "host", "new", vmIP, "--devices", VMDevices)
+		require.NoError(t, err, "quic host new --config should succeed\nOutput: %s", output)
+		requireFile(t, configPath)
+		require.NoFileExists(t, "quic.json")
+
+		cmd := exec.Command("../../bin/quic", "ping", "no-such-host")
+		cmd.Env = append(os.Environ(), "QUIC_CONFIG="+configPath)
+		envOutput, _ := cmd.CombinedOutput()
+		require.Contains(t, string(envOutput), "Host 'no-such-host' not found in quic.json.", "Expected hosts from QUIC_CONFIG")
+	})
+
+	t.Run("quic.json is found from a subdirectory", func(t *testing.T) {
+		rmConfigFiles(t)
+		output, err := runQuic(t, "host", "new", vmIP, "--devices", VMDevices)
+		require.NoError(t, err, "quic host new should succeed\nOutput: %s", output)
+
+		subdir := filepath.Join("tmp-subdir", "nested")
+		require.NoError(t, os.MkdirAll(subdir, 0755))
+		defer os.RemoveAll("tmp-subdir")
+
+		bin, err := filepath.Abs("../../bin/quic")
+		require.NoError(t, err)
+		cmd := exec.Command(bin, "ping", "no-such-host")
+		cmd.Dir = subdir
+		subdirOutput, _ := cmd.CombinedOutput()
+		require.Contains(t, string(subdirOutput), "Host 'no-such-host' not found in quic.json.", "Expected hosts from the parent quic.json")
+		require.NoFileExists(t, filepath.Join(subdir, "quic.json"))
+	})
 }
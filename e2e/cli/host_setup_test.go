@@ -1,6 +1,10 @@
 package e2e_cli
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -18,6 +22,7 @@ func TestQuicHostSetup(t *testing.T) {
 		// run once
 		output = runQuicHostSetupWithAck(t, []string{QuicHostVM})
 		require.Contains(t, output, "Setup completed: 1 successful")
+		require.Contains(t, output, "default ("+quicHostIP+"): changed", "first run should report changes")
 
 		// can rerun just fine
 		output = runQuicHostSetupWithAck(t, []string{QuicHostVM})
@@ -54,6 +59,52 @@ func TestQuicHostSetup(t *testing.T) {
 		validateHostSetup(t, QuicHostVM)
 	})
 
+	t.Run("verify reports a set up host as ready", func(t *testing.T) {
+		output, err := runQuic(t, "host", "verify", quicHostIP)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "✓ quicd:")
+		require.Contains(t, output, "✓ ZFS pool: tank encrypted with aes-256-gcm and mounted")
+		require.Contains(t, output, "✓ users database:")
+		require.Contains(t, output, "Host "+quicHostIP+" is ready")
+	})
+
+	t.Run("expand passes device paths to the host as single arguments", func(t *testing.T) {
+		output, err := runQuic(t, "host", "expand", quicHostIP, "--devices", "/dev/loop103;touch /tmp/expand-injected")
+		require.Error(t, err, output)
+		require.Contains(t, output, "device path '/dev/loop103;touch /tmp/expand-injected' not found")
+		runInVM(t, QuicHostVM, "test ! -e /tmp/expand-injected")
+	})
+
+	t.Run("verify checks the pool set in quicd.json", func(t *testing.T) {
+		// quicd only reads its config on start and reload, so it keeps running on tank
+		runInVM(t, QuicHostVM, "sudo cp /etc/quic/quicd.json /etc/quic/quicd.json.orig")
+		runInVM(t, QuicHostVM, `echo '{"storage": {"pool": "otherpool"}}' | sudo tee /etc/quic/quicd.json`)
+
+		output, err := runQuic(t, "host", "verify", quicHostIP)
+		runInVM(t, QuicHostVM, "sudo mv /etc/quic/quicd.json.orig /etc/quic/quicd.json")
+		require.Error(t, err, output)
+		require.Contains(t, output, "✗ ZFS pool: pool 'otherpool' not found")
+	})
+
+	t.Run("fingerprint refetches a host's certificate fingerprint", func(t *testing.T) {
+		output, err := runQuic(t, "host", "fingerprint", quicHostIP)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Certificate fingerprint of "+quicHostIP+" is unchanged")
+
+		// A fresh quic.json has no fingerprint, like after a failed setup step
+		rmConfigFiles(t)
+		output, err = runQuic(t, "host", "new", quicHostIP, "--devices", VMDevices)
+		require.NoError(t, err, output)
+
+		output, err = runQuic(t, "host", "fingerprint", quicHostIP)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Stored certificate fingerprint of "+quicHostIP)
+
+		output, err = runQuic(t, "host", "verify", quicHostIP)
+		require.NoError(t, err, output)
+		require.Contains(t, output, "Host "+quicHostIP+" is ready")
+	})
+
 	t.Run("setup with invalid host", func(t *testing.T) {
 		rmConfigFiles(t)
 		output, err := runQuic(t, "host", "new", quicHostIP, "--devices", VMDevices)
@@ -67,6 +118,24 @@ func TestQuicHostSetup(t *testing.T) {
 		require.Contains(t, output, "Host 'nonexistent' not found", "Should show error for non-existent host")
 	})
 
+	t.Run("setup rejects invalid PostgreSQL versions and repos", func(t *testing.T) {
+		rmConfigFiles(t)
+		output, err := runQuic(t, "host", "new", quicHostIP, "--devices", VMDevices)
+		require.NoError(t, err, output)
+
+		output, err = runQuic(t, "host", "setup", "--pg-version", "latest")
+		require.Error(t, err)
+		require.Contains(t, output, "invalid --pg-version 'latest'")
+
+		output, err = runQuic(t, "host", "setup", "--pg-version", "11")
+		require.Error(t, err)
+		require.Contains(t, output, "too old")
+
+		output, err = runQuic(t, "host", "setup", "--pg-repo", "ppa")
+		require.Error(t, err)
+		require.Contains(t, output, "invalid --pg-repo 'ppa'")
+	})
+
 	t.Run("setup no hosts configured", func(t *testing.T) {
 		rmConfigFiles(t)
 
@@ -102,12 +171,34 @@ func TestQuicHostSetup(t *testing.T) {
 		// Setup all hosts
 		output = runQuicHostSetupWithAck(t, []string{QuicHostVM, QuicHost2VM}, "--hosts", "all")
 		require.Contains(t, output, "Setup completed:", "Setup should complete for all hosts")
+		require.Contains(t, output, "Logging to ", "Multi-host setup should write each host's output to a log file")
 
 		// Validate complete setup on both hosts using reusable function
 		validateHostSetup(t, QuicHostVM)
 		validateHostSetup(t, QuicHost2VM)
 	})
 
+	t.Run("sets up hosts by group with group devices", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		devices, err := json.Marshal(strings.Split(VMDevices, ","))
+		require.NoError(t, err)
+		quicJSON := fmt.Sprintf(`{
+  "groups": [{ "name": "staging", "devices": %s }],
+  "hosts": [{ "ip": %q, "alias": "staging-1", "encryptionAtRest": "localFile", "group": "staging" }],
+  "templates": []
+}`, devices, quicHostIP)
+		require.NoError(t, os.WriteFile("quic.json", []byte(quicJSON), 0644))
+
+		output, err := runQuic(t, "host", "setup", "--hosts", "@missing")
+		require.NoError(t, err)
+		require.Contains(t, output, "Group 'missing' not found")
+
+		output = runQuicHostSetupWithAck(t, []string{QuicHostVM}, "--hosts", "@staging")
+		require.Contains(t, output, "Setup completed:")
+		validateHostSetup(t, QuicHostVM)
+	})
+
 	t.Run("duplicate alias validation", func(t *testing.T) {
 		rmConfigFiles(t)
 
@@ -187,6 +278,10 @@ func validateHostSetup(t *testing.T, vmName string) {
 		output = runShell(t, "multipass", "exec", vmName, "--", "dpkg", "-l", "postgresql-16")
 		require.Contains(t, output, "ii", "postgresql-16 should be installed")
 
+		// quicd is pointed at the installed version
+		output = runShell(t, "multipass", "exec", vmName, "--", "cat", "/etc/quic/quicd.json")
+		require.Contains(t, output, `"version": "16"`)
+
 		// Verify pgbackrest is installed
 		output = runShell(t, "multipass", "exec", vmName, "--", "which", "pgbackrest")
 		require.Contains(t, output, "/usr/bin/pgbackrest", "pgbackrest should be installed")
@@ -51,6 +51,15 @@ func TestQuicList(t *testing.T) {
 		require.NotContains(t, listOutput, branchName, "should not contain our branch")
 	})
 
+	t.Run("ListStaleBranches", func(t *testing.T) {
+		listOutput, err := runQuic(t, "ls", "--template", templateName, "--stale", "1000d")
+		require.NoError(t, err, "quic ls --stale should succeed")
+		require.Contains(t, listOutput, "No checkouts found.", "recently used branch should not be stale")
+
+		_, err = runQuic(t, "ls", "--stale", "soon")
+		require.Error(t, err, "invalid --stale value should fail")
+	})
+
 	t.Run("CreateMultipleBranchesAndList", func(t *testing.T) {
 		// Create another branch in the same template
 		secondBranchName := fmt.Sprintf("second-branch-%d", time.Now().UnixNano())
@@ -107,4 +116,33 @@ func TestQuicList(t *testing.T) {
 		metadataPath := fmt.Sprintf("%s/.quic-meta.json", expectedMountpoint)
 		runInVM(t, QuicListVM, "sudo test -f", metadataPath)
 	})
+
+	t.Run("TemplateInfo", func(t *testing.T) {
+		infoOutput, err := runQuic(t, "template", "info", templateName)
+		require.NoError(t, err, infoOutput)
+
+		require.Contains(t, infoOutput, templateName)
+		require.Contains(t, infoOutput, "quic_test", "should show the template database")
+		require.Contains(t, infoOutput, "Ready:       yes")
+		require.Contains(t, infoOutput, "Branches:    2")
+		require.Contains(t, infoOutput, "Backup LSN:", "should show the restored backup's provenance")
+		require.Contains(t, infoOutput, "Encryption:")
+		require.Contains(t, infoOutput, "Compression: lz4", "should show the pool's compression")
+	})
+	t.Run("ListBranchesSortedAndLimited", func(t *testing.T) {
+		listOutput, err := runQuic(t, "ls", "--template", templateName, "--sort", "created", "--limit", "1")
+		require.NoError(t, err, listOutput)
+
+		// 3 lines: 1 branch + header + separator
+		lines := strings.Split(strings.TrimSpace(listOutput), "\n")
+		require.Equal(t, 3, len(lines), "should list exactly 1 branch")
+		require.Contains(t, lines[2], "second-branch-", "newest branch should be listed first")
+		require.Contains(t, lines[0], "SIZE")
+	})
+
+	t.Run("ListBranchesInvalidSort", func(t *testing.T) {
+		listOutput, err := runQuic(t, "ls", "--sort", "oldest")
+		require.Error(t, err)
+		require.Contains(t, listOutput, "invalid sort 'oldest'")
+	})
 }
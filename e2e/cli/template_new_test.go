@@ -1,6 +1,7 @@
 package e2e_cli
 
 import (
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -42,4 +43,62 @@ func TestQuicTemplateNew(t *testing.T) {
 		require.Error(t, err, "Duplicate template should fail")
 		require.Contains(t, output, "template with name duplicate-template already exists", "Expected duplicate name error message")
 	})
+
+	t.Run("env references are expanded and kept on save", func(t *testing.T) {
+		rmConfigFiles(t)
+		t.Setenv("QUIC_TEST_TEMPLATE", "env-template")
+
+		quicJSON := `{"hosts": [], "templates": [{"name": "${QUIC_TEST_TEMPLATE}", "pgVersion": "16", "database": "db1", "provider": {"name": "crunchybridge", "clusterName": "cluster1"}}]}`
+		require.NoError(t, os.WriteFile("quic.json", []byte(quicJSON), 0644))
+
+		output, err := runQuic(t, "template", "new", "env-template", "--cluster-name", "cluster2", "--database", "db2")
+		require.Error(t, err, "name from the environment should count as a duplicate")
+		require.Contains(t, output, "template with name env-template already exists")
+
+		output, err = runQuic(t, "template", "new", "other-template", "--cluster-name", "cluster2", "--database", "db2")
+		require.NoError(t, err, output)
+		requireQuicConfigValue(t, "templates[0].name", "${QUIC_TEST_TEMPLATE}")
+		requireQuicConfigValue(t, "templates[1].name", "other-template")
+	})
+
+	t.Run("unset env reference fails clearly", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		quicJSON := `{"hosts": [], "templates": [{"name": "t", "pgVersion": "16", "database": "db", "provider": {"name": "crunchybridge", "clusterName": "${QUIC_TEST_UNSET}"}}]}`
+		require.NoError(t, os.WriteFile("quic.json", []byte(quicJSON), 0644))
+
+		output, err := runQuic(t, "template", "new", "another", "--cluster-name", "c", "--database", "d")
+		require.Error(t, err)
+		require.Contains(t, output, "templates[0].provider.clusterName: environment variable QUIC_TEST_UNSET is not set")
+	})
+
+	t.Run("misspelled field fails with its line", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		quicJSON := "{\n  \"hosts\": [],\n  \"templates\": [{\"name\": \"t\", \"pgversion\": \"16\"}]\n}"
+		require.NoError(t, os.WriteFile("quic.json", []byte(quicJSON), 0644))
+
+		output, err := runQuic(t, "template", "new", "another", "--cluster-name", "c", "--database", "d")
+		require.Error(t, err)
+		require.Contains(t, output, `line 3: unknown field templates[0].pgversion (did you mean "pgVersion"?)`)
+	})
+
+	t.Run("number fields are checked like strings", func(t *testing.T) {
+		rmConfigFiles(t)
+
+		// Fields after a number are still checked
+		quicJSON := "{\n  \"hosts\": [],\n  \"templates\": [{\"name\": \"t\", \"provider\": {\"repo\": 2,\n    \"clustername\": \"c\"}}]\n}"
+		require.NoError(t, os.WriteFile("quic.json", []byte(quicJSON), 0644))
+
+		output, err := runQuic(t, "template", "new", "another", "--cluster-name", "c", "--database", "d")
+		require.Error(t, err)
+		require.Contains(t, output, `line 4: unknown field templates[0].provider.clustername (did you mean "clusterName"?)`)
+
+		quicJSON = `{"hosts": [], "templates": [{"name": "t", "provider": {"repo": {"index": 2}}}]}`
+		require.NoError(t, os.WriteFile("quic.json", []byte(quicJSON), 0644))
+
+		output, err = runQuic(t, "template", "new", "another", "--cluster-name", "c", "--database", "d")
+		require.Error(t, err)
+		require.Contains(t, output, "templates[0].provider.repo must be a number, got an object")
+	})
 }
@@ -53,6 +53,30 @@ func TestQuicTemplateSetup(t *testing.T) {
 	require.Contains(t, templateSetupOutput, "Found cluster:")
 	require.Contains(t, templateSetupOutput, "Created backup token")
 	require.Contains(t, templateSetupOutput, "Successfully setup 1 template(s)")
+	require.Contains(t, templateSetupOutput, "pgBackRest process(es)")
+	require.Contains(t, templateSetupOutput, "Detected PostgreSQL 16")
+	require.Contains(t, templateSetupOutput, "Timing: dataset")
+	require.Regexp(t, `Backup: \S+ \(stanza \S+, LSN [0-9A-F/]+ - [0-9A-F/]+\)`, templateSetupOutput)
+
+	output, err := runQuic(t, "template", "setup", "--log-level", "loud")
+	require.Error(t, err, "template setup should reject unknown log levels")
+	require.Contains(t, output, "invalid --log-level 'loud'")
+
+	t.Run("TargetTimeValidation", func(t *testing.T) {
+		output, err := runQuic(t, "template", "setup", "--target-time", "yesterday")
+		require.Error(t, err, output)
+		require.Contains(t, output, "invalid --target-time 'yesterday'")
+
+		future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		output, err = runQuic(t, "template", "setup", "--target-time", future)
+		require.Error(t, err, output)
+		require.Contains(t, output, "is in the future")
+
+		// No backup of the e2e cluster is that old, so there's nothing to replay from
+		output, err = runQuic(t, "template", "setup", "--delta", "--target-time", "2000-01-01T00:00:00Z")
+		require.Error(t, err, output)
+		require.Contains(t, output, "is before the oldest backup")
+	})
 
 	// Verify ZFS dataset was created on the VM (tank/test-template)
 	datasetName := fmt.Sprintf("tank/%s", templateName)
@@ -71,6 +95,8 @@ func TestQuicTemplateSetup(t *testing.T) {
 	require.Contains(t, metadataOutput, templateName)
 	require.Contains(t, metadataOutput, "port")
 	require.Contains(t, metadataOutput, "service_name")
+	require.Contains(t, metadataOutput, `"pg_version": "16"`)
+	require.Contains(t, metadataOutput, `"step": "pgbackrest_restore"`)
 
 	// Verify PostgreSQL data directory was restored
 	runShell(t, "multipass", "exec", QuicTemplateVM, "--", "sudo", "test", "-d", restoreMount)
@@ -107,4 +133,103 @@ func TestQuicTemplateSetup(t *testing.T) {
 		require.NotContains(t, autoConfOutput, "# Clone instance - recovery disabled",
 			"postgresql.auto.conf should not contain clone-specific configuration")
 	}
+
+	t.Run("VerifyChecksums", func(t *testing.T) {
+		output, err := runQuic(t, "template", "setup", "--delta", "--verify-checksums")
+		require.NoError(t, err, output)
+		// Whether the e2e cluster has data checksums depends on the provider
+		if !strings.Contains(output, "Data checksums aren't enabled") {
+			require.Contains(t, output, "✓ Data checksums verified")
+			require.Contains(t, output, "Bad checksums:  0")
+		}
+		runShell(t, "multipass", "exec", QuicTemplateVM, "--", "sudo", "-u", "postgres", "pg_isready", "-p", fmt.Sprintf("%.0f", port))
+	})
+
+	t.Run("template with a source clones its restore", func(t *testing.T) {
+		cloneName := templateName + "-clone"
+
+		configContent, err := os.ReadFile("quic.json")
+		require.NoError(t, err)
+		var config map[string]interface{}
+		require.NoError(t, json.Unmarshal(configContent, &config))
+		config["templates"] = append(config["templates"].([]interface{}), map[string]interface{}{
+			"name":      cloneName,
+			"pgVersion": "16",
+			"database":  "quic_test",
+			"source":    templateName,
+		})
+		configContent, err = json.MarshalIndent(config, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile("quic.json", configContent, 0644))
+
+		// --delta refreshes the existing source and sets up the new clone
+		output, err := runQuic(t, "template", "setup", "--delta")
+		require.NoError(t, err, "quic template setup --delta should succeed\nOutput: %s", output)
+		require.Contains(t, output, fmt.Sprintf("Cloning template from '%s'", templateName))
+		require.Contains(t, output, "Successfully setup 2 template(s)")
+
+		origin := runInVM(t, QuicTemplateVM, "sudo", "zfs", "get", "-H", "-o", "value", "origin", "tank/"+cloneName)
+		require.Equal(t, fmt.Sprintf("tank/%s@template.%s", templateName, cloneName), strings.TrimSpace(origin))
+
+		cloneMetadata := runInVM(t, QuicTemplateVM, "sudo", "cat", fmt.Sprintf("/opt/quic/%s/_restore/.quic-init-meta.json", cloneName))
+		var clone map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(cloneMetadata), &clone))
+		require.Equal(t, templateName, clone["source"])
+		require.NotEqual(t, metadata["port"], clone["port"], "clone should get its own port")
+
+		queryOutput := runInVM(t, QuicTemplateVM, "sudo", "-u", "postgres", "psql", "-p", clone["port"].(string), "-d", "quic_test", "-c", "'SELECT COUNT(*) FROM users;'")
+		require.Contains(t, queryOutput, "5", "clone should have the source's data")
+
+		// Re-running keeps the existing clone
+		output, err = runQuic(t, "template", "setup", "--delta")
+		require.NoError(t, err, output)
+		require.Contains(t, output, fmt.Sprintf("Template already cloned from '%s'", templateName))
+
+		// Branch "clone" of the source would share the clone's service name
+		output, err = runQuic(t, "checkout", "clone", "--template", templateName)
+		require.Error(t, err, output)
+		require.Contains(t, output, fmt.Sprintf("service name quic-%s is already used by template '%s'", cloneName, cloneName))
+
+		// So would branch "clone-x" of the source and branch "x" of the clone
+		output, err = runQuic(t, "checkout", "x", "--template", cloneName)
+		require.NoError(t, err, output)
+		output, err = runQuic(t, "checkout", "clone-x", "--template", templateName)
+		require.Error(t, err, output)
+		require.Contains(t, output, fmt.Sprintf("is already used by branch 'x' of template '%s'", cloneName))
+
+		// A branch created under the wrong template moves with its data and checkpoints
+		output, err = runQuic(t, "checkout", "moved", "--template", templateName)
+		require.NoError(t, err, output)
+		output, err = runQuic(t, "branch", "snapshot", "moved", "--template", templateName, "--label", "before-move")
+		require.NoError(t, err, output)
+
+		output, err = runQuic(t, "branch", "move", "moved", "--template", templateName, "--to-template", cloneName)
+		require.NoError(t, err, output)
+		require.Contains(t, output, fmt.Sprintf("Moved branch 'moved' from '%s' to '%s'", templateName, cloneName))
+		require.Contains(t, output, "postgresql://admin:")
+
+		runInVM(t, QuicTemplateVM, fmt.Sprintf("! sudo zfs list tank/%s/moved", templateName))
+		origin = runInVM(t, QuicTemplateVM, "sudo", "zfs", "get", "-H", "-o", "value", "origin", "tank/"+cloneName+"/moved")
+		require.Equal(t, "-", strings.TrimSpace(origin), "moved branch should be a standalone copy")
+		runInVM(t, QuicTemplateVM, "sudo", "zfs", "list", "-t", "snapshot", "tank/"+cloneName+"/moved@checkpoint-before-move")
+
+		movedMetadata := runInVM(t, QuicTemplateVM, "sudo", "cat", fmt.Sprintf("/opt/quic/%s/moved/.quic-meta.json", cloneName))
+		var moved map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(movedMetadata), &moved))
+		require.Equal(t, cloneName, moved["template_name"])
+		require.Equal(t, "move", moved["source"])
+
+		queryOutput = runInVM(t, QuicTemplateVM, "sudo", "-u", "postgres", "psql", "-p", moved["port"].(string), "-d", "quic_test", "-c", "'SELECT COUNT(*) FROM users;'")
+		require.Contains(t, queryOutput, "5", "moved branch should keep its data")
+
+		output, err = runQuic(t, "branch", "move", "moved", "--template", cloneName, "--to-template", cloneName)
+		require.Error(t, err)
+		require.Contains(t, output, fmt.Sprintf("branch 'moved' is already in template '%s'", cloneName))
+
+		output, err = runQuic(t, "branch", "freeze", "moved", "--template", cloneName)
+		require.NoError(t, err, output)
+		output, err = runQuic(t, "branch", "move", "moved", "--template", cloneName, "--to-template", templateName)
+		require.Error(t, err, "Expected a frozen branch to stay put without --force")
+		require.Contains(t, output, "branch 'moved' is frozen")
+	})
 }
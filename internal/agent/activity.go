@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"log"
+	"time"
+)
+
+// Branch activity is written at most this often, so polling `branch info --watch`
+// doesn't rewrite the metadata file every refresh
+const touchInterval = time.Minute
+
+// LastActivity is when a quic operation last touched the branch, falling back
+// to its creation for branches created before activity was tracked.
+func (b *BranchInfo) LastActivity() time.Time {
+	if b.LastAccessedAt.IsZero() {
+		return b.CreatedAt
+	}
+	return b.LastAccessedAt
+}
+
+// touchBranch records activity on a branch. It's best effort: when another
+// operation holds the checkout lock, that operation owns the metadata file and
+// the touch is skipped.
+func (s *AgentService) touchBranch(branch *BranchInfo) {
+	now := time.Now().UTC().Truncate(time.Second)
+	if now.Sub(branch.LastAccessedAt) < touchInterval {
+		return
+	}
+
+	if !s.checkoutMutex.TryLock() {
+		return
+	}
+	defer s.checkoutMutex.Unlock()
+
+	s.touchBranchLocked(branch)
+}
+
+// touchBranchLocked is touchBranch for callers already holding checkoutMutex.
+func (s *AgentService) touchBranchLocked(branch *BranchInfo) {
+	now := time.Now().UTC().Truncate(time.Second)
+	if now.Sub(branch.LastAccessedAt) < touchInterval {
+		return
+	}
+
+	// Reload so fields changed since the caller read the metadata aren't overwritten
+	current, err := loadBranchMetadata(branch.BranchPath)
+	if err != nil || current == nil {
+		return
+	}
+
+	current.LastAccessedAt = now
+	if err := saveCheckoutMetadata(current); err != nil {
+		log.Printf("Warning: failed to record activity on branch %s: %v", branch.BranchName, err)
+		return
+	}
+	branch.LastAccessedAt = now
+}
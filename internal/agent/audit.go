@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -12,7 +13,37 @@ const (
 	AuditFile = "/var/log/quic/audit.log"
 )
 
+// AuditLog appends JSON lines to an audit file. Writes are serialized so
+// entries from concurrent branch operations never interleave, and anything
+// that swaps the file out (such as rotation) can hold the same lock.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+var defaultAuditLog = NewAuditLog(AuditFile)
+
 func auditEvent(eventType string, details interface{}) error {
+	return defaultAuditLog.Write(eventType, details)
+}
+
+// auditedBranch copies branch with its admin password masked.
+func auditedBranch(branch *BranchInfo) *BranchInfo {
+	if branch == nil {
+		return nil
+	}
+	audited := *branch
+	audited.AdminPassword = maskPassword(branch.AdminPassword)
+	return &audited
+}
+
+// Write records one event. Failing to write is logged rather than returned
+// so auditing never fails the operation being audited.
+func (l *AuditLog) Write(eventType string, details interface{}) error {
 	logEntry := map[string]interface{}{
 		"timestamp":  time.Now().UTC().Format(time.RFC3339),
 		"event_type": eventType,
@@ -24,14 +55,18 @@ func auditEvent(eventType string, details interface{}) error {
 		return fmt.Errorf("marshaling audit log entry: %w", err)
 	}
 
-	file, err := os.OpenFile(AuditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		log.Printf("Warning: failed to open audit log file: %v", err)
 		return nil
 	}
 	defer file.Close()
 
-	if _, err := file.WriteString(string(logJSON) + "\n"); err != nil {
+	// A single write per entry keeps each line whole
+	if _, err := file.Write(append(logJSON, '\n')); err != nil {
 		log.Printf("Warning: failed to write audit log: %v", err)
 	}
 
@@ -63,3 +98,24 @@ func getInt(m map[string]interface{}, key string) int {
 	}
 	return 0
 }
+
+func getBool(m map[string]interface{}, key string) bool {
+	if v, ok := m[key]; ok {
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	}
+	return false
+}
+
+func getStringSlice(m map[string]interface{}, key string) []string {
+	var result []string
+	if v, ok := m[key].([]interface{}); ok {
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
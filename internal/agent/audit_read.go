@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Size of the blocks the audit log is read backward in
+const auditReadChunk = 64 * 1024
+
+// ReadSince returns the entries written at or after since, oldest first.
+// Entries are appended in time order, so files are read backward from their
+// end and reading stops at the first older entry: a query over the last hour
+// costs the same however large the log grows. When the range reaches past the
+// current file, rotated backups (path.1, path.2.gz, ...) are read newest first.
+func (l *AuditLog) ReadSince(since time.Time) ([]map[string]interface{}, error) {
+	files, err := l.openNewestFirst()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	var entries []map[string]interface{}
+	for _, file := range files {
+		reachedOlder, err := readEntriesBackward(file, since, &entries)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", file.Name(), err)
+		}
+		if reachedOlder {
+			break
+		}
+	}
+
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// AuditEntriesSince returns the entries of the host's audit log written at or
+// after since, oldest first.
+func (s *AgentService) AuditEntriesSince(since time.Time) ([]map[string]interface{}, error) {
+	return defaultAuditLog.ReadSince(since)
+}
+
+// openNewestFirst opens the audit file and its rotated backups. They're all
+// opened under the write lock, so a rotation can't shift names between them.
+func (l *AuditLog) openNewestFirst() ([]*os.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var files []*os.File
+	if file, err := os.Open(l.path); err == nil {
+		files = append(files, file)
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+
+	for i := 1; ; i++ {
+		file, err := os.Open(fmt.Sprintf("%s.%d", l.path, i))
+		if os.IsNotExist(err) {
+			file, err = os.Open(fmt.Sprintf("%s.%d.gz", l.path, i))
+		}
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, fmt.Errorf("opening audit log backup: %w", err)
+		}
+		files = append(files, file)
+	}
+}
+
+// readEntriesBackward appends the entries of file written at or after since,
+// newest first. It reports whether it reached an older entry. Lines that
+// aren't whole entries, such as one being written, are skipped.
+func readEntriesBackward(file *os.File, since time.Time, entries *[]map[string]interface{}) (bool, error) {
+	reachedOlder := false
+	visit := func(line []byte) bool {
+		entry, err := ParseAuditEntry(string(line))
+		if err != nil {
+			return true
+		}
+		timestamp, err := time.Parse(time.RFC3339, getString(entry, "timestamp"))
+		if err != nil {
+			return true
+		}
+		if timestamp.Before(since) {
+			reachedOlder = true
+			return false
+		}
+		*entries = append(*entries, entry)
+		return true
+	}
+
+	// Compressed backups can't be read backward, so they're read whole
+	if strings.HasSuffix(file.Name(), ".gz") {
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			return false, err
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return false, err
+		}
+		lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+		for i := len(lines) - 1; i >= 0; i-- {
+			if !visit(lines[i]) {
+				break
+			}
+		}
+		return reachedOlder, nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	return reachedOlder, readLinesBackward(file, info.Size(), visit)
+}
+
+// readLinesBackward calls visit with each line of the first size bytes of r,
+// last line first, until visit returns false.
+func readLinesBackward(r io.ReaderAt, size int64, visit func(line []byte) bool) error {
+	// Bytes of a line whose start hasn't been read yet
+	var partial []byte
+	for offset := size; offset > 0; {
+		n := min(int64(auditReadChunk), offset)
+		offset -= n
+
+		chunk := make([]byte, n, int(n)+len(partial))
+		if _, err := r.ReadAt(chunk, offset); err != nil && err != io.EOF {
+			return err
+		}
+		chunk = append(chunk, partial...)
+
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			if line := chunk[i+1:]; len(line) > 0 && !visit(line) {
+				return nil
+			}
+			chunk = chunk[:i]
+		}
+		partial = chunk
+	}
+
+	if len(partial) > 0 {
+		visit(partial)
+	}
+	return nil
+}
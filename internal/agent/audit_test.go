@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogConcurrentWrites(t *testing.T) {
+	const writers = 50
+	const entriesPerWriter = 20
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog := NewAuditLog(path)
+
+	// require stops the test goroutine only, so errors are checked after the writers finish
+	errs := make(chan error, writers*entriesPerWriter)
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range entriesPerWriter {
+				errs <- auditLog.Write("concurrency_test", map[string]interface{}{
+					"writer": w,
+					"entry":  i,
+					"branch": fmt.Sprintf("branch-%d-%d", w, i),
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry, err := ParseAuditEntry(scanner.Text())
+		require.NoError(t, err, "line %d should be a whole JSON entry: %s", lines+1, scanner.Text())
+		require.Equal(t, "concurrency_test", entry["event_type"])
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, writers*entriesPerWriter, lines)
+}
+
+func TestAuditLogReadSince(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// One entry a minute, the first 100 in a compressed backup and enough in
+	// the current file to span several read chunks
+	writeEntries := func(w io.Writer, from, to int) {
+		for i := from; i < to; i++ {
+			fmt.Fprintf(w, `{"timestamp":%q,"event_type":"read_test","details":{"n":%d,"padding":%q}}`+"\n",
+				start.Add(time.Duration(i)*time.Minute).Format(time.RFC3339), i, strings.Repeat("x", 200))
+		}
+	}
+
+	backup, err := os.Create(path + ".1.gz")
+	require.NoError(t, err)
+	gz := gzip.NewWriter(backup)
+	writeEntries(gz, 0, 100)
+	require.NoError(t, gz.Close())
+	require.NoError(t, backup.Close())
+
+	current, err := os.Create(path)
+	require.NoError(t, err)
+	writeEntries(current, 100, 1100)
+	require.NoError(t, current.Close())
+
+	auditLog := NewAuditLog(path)
+
+	entries, err := auditLog.ReadSince(start.Add(1000 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, entries, 100)
+	require.Equal(t, float64(1000), entries[0]["details"].(map[string]interface{})["n"])
+	require.Equal(t, float64(1099), entries[99]["details"].(map[string]interface{})["n"])
+
+	// Reaching back into the rotated backup
+	entries, err = auditLog.ReadSince(start.Add(50 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, entries, 1050)
+	require.Equal(t, float64(50), entries[0]["details"].(map[string]interface{})["n"])
+
+	entries, err = auditLog.ReadSince(start.Add(2000 * time.Minute))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
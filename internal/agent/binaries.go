@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+)
+
+type requiredBinary struct {
+	path      string // Name looked up in PATH, or an absolute path
+	neededFor string
+	// Core binaries are needed to serve branches at all; quicd refuses to start without them
+	core bool
+}
+
+func requiredBinaries() []requiredBinary {
+	var binaries []requiredBinary
+	if privilegeMode == PrivilegeSudo {
+		binaries = append(binaries, requiredBinary{path: "sudo", neededFor: "all operations", core: true})
+	}
+	binaries = append(binaries, []requiredBinary{
+		{path: "zfs", neededFor: "all operations", core: true},
+		{path: "systemctl", neededFor: "managing PostgreSQL services", core: true},
+		{path: pgCtlPath(PgVersion), neededFor: "running PostgreSQL", core: true},
+		{path: psqlPath(PgVersion), neededFor: "configuring branches", core: true},
+		{path: pgIsReadyPath(PgVersion), neededFor: "checking PostgreSQL readiness", core: true},
+		{path: pgResetWalPath(PgVersion), neededFor: "creating branches", core: true},
+		{path: "pgbackrest", neededFor: "template restores"},
+		{path: pgDumpPath(PgVersion), neededFor: "branch exports"},
+		{path: pgRestorePath(PgVersion), neededFor: "branch imports"},
+		{path: initdbPath(PgVersion), neededFor: "branch imports"},
+	}...)
+	for _, path := range firewallBinaries() {
+		binaries = append(binaries, requiredBinary{path: path, neededFor: "opening branch ports"})
+	}
+	return binaries
+}
+
+// CheckBinaries reports missing binaries, split into those quicd can't run
+// without and those that only disable some operations.
+func CheckBinaries() (missingCore []string, missingOptional []string) {
+	for _, binary := range requiredBinaries() {
+		if _, err := exec.LookPath(binary.path); err == nil {
+			continue
+		}
+
+		entry := fmt.Sprintf("%s (%s)", binary.path, binary.neededFor)
+		if binary.core {
+			missingCore = append(missingCore, entry)
+		} else {
+			missingOptional = append(missingOptional, entry)
+		}
+	}
+
+	return missingCore, missingOptional
+}
+
+// requireBinaries returns a friendly error naming the first missing binary.
+func requireBinaries(paths ...string) error {
+	for _, path := range paths {
+		if _, err := exec.LookPath(path); err != nil {
+			return fmt.Errorf("%s is not installed on this host; run `quic host setup`", filepath.Base(path))
+		}
+	}
+	return nil
+}
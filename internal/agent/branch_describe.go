@@ -0,0 +1,142 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultDescribeLimit = 10
+	MaxDescribeLimit     = 1000
+)
+
+type BranchDescription struct {
+	// Database the tables are listed from
+	Database string
+	// Size of each database of the branch
+	Databases []DatabaseSize
+	// Largest tables of Database, by total size
+	Tables []TableSize
+	// Tables in Database, including those beyond the limit
+	TableCount int
+}
+
+type DatabaseSize struct {
+	Name  string
+	Bytes int64
+}
+
+type TableSize struct {
+	// Schema-qualified, quoted where needed
+	Name string
+	// From the planner statistics, -1 when the table was never analyzed
+	EstimatedRows int64
+	// Including indexes and TOAST
+	TotalBytes int64
+	IndexBytes int64
+}
+
+// DescribeBranch summarizes what a running branch holds: the size of each
+// database and the largest tables of database, or of the template database
+// when empty. It only reads catalogs and statistics, not table data.
+func (s *AgentService) DescribeBranch(ctx context.Context, template string, branchName string, database string, limit int) (*BranchDescription, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+	if limit == 0 {
+		limit = DefaultDescribeLimit
+	}
+	if limit < 1 || limit > MaxDescribeLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d, got %d", MaxDescribeLimit, limit)
+	}
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	s.touchBranch(branch)
+
+	if !IsPostgreSQLServerReady(branch.BranchPath) {
+		return nil, fmt.Errorf("branch '%s' is not accepting connections", branchName)
+	}
+
+	if database == "" {
+		database, err = getTemplateDatabase(GetTemplateMountpoint(template))
+		if err != nil {
+			return nil, fmt.Errorf("getting template database: %w", err)
+		}
+	}
+
+	sizes, err := databaseSizes(branch.Port)
+	if err != nil {
+		return nil, fmt.Errorf("reading database sizes: %w", err)
+	}
+	if _, ok := sizes[database]; !ok {
+		return nil, fmt.Errorf("database '%s' not found in branch '%s'", database, branchName)
+	}
+
+	description := &BranchDescription{Database: database}
+	for _, name := range slices.Sorted(maps.Keys(sizes)) {
+		description.Databases = append(description.Databases, DatabaseSize{Name: name, Bytes: sizes[name]})
+	}
+
+	output, err := ExecPostgresCommand(branch.Port, database, "SELECT count(*) FROM pg_stat_user_tables")
+	if err != nil {
+		return nil, fmt.Errorf("counting tables: %w", err)
+	}
+	description.TableCount, _ = strconv.Atoi(output)
+
+	description.Tables, err = largestTables(branch.Port, database, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return description, nil
+}
+
+// largestTables lists the limit largest user tables of database. Row counts
+// come from pg_class, as the activity statistics of a clone start empty.
+func largestTables(port, database string, limit int) ([]TableSize, error) {
+	output, err := ExecPostgresCommand(port, database, fmt.Sprintf(`
+		SELECT quote_ident(s.schemaname) || '.' || quote_ident(s.relname)
+			|| '|' || c.reltuples::bigint
+			|| '|' || pg_total_relation_size(s.relid)
+			|| '|' || pg_indexes_size(s.relid)
+		FROM pg_stat_user_tables s JOIN pg_class c ON c.oid = s.relid
+		ORDER BY pg_total_relation_size(s.relid) DESC, 1
+		LIMIT %d`, limit))
+	if err != nil {
+		return nil, fmt.Errorf("reading table sizes: %w", err)
+	}
+
+	var tables []TableSize
+	for line := range strings.SplitSeq(output, "\n") {
+		// Quoted names may contain the separator, the numbers can't
+		fields := strings.Split(line, "|")
+		if len(fields) < 4 {
+			continue
+		}
+		numbers := make([]int64, 3)
+		for i, field := range fields[len(fields)-3:] {
+			if numbers[i], err = strconv.ParseInt(field, 10, 64); err != nil {
+				return nil, fmt.Errorf("parsing table sizes %q: %w", line, err)
+			}
+		}
+		tables = append(tables, TableSize{
+			Name:          strings.Join(fields[:len(fields)-3], "|"),
+			EstimatedRows: numbers[0],
+			TotalBytes:    numbers[1],
+			IndexBytes:    numbers[2],
+		})
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type BranchDiff struct {
+	// Snapshot the branch was cloned from
+	BaseSnapshot string
+	// Bytes the branch has written since it was cloned
+	WrittenBytes int64
+	// Databases of the branch, empty when the branch or template isn't running
+	Databases []DatabaseSizeDiff
+}
+
+type DatabaseSizeDiff struct {
+	Name string
+	// Size in the template as it is now, 0 when the branch created the database
+	TemplateBytes int64
+	BranchBytes   int64
+}
+
+// GetBranchDiff reports how much a branch has changed since it was cloned,
+// using ZFS accounting for the bytes written and pg_database_size for a
+// per-database comparison with the template.
+func (s *AgentService) GetBranchDiff(ctx context.Context, template string, branchName string) (*BranchDiff, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	branchDataset := GetBranchDataset(template, branchName)
+	branch, err := s.getBranchMetadata(branchDataset)
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	s.touchBranch(branch)
+
+	if err := checkBranchOrigin(branch); err != nil {
+		return nil, err
+	}
+
+	base, err := getOrigin(branchDataset)
+	if err != nil {
+		return nil, err
+	}
+	if base == "" {
+		// Promoting moved the origin snapshot into the branch dataset
+		base = branchDataset + "@" + branchName
+	}
+
+	written, err := getWrittenSince(branchDataset, base)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &BranchDiff{BaseSnapshot: base, WrittenBytes: written}
+
+	templatePath := GetTemplateMountpoint(template)
+	if !IsPostgreSQLServerReady(branch.BranchPath) || !IsPostgreSQLServerReady(templatePath) {
+		return diff, nil
+	}
+
+	templatePid, _ := getPostmasterPid(templatePath)
+	templateSizes, err := databaseSizes(templatePid.Port)
+	if err != nil {
+		return nil, fmt.Errorf("reading template database sizes: %w", err)
+	}
+	branchSizes, err := databaseSizes(branch.Port)
+	if err != nil {
+		return nil, fmt.Errorf("reading branch database sizes: %w", err)
+	}
+
+	for name, size := range branchSizes {
+		diff.Databases = append(diff.Databases, DatabaseSizeDiff{
+			Name:          name,
+			TemplateBytes: templateSizes[name],
+			BranchBytes:   size,
+		})
+	}
+	slices.SortFunc(diff.Databases, func(a, b DatabaseSizeDiff) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return diff, nil
+}
+
+// getWrittenSince returns the bytes dataset has written since snapshot, which
+// may belong to the dataset's origin.
+func getWrittenSince(dataset, snapshot string) (int64, error) {
+	output, err := privileged("zfs", "get", "-Hp", "-o", "value", "written@"+snapshot, dataset).Output()
+	if err != nil {
+		return 0, fmt.Errorf("getting ZFS bytes written to %s since %s: %w", dataset, snapshot, err)
+	}
+
+	written, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing ZFS written value %q: %w", strings.TrimSpace(string(output)), err)
+	}
+
+	return written, nil
+}
+
+// databaseSizes returns the size of each database the server on port accepts connections to.
+func databaseSizes(port string) (map[string]int64, error) {
+	output, err := ExecPostgresCommand(port, "postgres",
+		"SELECT datname || '|' || pg_database_size(oid) FROM pg_database WHERE datallowconn")
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := make(map[string]int64)
+	for line := range strings.SplitSeq(output, "\n") {
+		// Database names may contain the separator, sizes can't
+		i := strings.LastIndex(line, "|")
+		if i < 0 {
+			continue
+		}
+		name, size := line[:i], line[i+1:]
+		bytes, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing size of database %s: %w", name, err)
+		}
+		sizes[name] = bytes
+	}
+
+	return sizes, nil
+}
@@ -0,0 +1,76 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+type BranchStatus struct {
+	*BranchInfo
+	ServiceStatus   string
+	Ready           bool
+	InRecovery      bool
+	ConnectionCount int
+	Space           DatasetSpace
+}
+
+func (s *AgentService) GetBranchInfo(ctx context.Context, template string, branchName string) (*BranchStatus, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	branchDataset := GetBranchDataset(template, branchName)
+	branch, err := s.getBranchMetadata(branchDataset)
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	s.touchBranch(branch)
+
+	if branch.TemplateMissing, err = branchOrphaned(branch); err != nil {
+		return nil, err
+	}
+
+	replicas, err := s.branchReplicas(ctx, template, branchName)
+	if err != nil {
+		return nil, err
+	}
+	for _, replica := range replicas {
+		branch.Replicas = append(branch.Replicas, replica.BranchName)
+	}
+
+	space, err := getDatasetSpace(branchDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &BranchStatus{
+		BranchInfo:    branch,
+		ServiceStatus: GetServiceStatus(GetBranchServiceName(template, branchName)),
+		Ready:         IsPostgreSQLServerReady(branch.BranchPath),
+		Space:         space,
+	}
+
+	if !status.Ready {
+		return status, nil
+	}
+
+	output, err := ExecPostgresCommand(branch.Port, "postgres",
+		"SELECT count(*) FROM pg_stat_activity WHERE backend_type = 'client backend' AND pid <> pg_backend_pid()")
+	if err != nil {
+		return nil, fmt.Errorf("counting connections: %w", err)
+	}
+	status.ConnectionCount, _ = strconv.Atoi(output)
+
+	output, err = ExecPostgresCommand(branch.Port, "postgres", "SELECT pg_is_in_recovery()")
+	if err != nil {
+		return nil, fmt.Errorf("checking recovery status: %w", err)
+	}
+	status.InRecovery = output == "t"
+
+	return status, nil
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PostgreSQL settings developers may set per branch. Anything that affects
+// startup (memory, paths, listen addresses, preload libraries) is left out.
+// A bad value for the others still stops the server from starting, so
+// checkBranchSettings runs before the branch starts.
+var allowedBranchSettings = map[string]bool{
+	"statement_timeout":                   true,
+	"lock_timeout":                        true,
+	"idle_in_transaction_session_timeout": true,
+	"log_statement":                       true,
+	"log_min_duration_statement":          true,
+	"log_duration":                        true,
+	"log_lock_waits":                      true,
+	"log_temp_files":                      true,
+	"log_connections":                     true,
+	"log_disconnections":                  true,
+	"track_io_timing":                     true,
+	"work_mem":                            true,
+	"random_page_cost":                    true,
+	"default_statistics_target":           true,
+	"jit":                                 true,
+	"timezone":                            true,
+	"auto_explain.log_min_duration":       true,
+	"auto_explain.log_analyze":            true,
+	"pg_stat_statements.track":            true,
+}
+
+// Branch connection limits. Each connection reserves memory at startup, so
+// the limit is capped rather than left to the developer.
+const (
+	DefaultMaxConnections = 50
+	minMaxConnections     = 5 // Leaves room for superuser_reserved_connections
+	maxMaxConnections     = 1000
+)
+
+// ValidateMaxConnections checks a requested max_connections, 0 picks the default.
+func ValidateMaxConnections(n int) (int, error) {
+	if n == 0 {
+		return DefaultMaxConnections, nil
+	}
+	if n < minMaxConnections || n > maxMaxConnections {
+		return 0, fmt.Errorf("max connections must be between %d and %d, got %d", minMaxConnections, maxMaxConnections, n)
+	}
+	return n, nil
+}
+
+// Values are written quoted into postgresql.auto.conf, so quotes, backslashes
+// and newlines are refused rather than escaped.
+var branchSettingValuePattern = regexp.MustCompile(`^[A-Za-z0-9_.:+/ -]+$`)
+
+// ValidateBranchSettings parses name=value pairs into settings for the branch's
+// postgresql.auto.conf. Later pairs override earlier ones.
+func ValidateBranchSettings(pairs []string) (map[string]string, error) {
+	settings := make(map[string]string)
+
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("setting '%s' must be in name=value form", pair)
+		}
+
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+
+		if !allowedBranchSettings[name] {
+			return nil, fmt.Errorf("setting '%s' is not allowed. Allowed settings: %s", name, strings.Join(slices.Sorted(maps.Keys(allowedBranchSettings)), ", "))
+		}
+		if !branchSettingValuePattern.MatchString(value) {
+			return nil, fmt.Errorf("invalid value '%s' for setting '%s'", value, name)
+		}
+
+		settings[name] = value
+	}
+
+	return settings, nil
+}
+
+// checkBranchSettings loads a data directory's configuration with postgres -C,
+// which fails on any value the server would refuse at startup.
+func checkBranchSettings(dataDir string, settings map[string]string) error {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	name := slices.Sorted(maps.Keys(settings))[0]
+	output, err := asPostgres(pgBinPath(PgVersion, "postgres"), "-D", dataDir, "-C", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("invalid branch settings: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// renderBranchSettings formats settings as postgresql.auto.conf lines, sorted by name.
+func renderBranchSettings(settings map[string]string) string {
+	if len(settings) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Branch settings\n")
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		fmt.Fprintf(&b, "%s = '%s'\n", name, settings[name])
+	}
+	return b.String()
+}
+
+// updateAutoConf replaces settings in a branch's postgresql.auto.conf, keeping
+// its other lines. An empty value removes the setting.
+func updateAutoConf(branchPath string, settings map[string]string) error {
+	autoConfPath := filepath.Join(branchPath, "postgresql.auto.conf")
+	content, err := privileged("cat", autoConfPath).Output()
+	if err != nil {
+		return fmt.Errorf("reading postgresql.auto.conf: %w", err)
+	}
+
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		name, _, _ := strings.Cut(line, "=")
+		if _, replaced := settings[strings.TrimSpace(name)]; line != "" && !replaced {
+			b.WriteString(strings.TrimSuffix(line, "\n") + "\n")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if settings[name] != "" {
+			fmt.Fprintf(&b, "%s = '%s'\n", name, settings[name])
+		}
+	}
+
+	cmd := privileged("tee", autoConfPath)
+	cmd.Stdin = strings.NewReader(b.String())
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("writing postgresql.auto.conf: %w", err)
+	}
+	return nil
+}
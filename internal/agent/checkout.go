@@ -2,18 +2,36 @@ package agent
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-func (s *AgentService) CreateBranch(ctx context.Context, branch string, template string, createdBy string) (*BranchInfo, error) {
+func (s *AgentService) CreateBranch(ctx context.Context, branch string, template string, createdBy string, opts BranchOptions) (*BranchInfo, error) {
+	if opts.IdempotencyKey != "" {
+		return s.withIdempotencyKey(ctx, createdBy, opts.IdempotencyKey, template, branch, func() (*BranchInfo, error) {
+			return s.createBranch(ctx, branch, template, createdBy, opts)
+		})
+	}
+
+	return s.createBranch(ctx, branch, template, createdBy, opts)
+}
+
+func (s *AgentService) createBranch(ctx context.Context, branch string, template string, createdBy string, opts BranchOptions) (*BranchInfo, error) {
+	op, done := s.beginOperation(OpCreateBranch, branchTarget(template, branch), createdBy)
+	defer done()
+
+	if err := requireBinaries(append(firewallBinaries(), pgResetWalPath(PgVersion))...); err != nil {
+		return nil, err
+	}
+
 	templatePath, err := GetMountpoint(GetTemplateDataset(template))
 	if err != nil {
 		return nil, err
@@ -22,11 +40,20 @@ func (s *AgentService) CreateBranch(ctx context.Context, branch string, template
 	if !IsPostgreSQLServerReady(templatePath) {
 		return nil, fmt.Errorf("template is still in recovery mode and not ready for branching. This process may take seconds to hours depending on WAL volume. Please retry in a few moments")
 	}
+	if err := checkRecoveryTargetReached(templatePath); err != nil {
+		return nil, err
+	}
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	// Released early for --analyze, which can take long on large databases
+	unlock := sync.OnceFunc(s.checkoutMutex.Unlock)
+	defer unlock()
 
-	if !s.tryLockWithShutdownCheck() {
-		return nil, fmt.Errorf("service restarting, please retry in a few seconds")
+	if op.cancelled() {
+		return nil, ErrOperationCancelled
 	}
-	defer s.checkoutMutex.Unlock()
 
 	// Validate and normalize clone name
 	validatedName, err := ValidateBranchName(branch)
@@ -35,49 +62,190 @@ func (s *AgentService) CreateBranch(ctx context.Context, branch string, template
 	}
 	branch = validatedName
 
+	extensions, err := ValidateExtensions(opts.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extensions: %w", err)
+	}
+
+	roleMode, err := ValidateRoleMode(opts.RoleMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid role mode: %w", err)
+	}
+
+	branchSettings, err := ValidateBranchSettings(opts.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	walReset, err := ValidateWALReset(opts.WALReset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WAL reset: %w", err)
+	}
+
+	maxConnections, err := ValidateMaxConnections(opts.MaxConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := ValidateProfile(opts.Profile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+
+	mountpoint := GetBranchMountpoint(template, branch)
+	if opts.Mountpoint != "" {
+		if err := ValidateMountpoint(opts.Mountpoint); err != nil {
+			return nil, err
+		}
+		mountpoint = opts.Mountpoint
+	}
+
+	if opts.AdminPassword != "" {
+		if err := s.Config().AdminPassword.ValidateAdminPassword(opts.AdminPassword); err != nil {
+			return nil, err
+		}
+	}
+
 	existing, err := s.getBranchMetadata(GetBranchDataset(template, branch))
 	if err != nil {
 		return nil, fmt.Errorf("checking existing checkout: %w", err)
 	}
 	if existing != nil {
+		if existing.Detached {
+			return nil, fmt.Errorf("branch '%s' is detached, run `quic branch attach %s` to start it again", branch, branch)
+		}
+		// Returning the branch would hand back a password other than the one asked for
+		if opts.AdminPassword != "" && opts.AdminPassword != existing.AdminPassword {
+			return nil, fmt.Errorf("branch '%s' already exists with a different admin password", branch)
+		}
+		s.touchBranchLocked(existing)
 		return existing, nil // Already exists
 	}
 
+	if err := checkServiceNameFree(template, branch); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkBranchQuota(ctx, template); err != nil {
+		return nil, err
+	}
+
 	// Find available port from OS
-	port, err := findAvailablePort()
+	port, err := s.findAvailablePort(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("finding available port: %w", err)
 	}
 
-	// Generate admin password
-	adminPassword, err := generateSecurePassword()
-	if err != nil {
-		return nil, fmt.Errorf("generating password: %w", err)
+	adminPassword := opts.AdminPassword
+	if adminPassword == "" {
+		adminPassword, err = generateSecurePassword(s.Config().AdminPassword.length())
+		if err != nil {
+			return nil, fmt.Errorf("generating password: %w", err)
+		}
 	}
 
+	if err := checkPoolSpace(ZPool); err != nil {
+		return nil, err
+	}
+
+	if opts.Mountpoint != "" {
+		if err := checkMountpointFree(mountpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	// Refuse before cloning so a template with external tablespaces doesn't leave a broken clone behind
+	if err := checkTemplateTablespaces(GetTemplateMountpoint(template)); err != nil {
+		return nil, err
+	}
+
+	// Read before the snapshot, so the branch holds data at least this recent
+	backupFinishedAt, dataAsOf := templateDataAge(templatePath)
+
 	// Create ZFS snapshot and clone
-	clonePath, err := s.createZFSClone(template, branch)
+	timer := newStepTimer()
+	clonePath, checkpointed, err := s.createZFSClone(template, branch, mountpoint, timer)
 	if err != nil {
 		return nil, fmt.Errorf("creating ZFS clone: %w", err)
 	}
 
+	// Without a checkpoint right before the snapshot, pg_resetwal may throw away
+	// changes that only exist in WAL
+	if walReset == "" {
+		walReset = WALResetSafe
+		if checkpointed {
+			walReset = WALResetFast
+		}
+	}
+
+	// Undo the partial branch when cancelled or its settings are refused. The
+	// firewall port is opened last, so there's nothing to close yet.
+	discard := func(cause error) (*BranchInfo, error) {
+		if err := removeBranch(template, branch, nil); err != nil {
+			log.Printf("Warning: failed to clean up branch %s: %v", branch, err)
+		}
+		if opts.Mountpoint != "" {
+			privileged("rmdir", mountpoint).Run()
+		}
+		return nil, cause
+	}
+	abort := func() (*BranchInfo, error) {
+		return discard(ErrOperationCancelled)
+	}
+
+	if err := relocateTablespaces(clonePath, GetTemplateMountpoint(template)); err != nil {
+		return nil, err
+	}
+
 	// Store metadata alongside the clone
 	now := time.Now().UTC().Truncate(time.Second)
 	checkout := &BranchInfo{
-		TemplateName:  template,
-		BranchName:    branch,
-		Port:          port,
-		BranchPath:    clonePath,
-		AdminPassword: adminPassword,
-		CreatedBy:     createdBy,
-		CreatedAt:     now,
-		UpdatedAt:     now,
-	}
-
-	// Prepare clone for startup (remove standby config, reset WAL, configure access)
-	if err := prepareCloneForStartup(clonePath); err != nil {
+		TemplateName:     template,
+		BranchName:       branch,
+		Port:             port,
+		BranchPath:       clonePath,
+		AdminPassword:    adminPassword,
+		Extensions:       extensions,
+		RoleMode:         roleMode,
+		Settings:         branchSettings,
+		MaxConnections:   maxConnections,
+		Profile:          profile,
+		Archive:          opts.Archive,
+		WALReset:         walReset,
+		BackupFinishedAt: backupFinishedAt,
+		DataAsOf:         dataAsOf,
+		CreatedBy:        createdBy,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		LastAccessedAt:   now,
+	}
+
+	// Prepare clone for startup (remove standby config, reset WAL if fast, configure access)
+	settings := map[string]string{
+		"shared_preload_libraries": sharedPreloadLibraries(extensions),
+		"ssl_min_protocol_version": s.Config().TLS.postgresMinVersion(),
+		"password_encryption":      s.Config().PgHba.passwordEncryption(),
+		"max_connections":          strconv.Itoa(maxConnections),
+	}
+	maps.Copy(settings, profileSettings(profile))
+	var archiveDir string
+	if opts.Archive {
+		archiveDir, err = createWALArchive(template, branch, clonePath)
+		if err != nil {
+			return nil, err
+		}
+		settings["wal_level"] = "replica"
+	}
+	if err := prepareCloneForStartup(clonePath, walReset, settings, branchSettings, archiveDir, s.Config().PgHba); err != nil {
 		return nil, fmt.Errorf("preparing clone for startup: %w", err)
 	}
+	if err := checkBranchSettings(clonePath, branchSettings); err != nil {
+		return discard(err)
+	}
+	timer.lap("prepare")
+	if op.cancelled() {
+		return abort()
+	}
 
 	// Save metadata to filesystem (after permissions are set)
 	if err := saveCheckoutMetadata(checkout); err != nil {
@@ -94,51 +262,119 @@ func (s *AgentService) CreateBranch(ctx context.Context, branch string, template
 	if err := StartService(serviceName); err != nil {
 		return nil, fmt.Errorf("starting systemd service: %w", err)
 	}
+	if op.cancelled() {
+		return abort()
+	}
+
+	// systemctl start returns before PostgreSQL accepts connections, and the
+	// admin user setup below needs a live server
+	if err := waitForPostgreSQLReady(checkout.BranchPath, branchStartTimeout); err != nil {
+		return nil, fmt.Errorf("waiting for branch to accept connections: %w\n%s", err, ServiceLogs(serviceName, 20))
+	}
+	timer.lap("service_start")
+
+	// Templates restored with --only-database still list the skipped databases.
+	// The template is a read-only standby following WAL, so they can't be
+	// dropped there once: only a started clone accepts DROP DATABASE.
+	if templateMeta, err := loadTemplateMetadata(templatePath); err == nil && templateMeta.OnlyDatabase {
+		if err := dropExcludedDatabases(checkout.Port, templateMeta.Database); err != nil {
+			return nil, err
+		}
+		timer.lap("drop_databases")
+	}
 
 	// Open firewall port
 	if err := openFirewallPort(port); err != nil {
 		return nil, fmt.Errorf("opening firewall port: %w", err)
 	}
+	timer.lap("firewall")
+
+	database := "postgres"
+	if len(extensions) > 0 || roleMode == RoleModeApp {
+		database, err = getTemplateDatabase(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("getting template database: %w", err)
+		}
+	}
 
 	// Setup admin user
-	if err := s.setupAdminUser(checkout); err != nil {
+	if err := s.setupAdminUser(checkout, database); err != nil {
 		return nil, fmt.Errorf("setting up admin user: %w", err)
 	}
+	timer.lap("admin_user")
+
+	// Enable requested extensions in the template's database
+	if len(extensions) > 0 {
+		if err := createExtensions(checkout.Port, database, extensions); err != nil {
+			return nil, fmt.Errorf("enabling extensions: %w", err)
+		}
+		timer.lap("extensions")
+	}
+
+	// Timings are diagnostic, the branch is usable without them
+	checkout.Timings = timer.steps
+	if err := saveCheckoutMetadata(checkout); err != nil {
+		log.Printf("Warning: failed to save timings of branch %s: %v", branch, err)
+	}
 
 	// Audit checkout creation
-	if err := auditEvent("checkout_create", checkout); err != nil {
+	if err := auditEvent("checkout_create", auditedBranch(checkout)); err != nil {
 		return nil, fmt.Errorf("auditing checkout creation: %w", err)
 	}
+	s.updatePortConflicts()
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_create",
+		Template: template,
+		Branch:   branch,
+		User:     createdBy,
+		Host:     s.PublicHost(ctx),
+		Port:     checkout.Port,
+	})
+
+	// Cloned statistics can be stale, or missing after an import, so
+	// performance tests get plans like production's. The branch is already
+	// complete, so other operations don't wait for it.
+	if opts.Analyze {
+		unlock()
+		if err := analyzeDatabases(checkout.Port); err != nil {
+			return nil, fmt.Errorf("branch created, but analyzing it failed: %w", err)
+		}
+		timer.lap("analyze")
+		s.recordAnalyzed(checkout, timer.steps)
+	}
 
 	return checkout, nil
 }
 
-func (s *AgentService) createZFSClone(template, branch string) (string, error) {
+// createZFSClone snapshots the template and clones the snapshot, reporting
+// whether the template was checkpointed right before the snapshot.
+func (s *AgentService) createZFSClone(template, branch, mountpoint string, timer *stepTimer) (string, bool, error) {
 	templateDataset := GetTemplateDataset(template)
 
 	// Check if restore dataset exists
 	if !datasetExists(templateDataset) {
-		return "", fmt.Errorf("restore dataset %s does not exist", templateDataset)
+		return "", false, fmt.Errorf("restore dataset %s does not exist", templateDataset)
 	}
 
 	// ZFS snapshot
-	err := s.createBranchSnapshot(template, branch)
+	checkpointed, err := s.createBranchSnapshot(template, branch)
 	if err != nil {
-		return "", fmt.Errorf("creating branch snapshot: %w", err)
+		return "", false, fmt.Errorf("creating branch snapshot: %w", err)
 	}
+	timer.lap("snapshot")
 
 	// ZFS clone
-	mountpoint, err := s.createBranchClone(template, branch)
+	mountpoint, err = s.createBranchClone(template, branch, mountpoint)
 	if err != nil {
-		return "", fmt.Errorf("getting clone mountpoint: %w", err)
+		return "", false, fmt.Errorf("getting clone mountpoint: %w", err)
 	}
+	timer.lap("clone")
 
-	return mountpoint, nil
+	return mountpoint, checkpointed, nil
 }
 
-func (s *AgentService) createBranchClone(template, branch string) (string, error) {
+func (s *AgentService) createBranchClone(template, branch, mountpoint string) (string, error) {
 	branchDataset := GetBranchDataset(template, branch)
-	mountpoint := GetBranchMountpoint(template, branch)
 
 	if !datasetExists(branchDataset) {
 		snapshotName := GetSnapshotName(template, branch)
@@ -151,103 +387,106 @@ func (s *AgentService) createBranchClone(template, branch string) (string, error
 	return mountpoint, nil
 }
 
-func (s *AgentService) createBranchSnapshot(template, branch string) error {
+// createBranchSnapshot snapshots the template, reporting whether it was
+// checkpointed right before. An existing snapshot counts as not checkpointed.
+func (s *AgentService) createBranchSnapshot(template, branch string) (bool, error) {
 	snapshotName := GetSnapshotName(template, branch)
 	if snapshotExists(snapshotName) {
-		return nil
+		return false, nil
 	}
 
 	sourcePath, err := GetMountpoint(GetTemplateDataset(template))
 	if err != nil {
-		return fmt.Errorf("getting mountpoint: %w", err)
+		return false, fmt.Errorf("getting mountpoint: %w", err)
 	}
 
 	postmasterPid, isRunning := getPostmasterPid(sourcePath)
 	if !isRunning {
 		// PostgreSQL isn't running, just create snapshot
-		return createSnapshot(snapshotName)
+		return false, createKindSnapshot(snapshotName, SnapshotKindBranch)
 	}
 
 	// PostgreSQL is running and ready - force checkpoint before taking snapshot
 	if _, err := ExecPostgresCommand(postmasterPid.Port, "postgres", "CHECKPOINT;"); err != nil {
-		return fmt.Errorf("forcing checkpoint: %w", err)
+		return false, fmt.Errorf("forcing checkpoint: %w", err)
 	}
-	return createSnapshot(snapshotName)
+	return true, createKindSnapshot(snapshotName, SnapshotKindBranch)
 }
 
-func prepareCloneForStartup(clonePath string) error {
+func prepareCloneForStartup(clonePath, walReset string, settings, branchSettings map[string]string, archiveDir string, hba PgHbaConfig) error {
 	// Remove standby.signal file
 	standbySignalPath := filepath.Join(clonePath, "standby.signal")
-	cmd := exec.Command("sudo", "rm", "-f", standbySignalPath)
+	cmd := privileged("rm", "-f", standbySignalPath)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("removing standby.signal: %w", err)
 	}
 
 	// Remove recovery.signal file
 	recoverySignalPath := filepath.Join(clonePath, "recovery.signal")
-	cmd = exec.Command("sudo", "rm", "-f", recoverySignalPath)
+	cmd = privileged("rm", "-f", recoverySignalPath)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("removing recovery.signal: %w", err)
 	}
 
 	// Remove recovery.conf if it exists
 	recoveryConfPath := filepath.Join(clonePath, "recovery.conf")
-	cmd = exec.Command("sudo", "rm", "-f", recoveryConfPath)
+	cmd = privileged("rm", "-f", recoveryConfPath)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("removing recovery.conf: %w", err)
 	}
 
 	// Remove postmaster.pid file to prevent startup conflicts
 	postmasterPidPath := filepath.Join(clonePath, "postmaster.pid")
-	cmd = exec.Command("sudo", "rm", "-f", postmasterPidPath)
+	cmd = privileged("rm", "-f", postmasterPidPath)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("removing postmaster.pid: %w", err)
 	}
 
-	// Reset WAL for fast startup (skips recovery entirely)
-	resetCmd := exec.Command("sudo", "-u", "postgres", pgResetWalPath(PgVersion), "-f", clonePath)
-	if err := resetCmd.Run(); err != nil {
-		return fmt.Errorf("resetting WAL for fast startup: %w", err)
+	// Reset WAL for fast startup (skips recovery entirely). In safe mode
+	// PostgreSQL replays the cloned WAL instead, like after a crash.
+	if walReset == WALResetFast {
+		resetCmd := asPostgres(pgResetWalPath(PgVersion), "-f", clonePath)
+		if err := resetCmd.Run(); err != nil {
+			return fmt.Errorf("resetting WAL for fast startup: %w", err)
+		}
 	}
 
 	// Clean postgresql.auto.conf and configure for clone
 	autoConfPath := filepath.Join(clonePath, "postgresql.auto.conf")
-	autoConfig := `# Clone instance
-archive_mode = 'off'
-restore_command = ''
-`
-	cmd = exec.Command("sudo", "tee", autoConfPath)
+	autoConfig := "# Clone instance\n" + walArchiveConf(archiveDir) + "restore_command = ''\n" + renderBranchSettings(branchSettings)
+	cmd = privileged("tee", autoConfPath)
 	cmd.Stdin = strings.NewReader(autoConfig)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("writing postgresql.auto.conf: %w", err)
 	}
 
+	return configureBranchAccess(clonePath, settings, hba)
+}
+
+// configureBranchAccess writes the branch postgresql.conf settings and pg_hba.conf.
+func configureBranchAccess(dataPath string, settings map[string]string, hba PgHbaConfig) error {
 	// Configure postgresql.conf for clone optimization
-	postgresqlConfPath := filepath.Join(clonePath, "postgresql.conf")
-	if err := updatePostgreSQLConf(postgresqlConfPath); err != nil {
+	postgresqlConfPath := filepath.Join(dataPath, "postgresql.conf")
+	if err := updatePostgreSQLConf(postgresqlConfPath, settings); err != nil {
 		return fmt.Errorf("updating postgresql.conf: %w", err)
 	}
 
 	// Configure pg_hba.conf to allow admin user access
-	pgHbaPath := filepath.Join(clonePath, "pg_hba.conf")
-	hbaConfig := `# Allow local connections for testing
-local   all             postgres                                peer
-local   all             all                                     md5
-host    all             all             127.0.0.1/32            md5
-host    all             all             ::1/128                 md5
-host    all             admin           0.0.0.0/0               md5
-`
-	cmd = exec.Command("sudo", "tee", pgHbaPath)
-	cmd.Stdin = strings.NewReader(hbaConfig)
+	return writePgHba(dataPath, hba)
+}
+
+func writePgHba(dataPath string, hba PgHbaConfig) error {
+	cmd := privileged("tee", filepath.Join(dataPath, "pg_hba.conf"))
+	cmd.Stdin = strings.NewReader(hba.render())
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("writing pg_hba.conf: %w", err)
 	}
-
 	return nil
 }
 
-func updatePostgreSQLConf(confPath string) error {
-	cmd := exec.Command("sudo", "cat", confPath)
+// updatePostgreSQLConf applies clone defaults, with settings taking precedence
+func updatePostgreSQLConf(confPath string, settings map[string]string) error {
+	cmd := privileged("cat", confPath)
 	data, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("reading postgresql.conf: %w", err)
@@ -256,7 +495,7 @@ func updatePostgreSQLConf(confPath string) error {
 	config := string(data)
 
 	cloneSettings := map[string]string{
-		"max_connections":                 "50",
+		"max_connections":                 strconv.Itoa(DefaultMaxConnections),
 		"wal_level":                       "minimal",
 		"max_wal_senders":                 "0",
 		"archive_mode":                    "off",
@@ -273,11 +512,13 @@ func updatePostgreSQLConf(confPath string) error {
 		"listen_addresses":                "'*'",
 		"shared_preload_libraries":        "''",
 		"ssl":                             "on",
-		"ssl_cert_file":                   "'/etc/quic/certs/server.crt'",
-		"ssl_key_file":                    "'/etc/quic/certs/server.key'",
+		"ssl_cert_file":                   fmt.Sprintf("'%s'", TLSCertFile),
+		"ssl_key_file":                    fmt.Sprintf("'%s'", TLSKeyFile),
 		"ssl_ca_file":                     "''",
 		"autovacuum":                      "off",
+		"unix_socket_directories":         fmt.Sprintf("'%s'", PgSocketDir),
 	}
+	maps.Copy(cloneSettings, settings)
 
 	for setting, value := range cloneSettings {
 		settingPattern := fmt.Sprintf("%s = ", setting)
@@ -301,7 +542,7 @@ func updatePostgreSQLConf(confPath string) error {
 		config = strings.Join(lines, "\n")
 	}
 
-	cmd = exec.Command("sudo", "tee", confPath)
+	cmd = privileged("tee", confPath)
 	cmd.Stdin = strings.NewReader(config)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("writing postgresql.conf: %w", err)
@@ -319,17 +560,45 @@ func saveCheckoutMetadata(checkout *BranchInfo) error {
 		"port":           checkout.Port,
 		"branch_path":    checkout.BranchPath,
 		"admin_password": checkout.AdminPassword,
+		"extensions":     checkout.Extensions,
+		"promoted":       checkout.Promoted,
+		"role_mode":      checkout.RoleMode,
+		"profile":        checkout.Profile,
+		"analyzed":       checkout.Analyzed,
+		"source":         checkout.Source,
+		"checkpoints":    checkout.Checkpoints,
+		"settings":       checkout.Settings,
+		"archive":        checkout.Archive,
+		"wal_reset":      checkout.WALReset,
+		"detached":       checkout.Detached,
+		"frozen":         checkout.Frozen,
+		"timings":        checkout.Timings,
 		"created_by":     checkout.CreatedBy,
 		"created_at":     checkout.CreatedAt.UTC().Format(time.RFC3339),
 		"updated_at":     checkout.UpdatedAt.UTC().Format(time.RFC3339),
 	}
+	if checkout.MaxConnections != 0 {
+		metadata["max_connections"] = checkout.MaxConnections
+	}
+	if checkout.ReplicaOf != "" {
+		metadata["replica_of"] = checkout.ReplicaOf
+	}
+	if checkout.BackupFinishedAt != "" {
+		metadata["backup_finished_at"] = checkout.BackupFinishedAt
+	}
+	if checkout.DataAsOf != "" {
+		metadata["data_as_of"] = checkout.DataAsOf
+	}
+	if !checkout.LastAccessedAt.IsZero() {
+		metadata["last_accessed_at"] = checkout.LastAccessedAt.UTC().Format(time.RFC3339)
+	}
 
 	data, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling metadata: %w", err)
 	}
 
-	cmd := exec.Command("sudo", "tee", metadataPath)
+	cmd := privileged("tee", metadataPath)
 	cmd.Stdin = strings.NewReader(string(data))
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("writing metadata file: %w", err)
@@ -338,20 +607,75 @@ func saveCheckoutMetadata(checkout *BranchInfo) error {
 	return nil
 }
 
-func (s *AgentService) setupAdminUser(branch *BranchInfo) error {
+func (s *AgentService) setupAdminUser(branch *BranchInfo, database string) error {
+	// Hash the password explicitly rather than relying on the template's setting
+	passwordEncryption := s.Config().PgHba.passwordEncryption()
+
+	if branch.RoleMode == RoleModeApp {
+		return setupAppUser(branch, database, passwordEncryption)
+	}
+
+	// The password is set outside the DO block, where a supplied password
+	// containing $$ couldn't end the block early
 	sqlCommands := fmt.Sprintf(`
+		SET password_encryption = %s;
+		DO $$ BEGIN
+			CREATE ROLE admin WITH LOGIN SUPERUSER CREATEDB CREATEROLE REPLICATION BYPASSRLS;
+		EXCEPTION
+			WHEN duplicate_object THEN
+				ALTER ROLE admin WITH LOGIN SUPERUSER CREATEDB CREATEROLE REPLICATION BYPASSRLS;
+		END $$;
+		ALTER ROLE admin WITH PASSWORD %s;
+	`, passwordEncryption, quoteLiteral(branch.AdminPassword))
+
+	// Sent on stdin to keep the password out of psql's arguments
+	_, err := ExecPostgresScript(branch.Port, "postgres", sqlCommands)
+	return err
+}
+
+// setupAppUser creates a non-superuser admin role limited to CRUD on the
+// template's database, for branches exposed to less-trusted consumers.
+func setupAppUser(branch *BranchInfo, database string, passwordEncryption string) error {
+	roleSQL := fmt.Sprintf(`
+		SET password_encryption = %s;
 		DO $$ BEGIN
-			CREATE ROLE admin WITH LOGIN SUPERUSER CREATEDB CREATEROLE REPLICATION BYPASSRLS PASSWORD '%s';
+			CREATE ROLE admin WITH LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE NOREPLICATION NOBYPASSRLS;
 		EXCEPTION
 			WHEN duplicate_object THEN
-				ALTER ROLE admin WITH SUPERUSER CREATEDB CREATEROLE REPLICATION BYPASSRLS PASSWORD '%s';
+				ALTER ROLE admin WITH LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE NOREPLICATION NOBYPASSRLS;
+		END $$;
+		ALTER ROLE admin WITH PASSWORD %s;
+		GRANT CONNECT ON DATABASE %s TO admin;
+	`, passwordEncryption, quoteLiteral(branch.AdminPassword), quoteIdentifier(database))
+
+	if _, err := ExecPostgresScript(branch.Port, "postgres", roleSQL); err != nil {
+		return err
+	}
+
+	grantSQL := `
+		DO $$
+		DECLARE
+			schema_name text;
+		BEGIN
+			FOR schema_name IN
+				SELECT nspname FROM pg_namespace
+				WHERE nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema'
+			LOOP
+				EXECUTE format('GRANT USAGE ON SCHEMA %I TO admin', schema_name);
+				EXECUTE format('GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA %I TO admin', schema_name);
+				EXECUTE format('GRANT USAGE, SELECT, UPDATE ON ALL SEQUENCES IN SCHEMA %I TO admin', schema_name);
+			END LOOP;
 		END $$;
-	`, branch.AdminPassword, branch.AdminPassword)
+	`
 
-	_, err := ExecPostgresCommand(branch.Port, "postgres", sqlCommands)
+	_, err := ExecPostgresCommand(branch.Port, database, grantSQL)
 	return err
 }
 
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
 func (s *AgentService) getBranchMetadata(dataset string) (*BranchInfo, error) {
 	if !datasetExists(dataset) {
 		return nil, nil
@@ -397,9 +721,25 @@ func loadBranchMetadata(branchPath string) (*BranchInfo, error) {
 		Port:          getString(metadata, "port"),
 		BranchPath:    branchPath,
 		AdminPassword: getString(metadata, "admin_password"),
+		Extensions:    getStringSlice(metadata, "extensions"),
+		Promoted:      getBool(metadata, "promoted"),
+		Archive:       getBool(metadata, "archive"),
+		Detached:      getBool(metadata, "detached"),
+		Frozen:        getBool(metadata, "frozen"),
+		RoleMode:      getString(metadata, "role_mode"),
+		Profile:       getString(metadata, "profile"),
+		Analyzed:      getBool(metadata, "analyzed"),
+		WALReset:      getString(metadata, "wal_reset"),
+		Source:        getString(metadata, "source"),
 		CreatedBy:     getString(metadata, "created_by"),
+		ReplicaOf:     getString(metadata, "replica_of"),
 	}
 
+	checkout.BackupFinishedAt = getString(metadata, "backup_finished_at")
+	checkout.DataAsOf = getString(metadata, "data_as_of")
+
+	checkout.MaxConnections = getInt(metadata, "max_connections")
+
 	if createdAtStr := getString(metadata, "created_at"); createdAtStr != "" {
 		if t, err := time.Parse(time.RFC3339, createdAtStr); err == nil {
 			checkout.CreatedAt = t.UTC()
@@ -412,13 +752,23 @@ func loadBranchMetadata(branchPath string) (*BranchInfo, error) {
 		}
 	}
 
-	return checkout, nil
-}
+	if lastAccessedAtStr := getString(metadata, "last_accessed_at"); lastAccessedAtStr != "" {
+		if t, err := time.Parse(time.RFC3339, lastAccessedAtStr); err == nil {
+			checkout.LastAccessedAt = t.UTC()
+		}
+	}
 
-func generateSecurePassword() (string, error) {
-	bytes := make([]byte, 24)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", err
+	var structured struct {
+		Checkpoints []BranchCheckpoint `json:"checkpoints"`
+		Settings    map[string]string  `json:"settings"`
+		Timings     []StepTiming       `json:"timings"`
 	}
-	return base64.URLEncoding.EncodeToString(bytes), nil
+	if err := json.Unmarshal(data, &structured); err != nil {
+		return nil, fmt.Errorf("unmarshaling metadata: %w", err)
+	}
+	checkout.Checkpoints = structured.Checkpoints
+	checkout.Settings = structured.Settings
+	checkout.Timings = structured.Timings
+
+	return checkout, nil
 }
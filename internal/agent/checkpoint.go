@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// BranchCheckpoint is a named ZFS snapshot of a branch dataset the branch can
+// be rolled back to.
+type BranchCheckpoint struct {
+	Label     string    `json:"label"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var checkpointLabelPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+func ValidateCheckpointLabel(label string) error {
+	if len(label) < 1 || len(label) > 50 {
+		return fmt.Errorf("checkpoint label must be between 1 and 50 characters")
+	}
+	if !checkpointLabelPattern.MatchString(label) {
+		return fmt.Errorf("checkpoint label must contain only lowercase letters, numbers, underscore, and dash")
+	}
+	return nil
+}
+
+// GetCheckpointSnapshot names checkpoint snapshots with a prefix so they can't
+// collide with the template snapshot a promoted branch owns.
+func GetCheckpointSnapshot(template, branch, label string) string {
+	return GetBranchDataset(template, branch) + "@checkpoint-" + label
+}
+
+// SnapshotBranch records a checkpoint of the branch's current state.
+func (s *AgentService) SnapshotBranch(ctx context.Context, template string, branchName string, label string, createdBy string) (*BranchCheckpoint, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+	if err := ValidateCheckpointLabel(label); err != nil {
+		return nil, err
+	}
+
+	op, done := s.beginOperation(OpSnapshotBranch, branchTarget(template, branchName), createdBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if slices.ContainsFunc(branch.Checkpoints, func(c BranchCheckpoint) bool { return c.Label == label }) {
+		return nil, fmt.Errorf("checkpoint '%s' already exists", label)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	checkpoint := BranchCheckpoint{Label: label, CreatedBy: createdBy, CreatedAt: now}
+
+	// Metadata lives inside the dataset, so it's saved before snapshotting
+	// for the checkpoint to list itself after a rollback.
+	previous := branch.Checkpoints
+	branch.Checkpoints = append(slices.Clone(previous), checkpoint)
+	branch.UpdatedAt = now
+	branch.LastAccessedAt = now
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return nil, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	if err := createSnapshot(GetCheckpointSnapshot(template, branchName, label)); err != nil {
+		branch.Checkpoints = previous
+		saveCheckoutMetadata(branch)
+		return nil, err
+	}
+
+	auditEvent("branch_snapshot", map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"label":         label,
+		"created_by":    createdBy,
+	})
+
+	return &checkpoint, nil
+}
+
+// RollbackBranch restores a branch to a checkpoint. Checkpoints taken after it
+// are destroyed, as `zfs rollback` requires.
+func (s *AgentService) RollbackBranch(ctx context.Context, template string, branchName string, label string, rolledBackBy string) error {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	op, done := s.beginOperation(OpRollbackBranch, branchTarget(template, branchName), rolledBackBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return fmt.Errorf("branch '%s' not found", branchName)
+	}
+
+	if branch.Frozen {
+		return fmt.Errorf("branch '%s' is frozen, unfreeze it first", branchName)
+	}
+
+	index := slices.IndexFunc(branch.Checkpoints, func(c BranchCheckpoint) bool { return c.Label == label })
+	if index < 0 {
+		return fmt.Errorf("checkpoint '%s' not found on branch '%s'", label, branchName)
+	}
+
+	serviceName := GetBranchServiceName(template, branchName)
+	if err := StopService(serviceName); err != nil {
+		return err
+	}
+
+	if err := rollbackSnapshot(GetCheckpointSnapshot(template, branchName, label)); err != nil {
+		if startErr := startBranchService(serviceName, branch.BranchPath); startErr != nil {
+			return fmt.Errorf("%w (restarting branch also failed: %v)", err, startErr)
+		}
+		return err
+	}
+
+	// The rollback reverted the metadata file too; write back the current
+	// state so later changes such as promotion aren't lost.
+	branch.Checkpoints = branch.Checkpoints[:index+1]
+	branch.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	branch.LastAccessedAt = branch.UpdatedAt
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return fmt.Errorf("saving branch metadata: %w", err)
+	}
+	// Checkpoints taken while the branch was frozen bring back its read-only default
+	if err := setReadOnlyDefault(branch.BranchPath, false); err != nil {
+		return err
+	}
+
+	// The checkpoint was taken while the branch ran, so it brings back a
+	// postmaster.pid of a server that's gone
+	if err := startBranchService(serviceName, branch.BranchPath); err != nil {
+		return err
+	}
+	if err := waitForPostgreSQLReady(branch.BranchPath, branchStartTimeout); err != nil {
+		return err
+	}
+
+	auditEvent("branch_rollback", map[string]interface{}{
+		"template_name":  template,
+		"branch_name":    branchName,
+		"label":          label,
+		"rolled_back_by": rolledBackBy,
+	})
+
+	return nil
+}
+
+// rollbackSnapshot reverts a dataset to a snapshot, destroying later snapshots.
+func rollbackSnapshot(snapshot string) error {
+	output, err := privileged("zfs", "rollback", "-r", snapshot).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rolling back to %s: %s", snapshot, output)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+// dataChecksumsEnabled reads from the control file whether the cluster at
+// dataDir was initialized with data checksums.
+func dataChecksumsEnabled(pgVersion, dataDir string) (bool, error) {
+	output, err := asPostgres(pgBinPath(pgVersion, "pg_controldata"), dataDir).Output()
+	if err != nil {
+		return false, fmt.Errorf("reading control file: %w", err)
+	}
+
+	for line := range strings.SplitSeq(string(output), "\n") {
+		if value, ok := strings.CutPrefix(line, "Data page checksum version:"); ok {
+			return strings.TrimSpace(value) != "0", nil
+		}
+	}
+	return false, fmt.Errorf("control file has no data page checksum version")
+}
+
+// verifyTemplateChecksums checks every data page of a restored template with
+// pg_checksums. It only reads a cleanly stopped cluster, so the template is
+// stopped once it reached a consistent state and started again afterwards.
+// Clusters without checksums are skipped with a warning.
+func (s *AgentService) verifyTemplateChecksums(stream pb.QuicService_RestoreTemplateServer, pgVersion, mountPath, serviceName string) error {
+	enabled, err := dataChecksumsEnabled(pgVersion, mountPath)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		s.sendLog(stream, "WARN", "Data checksums aren't enabled in this cluster, skipping verification")
+		return nil
+	}
+
+	s.sendLog(stream, "INFO", "Verifying data checksums...")
+	// Pages written before the cluster is consistent may still be torn
+	if err := waitForPostgreSQLReady(mountPath, templateStartTimeout); err != nil {
+		return fmt.Errorf("waiting for a consistent state to verify checksums: %w", err)
+	}
+	if err := StopService(serviceName); err != nil {
+		return err
+	}
+
+	output, checkErr := asPostgres(pgBinPath(pgVersion, "pg_checksums"), "--check", "-D", mountPath).CombinedOutput()
+	for line := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			s.sendLog(stream, "INFO", line)
+		}
+	}
+
+	if err := StartService(serviceName); err != nil {
+		return err
+	}
+	if checkErr != nil {
+		return fmt.Errorf("data checksum verification failed, the restored data may be corrupt: %w", checkErr)
+	}
+
+	s.sendLog(stream, "INFO", "✓ Data checksums verified")
+	return nil
+}
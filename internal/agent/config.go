@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+)
+
+const (
+	AgentConfigPath = "/etc/quic/quicd.json"
+)
+
+type AgentConfig struct {
+	Maintenance MaintenancePolicy `json:"maintenance"`
+	// Template restores beyond this limit wait in a queue
+	MaxConcurrentRestores int            `json:"maxConcurrentRestores"`
+	TLS                   TLSConfig      `json:"tls"`
+	PgHba                 PgHbaConfig    `json:"pgHba"`
+	BranchLimits          BranchLimits   `json:"branchLimits"`
+	Postgres              PostgresConfig `json:"postgres"`
+	// Address clients should use for branch connections, e.g. behind NAT
+	PublicHost string `json:"publicHost"`
+	// pgBackRest processes used by template restores that don't ask for a count
+	RestoreProcessMax int `json:"restoreProcessMax"`
+	// Users allowed to cancel other users' operations
+	Admins   []string       `json:"admins"`
+	Webhook  WebhookConfig  `json:"webhook"`
+	Storage  StorageConfig  `json:"storage"`
+	Firewall FirewallConfig `json:"firewall"`
+	// How long shutdown waits for running operations, as a Go duration
+	DrainTimeout string `json:"drainTimeout"`
+	// sudo or direct, by default direct when quicd runs as root
+	Privilege  string           `json:"privilege"`
+	SnapshotGC SnapshotGCConfig `json:"snapshotGC"`
+	// Branch admin passwords, generated or supplied at checkout
+	AdminPassword PasswordPolicy `json:"adminPassword"`
+	// How long PostgreSQL may take to accept connections after a start
+	StartTimeout StartTimeoutConfig `json:"startTimeout"`
+	Log          LogConfig          `json:"log"`
+	Metrics      MetricsConfig      `json:"metrics"`
+}
+
+const defaultDrainTimeout = 5 * time.Minute
+
+func DefaultAgentConfig() *AgentConfig {
+	return &AgentConfig{
+		MaxConcurrentRestores: 1,
+	}
+}
+
+// ShutdownTimeout returns how long shutdown waits for running operations.
+func (c *AgentConfig) ShutdownTimeout() time.Duration {
+	if c.DrainTimeout == "" {
+		return defaultDrainTimeout
+	}
+	// Validated by LoadAgentConfig
+	d, _ := time.ParseDuration(c.DrainTimeout)
+	return d
+}
+
+// LoadAgentConfig reads the quicd config file. A missing file means defaults.
+func LoadAgentConfig(path string) (*AgentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultAgentConfig(), nil
+		}
+		return nil, fmt.Errorf("reading agent config: %w", err)
+	}
+
+	cfg := DefaultAgentConfig()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// A misspelled key would otherwise leave its setting silently at the default
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("parsing agent config %s: %w", path, err)
+	}
+
+	if err := cfg.Maintenance.validate(); err != nil {
+		return nil, fmt.Errorf("invalid maintenance policy: %w", err)
+	}
+
+	if err := cfg.TLS.validate(); err != nil {
+		return nil, fmt.Errorf("invalid tls config: %w", err)
+	}
+
+	if err := cfg.PgHba.validate(); err != nil {
+		return nil, fmt.Errorf("invalid pgHba config: %w", err)
+	}
+
+	if err := cfg.BranchLimits.validate(); err != nil {
+		return nil, fmt.Errorf("invalid branchLimits: %w", err)
+	}
+
+	if err := cfg.Postgres.validate(); err != nil {
+		return nil, fmt.Errorf("invalid postgres config: %w", err)
+	}
+
+	if err := cfg.Webhook.validate(); err != nil {
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+
+	if err := cfg.Storage.validate(); err != nil {
+		return nil, fmt.Errorf("invalid storage config: %w", err)
+	}
+
+	if err := cfg.Firewall.validate(); err != nil {
+		return nil, fmt.Errorf("invalid firewall config: %w", err)
+	}
+
+	if err := cfg.AdminPassword.validate(); err != nil {
+		return nil, fmt.Errorf("invalid adminPassword policy: %w", err)
+	}
+
+	if err := cfg.SnapshotGC.validate(); err != nil {
+		return nil, fmt.Errorf("invalid snapshotGC config: %w", err)
+	}
+
+	if err := cfg.StartTimeout.validate(); err != nil {
+		return nil, fmt.Errorf("invalid startTimeout config: %w", err)
+	}
+
+	if err := cfg.Log.validate(); err != nil {
+		return nil, fmt.Errorf("invalid log config: %w", err)
+	}
+
+	if err := cfg.Metrics.validate(); err != nil {
+		return nil, fmt.Errorf("invalid metrics config: %w", err)
+	}
+
+	if err := validatePrivilegeMode(cfg.Privilege); err != nil {
+		return nil, err
+	}
+
+	if cfg.DrainTimeout != "" {
+		d, err := time.ParseDuration(cfg.DrainTimeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("drainTimeout must be a positive duration like 5m, got %q", cfg.DrainTimeout)
+		}
+	}
+
+	if cfg.RestoreProcessMax < 0 || cfg.RestoreProcessMax > runtime.NumCPU() {
+		return nil, fmt.Errorf("restoreProcessMax must be between 0 and the CPU count (%d)", runtime.NumCPU())
+	}
+
+	if cfg.MaxConcurrentRestores < 1 {
+		return nil, fmt.Errorf("maxConcurrentRestores must be at least 1")
+	}
+
+	return cfg, nil
+}
+
+func (c *AgentConfig) isAdmin(user string) bool {
+	return slices.Contains(c.Admins, user)
+}
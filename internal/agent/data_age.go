@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"cmp"
+	"log"
+)
+
+// templateDataAge reads how recent the data in a template is, for branches
+// cloned from it: the finish time of the backup it was restored from, and the
+// commit time of the last transaction it replayed since, which is newer for
+// templates following the WAL. Without replayed transactions, the data is as
+// of the recovery target or the backup. Times are RFC3339, empty when unknown.
+func templateDataAge(templatePath string) (backupFinishedAt, dataAsOf string) {
+	meta, err := loadTemplateMetadata(templatePath)
+	if err != nil {
+		log.Printf("Warning: reading data age of template %s: %v", templatePath, err)
+		return "", ""
+	}
+	backupFinishedAt = meta.Backup.FinishedAt
+	dataAsOf = cmp.Or(meta.TargetTime, backupFinishedAt)
+
+	if pid, running := getPostmasterPid(templatePath); running {
+		replayed, err := ExecPostgresCommand(pid.Port, "postgres",
+			`SELECT to_char(pg_last_xact_replay_timestamp() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`)
+		if err != nil {
+			log.Printf("Warning: reading last replayed transaction of template %s: %v", templatePath, err)
+		} else if replayed != "" {
+			dataAsOf = replayed
+		}
+	}
+
+	return backupFinishedAt, dataAsOf
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Databases every cluster needs, never dropped from branches
+var systemDatabases = []string{"postgres", "template0", "template1"}
+
+func validateOnlyDatabase(database string) error {
+	if database == "" {
+		return fmt.Errorf("only restoring the template database requires the template to have a database")
+	}
+	if slices.Contains(systemDatabases, database) {
+		return fmt.Errorf("can't restore only the %s system database", database)
+	}
+	return nil
+}
+
+// analyzeDatabases refreshes the planner statistics of every database a
+// client can connect to.
+func analyzeDatabases(port string) error {
+	output, err := ExecPostgresCommand(port, "postgres", "SELECT datname FROM pg_database WHERE datallowconn AND NOT datistemplate")
+	if err != nil {
+		return fmt.Errorf("listing databases: %w", err)
+	}
+
+	for name := range strings.Lines(output) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, err := ExecPostgresCommand(port, name, "ANALYZE"); err != nil {
+			return fmt.Errorf("analyzing database %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// recordAnalyzed marks branch as analyzed once ANALYZE, run without the
+// checkout lock, finished. The metadata is reloaded so changes made meanwhile
+// aren't overwritten, and a branch deleted meanwhile is left alone.
+func (s *AgentService) recordAnalyzed(branch *BranchInfo, timings []StepTiming) {
+	s.checkoutMutex.Lock()
+	defer s.checkoutMutex.Unlock()
+
+	current, err := loadBranchMetadata(branch.BranchPath)
+	if err != nil || current == nil {
+		return
+	}
+
+	current.Analyzed = true
+	current.Timings = timings
+	if err := saveCheckoutMetadata(current); err != nil {
+		log.Printf("Warning: failed to record that branch %s was analyzed: %v", branch.BranchName, err)
+		return
+	}
+	branch.Analyzed = true
+	branch.Timings = timings
+}
+
+// dropExcludedDatabases drops the databases pgBackRest skipped with
+// --db-include. They only hold zeroed files and error on connect.
+func dropExcludedDatabases(port, keep string) error {
+	output, err := ExecPostgresCommand(port, "postgres", "SELECT datname FROM pg_database")
+	if err != nil {
+		return fmt.Errorf("listing databases: %w", err)
+	}
+
+	for name := range strings.Lines(output) {
+		name = strings.TrimSpace(name)
+		if name == "" || name == keep || slices.Contains(systemDatabases, name) {
+			continue
+		}
+
+		quoted := `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		if _, err := ExecPostgresCommand(port, "postgres", "DROP DATABASE "+quoted); err != nil {
+			return fmt.Errorf("dropping database %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -4,21 +4,84 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os/exec"
+	"os"
+	"slices"
 	"strings"
 )
 
-func (s *AgentService) DeleteBranch(ctx context.Context, template string, branchName string) (bool, error) {
+// DeletePlan lists what DeleteBranch would remove for a branch.
+type DeletePlan struct {
+	Dataset     string
+	Snapshot    string
+	Mountpoint  string
+	ServiceName string
+	Port        string
+	Promoted    bool
+	// Clones of the branch snapshots, destroyed along with the branch
+	DependentClones []string
+	// Replicas of the branch, deleted along with it
+	Replicas []string
+}
+
+// DeleteBranch removes a branch and its replicas, returning the names of the
+// replicas. Frozen branches are only deleted with force.
+func (s *AgentService) DeleteBranch(ctx context.Context, template string, branchName string, deletedBy string, force bool) (bool, []string, error) {
 	branchName, err := ValidateBranchName(branchName)
 	if err != nil {
-		return false, fmt.Errorf("invalid branch name: %w", err)
+		return false, nil, fmt.Errorf("invalid branch name: %w", err)
 	}
 
+	_, done := s.beginOperation(OpDeleteBranch, branchTarget(template, branchName), deletedBy)
+	defer done()
+
 	// Check if template exists
 	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
 	if err != nil {
-		return false, fmt.Errorf("checking existing template: %w", err)
+		return false, nil, fmt.Errorf("checking existing template: %w", err)
+	}
+	if branch != nil && branch.Frozen && !force {
+		return false, nil, fmt.Errorf("branch '%s' is frozen, unfreeze it or delete it with --force", branchName)
+	}
+
+	// Leftovers of a branch whose metadata was never written are removed too
+	found := branch != nil || datasetExists(GetBranchDataset(template, branchName)) || ServiceExists(GetBranchServiceName(template, branchName))
+
+	replicas, err := s.removeReplicas(ctx, template, branchName)
+	if err != nil {
+		return false, replicas, err
+	}
+
+	if err := removeBranch(template, branchName, branch); err != nil {
+		return false, replicas, err
+	}
+	if !found {
+		return false, replicas, nil
+	}
+
+	auditEvent("branch_delete", auditedBranch(branch))
+	s.updatePortConflicts()
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_delete",
+		Template: template,
+		Branch:   branchName,
+		User:     deletedBy,
+	})
+
+	return true, replicas, nil
+}
+
+// removeBranch tears down everything a branch may have created. branch is
+// nil when its metadata was never written.
+func removeBranch(template, branchName string, branch *BranchInfo) error {
+	// A promoted branch owns the snapshot the template now depends on.
+	// Hand it back to the template so the branch can be destroyed below.
+	// Done first, so that a failure leaves the branch running and reachable.
+	if branch != nil && branch.Promoted {
+		if err := demoteBranch(template, branchName); err != nil {
+			return err
+		}
 	}
+
 	if branch != nil {
 		if err := closeFirewallPort(branch.Port); err != nil {
 			log.Printf("Warning: failed to close firewall port %s: %v", branch.Port, err)
@@ -33,21 +96,131 @@ func (s *AgentService) DeleteBranch(ctx context.Context, template string, branch
 		}
 	}
 
-	snapshotName := GetSnapshotName(template, branchName)
+	snapshotName := branchOriginSnapshot(template, branchName)
 	if snapshotExists(snapshotName) {
 		// -R to destroy the snapshot and its clones
 		if err := destroyDataset(snapshotName, "-R"); err != nil {
-			return false, err
+			return err
+		}
+	}
+
+	// Imported branches are standalone datasets, not clones of the snapshot
+	branchDataset := GetBranchDataset(template, branchName)
+	if datasetExists(branchDataset) {
+		if err := destroyDataset(branchDataset, "-r"); err != nil {
+			return err
+		}
+	}
+
+	// A replica is cloned from a snapshot of its parent rather than the template
+	if branch != nil && branch.ReplicaOf != "" {
+		replicaSnapshot := GetReplicaSnapshot(template, branch.ReplicaOf, branchName)
+		if snapshotExists(replicaSnapshot) {
+			if err := destroyDataset(replicaSnapshot); err != nil {
+				return err
+			}
 		}
 	}
 
 	mountpoint := GetBranchMountpoint(template, branchName)
-	output, err := exec.Command("sudo", "rmdir", mountpoint).CombinedOutput()
+	if branch != nil && branch.BranchPath != "" {
+		mountpoint = branch.BranchPath
+	}
+	output, err := privileged("rmdir", mountpoint).CombinedOutput()
 	if err != nil && !strings.Contains(string(output), "No such file or directory") {
-		return false, fmt.Errorf("failed to remove mountpoint %s: %v", mountpoint, err)
+		return fmt.Errorf("failed to remove mountpoint %s: %v", mountpoint, err)
+	}
+
+	return nil
+}
+
+// branchOriginSnapshot returns the snapshot a branch was cloned from. That's
+// the template snapshot named after the branch, unless promoting another
+// branch moved it onto that branch's dataset.
+func branchOriginSnapshot(template, branchName string) string {
+	origin, err := getOrigin(GetBranchDataset(template, branchName))
+	if err == nil && strings.HasSuffix(origin, "@"+branchName) {
+		return origin
 	}
+	return GetSnapshotName(template, branchName)
+}
 
-	auditEvent("branch_delete", branch)
+// PlanBranchDeletion reports what DeleteBranch would remove without changing anything.
+func (s *AgentService) PlanBranchDeletion(ctx context.Context, template string, branchName string) (*DeletePlan, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	branchDataset := GetBranchDataset(template, branchName)
+	branch, err := s.getBranchMetadata(branchDataset)
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+
+	plan := &DeletePlan{}
+	if branch != nil {
+		plan.Port = branch.Port
+		plan.Promoted = branch.Promoted
+	}
+
+	if datasetExists(branchDataset) {
+		plan.Dataset = branchDataset
+	}
+
+	snapshotName := branchOriginSnapshot(template, branchName)
+	snapshots := []string{}
+	if snapshotExists(snapshotName) {
+		plan.Snapshot = snapshotName
+		snapshots = append(snapshots, snapshotName)
+	}
+
+	// Snapshots taken on the branch itself (or moved to it by a promote)
+	if plan.Dataset != "" {
+		branchSnapshots, err := listSnapshots(branchDataset)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, branchSnapshots...)
+	}
+
+	replicas, err := s.branchReplicas(ctx, template, branchName)
+	if err != nil {
+		return nil, err
+	}
+	replicaDatasets := make([]string, len(replicas))
+	for i, replica := range replicas {
+		plan.Replicas = append(plan.Replicas, replica.BranchName)
+		replicaDatasets[i] = GetBranchDataset(template, replica.BranchName)
+	}
+
+	templateDataset := GetTemplateDataset(template)
+	for _, snapshot := range snapshots {
+		clones, err := getClones(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		for _, clone := range clones {
+			// The template is handed its snapshot back rather than destroyed
+			if clone == branchDataset || clone == templateDataset || slices.Contains(replicaDatasets, clone) || slices.Contains(plan.DependentClones, clone) {
+				continue
+			}
+			plan.DependentClones = append(plan.DependentClones, clone)
+		}
+	}
+
+	serviceName := GetBranchServiceName(template, branchName)
+	if ServiceExists(serviceName) {
+		plan.ServiceName = serviceName
+	}
+
+	mountpoint := GetBranchMountpoint(template, branchName)
+	if branch != nil && branch.BranchPath != "" {
+		mountpoint = branch.BranchPath
+	}
+	if _, err := os.Stat(mountpoint); err == nil {
+		plan.Mountpoint = mountpoint
+	}
 
-	return true, nil
+	return plan, nil
 }
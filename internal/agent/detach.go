@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+)
+
+// detachedBranchPorts returns the ports recorded by detached branches, which
+// attach expects to find free.
+func (s *AgentService) detachedBranchPorts(ctx context.Context) map[string]bool {
+	ports := make(map[string]bool)
+	branches, _ := s.ListBranches(ctx, "")
+	for _, branch := range branches {
+		if branch.Detached && branch.Port != "" {
+			ports[branch.Port] = true
+		}
+	}
+	return ports
+}
+
+// DetachBranch removes a branch's service and firewall rule but keeps its
+// dataset and metadata, so the data can be inspected or the branch attached
+// again later. Detaching an already detached branch does nothing.
+func (s *AgentService) DetachBranch(ctx context.Context, template string, branchName string, detachedBy string) (bool, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return false, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	op, done := s.beginOperation(OpDetachBranch, branchTarget(template, branchName), detachedBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return false, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return false, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return false, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if branch.Detached {
+		return false, nil
+	}
+
+	if err := closeFirewallPort(branch.Port); err != nil {
+		log.Printf("Warning: failed to close firewall port %s: %v", branch.Port, err)
+	}
+
+	serviceName := GetBranchServiceName(template, branchName)
+	if ServiceExists(serviceName) {
+		if err := DeleteService(serviceName); err != nil {
+			return false, fmt.Errorf("removing systemd service: %w", err)
+		}
+	}
+
+	branch.Detached = true
+	branch.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return false, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	auditEvent("branch_detach", map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"port":          branch.Port,
+		"detached_by":   detachedBy,
+	})
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_detach",
+		Template: template,
+		Branch:   branchName,
+		User:     detachedBy,
+	})
+
+	return true, nil
+}
+
+// AttachResult is a branch brought back by AttachBranch.
+type AttachResult struct {
+	*BranchInfo
+	// The branch's stored port was taken, so it moved from this one to Port
+	PreviousPort string
+}
+
+// AttachBranch brings back a branch whose dataset and metadata exist but whose
+// service doesn't run, after DetachBranch, a crash or a quicd reinstall. The
+// branch keeps its stored port while it's free, and gets a new one otherwise.
+func (s *AgentService) AttachBranch(ctx context.Context, template string, branchName string, attachedBy string) (*AttachResult, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	op, done := s.beginOperation(OpAttachBranch, branchTarget(template, branchName), attachedBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found: no dataset with quic metadata", branchName)
+	}
+
+	serviceName := GetBranchServiceName(template, branchName)
+	if !branch.Detached && GetServiceStatus(serviceName) == "active" {
+		return &AttachResult{BranchInfo: branch}, nil
+	}
+
+	// A detached branch gave up its firewall rule, so a rule on its port
+	// belongs to another branch now
+	result := &AttachResult{BranchInfo: branch}
+	if err := checkPortFree(branch.Port, !branch.Detached); err != nil {
+		port, findErr := s.findAvailablePort(ctx)
+		if findErr != nil {
+			return nil, fmt.Errorf("%w, and finding another port: %w", err, findErr)
+		}
+		log.Printf("Branch %s/%s: %v, attaching on port %s", template, branchName, err, port)
+		result.PreviousPort = branch.Port
+		branch.Port = port
+	}
+
+	if err := CreateBranchService(template, branchName, branch.BranchPath, branch.Port); err != nil {
+		return nil, fmt.Errorf("creating systemd service: %w", err)
+	}
+	// After a crash the branch's postmaster.pid outlives its server
+	if err := startBranchService(serviceName, branch.BranchPath); err != nil {
+		return nil, fmt.Errorf("starting systemd service: %w", err)
+	}
+	if err := waitForPostgreSQLReady(branch.BranchPath, branchStartTimeout); err != nil {
+		return nil, fmt.Errorf("waiting for branch to accept connections: %w\n%s", err, ServiceLogs(serviceName, 20))
+	}
+
+	if err := openFirewallPort(branch.Port); err != nil {
+		return nil, fmt.Errorf("opening firewall port: %w", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	branch.Detached = false
+	branch.UpdatedAt = now
+	branch.LastAccessedAt = now
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return nil, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	auditEvent("branch_attach", map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"port":          branch.Port,
+		"previous_port": result.PreviousPort,
+		"attached_by":   attachedBy,
+	})
+	s.updatePortConflicts()
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_attach",
+		Template: template,
+		Branch:   branchName,
+		User:     attachedBy,
+		Host:     s.PublicHost(ctx),
+		Port:     branch.Port,
+	})
+
+	return result, nil
+}
+
+// checkPortFree fails when something listens on port, or when it has a
+// firewall rule that isn't the branch's own.
+func checkPortFree(port string, ownRule bool) error {
+	conn, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("port %s is already in use", port)
+	}
+	conn.Close()
+
+	if ownRule {
+		return nil
+	}
+	taken, err := hasUFWRule(port)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("port %s is already taken by another branch", port)
+	}
+	return nil
+}
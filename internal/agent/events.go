@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// Events a subscriber may fall behind by before new ones are dropped
+const eventBufferSize = 64
+
+// LifecycleEvent reports a branch or template change to webhooks and
+// event stream subscribers. It never carries credentials.
+type LifecycleEvent struct {
+	Event     string `json:"event"`
+	Template  string `json:"template"`
+	Branch    string `json:"branch,omitempty"`
+	User      string `json:"user"`
+	Timestamp string `json:"timestamp"`
+	Host      string `json:"host,omitempty"`
+	Port      string `json:"port,omitempty"`
+	// Events dropped before this one because the subscriber fell behind.
+	// Only set on events delivered through SubscribeEvents.
+	Dropped int `json:"-"`
+}
+
+// EventSubscription receives lifecycle events until closed.
+type EventSubscription struct {
+	C <-chan LifecycleEvent
+
+	ch      chan LifecycleEvent
+	dropped int
+}
+
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*EventSubscription]struct{}
+}
+
+// SubscribeEvents starts receiving lifecycle events. A subscriber that doesn't
+// keep up loses events rather than slowing down the operations emitting them.
+func (s *AgentService) SubscribeEvents() *EventSubscription {
+	b := &s.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs == nil {
+		b.subs = make(map[*EventSubscription]struct{})
+	}
+	ch := make(chan LifecycleEvent, eventBufferSize)
+	sub := &EventSubscription{C: ch, ch: ch}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// UnsubscribeEvents stops delivery to sub.
+func (s *AgentService) UnsubscribeEvents(sub *EventSubscription) {
+	b := &s.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
+
+// publishEvent fans event out to subscribers and the webhook.
+func (s *AgentService) publishEvent(event LifecycleEvent) {
+	event.Timestamp = time.Now().UTC().Format(time.RFC3339)
+
+	b := &s.events
+	b.mu.Lock()
+	for sub := range b.subs {
+		e := event
+		e.Dropped = sub.dropped
+		select {
+		case sub.ch <- e:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+	b.mu.Unlock()
+
+	s.notifyWebhook(event)
+}
@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+const exportChunkSize = 64 * 1024
+
+func (s *AgentService) ExportBranch(req *pb.ExportBranchRequest, stream pb.QuicService_ExportBranchServer, user string) error {
+	branchName, err := ValidateBranchName(req.CloneName)
+	if err != nil {
+		return fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	formatFlag, err := pgDumpFormatFlag(req.Format)
+	if err != nil {
+		return err
+	}
+
+	if err := requireBinaries(pgDumpPath(PgVersion)); err != nil {
+		return err
+	}
+
+	_, done := s.beginOperation(OpExportBranch, branchTarget(req.RestoreName, branchName), user)
+	defer done()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(req.RestoreName, branchName))
+	if err != nil {
+		return fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return fmt.Errorf("branch '%s' not found", branchName)
+	}
+	s.touchBranch(branch)
+
+	database := req.Database
+	if database != "" {
+		if err := ValidateDatabaseName(database); err != nil {
+			return err
+		}
+	} else {
+		templatePath, err := GetMountpoint(GetTemplateDataset(req.RestoreName))
+		if err != nil {
+			return fmt.Errorf("getting template path: %w", err)
+		}
+		database, err = getTemplateDatabase(templatePath)
+		if err != nil {
+			return fmt.Errorf("getting template database: %w", err)
+		}
+	}
+
+	cmd := asPostgresContext(stream.Context(), pgDumpPath(PgVersion),
+		"-h", PgSocketDir,
+		"-p", branch.Port,
+		dbnameConninfo(database),
+		formatFlag)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start pg_dump: %w", err)
+	}
+
+	// Stream in fixed-size chunks so large dumps never sit in memory
+	var totalBytes int64
+	buf := make([]byte, exportChunkSize)
+	for {
+		n, readErr := stdout.Read(buf)
+		if n > 0 {
+			if err := stream.Send(&pb.ExportBranchResponse{Data: buf[:n]}); err != nil {
+				cmd.Process.Kill()
+				cmd.Wait()
+				return fmt.Errorf("sending export chunk: %w", err)
+			}
+			totalBytes += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return fmt.Errorf("reading pg_dump output: %w", readErr)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("pg_dump failed: %w (output: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	auditEvent("branch_export", map[string]interface{}{
+		"template_name": req.RestoreName,
+		"branch_name":   branchName,
+		"database":      database,
+		"format":        req.Format,
+		"bytes":         totalBytes,
+		"exported_by":   user,
+	})
+
+	return nil
+}
+
+func pgDumpFormatFlag(format string) (string, error) {
+	switch format {
+	case "", "custom":
+		return "--format=custom", nil
+	case "plain":
+		return "--format=plain", nil
+	default:
+		return "", fmt.Errorf("unsupported export format '%s'. Use custom or plain", format)
+	}
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type extensionSpec struct {
+	// Must be listed in shared_preload_libraries to work
+	preload bool
+	// Has a CREATE EXTENSION counterpart (auto_explain is a plain module)
+	create bool
+}
+
+// Extensions considered safe to enable on branches.
+var allowedExtensions = map[string]extensionSpec{
+	"pg_stat_statements": {preload: true, create: true},
+	"auto_explain":       {preload: true, create: false},
+	"pg_prewarm":         {preload: true, create: true},
+	"pg_buffercache":     {create: true},
+	"pg_trgm":            {create: true},
+	"pgcrypto":           {create: true},
+	"citext":             {create: true},
+	"hstore":             {create: true},
+	"btree_gin":          {create: true},
+	"btree_gist":         {create: true},
+	"uuid-ossp":          {create: true},
+}
+
+func ValidateExtensions(extensions []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var validated []string
+
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || seen[ext] {
+			continue
+		}
+
+		if _, ok := allowedExtensions[ext]; !ok {
+			return nil, fmt.Errorf("extension '%s' is not allowed. Allowed extensions: %s", ext, strings.Join(allowedExtensionNames(), ", "))
+		}
+
+		seen[ext] = true
+		validated = append(validated, ext)
+	}
+
+	return validated, nil
+}
+
+func allowedExtensionNames() []string {
+	var names []string
+	for name := range allowedExtensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sharedPreloadLibraries(extensions []string) string {
+	var libraries []string
+	for _, ext := range extensions {
+		if allowedExtensions[ext].preload {
+			libraries = append(libraries, ext)
+		}
+	}
+	return fmt.Sprintf("'%s'", strings.Join(libraries, ","))
+}
+
+func createExtensions(port, database string, extensions []string) error {
+	for _, ext := range extensions {
+		if !allowedExtensions[ext].create {
+			continue
+		}
+
+		sql := fmt.Sprintf(`CREATE EXTENSION IF NOT EXISTS "%s";`, ext)
+		if _, err := ExecPostgresCommand(port, database, sql); err != nil {
+			return fmt.Errorf("creating extension %s: %w", ext, err)
+		}
+	}
+
+	return nil
+}
+
+// Reads the database name recorded by template setup in .quic-init-meta.json
+func getTemplateDatabase(templatePath string) (string, error) {
+	result, err := loadTemplateMetadata(templatePath)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Database == "" {
+		return "postgres", nil
+	}
+
+	return result.Database, nil
+}
@@ -1,30 +1,137 @@
 package agent
 
 import (
+	"cmp"
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
 )
 
+const (
+	FirewallUFW  = "ufw"
+	FirewallNone = "none"
+)
+
+// Firewall backend that opens branch ports. Set from the agent config at
+// startup, see ApplyFirewallConfig.
+var firewallBackend = FirewallUFW
+
+// Serializes rule changes. ufw rewrites its rule files on every change, so
+// concurrent deletions can lose each other's rules.
+var firewallMutex sync.Mutex
+
+// FirewallConfig selects how branch ports are opened.
+type FirewallConfig struct {
+	// ufw (default), or none when ports are controlled outside the host,
+	// e.g. by cloud security groups
+	Backend string `json:"backend"`
+}
+
+func (c FirewallConfig) validate() error {
+	switch c.Backend {
+	case "", FirewallUFW, FirewallNone:
+		return nil
+	default:
+		return fmt.Errorf("backend must be '%s' or '%s', got %q", FirewallUFW, FirewallNone, c.Backend)
+	}
+}
+
+// ApplyFirewallConfig selects the firewall backend. Must be called before serving requests.
+func ApplyFirewallConfig(c FirewallConfig) {
+	firewallBackend = cmp.Or(c.Backend, FirewallUFW)
+}
+
+// firewallBinaries lists the binaries the firewall backend runs.
+func firewallBinaries() []string {
+	if firewallBackend == FirewallUFW {
+		return []string{"ufw"}
+	}
+	return nil
+}
+
+// CheckFirewall fails when the firewall backend can't gate branch ports.
+func CheckFirewall() error {
+	if firewallBackend == FirewallNone {
+		return nil
+	}
+	_, err := ufwStatus()
+	return err
+}
+
+// ufwStatus returns the output of `ufw status`, failing when UFW is missing
+// or inactive. An inactive UFW accepts rules without applying them, so
+// branch ports would look firewalled while every port is reachable.
+func ufwStatus() (string, error) {
+	if _, err := exec.LookPath("ufw"); err != nil {
+		return "", fmt.Errorf("ufw is not installed on this host; run `quic host setup`, or set firewall.backend to '%s' in %s when ports are controlled elsewhere", FirewallNone, AgentConfigPath)
+	}
+
+	output, err := privileged("ufw", "status").Output()
+	if err != nil {
+		return "", fmt.Errorf("querying ufw status: %w", err)
+	}
+	if !strings.HasPrefix(string(output), "Status: active") {
+		return "", fmt.Errorf("ufw is inactive, so branch ports wouldn't be firewalled. Enable it with `sudo ufw enable`, or set firewall.backend to '%s' in %s when ports are controlled elsewhere", FirewallNone, AgentConfigPath)
+	}
+	return string(output), nil
+}
+
 func openFirewallPort(port string) error {
+	if firewallBackend == FirewallNone {
+		return nil
+	}
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
+
+	if _, err := ufwStatus(); err != nil {
+		return err
+	}
 	portSpec := fmt.Sprintf("%s/tcp", port)
-	cmd := exec.Command("sudo", "ufw", "allow", portSpec)
-	return cmd.Run()
+	if output, err := privileged("ufw", "allow", portSpec).CombinedOutput(); err != nil {
+		return fmt.Errorf("ufw allow %s: %w (output: %s)", portSpec, err, strings.TrimSpace(string(output)))
+	}
+	return nil
 }
 
-func hasUFWRule(port string) bool {
-	cmd := exec.Command("sudo", "ufw", "status")
-	output, err := cmd.Output()
+// firewalledPorts returns the ports with a UFW rule, nil with no firewall backend.
+func firewalledPorts() (map[string]bool, error) {
+	if firewallBackend == FirewallNone {
+		return nil, nil
+	}
+	output, err := ufwStatus()
 	if err != nil {
-		return false // If we can't check UFW, assume no rule exists
+		return nil, err
 	}
 
-	portStr := fmt.Sprintf("%s/tcp", port)
-	return strings.Contains(string(output), portStr)
+	// Rules are listed like "5433/tcp   ALLOW   Anywhere", and again with (v6)
+	ports := make(map[string]bool)
+	for line := range strings.SplitSeq(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if port, ok := strings.CutSuffix(fields[0], "/tcp"); ok {
+			ports[port] = true
+		}
+	}
+	return ports, nil
+}
+
+// hasUFWRule reports whether port has a UFW rule. Failing to query UFW is an
+// error rather than no rule, which would hand out ports of stopped branches.
+func hasUFWRule(port string) (bool, error) {
+	ports, err := firewalledPorts()
+	return ports[port], err
 }
 
 func closeFirewallPort(port string) error {
+	if firewallBackend == FirewallNone {
+		return nil
+	}
+	firewallMutex.Lock()
+	defer firewallMutex.Unlock()
 	portSpec := fmt.Sprintf("%s/tcp", port)
-	cmd := exec.Command("sudo", "ufw", "delete", "allow", portSpec)
+	cmd := privileged("ufw", "delete", "allow", portSpec)
 	return cmd.Run()
 }
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const readOnlySetting = "default_transaction_read_only"
+
+// FreezeBranch makes sessions of a branch read-only by default and marks it
+// frozen, so quic refuses rollbacks and deletes it only when forced.
+// Unfreezing reverses both. It reports whether the branch changed.
+//
+// The dataset itself stays writable: PostgreSQL writes to its data directory
+// even when no transaction does, and doesn't start on a read-only one.
+func (s *AgentService) FreezeBranch(ctx context.Context, template string, branchName string, frozen bool, frozenBy string) (bool, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return false, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	op, done := s.beginOperation(OpFreezeBranch, branchTarget(template, branchName), frozenBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return false, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return false, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return false, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if branch.Frozen == frozen {
+		return false, nil
+	}
+	if branch.Detached {
+		return false, fmt.Errorf("branch '%s' is detached, attach it first", branchName)
+	}
+
+	if err := setReadOnlyDefault(branch.BranchPath, frozen); err != nil {
+		return false, err
+	}
+
+	// Restart rather than reload, so sessions opened before the freeze can't
+	// keep writing
+	serviceName := GetBranchServiceName(template, branchName)
+	if err := StopService(serviceName); err != nil {
+		return false, err
+	}
+	if err := startBranchService(serviceName, branch.BranchPath); err != nil {
+		return false, err
+	}
+	if err := waitForPostgreSQLReady(branch.BranchPath, branchStartTimeout); err != nil {
+		return false, fmt.Errorf("waiting for branch to accept connections: %w\n%s", err, ServiceLogs(serviceName, 20))
+	}
+
+	branch.Frozen = frozen
+	branch.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	branch.LastAccessedAt = branch.UpdatedAt
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return false, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	event := "branch_freeze"
+	if !frozen {
+		event = "branch_unfreeze"
+	}
+	auditEvent(event, map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"user":          frozenBy,
+	})
+
+	return true, nil
+}
+
+// setReadOnlyDefault sets or removes default_transaction_read_only in a
+// branch's postgresql.auto.conf. It takes effect on the next start.
+func setReadOnlyDefault(branchPath string, readOnly bool) error {
+	value := ""
+	if readOnly {
+		value = "on"
+	}
+	return updateAutoConf(branchPath, map[string]string{readOnlySetting: value})
+}
@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const idempotencyKeyTTL = 10 * time.Minute
+
+// Keys are scoped to the user, so one user's key can't return another's branch
+type idempotencyScope struct {
+	user string
+	key  string
+}
+
+type idempotencyEntry struct {
+	template  string
+	branch    string
+	done      chan struct{}
+	result    *BranchInfo
+	err       error
+	expiresAt time.Time
+}
+
+// withIdempotencyKey runs fn once per user and key. Duplicate requests with the
+// same key wait for the in-flight call and get its result instead of repeating
+// the work. Successful results are remembered for idempotencyKeyTTL, or until
+// the branch is deleted; failures are forgotten so the caller can retry.
+func (s *AgentService) withIdempotencyKey(ctx context.Context, user, key, template, branch string, fn func() (*BranchInfo, error)) (*BranchInfo, error) {
+	scoped := idempotencyScope{user: user, key: key}
+
+	var entry *idempotencyEntry
+	for {
+		s.idempotencyMutex.Lock()
+		s.purgeExpiredIdempotencyKeys()
+		existing, ok := s.idempotencyKeys[scoped]
+		if !ok {
+			entry = &idempotencyEntry{
+				template: template,
+				branch:   branch,
+				done:     make(chan struct{}),
+			}
+			s.idempotencyKeys[scoped] = entry
+			s.idempotencyMutex.Unlock()
+			break
+		}
+		s.idempotencyMutex.Unlock()
+
+		// Checked without the lock, it runs zfs and would hold up every
+		// other request with a key
+		if existing.branchGone() {
+			s.idempotencyMutex.Lock()
+			// Unless another request replaced it meanwhile
+			if s.idempotencyKeys[scoped] == existing {
+				delete(s.idempotencyKeys, scoped)
+			}
+			s.idempotencyMutex.Unlock()
+			continue
+		}
+
+		if existing.template != template || existing.branch != branch {
+			return nil, fmt.Errorf("idempotency key already used for branch '%s' of template '%s'", existing.branch, existing.template)
+		}
+
+		select {
+		case <-existing.done:
+			return existing.result, existing.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	entry.result, entry.err = fn()
+
+	s.idempotencyMutex.Lock()
+	if entry.err != nil {
+		delete(s.idempotencyKeys, scoped)
+	} else {
+		entry.expiresAt = time.Now().Add(idempotencyKeyTTL)
+	}
+	s.idempotencyMutex.Unlock()
+	close(entry.done)
+
+	return entry.result, entry.err
+}
+
+// branchGone reports whether a successful call's branch was deleted since, so
+// the key creates it again rather than returning the deleted branch.
+func (e *idempotencyEntry) branchGone() bool {
+	select {
+	case <-e.done:
+		return e.err == nil && !datasetExists(GetBranchDataset(e.template, e.branch))
+	default:
+		return false
+	}
+}
+
+// Must be called with idempotencyMutex held
+func (s *AgentService) purgeExpiredIdempotencyKeys() {
+	now := time.Now()
+	for key, entry := range s.idempotencyKeys {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(s.idempotencyKeys, key)
+		}
+	}
+}
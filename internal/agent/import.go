@@ -0,0 +1,359 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+const BranchSourceImport = "import"
+
+// ImportBranch creates a branch from an uploaded dump instead of a template
+// snapshot: a fresh initdb on its own dataset, restored from the dump. The
+// branch lives under the template's dataset so naming, listing, and deletion
+// work like any other branch.
+func (s *AgentService) ImportBranch(stream pb.QuicService_ImportBranchServer, user string) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return fmt.Errorf("receiving import header: %w", err)
+	}
+	header := first.GetHeader()
+	if header == nil {
+		return fmt.Errorf("first import message must be the header")
+	}
+
+	branchName, err := ValidateBranchName(header.CloneName)
+	if err != nil {
+		return fmt.Errorf("invalid branch name: %w", err)
+	}
+	template := header.RestoreName
+
+	if header.PgVersion != "" && header.PgVersion != PgVersion {
+		return fmt.Errorf("PostgreSQL %s is not available on this host, only %s", header.PgVersion, PgVersion)
+	}
+	if header.Format != "custom" && header.Format != "plain" {
+		return fmt.Errorf("unsupported dump format '%s'. Use custom or plain", header.Format)
+	}
+	if header.Database == "" {
+		return fmt.Errorf("database is required")
+	}
+	if err := ValidateDatabaseName(header.Database); err != nil {
+		return err
+	}
+
+	if err := requireBinaries(append(firewallBinaries(), initdbPath(PgVersion), pgRestorePath(PgVersion))...); err != nil {
+		return err
+	}
+
+	op, done := s.beginOperation(OpImportBranch, branchTarget(template, branchName), user)
+	defer done()
+
+	if !datasetExists(GetTemplateDataset(template)) {
+		return fmt.Errorf("template '%s' not found", template)
+	}
+
+	// Fail before the upload when the branch is already taken
+	existing, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return fmt.Errorf("checking existing branch: %w", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("branch '%s' already exists", branchName)
+	}
+
+	s.sendImportLog(stream, "Receiving dump...")
+	dumpPath, size, err := receiveDump(stream)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dumpPath)
+	s.sendImportLog(stream, fmt.Sprintf("✓ Received %d bytes", size))
+
+	if !s.lockForOperation(op) {
+		return ErrServiceRestarting
+	}
+	// Released before the restore, which can take long for large dumps
+	unlock := sync.OnceFunc(s.checkoutMutex.Unlock)
+	defer unlock()
+
+	if datasetExists(GetBranchDataset(template, branchName)) {
+		return fmt.Errorf("branch '%s' already exists", branchName)
+	}
+	if err := checkServiceNameFree(template, branchName); err != nil {
+		return err
+	}
+
+	if err := s.checkBranchQuota(stream.Context(), template); err != nil {
+		return err
+	}
+
+	branch, err := s.createImportedBranch(stream, template, branchName, header.Database, user)
+	if err != nil {
+		return err
+	}
+	unlock()
+
+	s.sendImportLog(stream, "Restoring dump...")
+	if err := restoreDump(branch.Port, header.Database, header.Format, dumpPath); err != nil {
+		s.checkoutMutex.Lock()
+		defer s.checkoutMutex.Unlock()
+		if err := removeBranch(template, branchName, branch); err != nil {
+			log.Printf("Warning: failed to clean up branch %s after a failed import: %v", branchName, err)
+		}
+		return err
+	}
+	s.sendImportLog(stream, "✓ Dump restored")
+
+	auditEvent("branch_import", map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"database":      header.Database,
+		"format":        header.Format,
+		"bytes":         size,
+		"imported_by":   user,
+	})
+	s.updatePortConflicts()
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_import",
+		Template: template,
+		Branch:   branchName,
+		User:     user,
+		Host:     s.PublicHost(stream.Context()),
+		Port:     branch.Port,
+	})
+
+	return stream.Send(&pb.ImportBranchResponse{
+		Message: &pb.ImportBranchResponse_Result{
+			Result: &pb.ImportBranchResult{
+				ConnectionString: fmt.Sprintf("postgresql://admin:%s@localhost:%s/%s", branch.AdminPassword, branch.Port, header.Database),
+				Host:             s.PublicHost(stream.Context()),
+			},
+		},
+	})
+}
+
+// createImportedBranch sets up an empty, running instance with the usual
+// service, firewall, metadata, and admin role.
+func (s *AgentService) createImportedBranch(stream pb.QuicService_ImportBranchServer, template, branchName, database, user string) (*BranchInfo, error) {
+	branchDataset := GetBranchDataset(template, branchName)
+	mountpoint := GetBranchMountpoint(template, branchName)
+
+	if err := checkPoolSpace(ZPool); err != nil {
+		return nil, err
+	}
+
+	s.sendImportLog(stream, "Initializing PostgreSQL data directory...")
+	if output, err := privileged("zfs", "create", "-o", "mountpoint="+mountpoint, branchDataset).CombinedOutput(); err != nil {
+		if isOutOfSpace(string(output)) {
+			return nil, poolFullError()
+		}
+		return nil, fmt.Errorf("creating ZFS dataset: %s", output)
+	}
+
+	// Undo the partial branch. Its port is only closed once picked.
+	var branch *BranchInfo
+	fail := func(cause error) (*BranchInfo, error) {
+		if err := removeBranch(template, branchName, branch); err != nil {
+			log.Printf("Warning: failed to clean up branch %s: %v", branchName, err)
+		}
+		return nil, cause
+	}
+
+	if err := privileged("chown", "postgres:postgres", mountpoint).Run(); err != nil {
+		return fail(fmt.Errorf("setting ownership: %w", err))
+	}
+
+	initdb := asPostgres(initdbPath(PgVersion),
+		"-D", mountpoint,
+		"--encoding=UTF8",
+		"--auth-local=peer",
+		"--auth-host="+s.Config().PgHba.authMethod())
+	if output, err := initdb.CombinedOutput(); err != nil {
+		return fail(fmt.Errorf("initdb failed: %w (output: %s)", err, strings.TrimSpace(string(output))))
+	}
+
+	settings := map[string]string{
+		"ssl_min_protocol_version": s.Config().TLS.postgresMinVersion(),
+		"password_encryption":      s.Config().PgHba.passwordEncryption(),
+	}
+	if err := configureBranchAccess(mountpoint, settings, s.Config().PgHba); err != nil {
+		return fail(err)
+	}
+
+	port, err := s.findAvailablePort(stream.Context())
+	if err != nil {
+		return fail(fmt.Errorf("finding available port: %w", err))
+	}
+
+	adminPassword, err := generateSecurePassword(s.Config().AdminPassword.length())
+	if err != nil {
+		return fail(fmt.Errorf("generating password: %w", err))
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	branch = &BranchInfo{
+		TemplateName:  template,
+		BranchName:    branchName,
+		Port:          port,
+		BranchPath:    mountpoint,
+		AdminPassword: adminPassword,
+		RoleMode:      RoleModeSuperuser,
+		Source:        BranchSourceImport,
+		CreatedBy:     user,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return fail(fmt.Errorf("saving branch metadata: %w", err))
+	}
+
+	if err := CreateBranchService(template, branchName, mountpoint, port); err != nil {
+		return fail(fmt.Errorf("creating systemd service: %w", err))
+	}
+
+	if err := StartService(GetBranchServiceName(template, branchName)); err != nil {
+		return fail(fmt.Errorf("starting systemd service: %w", err))
+	}
+
+	if err := waitForPostgreSQLReady(mountpoint, branchStartTimeout); err != nil {
+		return fail(err)
+	}
+	s.sendImportLog(stream, fmt.Sprintf("✓ PostgreSQL started on port %s", port))
+
+	if err := openFirewallPort(port); err != nil {
+		return fail(fmt.Errorf("opening firewall port: %w", err))
+	}
+
+	if _, err := ExecPostgresCommand(port, "postgres", fmt.Sprintf("CREATE DATABASE %s", quoteIdentifier(database))); err != nil {
+		return fail(fmt.Errorf("creating database: %w", err))
+	}
+
+	if err := s.setupAdminUser(branch, database); err != nil {
+		return fail(fmt.Errorf("setting up admin user: %w", err))
+	}
+
+	return branch, nil
+}
+
+// Left free on the filesystem receiving an upload, so an import can't fill it
+const importReserveBytes = 1024 * 1024 * 1024
+
+// receiveDump writes the uploaded chunks to a temp file only quicd can read.
+// The upload is refused once it outgrows importSizeLimit.
+func receiveDump(stream pb.QuicService_ImportBranchServer) (string, int64, error) {
+	file, err := os.CreateTemp("", "quic-import-*.dump")
+	if err != nil {
+		return "", 0, fmt.Errorf("creating temp file: %w", err)
+	}
+	fail := func(err error) (string, int64, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return "", 0, err
+	}
+
+	maxSize, err := importSizeLimit(filepath.Dir(file.Name()))
+	if err != nil {
+		return fail(err)
+	}
+
+	var size int64
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("receiving dump: %w", err))
+		}
+
+		if size+int64(len(msg.GetData())) > maxSize {
+			return fail(fmt.Errorf("dump is larger than the %d MiB of free space this host has for it", maxSize/(1024*1024)))
+		}
+		n, err := file.Write(msg.GetData())
+		if err != nil {
+			return fail(fmt.Errorf("writing dump: %w", err))
+		}
+		size += int64(n)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", 0, fmt.Errorf("closing dump: %w", err)
+	}
+
+	return file.Name(), size, nil
+}
+
+// importSizeLimit is the largest dump dir can receive while keeping
+// importReserveBytes free. Bounded by the pool's free space too, the restored
+// data being at least as large as the dump.
+func importSizeLimit(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, fmt.Errorf("checking free space of %s: %w", dir, err)
+	}
+	limit := int64(stat.Bavail)*int64(stat.Bsize) - importReserveBytes
+
+	poolFree, err := getPoolFree(ZPool)
+	if err != nil {
+		return 0, err
+	}
+	return max(min(limit, poolFree), 0), nil
+}
+
+// restoreDump feeds the dump to pg_restore or psql on stdin, so the file
+// never has to be readable by the postgres user.
+func restoreDump(port, database, format, dumpPath string) error {
+	dump, err := os.Open(dumpPath)
+	if err != nil {
+		return fmt.Errorf("opening dump: %w", err)
+	}
+	defer dump.Close()
+
+	var cmd *exec.Cmd
+	if format == "plain" {
+		cmd = asPostgres(psqlPath(PgVersion),
+			"-h", PgSocketDir,
+			"-p", port,
+			dbnameConninfo(database),
+			"--quiet",
+			"-v", "ON_ERROR_STOP=1")
+	} else {
+		cmd = asPostgres(pgRestorePath(PgVersion),
+			"-h", PgSocketDir,
+			"-p", port,
+			dbnameConninfo(database),
+			"--no-owner",
+			"--no-privileges",
+			"--exit-on-error")
+	}
+	cmd.Stdin = dump
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("restoring dump: %w (output: %s)", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func (s *AgentService) sendImportLog(stream pb.QuicService_ImportBranchServer, message string) {
+	stream.Send(&pb.ImportBranchResponse{
+		Message: &pb.ImportBranchResponse_Log{
+			Log: &pb.LogLine{
+				Line:      message,
+				Level:     "INFO",
+				Timestamp: time.Now().Unix(),
+			},
+		},
+	})
+}
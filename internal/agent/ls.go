@@ -1,8 +1,11 @@
 package agent
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"slices"
+	"time"
 )
 
 func (s *AgentService) ListBranches(ctx context.Context, template string) ([]*BranchInfo, error) {
@@ -33,3 +36,96 @@ func (s *AgentService) ListBranches(ctx context.Context, template string) ([]*Br
 
 	return branches, nil
 }
+
+const (
+	ListSortCreated = "created"
+	ListSortName    = "name"
+	ListSortSize    = "size"
+	ListSortUsedBy  = "used-by"
+)
+
+type ListOptions struct {
+	Sort     string        // One of the ListSort* values, empty keeps the dataset order
+	Limit    int           // Zero means no limit
+	StaleFor time.Duration // Only branches idle for at least this long, zero lists all
+}
+
+func ValidateListOptions(opts ListOptions) error {
+	switch opts.Sort {
+	case "", ListSortCreated, ListSortName, ListSortSize, ListSortUsedBy:
+	default:
+		return fmt.Errorf("invalid sort '%s': must be one of %s, %s, %s, %s", opts.Sort, ListSortCreated, ListSortName, ListSortSize, ListSortUsedBy)
+	}
+
+	if opts.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+
+	if opts.StaleFor < 0 {
+		return fmt.Errorf("stale duration must not be negative")
+	}
+
+	return nil
+}
+
+// ListBranchesWithOptions lists branches with their disk usage, sorted and limited.
+func (s *AgentService) ListBranchesWithOptions(ctx context.Context, template string, opts ListOptions) ([]*BranchInfo, error) {
+	if err := ValidateListOptions(opts); err != nil {
+		return nil, err
+	}
+
+	branches, err := s.ListBranches(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := listDatasetUsed(ZPool)
+	if err != nil {
+		fmt.Printf("Warning: failed to load branch disk usage: %v\n", err)
+	}
+	missing := make(map[string]bool)
+	for _, branch := range branches {
+		branch.UsedBytes = used[GetBranchDataset(branch.TemplateName, branch.BranchName)]
+
+		if !dependsOnTemplate(branch) {
+			continue
+		}
+		if _, checked := missing[branch.TemplateName]; !checked {
+			missing[branch.TemplateName] = templateMissing(branch.TemplateName)
+		}
+		branch.TemplateMissing = missing[branch.TemplateName]
+	}
+
+	if opts.StaleFor > 0 {
+		branches = slices.DeleteFunc(branches, func(b *BranchInfo) bool {
+			return time.Since(b.LastActivity()) < opts.StaleFor
+		})
+	}
+
+	switch opts.Sort {
+	case ListSortCreated:
+		// Newest first
+		slices.SortStableFunc(branches, func(a, b *BranchInfo) int {
+			return b.CreatedAt.Compare(a.CreatedAt)
+		})
+	case ListSortName:
+		slices.SortStableFunc(branches, func(a, b *BranchInfo) int {
+			return cmp.Or(cmp.Compare(a.BranchName, b.BranchName), cmp.Compare(a.TemplateName, b.TemplateName))
+		})
+	case ListSortSize:
+		// Largest first
+		slices.SortStableFunc(branches, func(a, b *BranchInfo) int {
+			return cmp.Compare(b.UsedBytes, a.UsedBytes)
+		})
+	case ListSortUsedBy:
+		slices.SortStableFunc(branches, func(a, b *BranchInfo) int {
+			return cmp.Or(cmp.Compare(a.CreatedBy, b.CreatedBy), cmp.Compare(a.BranchName, b.BranchName))
+		})
+	}
+
+	if opts.Limit > 0 && len(branches) > opts.Limit {
+		branches = branches[:opts.Limit]
+	}
+
+	return branches, nil
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Destructive operations are rejected between start and end
+	MaintenanceBlockInside = "block_inside"
+	// Destructive operations are only allowed between start and end
+	MaintenanceBlockOutside = "block_outside"
+)
+
+type MaintenancePolicy struct {
+	Mode     string `json:"mode"`     // "" (no restriction), block_inside or block_outside
+	Start    string `json:"start"`    // HH:MM
+	End      string `json:"end"`      // HH:MM, may be earlier than start to span midnight
+	Timezone string `json:"timezone"` // IANA name, defaults to UTC
+}
+
+func (p MaintenancePolicy) validate() error {
+	switch p.Mode {
+	case "":
+		return nil
+	case MaintenanceBlockInside, MaintenanceBlockOutside:
+	default:
+		return fmt.Errorf("mode must be '%s' or '%s', got '%s'", MaintenanceBlockInside, MaintenanceBlockOutside, p.Mode)
+	}
+
+	if _, err := parseClock(p.Start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if _, err := parseClock(p.End); err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if _, err := p.location(); err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+
+	return nil
+}
+
+func (p MaintenancePolicy) location() (*time.Location, error) {
+	if p.Timezone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(p.Timezone)
+}
+
+// blocks reports whether destructive operations are blocked at the given time.
+func (p MaintenancePolicy) blocks(now time.Time) bool {
+	if p.Mode == "" {
+		return false
+	}
+
+	loc, err := p.location()
+	if err != nil {
+		return false
+	}
+	start, _ := parseClock(p.Start)
+	end, _ := parseClock(p.End)
+
+	local := now.In(loc)
+	minutes := local.Hour()*60 + local.Minute()
+
+	var inside bool
+	if start <= end {
+		inside = minutes >= start && minutes < end
+	} else {
+		inside = minutes >= start || minutes < end
+	}
+
+	if p.Mode == MaintenanceBlockInside {
+		return inside
+	}
+	return !inside
+}
+
+func (p MaintenancePolicy) describe() string {
+	tz := p.Timezone
+	if tz == "" {
+		tz = "UTC"
+	}
+
+	if p.Mode == MaintenanceBlockInside {
+		return fmt.Sprintf("destructive operations are blocked between %s and %s %s", p.Start, p.End, tz)
+	}
+	return fmt.Sprintf("destructive operations are only allowed between %s and %s %s", p.Start, p.End, tz)
+}
+
+// CheckDestructiveAllowed returns an error when the maintenance policy blocks
+// the given operation, unless ignoreMaintenance is set.
+func (s *AgentService) CheckDestructiveAllowed(operation string, ignoreMaintenance bool) error {
+	if ignoreMaintenance {
+		return nil
+	}
+
+	policy := s.Config().Maintenance
+	if policy.blocks(time.Now()) {
+		return fmt.Errorf("%s rejected by maintenance policy: %s. Use --ignore-maintenance to override", operation, policy.describe())
+	}
+
+	return nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got '%s'", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type MetricsConfig struct {
+	// Address serving Prometheus metrics over plain HTTP, e.g. 127.0.0.1:9187.
+	// Empty disables them.
+	Listen string `json:"listen"`
+}
+
+func (c MetricsConfig) validate() error {
+	if c.Listen == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		return fmt.Errorf("listen must be a host:port address, got %q", c.Listen)
+	}
+	return nil
+}
+
+type LogConfig struct {
+	// File quicd logs to instead of stderr, i.e. the journal
+	File string `json:"file"`
+}
+
+func (c LogConfig) validate() error {
+	if c.File != "" && !filepath.IsAbs(c.File) {
+		return fmt.Errorf("file must be an absolute path, got %q", c.File)
+	}
+	return nil
+}
+
+// ApplyLogConfig sends the log output to the configured file. Must be called
+// before serving requests.
+func ApplyLogConfig(c LogConfig) error {
+	if c.File == "" {
+		return nil
+	}
+	f, err := os.OpenFile(c.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	log.SetOutput(f)
+	return nil
+}
+
+// StartMetrics serves the metrics on the configured address until quicd
+// exits. They only read in-memory state, so scrapes never run zfs.
+func (s *AgentService) StartMetrics(c MetricsConfig) error {
+	if c.Listen == "" {
+		return nil
+	}
+	lis, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return fmt.Errorf("listening for metrics on %s: %w", c.Listen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprint(w, s.metrics())
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+
+	log.Printf("Serving metrics on %s/metrics", c.Listen)
+	return nil
+}
+
+// metrics renders the metrics in the Prometheus text format.
+func (s *AgentService) metrics() string {
+	type opKey struct {
+		opType string
+		queued bool
+	}
+	counts := make(map[opKey]int)
+	for _, op := range s.ListOperations() {
+		counts[opKey{op.Type, op.Queued}]++
+	}
+	keys := make([]opKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b opKey) int {
+		if c := strings.Compare(a.opType, b.opType); c != 0 {
+			return c
+		}
+		// Running before queued
+		switch {
+		case a.queued == b.queued:
+			return 0
+		case b.queued:
+			return -1
+		default:
+			return 1
+		}
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP quicd_operations Operations in progress or waiting to start.\n")
+	b.WriteString("# TYPE quicd_operations gauge\n")
+	for _, key := range keys {
+		state := "running"
+		if key.queued {
+			state = "queued"
+		}
+		fmt.Fprintf(&b, "quicd_operations{type=%q,state=%q} %d\n", key.opType, state, counts[key])
+	}
+	b.WriteString("# HELP quicd_port_conflicts Ports recorded by more than one branch.\n")
+	b.WriteString("# TYPE quicd_port_conflicts gauge\n")
+	fmt.Fprintf(&b, "quicd_port_conflicts %d\n", len(s.PortConflicts()))
+	b.WriteString("# HELP quicd_shutting_down Whether quicd is draining operations before stopping.\n")
+	b.WriteString("# TYPE quicd_shutting_down gauge\n")
+	shuttingDown := 0
+	if s.shutdownSignal.Load() {
+		shuttingDown = 1
+	}
+	fmt.Fprintf(&b, "quicd_shutting_down %d\n", shuttingDown)
+	return b.String()
+}
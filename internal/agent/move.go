@@ -0,0 +1,287 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Branches moved to another template own their data, like imported ones
+const BranchSourceMove = "move"
+
+// Snapshot of the branch's current data that a move copies last
+const moveSnapshotName = "quic-move"
+
+// MoveResult is a branch moved by MoveBranch.
+type MoveResult struct {
+	*BranchInfo
+	// Data sent to the new dataset, an estimate from the branch's referenced size
+	CopiedBytes int64
+}
+
+// MoveBranch moves a branch under another template. A clone can't change its
+// origin, so the branch's data and checkpoints are copied with zfs send and
+// recv into a standalone dataset under the new template, and the old branch is
+// removed. The branch is stopped during the copy and keeps its port, password
+// and settings. Frozen branches are only moved with force.
+func (s *AgentService) MoveBranch(ctx context.Context, template string, branchName string, toTemplate string, movedBy string, force bool) (*MoveResult, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+	if toTemplate == template {
+		return nil, fmt.Errorf("branch '%s' is already in template '%s'", branchName, template)
+	}
+
+	op, done := s.beginOperation(OpMoveBranch, branchTarget(template, branchName), movedBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	if !datasetExists(GetTemplateDataset(toTemplate)) {
+		return nil, fmt.Errorf("template '%s' not found", toTemplate)
+	}
+
+	branchDataset := GetBranchDataset(template, branchName)
+	branch, err := s.getBranchMetadata(branchDataset)
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if branch.Frozen && !force {
+		return nil, fmt.Errorf("branch '%s' is frozen, unfreeze it or move it with --force", branchName)
+	}
+	if branch.ReplicaOf != "" {
+		return nil, fmt.Errorf("branch '%s' is a replica of '%s' and can't be moved, create a replica of the moved branch instead", branchName, branch.ReplicaOf)
+	}
+	replicas, err := s.branchReplicas(ctx, template, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("listing replicas: %w", err)
+	}
+	if len(replicas) > 0 {
+		return nil, fmt.Errorf("branch '%s' has replicas, delete them before moving it", branchName)
+	}
+	// The archive is a child dataset whose directory archive_command points at
+	if branch.Archive {
+		return nil, fmt.Errorf("branch '%s' archives WAL and can't be moved", branchName)
+	}
+
+	targetDataset := GetBranchDataset(toTemplate, branchName)
+	if datasetExists(targetDataset) {
+		return nil, fmt.Errorf("branch '%s' already exists in template '%s'", branchName, toTemplate)
+	}
+	if err := checkServiceNameFree(toTemplate, branchName); err != nil {
+		return nil, err
+	}
+	if err := s.checkBranchQuota(ctx, toTemplate); err != nil {
+		return nil, err
+	}
+
+	space, err := getDatasetSpace(branchDataset)
+	if err != nil {
+		return nil, err
+	}
+	free, err := getPoolFree(ZPool)
+	if err != nil {
+		return nil, err
+	}
+	if free < space.Referenced+minBranchFreeBytes {
+		return nil, fmt.Errorf("moving branch '%s' copies its %d MiB of data, but pool '%s' has %d MiB free",
+			branchName, space.Referenced/(1024*1024), ZPool, free/(1024*1024))
+	}
+
+	// Checkpoints are copied oldest first, each as an increment of the previous
+	snapshots, err := listSnapshotsByCreation(branchDataset)
+	if err != nil {
+		return nil, err
+	}
+	moveSnapshot := branchDataset + "@" + moveSnapshotName
+	if slices.Contains(snapshots, moveSnapshot) {
+		// Left over from a failed move
+		if err := destroyDataset(moveSnapshot); err != nil {
+			return nil, err
+		}
+	}
+	// A promoted branch also holds the template's snapshots, which stay behind
+	checkpointPrefix := branchDataset + "@checkpoint-"
+	snapshots = slices.DeleteFunc(snapshots, func(s string) bool { return !strings.HasPrefix(s, checkpointPrefix) })
+	snapshots = append(snapshots, moveSnapshot)
+
+	// A custom mountpoint is kept, the default one follows the template
+	oldPath := branch.BranchPath
+	newPath := oldPath
+	if oldPath == GetBranchMountpoint(template, branchName) {
+		newPath = GetBranchMountpoint(toTemplate, branchName)
+	}
+
+	serviceName := GetBranchServiceName(template, branchName)
+	running := !branch.Detached && ServiceExists(serviceName)
+	if running {
+		// A clean shutdown leaves nothing for the copy to replay
+		if err := StopService(serviceName); err != nil {
+			return nil, err
+		}
+	}
+
+	restore := func(cause error) (*MoveResult, error) {
+		if datasetExists(targetDataset) {
+			if err := destroyDataset(targetDataset, "-r"); err != nil {
+				log.Printf("Warning: failed to clean up moved copy %s: %v", targetDataset, err)
+			}
+		}
+		if snapshotExists(moveSnapshot) {
+			if err := destroyDataset(moveSnapshot); err != nil {
+				log.Printf("Warning: failed to remove %s: %v", moveSnapshot, err)
+			}
+		}
+		if running {
+			if err := startBranchService(serviceName, oldPath); err != nil {
+				log.Printf("Warning: failed to restart branch %s after a failed move: %v", branchName, err)
+			}
+		}
+		return nil, cause
+	}
+
+	if err := createSnapshot(moveSnapshot); err != nil {
+		return restore(err)
+	}
+	base := ""
+	for _, snapshot := range snapshots {
+		if err := sendSnapshot(base, snapshot, targetDataset, newPath); err != nil {
+			return restore(fmt.Errorf("copying branch '%s': %w", branchName, err))
+		}
+		base = snapshot
+	}
+	if err := destroyDataset(targetDataset + "@" + moveSnapshotName); err != nil {
+		return restore(err)
+	}
+
+	// From here the copy holds the branch, so failures leave it to attach
+	if err := removeBranch(template, branchName, branch); err != nil {
+		return nil, fmt.Errorf("removing branch '%s' from template '%s' after copying it to '%s': %w", branchName, template, toTemplate, err)
+	}
+	if output, err := privileged("zfs", "mount", targetDataset).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("mounting moved branch: %w (output: %s)", err, output)
+	}
+	if newPath != oldPath {
+		if err := relocateTablespaces(newPath, oldPath); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	branch.TemplateName = toTemplate
+	branch.BranchPath = newPath
+	branch.Source = BranchSourceMove
+	branch.Promoted = false
+	branch.UpdatedAt = now
+	branch.LastAccessedAt = now
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return nil, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	if !branch.Detached {
+		if err := CreateBranchService(toTemplate, branchName, newPath, branch.Port); err != nil {
+			return nil, fmt.Errorf("creating systemd service: %w", err)
+		}
+		newService := GetBranchServiceName(toTemplate, branchName)
+		if err := startBranchService(newService, newPath); err != nil {
+			return nil, fmt.Errorf("starting systemd service: %w", err)
+		}
+		if err := waitForPostgreSQLReady(newPath, branchStartTimeout); err != nil {
+			return nil, fmt.Errorf("waiting for branch to accept connections: %w\n%s", err, ServiceLogs(newService, 20))
+		}
+		// Removing the old branch closed its port
+		if err := openFirewallPort(branch.Port); err != nil {
+			return nil, fmt.Errorf("opening firewall port: %w", err)
+		}
+	}
+
+	auditEvent("branch_move", map[string]interface{}{
+		"template_name": toTemplate,
+		"from_template": template,
+		"branch_name":   branchName,
+		"port":          branch.Port,
+		"bytes":         space.Referenced,
+		"moved_by":      movedBy,
+	})
+	s.updatePortConflicts()
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_move",
+		Template: toTemplate,
+		Branch:   branchName,
+		User:     movedBy,
+		Host:     s.PublicHost(ctx),
+		Port:     branch.Port,
+	})
+
+	return &MoveResult{BranchInfo: branch, CopiedBytes: space.Referenced}, nil
+}
+
+// listSnapshotsByCreation returns the snapshots of dataset, oldest first.
+func listSnapshotsByCreation(dataset string) ([]string, error) {
+	output, err := privileged("zfs", "list", "-H", "-o", "name", "-t", "snapshot", "-d", "1", "-s", "createtxg", dataset).Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing ZFS snapshots of %s: %w", dataset, err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// sendSnapshot copies snapshot into dataset with zfs send | zfs recv, as an
+// increment of base when it isn't empty. The first copy creates dataset with
+// mountpoint, unmounted. The stream isn't raw, so on an encrypted pool the
+// data is encrypted with dataset's parent key.
+func sendSnapshot(base, snapshot, dataset, mountpoint string) error {
+	sendArgs := []string{"send"}
+	recvArgs := []string{"recv", "-u"}
+	if base != "" {
+		sendArgs = append(sendArgs, "-i", base)
+	} else {
+		recvArgs = append(recvArgs, "-o", "mountpoint="+mountpoint)
+	}
+	sendArgs = append(sendArgs, snapshot)
+	recvArgs = append(recvArgs, dataset)
+
+	send := privileged("zfs", sendArgs...)
+	recv := privileged("zfs", recvArgs...)
+	stream, err := send.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get zfs send output: %w", err)
+	}
+	recv.Stdin = stream
+	var sendOutput, recvOutput bytes.Buffer
+	send.Stderr = &sendOutput
+	recv.Stdout = &recvOutput
+	recv.Stderr = &recvOutput
+
+	if err := send.Start(); err != nil {
+		return fmt.Errorf("starting zfs send: %w", err)
+	}
+	if err := recv.Start(); err != nil {
+		send.Process.Kill()
+		send.Wait()
+		return fmt.Errorf("starting zfs recv: %w", err)
+	}
+	recvErr := recv.Wait()
+	sendErr := send.Wait()
+
+	if recvErr != nil {
+		if isOutOfSpace(recvOutput.String()) {
+			return poolFullError()
+		}
+		return fmt.Errorf("zfs recv %s: %w (output: %s)", snapshot, recvErr, strings.TrimSpace(recvOutput.String()))
+	}
+	if sendErr != nil {
+		return fmt.Errorf("zfs send %s: %w (output: %s)", snapshot, sendErr, strings.TrimSpace(sendOutput.String()))
+	}
+	return nil
+}
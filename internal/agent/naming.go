@@ -0,0 +1,38 @@
+package agent
+
+import "fmt"
+
+// checkServiceNameFree fails when the service of a new template, or of a new
+// branch when branch isn't empty, would be named like the service of an
+// existing template or branch. Service names join the template and branch
+// with '-', which both may contain, so branch b-c of template a and branch c
+// of template a-b would share the service quic-a-b-c. Datasets nest with '/'
+// and can't collide.
+func checkServiceNameFree(template, branch string) error {
+	name := template
+	if branch != "" {
+		name += "-" + branch
+	}
+	serviceName := GetTemplateServiceName(name)
+
+	if branch != "" && datasetExists(GetTemplateDataset(name)) {
+		return serviceNameTakenError(serviceName, fmt.Sprintf("template '%s'", name))
+	}
+	for i := range len(name) {
+		if name[i] != '-' {
+			continue
+		}
+		otherTemplate, otherBranch := name[:i], name[i+1:]
+		if otherTemplate == template && otherBranch == branch {
+			continue
+		}
+		if datasetExists(GetBranchDataset(otherTemplate, otherBranch)) {
+			return serviceNameTakenError(serviceName, fmt.Sprintf("branch '%s' of template '%s'", otherBranch, otherTemplate))
+		}
+	}
+	return nil
+}
+
+func serviceNameTakenError(serviceName, owner string) error {
+	return fmt.Errorf("service name %s is already used by %s, pick a name that doesn't combine into it", serviceName, owner)
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	OpCreateBranch     = "create_branch"
+	OpDeleteBranch     = "delete_branch"
+	OpDetachBranch     = "detach_branch"
+	OpAttachBranch     = "attach_branch"
+	OpImportBranch     = "import_branch"
+	OpExportBranch     = "export_branch"
+	OpPromoteBranch    = "promote_branch"
+	OpSnapshotBranch   = "snapshot_branch"
+	OpRollbackBranch   = "rollback_branch"
+	OpFreezeBranch     = "freeze_branch"
+	OpConfigureBranch  = "configure_branch"
+	OpCreateReplica    = "create_replica"
+	OpMoveBranch       = "move_branch"
+	OpRestoreTemplate  = "restore_template"
+	OpCloneTemplate    = "clone_template"
+	OpCollectSnapshots = "collect_snapshots"
+)
+
+var (
+	ErrOperationNotFound      = errors.New("operation not found")
+	ErrOperationNotCancelable = errors.New("operation can't be cancelled")
+	ErrCancelNotAllowed       = errors.New("only the user who started an operation or an admin can cancel it")
+	ErrOperationCancelled     = errors.New("operation cancelled")
+	// quicd is shutting down and no longer starts operations
+	ErrServiceRestarting = errors.New("service restarting, please retry in a few seconds")
+)
+
+// Long running operations that check their context and clean up after themselves
+var cancelableOperations = map[string]bool{
+	OpCreateBranch:    true,
+	OpRestoreTemplate: true,
+}
+
+// Operation is a request quicd is currently working on, or waiting to start.
+type Operation struct {
+	ID        string
+	Type      string
+	Target    string
+	User      string
+	StartedAt time.Time
+	// Waiting for the checkout lock or a restore slot
+	Queued     bool
+	Cancelable bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// cancelled reports whether the operation was cancelled with CancelOperation.
+func (op *Operation) cancelled() bool {
+	return op.ctx.Err() != nil
+}
+
+type operationTracker struct {
+	mu     sync.Mutex
+	nextID int
+	ops    map[string]*Operation
+}
+
+// beginOperation registers an in-flight operation. Call the returned func
+// when it finishes.
+func (s *AgentService) beginOperation(opType, target, user string) (*Operation, func()) {
+	t := &s.operations
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ops == nil {
+		t.ops = make(map[string]*Operation)
+	}
+	t.nextID++
+	// Not derived from the request context: a client going away doesn't
+	// abort the operation, only CancelOperation does
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &Operation{
+		ID:         strconv.Itoa(t.nextID),
+		Type:       opType,
+		Target:     target,
+		User:       user,
+		StartedAt:  time.Now().UTC(),
+		Cancelable: cancelableOperations[opType],
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	t.ops[op.ID] = op
+
+	return op, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.ops, op.ID)
+		cancel()
+	}
+}
+
+func (s *AgentService) setOperationQueued(op *Operation, queued bool) {
+	s.operations.mu.Lock()
+	defer s.operations.mu.Unlock()
+	op.Queued = queued
+}
+
+// lockForOperation takes the checkout lock, showing op as queued while it waits.
+func (s *AgentService) lockForOperation(op *Operation) bool {
+	s.setOperationQueued(op, true)
+	defer s.setOperationQueued(op, false)
+	return s.tryLockWithShutdownCheck()
+}
+
+// ListOperations returns in-flight operations, oldest first.
+func (s *AgentService) ListOperations() []Operation {
+	s.operations.mu.Lock()
+	defer s.operations.mu.Unlock()
+
+	ops := make([]Operation, 0, len(s.operations.ops))
+	for _, op := range s.operations.ops {
+		ops = append(ops, *op)
+	}
+	slices.SortFunc(ops, func(a, b Operation) int {
+		return a.StartedAt.Compare(b.StartedAt)
+	})
+
+	return ops
+}
+
+// CancelOperation stops a running operation. Users may cancel their own
+// operations; admins listed in the agent config may cancel any.
+func (s *AgentService) CancelOperation(id string, user string) error {
+	s.operations.mu.Lock()
+	op, ok := s.operations.ops[id]
+	s.operations.mu.Unlock()
+
+	if !ok {
+		return ErrOperationNotFound
+	}
+	if !op.Cancelable {
+		return fmt.Errorf("%w: %s", ErrOperationNotCancelable, op.Type)
+	}
+	if op.User != user && !s.Config().isAdmin(user) {
+		return ErrCancelNotAllowed
+	}
+
+	op.cancel()
+
+	auditEvent("operation_cancel", map[string]interface{}{
+		"operation_id": op.ID,
+		"type":         op.Type,
+		"target":       op.Target,
+		"started_by":   op.User,
+		"cancelled_by": user,
+	})
+
+	return nil
+}
+
+func branchTarget(template, branch string) string {
+	return template + "/" + branch
+}
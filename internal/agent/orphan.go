@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// templateMissing reports whether a template's data is gone, e.g. removed by
+// hand. ZFS keeps the template dataset while branches are cloned from it, so
+// a missing template shows as a dataset without setup metadata.
+func templateMissing(template string) bool {
+	_, err := os.Stat(filepath.Join(GetTemplateMountpoint(template), ".quic-init-meta.json"))
+	return os.IsNotExist(err)
+}
+
+// dependsOnTemplate reports whether branch is still a clone of its template's
+// snapshot. Promoted, imported and moved branches own their data.
+func dependsOnTemplate(branch *BranchInfo) bool {
+	return !branch.Promoted && branch.Source != BranchSourceImport && branch.Source != BranchSourceMove
+}
+
+// branchOrphaned reports whether branch depends on a template or origin
+// snapshot that no longer exists.
+func branchOrphaned(branch *BranchInfo) (bool, error) {
+	if !dependsOnTemplate(branch) {
+		return false, nil
+	}
+
+	origin, err := getOrigin(GetBranchDataset(branch.TemplateName, branch.BranchName))
+	if err != nil {
+		return false, err
+	}
+	return (origin != "" && !snapshotExists(origin)) || templateMissing(branch.TemplateName), nil
+}
+
+// checkBranchOrigin fails with an explanation when branch is orphaned, rather
+// than letting the operation fail on a ZFS or PostgreSQL error.
+func checkBranchOrigin(branch *BranchInfo) error {
+	orphaned, err := branchOrphaned(branch)
+	if err != nil {
+		return err
+	}
+	if orphaned {
+		return orphanedBranchError(branch)
+	}
+	return nil
+}
+
+func orphanedBranchError(branch *BranchInfo) error {
+	return fmt.Errorf("template '%s' of branch '%s' no longer exists, delete the branch",
+		branch.TemplateName, branch.BranchName)
+}
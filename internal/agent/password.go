@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultPasswordLength    = 32
+	defaultPasswordMinLength = 16
+)
+
+// PasswordPolicy controls branch admin passwords. Zero values use the defaults.
+type PasswordPolicy struct {
+	// Length of generated passwords
+	Length int `json:"length"`
+	// Shortest password accepted when the client supplies one
+	MinLength int `json:"minLength"`
+}
+
+func (p PasswordPolicy) validate() error {
+	if p.Length != 0 && (p.Length < 16 || p.Length > 128) {
+		return fmt.Errorf("length must be between 16 and 128, got %d", p.Length)
+	}
+	if p.MinLength != 0 && (p.MinLength < 8 || p.MinLength > 128) {
+		return fmt.Errorf("minLength must be between 8 and 128, got %d", p.MinLength)
+	}
+	return nil
+}
+
+func (p PasswordPolicy) length() int {
+	if p.Length == 0 {
+		return defaultPasswordLength
+	}
+	return p.Length
+}
+
+func (p PasswordPolicy) minLength() int {
+	if p.MinLength == 0 {
+		return defaultPasswordMinLength
+	}
+	return p.MinLength
+}
+
+// ValidateAdminPassword checks a client supplied password against the policy.
+// Length is the measure of strength, so random hex or passphrases from a
+// secret manager are accepted as they are.
+func (p PasswordPolicy) ValidateAdminPassword(password string) error {
+	if !utf8.ValidString(password) {
+		return fmt.Errorf("admin password must be valid UTF-8")
+	}
+	if n := utf8.RuneCountInString(password); n < p.minLength() {
+		return fmt.Errorf("admin password must be at least %d characters, got %d", p.minLength(), n)
+	}
+	if len(password) > 1000 {
+		return fmt.Errorf("admin password must be at most 1000 bytes")
+	}
+
+	for _, r := range password {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("admin password can't contain control characters")
+		}
+	}
+
+	return nil
+}
+
+func generateSecurePassword(length int) (string, error) {
+	// base64 encodes 3 bytes in 4 characters
+	bytes := make([]byte, (length*3+3)/4)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes)[:length], nil
+}
+
+// maskPassword identifies a password in logs without revealing it.
+func maskPassword(password string) string {
+	if password == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(password))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// quoteLiteral quotes a string for use as an SQL literal. PostgreSQL treats
+// backslashes in standard strings literally, so doubling quotes is enough.
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	AuthMethodScram = "scram-sha-256"
+	// Compatibility for clients whose drivers predate SCRAM support
+	AuthMethodMD5 = "md5"
+)
+
+type PgHbaConfig struct {
+	AuthMethod string `json:"authMethod"` // "scram-sha-256" (default) or "md5"
+	// Extra pg_hba.conf entries, placed before the generated ones so they take precedence
+	ExtraLines []string `json:"extraLines"`
+}
+
+var pgHbaConnectionTypes = map[string]bool{
+	"local":        true,
+	"host":         true,
+	"hostssl":      true,
+	"hostnossl":    true,
+	"hostgssenc":   true,
+	"hostnogssenc": true,
+}
+
+func (c PgHbaConfig) validate() error {
+	switch c.AuthMethod {
+	case "", AuthMethodScram, AuthMethodMD5:
+	default:
+		return fmt.Errorf("authMethod must be '%s' or '%s', got '%s'", AuthMethodScram, AuthMethodMD5, c.AuthMethod)
+	}
+
+	for _, line := range c.ExtraLines {
+		if strings.ContainsAny(line, "\r\n") {
+			return fmt.Errorf("extraLines entries must be single lines: %q", line)
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if !pgHbaConnectionTypes[fields[0]] {
+			return fmt.Errorf("invalid pg_hba entry %q: unknown connection type '%s'", line, fields[0])
+		}
+		if len(fields) < 4 {
+			return fmt.Errorf("invalid pg_hba entry %q: too few fields", line)
+		}
+	}
+
+	return nil
+}
+
+func (c PgHbaConfig) authMethod() string {
+	if c.AuthMethod == "" {
+		return AuthMethodScram
+	}
+	return c.AuthMethod
+}
+
+// passwordEncryption returns the value for password_encryption, so stored
+// role passwords match the method clients authenticate with.
+func (c PgHbaConfig) passwordEncryption() string {
+	return fmt.Sprintf("'%s'", c.authMethod())
+}
+
+// render builds the pg_hba.conf written to each branch.
+func (c PgHbaConfig) render() string {
+	var b strings.Builder
+
+	if len(c.ExtraLines) > 0 {
+		b.WriteString("# Host-specific entries from quicd config\n")
+		for _, line := range c.ExtraLines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	method := c.authMethod()
+	fmt.Fprintf(&b, `# Allow local connections for testing
+local   all             postgres                                peer
+local   replication     postgres                                peer
+local   all             all                                     %[1]s
+host    all             all             127.0.0.1/32            %[1]s
+host    all             all             ::1/128                 %[1]s
+host    all             admin           0.0.0.0/0               %[1]s
+`, method)
+
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Matches e.g. "P00   INFO: repo1: restore backup set 20240101-010101F, recovery will start at ..."
+var backupSetPattern = regexp.MustCompile(`restore backup set ([^\s,]+)`)
+
+// Level of a pgBackRest log line, e.g. "P00   INFO: restore command begin"
+var pgBackRestLinePattern = regexp.MustCompile(`^\S+\s+([A-Z]+):`)
+
+type BackupProvenance struct {
+	Label      string `json:"label,omitempty"`
+	LSNStart   string `json:"lsn_start,omitempty"`
+	LSNStop    string `json:"lsn_stop,omitempty"`
+	FinishedAt string `json:"finished_at,omitempty"` // RFC3339
+}
+
+type pgBackRestInfo struct {
+	Backup []struct {
+		Label string `json:"label"`
+		LSN   struct {
+			Start string `json:"start"`
+			Stop  string `json:"stop"`
+		} `json:"lsn"`
+		Timestamp struct {
+			Stop int64 `json:"stop"`
+		} `json:"timestamp"`
+	} `json:"backup"`
+}
+
+// Each template gets its own pgBackRest config, so concurrent restores of
+// different clusters never read each other's repository or credentials. The
+// restored postgresql.auto.conf keeps pointing at it for WAL fetches.
+const PgBackRestConfigDir = "/etc/pgbackrest"
+
+func GetPgBackRestConfigPath(template string) string {
+	return PgBackRestConfigDir + "/" + template + ".conf"
+}
+
+// pgBackRest numbers repositories repo1 to repo256.
+const maxPgBackRestRepo = 256
+
+// restoreRepo returns the repo index to restore from, repo 1 when unset.
+func restoreRepo(repo int32) (int, error) {
+	if repo == 0 {
+		return 1, nil
+	}
+	if repo < 1 || repo > maxPgBackRestRepo {
+		return 0, fmt.Errorf("pgBackRest repo must be between 1 and %d, got %d", maxPgBackRestRepo, repo)
+	}
+	return int(repo), nil
+}
+
+// getBackupProvenance looks up a backup set in the stanza's repo.
+func getBackupProvenance(configPath, stanza string, repo int, label string) (*BackupProvenance, error) {
+	output, err := privileged("pgbackrest", "info",
+		"--stanza="+stanza,
+		fmt.Sprintf("--repo=%d", repo),
+		"--config="+configPath,
+		"--output=json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("pgbackrest info: %w", err)
+	}
+
+	var stanzas []pgBackRestInfo
+	if err := json.Unmarshal(output, &stanzas); err != nil {
+		return nil, fmt.Errorf("parsing pgbackrest info: %w", err)
+	}
+
+	for _, info := range stanzas {
+		for _, backup := range info.Backup {
+			if backup.Label != label {
+				continue
+			}
+			return &BackupProvenance{
+				Label:      backup.Label,
+				LSNStart:   backup.LSN.Start,
+				LSNStop:    backup.LSN.Stop,
+				FinishedAt: time.Unix(backup.Timestamp.Stop, 0).UTC().Format(time.RFC3339),
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("backup set %s not found in stanza %s", label, stanza)
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Branches start writing WAL right away, so refuse to clone below this
+const minBranchFreeBytes = 256 * 1024 * 1024
+
+type PoolFullError struct {
+	Pool string
+	Free int64
+}
+
+func (e *PoolFullError) Error() string {
+	return fmt.Sprintf("ZFS pool '%s' is full (%d MiB free). Delete unused branches or add storage to the pool", e.Pool, e.Free/(1024*1024))
+}
+
+func getPoolFree(pool string) (int64, error) {
+	output, err := privileged("zpool", "list", "-Hp", "-o", "free", pool).Output()
+	if err != nil {
+		return 0, fmt.Errorf("getting free space of pool %s: %w", pool, err)
+	}
+
+	free, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing free space of pool %s: %w", pool, err)
+	}
+
+	return free, nil
+}
+
+// checkPoolSpace fails with a PoolFullError when the pool can't hold a new branch.
+func checkPoolSpace(pool string) error {
+	free, err := getPoolFree(pool)
+	if err != nil {
+		return err
+	}
+	if free < minBranchFreeBytes {
+		return &PoolFullError{Pool: pool, Free: free}
+	}
+	return nil
+}
+
+// isOutOfSpace recognizes ENOSPC as reported by zfs commands.
+func isOutOfSpace(output string) bool {
+	return strings.Contains(output, "out of space") || strings.Contains(output, "No space left on device")
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+)
+
+// PortConflict is a port recorded in the metadata of several branches, e.g.
+// after a crash between picking a port and opening it in the firewall. Only
+// one of them can start.
+type PortConflict struct {
+	Port string
+	// template/branch
+	Branches []string
+}
+
+// findPortConflicts groups branches sharing a port. Detached branches count
+// too: their port stays reserved for attach.
+func findPortConflicts(branches []*BranchInfo) []PortConflict {
+	byPort := make(map[string][]string)
+	for _, branch := range branches {
+		if branch.Port == "" {
+			continue
+		}
+		byPort[branch.Port] = append(byPort[branch.Port], branch.TemplateName+"/"+branch.BranchName)
+	}
+
+	var conflicts []PortConflict
+	for port, names := range byPort {
+		if len(names) > 1 {
+			slices.Sort(names)
+			conflicts = append(conflicts, PortConflict{Port: port, Branches: names})
+		}
+	}
+	slices.SortFunc(conflicts, func(a, b PortConflict) int { return strings.Compare(a.Port, b.Port) })
+	return conflicts
+}
+
+// refreshPortConflicts recomputes the conflicts PortConflicts returns.
+// Refreshes are serialized so that an older listing can't overwrite a newer
+// one.
+func (s *AgentService) refreshPortConflicts(ctx context.Context) ([]PortConflict, error) {
+	s.portConflictsRefresh.Lock()
+	defer s.portConflictsRefresh.Unlock()
+
+	branches, err := s.ListBranches(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	conflicts := findPortConflicts(branches)
+
+	s.portConflictsMutex.Lock()
+	defer s.portConflictsMutex.Unlock()
+	s.portConflicts = conflicts
+	return conflicts, nil
+}
+
+// updatePortConflicts refreshes the conflicts after a branch was created,
+// deleted, moved or given another port.
+func (s *AgentService) updatePortConflicts() {
+	if _, err := s.refreshPortConflicts(context.Background()); err != nil {
+		log.Printf("Warning: updating port conflicts: %v", err)
+	}
+}
+
+// PortConflicts returns the conflicts as of the last branch change. It doesn't
+// list branches, Health calls it on every probe.
+func (s *AgentService) PortConflicts() []PortConflict {
+	s.portConflictsMutex.Lock()
+	defer s.portConflictsMutex.Unlock()
+	return s.portConflicts
+}
+
+// CheckPortConflicts looks for branches recorded on the same port when quicd
+// starts, logs and audits them. Ports aren't reassigned, that would silently
+// change a developer's connection string.
+func (s *AgentService) CheckPortConflicts() {
+	conflicts, err := s.refreshPortConflicts(context.Background())
+	if err != nil {
+		log.Printf("Port conflict check: listing branches: %v", err)
+		return
+	}
+
+	for _, conflict := range conflicts {
+		log.Printf("Warning: port %s is recorded by several branches: %s. Delete all but one of them", conflict.Port, strings.Join(conflict.Branches, ", "))
+		auditEvent("port_conflict", map[string]interface{}{
+			"port":     conflict.Port,
+			"branches": conflict.Branches,
+		})
+	}
+}
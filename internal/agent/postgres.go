@@ -1,9 +1,12 @@
 package agent
 
 import (
+	"cmp"
 	"fmt"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type PostmasterPid struct {
@@ -14,30 +17,122 @@ type PostmasterPid struct {
 }
 
 const (
-	PgVersion   = "16"
-	StartPort   = 15432
-	EndPort     = 16432
-	PgSocketDir = "/var/run/postgresql"
+	DefaultPgVersion   = "16"
+	DefaultPgSocketDir = "/var/run/postgresql"
+	DefaultStartPort   = 15432
+	DefaultEndPort     = 16432
 )
 
+// PostgreSQL version, socket directory and port range used by this host. Set
+// from the agent config at startup, see ApplyPostgresConfig.
+var (
+	PgVersion   = DefaultPgVersion
+	PgSocketDir = DefaultPgSocketDir
+	StartPort   = DefaultStartPort
+	EndPort     = DefaultEndPort
+)
+
+// PostgresConfig selects the PostgreSQL installation quicd drives.
+type PostgresConfig struct {
+	// Major version whose binaries live in /usr/lib/postgresql/<version>/bin
+	Version string `json:"version"`
+	// Directory holding the Unix sockets of templates and branches
+	SocketDir string `json:"socketDir"`
+	// Ports templates and branches listen on
+	Ports PortRange `json:"ports"`
+}
+
+// PortRange is an inclusive range of TCP ports. Zero values use the defaults.
+type PortRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+func (c PostgresConfig) validate() error {
+	if c.Version != "" {
+		if _, err := strconv.Atoi(c.Version); err != nil {
+			return fmt.Errorf("version must be a major version number like 16, got %q", c.Version)
+		}
+	}
+	if c.SocketDir != "" && !filepath.IsAbs(c.SocketDir) {
+		return fmt.Errorf("socketDir must be an absolute path, got %q", c.SocketDir)
+	}
+	start := cmp.Or(c.Ports.Start, DefaultStartPort)
+	end := cmp.Or(c.Ports.End, DefaultEndPort)
+	if start < 1024 || end > 65535 || start > end {
+		return fmt.Errorf("ports must be a range within 1024-65535, got %d-%d", start, end)
+	}
+	return nil
+}
+
+// ApplyPostgresConfig points binary paths and socket connections at the
+// configured installation. Must be called before serving requests.
+func ApplyPostgresConfig(c PostgresConfig) {
+	PgVersion = cmp.Or(c.Version, DefaultPgVersion)
+	PgSocketDir = cmp.Or(c.SocketDir, DefaultPgSocketDir)
+	StartPort = cmp.Or(c.Ports.Start, DefaultStartPort)
+	EndPort = cmp.Or(c.Ports.End, DefaultEndPort)
+}
+
+// readDataDirVersion returns the major version recorded in a data directory's PG_VERSION file.
+func readDataDirVersion(dataDir string) (string, error) {
+	content, err := privileged("cat", filepath.Join(dataDir, "PG_VERSION")).Output()
+	if err != nil {
+		return "", fmt.Errorf("reading PG_VERSION in %s: %w", dataDir, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// checkDataDirVersion verifies restored data matches both the requested
+// version and the binaries this host runs, returning the detected version.
+func checkDataDirVersion(dataDir, requested string) (string, error) {
+	detected, err := readDataDirVersion(dataDir)
+	if err != nil {
+		return "", err
+	}
+	if requested != "" && requested != detected {
+		return "", fmt.Errorf("restored data is PostgreSQL %s but the template's pgVersion is %s. Update pgVersion in quic.json", detected, requested)
+	}
+	if detected != PgVersion {
+		return "", fmt.Errorf("restored data is PostgreSQL %s but this host runs PostgreSQL %s (postgres.version in %s)", detected, PgVersion, AgentConfigPath)
+	}
+	return detected, nil
+}
+
+func pgBinPath(pgVersion, name string) string {
+	return fmt.Sprintf("/usr/lib/postgresql/%s/bin/%s", pgVersion, name)
+}
+
 func psqlPath(pgVersion string) string {
-	return fmt.Sprintf("/usr/lib/postgresql/%s/bin/psql", pgVersion)
+	return pgBinPath(pgVersion, "psql")
 }
 
 func pgCtlPath(pgVersion string) string {
-	return fmt.Sprintf("/usr/lib/postgresql/%s/bin/pg_ctl", pgVersion)
+	return pgBinPath(pgVersion, "pg_ctl")
 }
 
 func pgResetWalPath(pgVersion string) string {
-	return fmt.Sprintf("/usr/lib/postgresql/%s/bin/pg_resetwal", pgVersion)
+	return pgBinPath(pgVersion, "pg_resetwal")
+}
+
+func pgDumpPath(pgVersion string) string {
+	return pgBinPath(pgVersion, "pg_dump")
+}
+
+func pgRestorePath(pgVersion string) string {
+	return pgBinPath(pgVersion, "pg_restore")
+}
+
+func initdbPath(pgVersion string) string {
+	return pgBinPath(pgVersion, "initdb")
 }
 
 func pgIsReadyPath(pgVersion string) string {
-	return fmt.Sprintf("/usr/lib/postgresql/%s/bin/pg_isready", pgVersion)
+	return pgBinPath(pgVersion, "pg_isready")
 }
 
 func ExecPostgresCommand(port string, database, sqlCommand string) (string, error) {
-	cmd := exec.Command("sudo", "-u", "postgres", psqlPath(PgVersion),
+	cmd := asPostgres(psqlPath(PgVersion),
 		"-h", PgSocketDir,
 		"-p", port,
 		"-d", database,
@@ -52,6 +147,27 @@ func ExecPostgresCommand(port string, database, sqlCommand string) (string, erro
 	return strings.TrimSpace(string(output)), nil
 }
 
+// ExecPostgresScript runs SQL read from psql's stdin, for statements holding
+// secrets that shouldn't show up in the process list. It stops at the first
+// error and runs in one transaction, like ExecPostgresCommand.
+func ExecPostgresScript(port string, database, sqlScript string) (string, error) {
+	cmd := asPostgres(psqlPath(PgVersion),
+		"-h", PgSocketDir,
+		"-p", port,
+		"-d", database,
+		"--no-align",
+		"--tuples-only",
+		"--single-transaction",
+		"-v", "ON_ERROR_STOP=1")
+	cmd.Stdin = strings.NewReader(sqlScript)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("psql command failed: %w (output: %s)", err, string(output))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func IsPostgreSQLServerReady(dataDir string) bool {
 	postmasterPid, isRunning := getPostmasterPid(dataDir)
 	if !isRunning {
@@ -62,13 +178,31 @@ func IsPostgreSQLServerReady(dataDir string) bool {
 	// - not started: no response - exit status 2
 	// - backup recovery mode: rejecting connections - exit status 1
 	// - database system is ready to accept read-only connections: accepting connections - nil
-	cmd := exec.Command("sudo", "-u", "postgres", pgIsReadyPath(PgVersion), "--port", postmasterPid.Port)
+	cmd := asPostgres(pgIsReadyPath(PgVersion), "--host", PgSocketDir, "--port", postmasterPid.Port)
 	output := cmd.Run()
 	return output == nil
 }
 
+// waitForPostgreSQLReady waits for the server of dataDir to accept
+// connections. On timeout it tells a server that's still starting, e.g.
+// replaying WAL, from one that's gone.
+func waitForPostgreSQLReady(dataDir string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if IsPostgreSQLServerReady(dataDir) {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if pid, exists := getPostmasterPid(dataDir); exists && postmasterRunning(dataDir, pid) {
+		return fmt.Errorf("PostgreSQL in %s is still starting after %v, it may be replaying WAL. Retry later or raise startTimeout in the quicd config", dataDir, timeout)
+	}
+	return fmt.Errorf("PostgreSQL in %s is not running after %v, it failed to start or crashed", dataDir, timeout)
+}
+
 func getPostmasterPid(dataDir string) (PostmasterPid, bool) {
-	content, err := exec.Command("sudo", "cat", dataDir+"/postmaster.pid").Output()
+	content, err := privileged("cat", dataDir+"/postmaster.pid").Output()
 	if err != nil {
 		return PostmasterPid{}, false
 	}
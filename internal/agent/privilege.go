@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+const (
+	// Run privileged commands through passwordless sudo, as set up by quic host setup
+	PrivilegeSudo = "sudo"
+	// Run privileged commands directly, for quicd running as root, e.g. in a container
+	PrivilegeDirect = "direct"
+)
+
+// How privileged commands are run. Set from the agent config at startup, see
+// ApplyPrivilegeMode.
+var privilegeMode = PrivilegeSudo
+
+func validatePrivilegeMode(mode string) error {
+	switch mode {
+	case "", PrivilegeSudo, PrivilegeDirect:
+		return nil
+	default:
+		return fmt.Errorf("privilege must be '%s' or '%s', got %q", PrivilegeSudo, PrivilegeDirect, mode)
+	}
+}
+
+// ApplyPrivilegeMode selects how privileged commands are run. Without a mode,
+// commands run directly when quicd is root and through sudo otherwise. Must
+// be called before serving requests.
+func ApplyPrivilegeMode(mode string) {
+	switch {
+	case mode != "":
+		privilegeMode = mode
+	case os.Geteuid() == 0:
+		privilegeMode = PrivilegeDirect
+	default:
+		privilegeMode = PrivilegeSudo
+	}
+}
+
+// privileged builds a command that runs as root.
+func privileged(name string, args ...string) *exec.Cmd {
+	return privilegedContext(context.Background(), name, args...)
+}
+
+func privilegedContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if privilegeMode == PrivilegeDirect {
+		return exec.CommandContext(ctx, name, args...)
+	}
+	return exec.CommandContext(ctx, "sudo", append([]string{name}, args...)...)
+}
+
+// asPostgres builds a command that runs as the postgres user. PostgreSQL
+// tools like initdb and pg_resetwal refuse to run as root.
+func asPostgres(name string, args ...string) *exec.Cmd {
+	return asPostgresContext(context.Background(), name, args...)
+}
+
+func asPostgresContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if privilegeMode != PrivilegeDirect {
+		return exec.CommandContext(ctx, "sudo", append([]string{"-u", "postgres", name}, args...)...)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Without a postgres user the command runs as root, which PostgreSQL tools
+	// refuse with a clear error
+	if credential, err := postgresCredential(); err == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	}
+	return cmd
+}
+
+func postgresCredential() (*syscall.Credential, error) {
+	u, err := user.Lookup("postgres")
+	if err != nil {
+		return nil, fmt.Errorf("looking up postgres user: %w", err)
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parsing postgres uid: %w", err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("parsing postgres gid: %w", err)
+	}
+
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	if groupIDs, err := u.GroupIds(); err == nil {
+		for _, id := range groupIDs {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				credential.Groups = append(credential.Groups, uint32(g))
+			}
+		}
+	}
+
+	return credential, nil
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PromoteBranch runs `zfs promote` on the branch clone. The origin snapshot,
+// and the space it references, move to the branch dataset; the template
+// becomes a clone of the branch's snapshot. The branch dataset is still a
+// child of the template, so this only changes space accounting. See
+// branchOriginSnapshot for the snapshots of older branches that move along.
+func (s *AgentService) PromoteBranch(ctx context.Context, template string, branchName string, promotedBy string) (bool, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return false, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	op, done := s.beginOperation(OpPromoteBranch, branchTarget(template, branchName), promotedBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return false, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return false, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return false, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if branch.ReplicaOf != "" {
+		return false, fmt.Errorf("branch '%s' is a replica of '%s' and can't be promoted", branchName, branch.ReplicaOf)
+	}
+	if branch.Promoted {
+		return false, nil
+	}
+
+	if err := promoteDataset(GetBranchDataset(template, branchName)); err != nil {
+		return false, err
+	}
+
+	branch.Promoted = true
+	branch.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	branch.LastAccessedAt = branch.UpdatedAt
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return false, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	auditEvent("branch_promote", map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"promoted_by":   promotedBy,
+	})
+
+	return true, nil
+}
+
+// demoteBranch promotes the template back when it is a clone of the branch's
+// snapshot, restoring the original template -> branch dependency.
+func demoteBranch(template, branchName string) error {
+	templateDataset := GetTemplateDataset(template)
+	if !datasetExists(templateDataset) {
+		return nil
+	}
+
+	origin, err := getOrigin(templateDataset)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(origin, GetBranchDataset(template, branchName)+"@") {
+		if err := promoteDataset(templateDataset); err != nil {
+			return fmt.Errorf("returning snapshot ownership to template: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/peer"
+)
+
+// PublicHost returns the host clients should use to reach branches on this
+// agent: the configured publicHost, else the local address the request
+// arrived on, else the host's primary IP.
+func (s *AgentService) PublicHost(ctx context.Context) string {
+	if host := s.Config().PublicHost; host != "" {
+		return host
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.LocalAddr != nil {
+		if host, _, err := net.SplitHostPort(p.LocalAddr.String()); err == nil && !net.ParseIP(host).IsLoopback() {
+			return host
+		}
+	}
+
+	return primaryIP()
+}
+
+// primaryIP returns the source address of the default route. No packets are sent.
+func primaryIP() string {
+	conn, err := net.Dial("udp", "192.0.2.1:80")
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
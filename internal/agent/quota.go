@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+)
+
+// BranchLimits caps how many branches may exist. Zero means unlimited.
+type BranchLimits struct {
+	PerTemplate int `json:"perTemplate"`
+	PerHost     int `json:"perHost"`
+}
+
+func (l BranchLimits) validate() error {
+	if l.PerTemplate < 0 || l.PerHost < 0 {
+		return fmt.Errorf("limits must be zero (unlimited) or positive")
+	}
+	return nil
+}
+
+type QuotaExceededError struct {
+	Scope string
+	Count int
+	Limit int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("branch limit reached for %s: %d of %d branches in use. Delete unused branches to free capacity", e.Scope, e.Count, e.Limit)
+}
+
+// checkBranchQuota must be called with checkoutMutex held so counts can't race.
+func (s *AgentService) checkBranchQuota(ctx context.Context, template string) error {
+	limits := s.Config().BranchLimits
+
+	if limits.PerTemplate > 0 {
+		branches, err := s.ListBranches(ctx, template)
+		if err != nil {
+			return fmt.Errorf("counting template branches: %w", err)
+		}
+		if len(branches) >= limits.PerTemplate {
+			return &QuotaExceededError{Scope: fmt.Sprintf("template '%s'", template), Count: len(branches), Limit: limits.PerTemplate}
+		}
+	}
+
+	if limits.PerHost > 0 {
+		branches, err := s.ListBranches(ctx, "")
+		if err != nil {
+			return fmt.Errorf("counting host branches: %w", err)
+		}
+		if len(branches) >= limits.PerHost {
+			return &QuotaExceededError{Scope: "this host", Count: len(branches), Limit: limits.PerHost}
+		}
+	}
+
+	return nil
+}
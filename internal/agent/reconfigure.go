@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SettingsChange reports how settings changed by ReconfigureBranch took effect.
+type SettingsChange struct {
+	// Applied by reloading the configuration
+	Reloaded []string
+	// Only take effect on server start, so the branch was restarted
+	Restarted []string
+}
+
+// ReconfigureBranch sets name=value pairs, see ValidateBranchSettings, in a
+// running branch's postgresql.auto.conf. The configuration is reloaded, and
+// the branch restarted when pg_settings says a setting needs it. Values
+// PostgreSQL refuses leave the previous postgresql.auto.conf in place. Frozen
+// branches are refused.
+func (s *AgentService) ReconfigureBranch(ctx context.Context, template string, branchName string, pairs []string, changedBy string) (*SettingsChange, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+	settings, err := ValidateBranchSettings(pairs)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, fmt.Errorf("no settings given")
+	}
+
+	op, done := s.beginOperation(OpConfigureBranch, branchTarget(template, branchName), changedBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	branch, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if branch == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if branch.Detached {
+		return nil, fmt.Errorf("branch '%s' is detached, attach it first", branchName)
+	}
+	if branch.Frozen {
+		return nil, fmt.Errorf("branch '%s' is frozen, unfreeze it first", branchName)
+	}
+
+	names := slices.Sorted(maps.Keys(settings))
+	restart, err := settingsNeedingRestart(branch.Port, names)
+	if err != nil {
+		return nil, err
+	}
+
+	autoConfPath := filepath.Join(branch.BranchPath, "postgresql.auto.conf")
+	previous, err := privileged("cat", autoConfPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading postgresql.auto.conf: %w", err)
+	}
+	if err := updateAutoConf(branch.BranchPath, settings); err != nil {
+		return nil, err
+	}
+
+	serviceName := GetBranchServiceName(template, branchName)
+	stopped := false
+	rollback := func(cause error) (*SettingsChange, error) {
+		cmd := privileged("tee", autoConfPath)
+		cmd.Stdin = bytes.NewReader(previous)
+		if err := cmd.Run(); err != nil {
+			log.Printf("Warning: failed to restore postgresql.auto.conf of branch %s: %v", branchName, err)
+			return nil, cause
+		}
+		if stopped {
+			if err := startBranchService(serviceName, branch.BranchPath); err != nil {
+				log.Printf("Warning: failed to restart branch %s with its previous settings: %v", branchName, err)
+			}
+		} else if _, err := ExecPostgresCommand(branch.Port, "postgres", "SELECT pg_reload_conf();"); err != nil {
+			log.Printf("Warning: failed to reload the previous settings of branch %s: %v", branchName, err)
+		}
+		return nil, cause
+	}
+
+	// pg_file_settings parses the files as they are now, before anything applies them
+	if err := checkFileSettings(branch.Port); err != nil {
+		return rollback(err)
+	}
+
+	change := &SettingsChange{}
+	if len(restart) > 0 {
+		if err := StopService(serviceName); err != nil {
+			return nil, err
+		}
+		stopped = true
+		if err := startBranchService(serviceName, branch.BranchPath); err != nil {
+			return rollback(err)
+		}
+		if err := waitForPostgreSQLReady(branch.BranchPath, branchStartTimeout); err != nil {
+			return rollback(fmt.Errorf("waiting for branch to accept connections: %w\n%s", err, ServiceLogs(serviceName, 20)))
+		}
+		change.Restarted = restart
+	} else {
+		if _, err := ExecPostgresCommand(branch.Port, "postgres", "SELECT pg_reload_conf();"); err != nil {
+			return rollback(fmt.Errorf("reloading configuration: %w", err))
+		}
+		pending, err := settingsPendingRestart(branch.Port, names)
+		if err != nil {
+			return rollback(err)
+		}
+		if len(pending) > 0 {
+			return rollback(fmt.Errorf("settings %s didn't apply on reload and need a restart", strings.Join(pending, ", ")))
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(restart, name) {
+			change.Reloaded = append(change.Reloaded, name)
+		}
+	}
+
+	if branch.Settings == nil {
+		branch.Settings = make(map[string]string)
+	}
+	maps.Copy(branch.Settings, settings)
+	branch.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	branch.LastAccessedAt = branch.UpdatedAt
+	if err := saveCheckoutMetadata(branch); err != nil {
+		return nil, fmt.Errorf("saving branch metadata: %w", err)
+	}
+
+	auditEvent("branch_reconfigure", map[string]interface{}{
+		"template_name": template,
+		"branch_name":   branchName,
+		"settings":      settings,
+		"restarted":     len(restart) > 0,
+		"changed_by":    changedBy,
+	})
+
+	return change, nil
+}
+
+// checkFileSettings fails when PostgreSQL refuses a line of its configuration
+// files, such as a value out of range or not one of a setting's options.
+func checkFileSettings(port string) error {
+	output, err := ExecPostgresCommand(port, "postgres",
+		"SELECT coalesce(name, '') || ': ' || error FROM pg_file_settings WHERE error IS NOT NULL;")
+	if err != nil {
+		return fmt.Errorf("checking configuration files: %w", err)
+	}
+	if output != "" {
+		return fmt.Errorf("invalid branch settings: %s", strings.ReplaceAll(output, "\n", "; "))
+	}
+	return nil
+}
+
+// settingsPendingRestart returns the names whose new values the server
+// couldn't apply without a restart. Names must be validated, like for
+// settingsNeedingRestart.
+func settingsPendingRestart(port string, names []string) ([]string, error) {
+	query := fmt.Sprintf("SELECT name FROM pg_settings WHERE pending_restart AND name IN ('%s');", strings.Join(names, "', '"))
+	output, err := ExecPostgresCommand(port, "postgres", query)
+	if err != nil {
+		return nil, fmt.Errorf("checking settings pending restart: %w", err)
+	}
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
+
+// settingsNeedingRestart returns the names that PostgreSQL only reads at
+// server start. Names must be validated, they're quoted into the query as is.
+// Settings of libraries that aren't loaded aren't in pg_settings, they apply
+// to new sessions like any reloadable setting.
+func settingsNeedingRestart(port string, names []string) ([]string, error) {
+	query := fmt.Sprintf("SELECT name FROM pg_settings WHERE context = 'postmaster' AND name IN ('%s');", strings.Join(names, "', '"))
+	output, err := ExecPostgresCommand(port, "postgres", query)
+	if err != nil {
+		return nil, fmt.Errorf("checking setting contexts: %w", err)
+	}
+	if output == "" {
+		return nil, nil
+	}
+	return strings.Split(output, "\n"), nil
+}
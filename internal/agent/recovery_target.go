@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// How long template setup waits for a point-in-time restore to reach its
+// target before leaving the replay running in the background
+const recoveryTargetWait = 2 * time.Minute
+
+// Logged by PostgreSQL when the WAL archive ends before the recovery target
+const recoveryTargetMissedMessage = "recovery ended before configured recovery target was reached"
+
+const (
+	// Still replaying WAL towards the target time
+	RecoveryReplaying = "replaying"
+	// Replay paused at the target time, ready for branching
+	RecoveryPaused = "paused"
+	// Recovery ended, e.g. someone promoted the template
+	RecoveryPromoted = "promoted"
+)
+
+type RecoveryTargetStatus struct {
+	State string
+	// Last WAL location replayed
+	LSN string
+}
+
+// ValidateTargetTime parses a requested recovery target time, which must be
+// in the past.
+func ValidateTargetTime(value string, now time.Time) (time.Time, error) {
+	target, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("target time must be RFC3339, e.g. 2024-01-02T15:04:05Z, got '%s'", value)
+	}
+	if target.After(now) {
+		return time.Time{}, fmt.Errorf("target time %s is in the future", value)
+	}
+	return target, nil
+}
+
+// pgBackRestTargetTime formats a target time for pgBackRest, which hands it
+// to PostgreSQL's recovery_target_time.
+func pgBackRestTargetTime(target time.Time) string {
+	return target.UTC().Format("2006-01-02 15:04:05.999999") + "+00"
+}
+
+// checkTargetTimeInBackups fails unless the stanza has a backup that finished
+// before target, so there is a base backup to replay WAL forward from.
+func checkTargetTimeInBackups(configPath, stanza string, repo int, target time.Time) error {
+	output, err := privileged("pgbackrest", "info",
+		"--stanza="+stanza,
+		fmt.Sprintf("--repo=%d", repo),
+		"--config="+configPath,
+		"--output=json").Output()
+	if err != nil {
+		return fmt.Errorf("pgbackrest info: %w", err)
+	}
+
+	var stanzas []pgBackRestInfo
+	if err := json.Unmarshal(output, &stanzas); err != nil {
+		return fmt.Errorf("parsing pgbackrest info: %w", err)
+	}
+
+	var oldest time.Time
+	for _, info := range stanzas {
+		for _, backup := range info.Backup {
+			stop := time.Unix(backup.Timestamp.Stop, 0)
+			if oldest.IsZero() || stop.Before(oldest) {
+				oldest = stop
+			}
+		}
+	}
+
+	if oldest.IsZero() {
+		return fmt.Errorf("stanza %s has no backups", stanza)
+	}
+	if target.Before(oldest) {
+		return fmt.Errorf("target time %s is before the oldest backup, which finished at %s", target.UTC().Format(time.RFC3339), oldest.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// getRecoveryTargetStatus reports how far the server on port has replayed.
+func getRecoveryTargetStatus(port string) (*RecoveryTargetStatus, error) {
+	output, err := ExecPostgresCommand(port, "postgres", `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() THEN 'promoted'
+			WHEN pg_is_wal_replay_paused() THEN 'paused'
+			ELSE 'replaying'
+		END || '|' || coalesce(pg_last_wal_replay_lsn()::text, '')`)
+	if err != nil {
+		return nil, fmt.Errorf("reading recovery status: %w", err)
+	}
+
+	state, lsn, _ := strings.Cut(output, "|")
+	return &RecoveryTargetStatus{State: state, LSN: lsn}, nil
+}
+
+// checkRecoveryTargetReached fails while a template restored to a target time
+// is still replaying towards it. Other templates always pass.
+func checkRecoveryTargetReached(templatePath string) error {
+	meta, err := loadTemplateMetadata(templatePath)
+	if err != nil || meta.TargetTime == "" {
+		return nil
+	}
+
+	pid, running := getPostmasterPid(templatePath)
+	if !running {
+		return fmt.Errorf("template is not running")
+	}
+	status, err := getRecoveryTargetStatus(pid.Port)
+	if err != nil {
+		return err
+	}
+	if status.State == RecoveryReplaying {
+		return fmt.Errorf("template is still replaying WAL up to its target time %s (replayed up to %s). Please retry in a few moments", meta.TargetTime, status.LSN)
+	}
+
+	return nil
+}
+
+// waitForRecoveryTarget waits for a template started at started to pause at
+// its target time. It returns a replaying status when the replay takes longer
+// than recoveryTargetWait, and an error when PostgreSQL gave up because the
+// WAL archive doesn't reach the target.
+func waitForRecoveryTarget(mountPath, serviceName string, started time.Time) (*RecoveryTargetStatus, error) {
+	deadline := time.Now().Add(recoveryTargetWait)
+	status := &RecoveryTargetStatus{State: RecoveryReplaying}
+
+	for time.Now().Before(deadline) {
+		// systemd restarts the failed server, so look for the reason in its
+		// logs. Only since started, a delta restore keeps older runs' logs.
+		logs, _ := privileged("journalctl", "-u", serviceName, "--since", fmt.Sprintf("@%d", started.Unix()), "--no-pager").Output()
+		if strings.Contains(string(logs), recoveryTargetMissedMessage) {
+			return nil, fmt.Errorf("the WAL archive ends before the target time, pick an earlier time. See `journalctl -u %s`", serviceName)
+		}
+
+		if pid, running := getPostmasterPid(mountPath); running && IsPostgreSQLServerReady(mountPath) {
+			current, err := getRecoveryTargetStatus(pid.Port)
+			if err == nil {
+				status = current
+				if status.State != RecoveryReplaying {
+					return status, nil
+				}
+			}
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	return status, nil
+}
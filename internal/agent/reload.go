@@ -0,0 +1,59 @@
+package agent
+
+// ReloadConfig applies a config re-read from the config file to the running
+// service. Settings read per request, like limits, the webhook and the drain
+// timeout, take effect right away. Sections applied when quicd starts keep
+// their current values, and the names of those that changed are returned
+// since they need a restart.
+func (s *AgentService) ReloadConfig(cfg *AgentConfig) []string {
+	current := s.Config()
+
+	var needRestart []string
+	if current.TLS != cfg.TLS {
+		needRestart = append(needRestart, "tls")
+		cfg.TLS = current.TLS
+	}
+	if current.Postgres != cfg.Postgres {
+		needRestart = append(needRestart, "postgres")
+		cfg.Postgres = current.Postgres
+	}
+	if current.Storage != cfg.Storage {
+		needRestart = append(needRestart, "storage")
+		cfg.Storage = current.Storage
+	}
+	if current.Firewall != cfg.Firewall {
+		needRestart = append(needRestart, "firewall")
+		cfg.Firewall = current.Firewall
+	}
+	if current.Privilege != cfg.Privilege {
+		needRestart = append(needRestart, "privilege")
+		cfg.Privilege = current.Privilege
+	}
+	if current.StartTimeout != cfg.StartTimeout {
+		needRestart = append(needRestart, "startTimeout")
+		cfg.StartTimeout = current.StartTimeout
+	}
+	if current.Log != cfg.Log {
+		needRestart = append(needRestart, "log")
+		cfg.Log = current.Log
+	}
+	if current.Metrics != cfg.Metrics {
+		needRestart = append(needRestart, "metrics")
+		cfg.Metrics = current.Metrics
+	}
+
+	s.SetConfig(cfg)
+
+	if current.SnapshotGC != cfg.SnapshotGC {
+		select {
+		case s.snapshotGCReload <- struct{}{}:
+		default:
+		}
+	}
+
+	auditEvent("config_reload", map[string]interface{}{
+		"restart_required": needRestart,
+	})
+
+	return needRestart
+}
@@ -0,0 +1,301 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GetReplicaSnapshot names the snapshot of a branch that its replica is cloned from.
+func GetReplicaSnapshot(template, branch, replica string) string {
+	return GetBranchDataset(template, branch) + "@replica-" + replica
+}
+
+// ReplicaResult is a replica created by CreateReplica.
+type ReplicaResult struct {
+	*BranchInfo
+	// The parent was restarted to enable replication
+	ParentRestarted bool
+}
+
+// CreateReplica starts a read-only hot standby of a running branch. It's a
+// branch of the same template, cloned from a snapshot of the parent and
+// streaming its WAL, with its own port and service. Without a name the first
+// free <branch>-replica-<n> is used.
+func (s *AgentService) CreateReplica(ctx context.Context, template string, branchName string, replicaName string, createdBy string) (*ReplicaResult, error) {
+	branchName, err := ValidateBranchName(branchName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+	if replicaName == "" {
+		replicaName = nextReplicaName(template, branchName)
+	}
+	replicaName, err = ValidateBranchName(replicaName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replica name: %w", err)
+	}
+
+	op, done := s.beginOperation(OpCreateReplica, branchTarget(template, replicaName), createdBy)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	parent, err := s.getBranchMetadata(GetBranchDataset(template, branchName))
+	if err != nil {
+		return nil, fmt.Errorf("loading branch: %w", err)
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("branch '%s' not found", branchName)
+	}
+	if parent.Detached {
+		return nil, fmt.Errorf("branch '%s' is detached, attach it first", branchName)
+	}
+	if parent.ReplicaOf != "" {
+		return nil, fmt.Errorf("branch '%s' is a replica of '%s', create replicas of '%s' instead", branchName, parent.ReplicaOf, parent.ReplicaOf)
+	}
+	if !IsPostgreSQLServerReady(parent.BranchPath) {
+		return nil, fmt.Errorf("branch '%s' is not accepting connections", branchName)
+	}
+
+	replicaDataset := GetBranchDataset(template, replicaName)
+	existing, err := s.getBranchMetadata(replicaDataset)
+	if err != nil {
+		return nil, fmt.Errorf("checking existing branch: %w", err)
+	}
+	if existing != nil && existing.ReplicaOf == branchName {
+		return &ReplicaResult{BranchInfo: existing}, nil // Already exists
+	}
+	if existing != nil || datasetExists(replicaDataset) {
+		return nil, fmt.Errorf("branch '%s' already exists", replicaName)
+	}
+
+	if err := checkServiceNameFree(template, replicaName); err != nil {
+		return nil, err
+	}
+	if err := s.checkBranchQuota(ctx, template); err != nil {
+		return nil, err
+	}
+	if err := checkPoolSpace(ZPool); err != nil {
+		return nil, err
+	}
+
+	port, err := s.findAvailablePort(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("finding available port: %w", err)
+	}
+
+	result := &ReplicaResult{}
+	enabled, err := replicationEnabled(parent.Port)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		if err := s.enableReplication(parent); err != nil {
+			return nil, fmt.Errorf("enabling replication on branch '%s': %w", branchName, err)
+		}
+		result.ParentRestarted = true
+	}
+
+	// The standby replays from the snapshot's last checkpoint, keep that short
+	if _, err := ExecPostgresCommand(parent.Port, "postgres", "CHECKPOINT;"); err != nil {
+		return nil, fmt.Errorf("checkpointing branch: %w", err)
+	}
+	snapshot := GetReplicaSnapshot(template, branchName, replicaName)
+	mountpoint := GetBranchMountpoint(template, replicaName)
+
+	// Undo the partial replica: its service, clone and the parent's snapshot
+	fail := func(cause error) (*ReplicaResult, error) {
+		partial := &BranchInfo{Port: port, BranchPath: mountpoint, ReplicaOf: branchName}
+		if err := removeBranch(template, replicaName, partial); err != nil {
+			log.Printf("Warning: failed to clean up replica %s: %v", replicaName, err)
+		}
+		return nil, cause
+	}
+
+	if !snapshotExists(snapshot) {
+		if err := createSnapshot(snapshot); err != nil {
+			return fail(err)
+		}
+	}
+	if err := createClone(snapshot, replicaDataset, mountpoint); err != nil {
+		return fail(err)
+	}
+
+	if err := prepareReplicaForStartup(mountpoint, parent, replicaName); err != nil {
+		return fail(fmt.Errorf("preparing replica for startup: %w", err))
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	replica := &BranchInfo{
+		TemplateName:   template,
+		BranchName:     replicaName,
+		Port:           port,
+		BranchPath:     mountpoint,
+		AdminPassword:  parent.AdminPassword, // Roles are replicated from the parent
+		Extensions:     parent.Extensions,
+		RoleMode:       parent.RoleMode,
+		Settings:       parent.Settings,
+		MaxConnections: parent.MaxConnections,
+		Profile:        parent.Profile,
+		ReplicaOf:      branchName,
+		// Streaming keeps the replica as recent as its parent
+		BackupFinishedAt: parent.BackupFinishedAt,
+		DataAsOf:         parent.DataAsOf,
+		CreatedBy:        createdBy,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		LastAccessedAt:   now,
+	}
+	if err := saveCheckoutMetadata(replica); err != nil {
+		return fail(fmt.Errorf("saving replica metadata: %w", err))
+	}
+
+	if err := CreateBranchService(template, replicaName, mountpoint, port); err != nil {
+		return fail(fmt.Errorf("creating systemd service: %w", err))
+	}
+	serviceName := GetBranchServiceName(template, replicaName)
+	if err := StartService(serviceName); err != nil {
+		return fail(fmt.Errorf("starting systemd service: %w", err))
+	}
+	// A hot standby accepts connections once it replayed to a consistent state
+	if err := waitForPostgreSQLReady(mountpoint, branchStartTimeout); err != nil {
+		return fail(fmt.Errorf("waiting for replica to accept connections: %w\n%s", err, ServiceLogs(serviceName, 20)))
+	}
+
+	if err := openFirewallPort(port); err != nil {
+		return fail(fmt.Errorf("opening firewall port: %w", err))
+	}
+
+	auditEvent("replica_create", auditedBranch(replica))
+	s.updatePortConflicts()
+	s.publishEvent(LifecycleEvent{
+		Event:    "branch_create",
+		Template: template,
+		Branch:   replicaName,
+		User:     createdBy,
+		Host:     s.PublicHost(ctx),
+		Port:     port,
+	})
+
+	result.BranchInfo = replica
+	return result, nil
+}
+
+// nextReplicaName returns the first <branch>-replica-<n> without a dataset.
+func nextReplicaName(template, branch string) string {
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("%s-replica-%d", branch, n)
+		if !datasetExists(GetBranchDataset(template, name)) {
+			return name
+		}
+	}
+}
+
+// replicationEnabled reports whether the server on port can stream WAL to
+// standbys. Branches start with wal_level minimal and no WAL senders.
+func replicationEnabled(port string) (bool, error) {
+	output, err := ExecPostgresCommand(port, "postgres",
+		"SELECT current_setting('wal_level') <> 'minimal' AND current_setting('max_wal_senders')::int > 0;")
+	if err != nil {
+		return false, fmt.Errorf("checking replication settings: %w", err)
+	}
+	return output == "t", nil
+}
+
+// enableReplication sets up a branch to stream WAL to replicas. The settings
+// are only read at server start, so the branch is restarted.
+func (s *AgentService) enableReplication(branch *BranchInfo) error {
+	settings := map[string]string{
+		"wal_level":       "replica",
+		"max_wal_senders": "10", // PostgreSQL's default
+	}
+	if err := updateAutoConf(branch.BranchPath, settings); err != nil {
+		return err
+	}
+	// Branches created before replicas existed lack the replication entry
+	if err := writePgHba(branch.BranchPath, s.Config().PgHba); err != nil {
+		return err
+	}
+
+	serviceName := GetBranchServiceName(branch.TemplateName, branch.BranchName)
+	if err := StopService(serviceName); err != nil {
+		return err
+	}
+	if err := startBranchService(serviceName, branch.BranchPath); err != nil {
+		return err
+	}
+	if err := waitForPostgreSQLReady(branch.BranchPath, branchStartTimeout); err != nil {
+		return fmt.Errorf("waiting for branch to accept connections: %w\n%s", err, ServiceLogs(serviceName, 20))
+	}
+
+	auditEvent("replication_enable", map[string]interface{}{
+		"template_name": branch.TemplateName,
+		"branch_name":   branch.BranchName,
+	})
+	return nil
+}
+
+// prepareReplicaForStartup configures a clone of parent as a standby
+// streaming from it over the local socket. The clone keeps the parent's
+// settings, which a hot standby needs at least as high as its primary's.
+func prepareReplicaForStartup(replicaPath string, parent *BranchInfo, replicaName string) error {
+	for _, name := range []string{"postmaster.pid", "recovery.signal", ".quic-meta.json"} {
+		if err := privileged("rm", "-f", filepath.Join(replicaPath, name)).Run(); err != nil {
+			return fmt.Errorf("removing %s: %w", name, err)
+		}
+	}
+	cmd := privileged("tee", filepath.Join(replicaPath, "standby.signal"))
+	cmd.Stdin = strings.NewReader("")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("creating standby.signal: %w", err)
+	}
+
+	// Archiving is left to the parent
+	return updateAutoConf(replicaPath, map[string]string{
+		"primary_conninfo": fmt.Sprintf("host=%s port=%s user=postgres application_name=%s", PgSocketDir, parent.Port, replicaName),
+		"archive_mode":     "off",
+		"archive_command":  "",
+		"archive_timeout":  "",
+	})
+}
+
+// branchReplicas returns the replicas of a branch.
+func (s *AgentService) branchReplicas(ctx context.Context, template, branchName string) ([]*BranchInfo, error) {
+	branches, err := s.ListBranches(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []*BranchInfo
+	for _, branch := range branches {
+		if branch.ReplicaOf == branchName {
+			replicas = append(replicas, branch)
+		}
+	}
+	return replicas, nil
+}
+
+// removeReplicas deletes the replicas of a branch before the branch itself,
+// whose snapshots they're cloned from.
+func (s *AgentService) removeReplicas(ctx context.Context, template, branchName string) ([]string, error) {
+	replicas, err := s.branchReplicas(ctx, template, branchName)
+	if err != nil {
+		return nil, fmt.Errorf("listing replicas: %w", err)
+	}
+
+	var removed []string
+	for _, replica := range replicas {
+		if err := removeBranch(template, replica.BranchName, replica); err != nil {
+			return removed, fmt.Errorf("deleting replica '%s': %w", replica.BranchName, err)
+		}
+		auditEvent("branch_delete", auditedBranch(replica))
+		removed = append(removed, replica.BranchName)
+	}
+	return removed, nil
+}
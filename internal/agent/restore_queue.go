@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const restoreQueuePollInterval = 2 * time.Second
+
+// restoreQueue bounds how many template restores run at once on this host.
+// Waiters are served in FIFO order.
+type restoreQueue struct {
+	mu      sync.Mutex
+	running int
+	waiting []*restoreWaiter
+}
+
+type restoreWaiter struct {
+	ready chan struct{}
+}
+
+// acquire blocks until a restore slot is free. report is called with the
+// 1-based queue position whenever it changes while waiting.
+func (q *restoreQueue) acquire(ctx context.Context, slots int, report func(position int)) error {
+	q.mu.Lock()
+	if q.running < max(slots, 1) && len(q.waiting) == 0 {
+		q.running++
+		q.mu.Unlock()
+		return nil
+	}
+
+	w := &restoreWaiter{ready: make(chan struct{})}
+	q.waiting = append(q.waiting, w)
+	lastPosition := len(q.waiting)
+	q.mu.Unlock()
+
+	report(lastPosition)
+
+	ticker := time.NewTicker(restoreQueuePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ready:
+			return nil
+		case <-ctx.Done():
+			q.mu.Lock()
+			if i := slices.Index(q.waiting, w); i >= 0 {
+				q.waiting = slices.Delete(q.waiting, i, i+1)
+				q.mu.Unlock()
+				return ctx.Err()
+			}
+			q.mu.Unlock()
+			// The slot was handed over while cancelling
+			q.release()
+			return ctx.Err()
+		case <-ticker.C:
+			q.mu.Lock()
+			position := slices.Index(q.waiting, w) + 1
+			q.mu.Unlock()
+			if position > 0 && position != lastPosition {
+				lastPosition = position
+				report(position)
+			}
+		}
+	}
+}
+
+func (q *restoreQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.waiting) > 0 {
+		// Hand the slot directly to the next waiter
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		close(next.ready)
+		return
+	}
+
+	q.running--
+}
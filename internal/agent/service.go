@@ -10,10 +10,44 @@ import (
 type AgentService struct {
 	checkoutMutex  sync.Mutex
 	shutdownSignal atomic.Bool
+
+	configMutex sync.RWMutex
+	config      *AgentConfig
+
+	idempotencyMutex sync.Mutex
+	idempotencyKeys  map[idempotencyScope]*idempotencyEntry
+
+	restoreQueue restoreQueue
+	operations   operationTracker
+	events       eventBus
+
+	// See refreshPortConflicts
+	portConflictsRefresh sync.Mutex
+	portConflictsMutex   sync.Mutex
+	portConflicts        []PortConflict
+
+	// Wakes the snapshot GC when a reload changed its interval
+	snapshotGCReload chan struct{}
 }
 
 func NewCheckoutService() *AgentService {
-	return &AgentService{}
+	return &AgentService{
+		config:           DefaultAgentConfig(),
+		idempotencyKeys:  make(map[idempotencyScope]*idempotencyEntry),
+		snapshotGCReload: make(chan struct{}, 1),
+	}
+}
+
+func (s *AgentService) SetConfig(cfg *AgentConfig) {
+	s.configMutex.Lock()
+	defer s.configMutex.Unlock()
+	s.config = cfg
+}
+
+func (s *AgentService) Config() *AgentConfig {
+	s.configMutex.RLock()
+	defer s.configMutex.RUnlock()
+	return s.config
 }
 
 // Attempts to acquire the checkout lock while respecting shutdown signal.
@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotKindProperty is the ZFS user property quic sets on the template
+// snapshots it creates. Garbage collection only considers snapshots carrying
+// it.
+const SnapshotKindProperty = "quic:kind"
+
+const (
+	// Snapshot a branch was cloned from, named after the branch
+	SnapshotKindBranch = "branch"
+	// Snapshot another template was cloned from, see GetTemplateSourceSnapshot
+	SnapshotKindTemplate = "template"
+)
+
+// OrphanSnapshot is a template snapshot nothing is cloned from anymore.
+type OrphanSnapshot struct {
+	Name string
+	Kind string
+	// Bytes only this snapshot holds
+	UsedBytes int64
+}
+
+type SnapshotGCResult struct {
+	Orphans []OrphanSnapshot
+	// Snapshots still cloned by a branch or template
+	InUse int
+	// Space freed by destroying the orphans, or that would be freed on a dry run
+	ReclaimedBytes int64
+}
+
+type SnapshotGCConfig struct {
+	// How often quicd collects orphaned snapshots of every template, as a Go
+	// duration. Empty disables automatic collection.
+	Interval string `json:"interval"`
+}
+
+func (c SnapshotGCConfig) validate() error {
+	if c.Interval == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(c.Interval)
+	if err != nil || d < time.Minute {
+		return fmt.Errorf("interval must be a duration of at least 1m, got %q", c.Interval)
+	}
+	return nil
+}
+
+// CollectTemplateSnapshots destroys snapshots of the template dataset that no
+// branch or template is cloned from, e.g. left behind by a branch deletion
+// that failed halfway. Snapshots quic didn't create are never touched.
+func (s *AgentService) CollectTemplateSnapshots(ctx context.Context, template string, dryRun bool, user string) (*SnapshotGCResult, error) {
+	op, done := s.beginOperation(OpCollectSnapshots, template, user)
+	defer done()
+
+	// Branch creation snapshots before it clones, so hold the lock to not
+	// mistake a snapshot about to be cloned for an orphan
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	dataset := GetTemplateDataset(template)
+	if !datasetExists(dataset) {
+		return nil, fmt.Errorf("template '%s' not found", template)
+	}
+
+	result, err := findOrphanSnapshots(dataset)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Orphans) == 0 {
+		return result, nil
+	}
+
+	// Orphans may share blocks, so the space they free together can be more
+	// than the sum of their used bytes
+	result.ReclaimedBytes, err = estimateReclaimedSpace(dataset, result.Orphans)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	for _, orphan := range result.Orphans {
+		// Without -R, so a snapshot cloned in the meantime makes this fail
+		// rather than take the clone with it
+		if err := destroyDataset(orphan.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	auditEvent("template_snapshot_gc", map[string]interface{}{
+		"template_name":   template,
+		"snapshots":       len(result.Orphans),
+		"reclaimed_bytes": result.ReclaimedBytes,
+		"collected_by":    user,
+	})
+
+	return result, nil
+}
+
+// findOrphanSnapshots lists the snapshots of dataset without clones. Only
+// snapshots tagged with SnapshotKindProperty are considered.
+func findOrphanSnapshots(dataset string) (*SnapshotGCResult, error) {
+	output, err := privileged("zfs", "list", "-Hp", "-t", "snapshot", "-d", "1", "-o", "name,used,clones,"+SnapshotKindProperty, dataset).Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing ZFS snapshots of %s: %w", dataset, err)
+	}
+
+	result := &SnapshotGCResult{}
+	for line := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		name, clones, kind := fields[0], fields[2], fields[3]
+		// "-" when unset, i.e. created outside quic
+		if kind != SnapshotKindBranch && kind != SnapshotKindTemplate {
+			continue
+		}
+
+		if clones != "" && clones != "-" {
+			result.InUse++
+			continue
+		}
+
+		used, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing used bytes of %s: %w", name, err)
+		}
+		result.Orphans = append(result.Orphans, OrphanSnapshot{Name: name, Kind: kind, UsedBytes: used})
+	}
+
+	return result, nil
+}
+
+// estimateReclaimedSpace asks zfs how much destroying the snapshots together would free.
+func estimateReclaimedSpace(dataset string, snapshots []OrphanSnapshot) (int64, error) {
+	names := make([]string, len(snapshots))
+	for i, snapshot := range snapshots {
+		_, names[i], _ = strings.Cut(snapshot.Name, "@")
+	}
+
+	output, err := privileged("zfs", "destroy", "-nvp", dataset+"@"+strings.Join(names, ",")).CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("estimating space of snapshots of %s: %s", dataset, output)
+	}
+
+	for line := range strings.SplitSeq(string(output), "\n") {
+		if value, ok := strings.CutPrefix(line, "reclaim\t"); ok {
+			return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("unexpected zfs destroy output: %s", output)
+}
+
+// StartSnapshotGC collects orphaned snapshots of every template on the
+// configured interval until shutdown. Without an interval it waits for a
+// config reload to set one.
+func (s *AgentService) StartSnapshotGC() {
+	go func() {
+		for !s.shutdownSignal.Load() {
+			interval := s.Config().SnapshotGC.Interval
+			if interval == "" {
+				<-s.snapshotGCReload
+				continue
+			}
+			// Validated by LoadAgentConfig
+			d, _ := time.ParseDuration(interval)
+
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+				if !s.shutdownSignal.Load() {
+					s.collectAllTemplateSnapshots()
+				}
+			case <-s.snapshotGCReload:
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+func (s *AgentService) collectAllTemplateSnapshots() {
+	output, err := privileged("zfs", "list", "-H", "-o", "name", "-d", "1", ZPool).Output()
+	if err != nil {
+		log.Printf("Snapshot GC: listing templates: %v", err)
+		return
+	}
+
+	for dataset := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		template, ok := strings.CutPrefix(dataset, ZPool+"/")
+		if !ok {
+			continue
+		}
+
+		result, err := s.CollectTemplateSnapshots(context.Background(), template, false, "quicd")
+		if err != nil {
+			log.Printf("Snapshot GC: template %s: %v", template, err)
+			continue
+		}
+		if len(result.Orphans) > 0 {
+			log.Printf("Snapshot GC: destroyed %d snapshots of template %s, reclaimed %d bytes", len(result.Orphans), template, result.ReclaimedBytes)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// postmasterRunning tells whether the process recorded in a data directory's
+// postmaster.pid is a postmaster serving that directory. After a reboot the
+// PID is often taken by an unrelated process, or another branch's server,
+// which PostgreSQL mistakes for a running instance and refuses to start.
+func postmasterRunning(dataDir string, pid PostmasterPid) bool {
+	cmdline, err := privileged("cat", "/proc/"+pid.PID+"/cmdline").Output()
+	if err != nil {
+		return false
+	}
+
+	args := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	if len(args) == 0 || filepath.Base(string(args[0])) != "postgres" {
+		return false
+	}
+	for i, arg := range args[:len(args)-1] {
+		if string(arg) == "-D" && filepath.Clean(string(args[i+1])) == filepath.Clean(dataDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeStalePostmasterPid removes a data directory's postmaster.pid when no
+// postmaster serving the directory runs under the recorded PID, e.g. after a
+// crash or an ungraceful reboot. It reports whether a stale file was removed.
+func removeStalePostmasterPid(dataDir string) (bool, error) {
+	pid, exists := getPostmasterPid(dataDir)
+	if !exists || postmasterRunning(dataDir, pid) {
+		return false, nil
+	}
+
+	if err := privileged("rm", "-f", filepath.Join(dataDir, "postmaster.pid")).Run(); err != nil {
+		return false, fmt.Errorf("removing stale postmaster.pid: %w", err)
+	}
+	log.Printf("Removed stale postmaster.pid of %s (PID %s is not its postmaster)", dataDir, pid.PID)
+	return true, nil
+}
+
+// startBranchService starts a branch's service after clearing a stale
+// postmaster.pid, so branches come back after their server crashed.
+func startBranchService(serviceName, branchPath string) error {
+	removed, err := removeStalePostmasterPid(branchPath)
+	if err != nil {
+		return err
+	}
+	if removed {
+		// systemd gives up on a unit restarting too often, as it does while
+		// PostgreSQL refuses the stale file
+		privileged("systemctl", "reset-failed", serviceName).Run()
+	}
+	return StartService(serviceName)
+}
+
+// RecoverStaleBranches restarts branches whose server doesn't run because of a
+// stale postmaster.pid, which happens to every running branch when the host
+// reboots ungracefully. Detached branches and branches failing for other
+// reasons are left alone.
+func (s *AgentService) RecoverStaleBranches() {
+	branches, err := s.ListBranches(context.Background(), "")
+	if err != nil {
+		log.Printf("Stale branch recovery: listing branches: %v", err)
+		return
+	}
+
+	for _, branch := range branches {
+		serviceName := GetBranchServiceName(branch.TemplateName, branch.BranchName)
+		if branch.Detached || GetServiceStatus(serviceName) == "active" {
+			continue
+		}
+
+		removed, err := removeStalePostmasterPid(branch.BranchPath)
+		if err != nil {
+			log.Printf("Stale branch recovery: branch %s/%s: %v", branch.TemplateName, branch.BranchName, err)
+			continue
+		}
+		if !removed {
+			continue
+		}
+
+		privileged("systemctl", "reset-failed", serviceName).Run()
+		if err := StartService(serviceName); err != nil {
+			log.Printf("Stale branch recovery: branch %s/%s: %v", branch.TemplateName, branch.BranchName, err)
+			continue
+		}
+		log.Printf("Stale branch recovery: restarted branch %s/%s", branch.TemplateName, branch.BranchName)
+	}
+}
@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// Templates may replay a long WAL backlog before accepting connections
+	defaultTemplateStartTimeout = 20 * time.Minute
+	// Branches start from a snapshot, but may still need crash recovery
+	defaultBranchStartTimeout = 5 * time.Minute
+	minStartTimeout           = 10 * time.Second
+)
+
+// How long PostgreSQL may take to accept connections after a start. Set at
+// startup, see ApplyStartTimeoutConfig.
+var (
+	templateStartTimeout = defaultTemplateStartTimeout
+	branchStartTimeout   = defaultBranchStartTimeout
+)
+
+// StartTimeoutConfig bounds PostgreSQL startup, both the waits of operations
+// that start a server and the TimeoutStartSec of its systemd unit.
+type StartTimeoutConfig struct {
+	// Go durations, 20m for templates and 5m for branches by default
+	Template string `json:"template"`
+	Branch   string `json:"branch"`
+}
+
+func (c StartTimeoutConfig) validate() error {
+	if _, err := parseStartTimeout(c.Template, defaultTemplateStartTimeout); err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+	if _, err := parseStartTimeout(c.Branch, defaultBranchStartTimeout); err != nil {
+		return fmt.Errorf("branch: %w", err)
+	}
+	return nil
+}
+
+func parseStartTimeout(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < minStartTimeout {
+		return 0, fmt.Errorf("must be a duration of at least %v like 5m, got %q", minStartTimeout, value)
+	}
+	return d, nil
+}
+
+// ApplyStartTimeoutConfig sets the startup timeouts. Must be called before serving requests.
+func ApplyStartTimeoutConfig(c StartTimeoutConfig) {
+	templateStartTimeout, _ = parseStartTimeout(c.Template, defaultTemplateStartTimeout)
+	branchStartTimeout, _ = parseStartTimeout(c.Branch, defaultBranchStartTimeout)
+}
+
+// timeoutStartSec renders a timeout for a systemd unit's TimeoutStartSec.
+func timeoutStartSec(timeout time.Duration) int {
+	return int(timeout.Round(time.Second) / time.Second)
+}
@@ -2,10 +2,15 @@ package agent
 
 import (
 	"fmt"
-	"os/exec"
 	"strings"
+	"sync"
 )
 
+// Serializes unit file changes and the daemon-reloads that go with them, so
+// concurrent branch deletions don't reload systemd halfway through another's
+// change.
+var unitMutex sync.Mutex
+
 func GetTemplateServiceName(template string) string {
 	return fmt.Sprintf("quic-%s", template)
 }
@@ -33,14 +38,14 @@ ExecStop=%s stop --pgdata=%s --mode=fast
 ExecReload=/bin/kill -HUP $MAINPID
 KillMode=mixed
 KillSignal=SIGINT
-TimeoutStartSec=10
+TimeoutStartSec=%d
 TimeoutStopSec=30
 Restart=on-failure
 RestartSec=1
 
 [Install]
 WantedBy=multi-user.target
-`, templateName, pgCtlPath(PgVersion), mountPath, port, pgCtlPath(PgVersion), mountPath)
+`, templateName, pgCtlPath(PgVersion), mountPath, port, pgCtlPath(PgVersion), mountPath, timeoutStartSec(templateStartTimeout))
 
 	return writeSystemdService(serviceName, serviceContent)
 }
@@ -60,66 +65,91 @@ ExecStop=%s stop --pgdata=%s --mode=immediate
 ExecReload=/bin/kill -HUP $MAINPID
 KillMode=mixed
 KillSignal=SIGINT
-TimeoutStartSec=10
+TimeoutStartSec=%d
 TimeoutStopSec=30
 Restart=on-failure
 RestartSec=1
 
 [Install]
 WantedBy=multi-user.target
-`, cloneName, pgCtlPath(PgVersion), clonePath, port, pgCtlPath(PgVersion), clonePath)
+`, cloneName, pgCtlPath(PgVersion), clonePath, port, pgCtlPath(PgVersion), clonePath, timeoutStartSec(branchStartTimeout))
 
 	return writeSystemdService(serviceName, serviceContent)
 }
 
 func StartService(serviceName string) error {
-	err := exec.Command("sudo", "systemctl", "start", serviceName).Run()
+	err := privileged("systemctl", "start", serviceName).Run()
 	if err != nil {
 		return fmt.Errorf("starting systemd service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
+// ServiceLogs returns the last lines of a service's journal, for error messages.
+func ServiceLogs(serviceName string, lines int) string {
+	output, err := privileged("journalctl", "-u", serviceName, "-n", fmt.Sprint(lines), "--no-pager").CombinedOutput()
+	if err != nil {
+		return fmt.Sprintf("(failed to read logs: %v)", err)
+	}
+	return strings.TrimSpace(string(output))
+}
+
 func StopService(serviceName string) error {
-	if err := exec.Command("sudo", "systemctl", "stop", serviceName).Run(); err != nil {
+	if err := privileged("systemctl", "stop", serviceName).Run(); err != nil {
 		return fmt.Errorf("stopping systemd service %s: %w", serviceName, err)
 	}
 	return nil
 }
 
 func DeleteService(serviceName string) error {
+	unitMutex.Lock()
+	defer unitMutex.Unlock()
+
 	// Stop the service
-	exec.Command("sudo", "systemctl", "stop", serviceName).Run()
+	privileged("systemctl", "stop", serviceName).Run()
 
 	// Disable the service
-	if err := exec.Command("sudo", "systemctl", "disable", serviceName).Run(); err != nil {
+	if err := privileged("systemctl", "disable", serviceName).Run(); err != nil {
 		return fmt.Errorf("disabling systemd service %s: %w", serviceName, err)
 	}
 
 	// Remove service file
 	serviceFilePath := GetServiceFilePath(serviceName)
-	if err := exec.Command("sudo", "rm", "-f", serviceFilePath).Run(); err != nil {
+	if err := privileged("rm", "-f", serviceFilePath).Run(); err != nil {
 		return fmt.Errorf("removing systemd service file %s: %w", serviceFilePath, err)
 	}
 
 	// Reload systemd daemon
-	if err := exec.Command("sudo", "systemctl", "daemon-reload").Run(); err != nil {
+	if err := privileged("systemctl", "daemon-reload").Run(); err != nil {
 		return fmt.Errorf("reloading systemd daemon: %w", err)
 	}
 
 	return nil
 }
 
+func GetServiceStatus(serviceName string) string {
+	// is-active exits non-zero for inactive services but still prints the state
+	output, _ := privileged("systemctl", "is-active", serviceName).Output()
+	status := strings.TrimSpace(string(output))
+	if status == "" {
+		return "unknown"
+	}
+	return status
+}
+
 func ServiceExists(serviceName string) bool {
-	err := exec.Command("sudo", "systemctl", "cat", serviceName).Run()
+	err := privileged("systemctl", "cat", serviceName).Run()
 	return err == nil
 }
 
 func writeSystemdService(serviceName, serviceContent string) error {
+	unitMutex.Lock()
+	defer unitMutex.Unlock()
+
 	serviceFilePath := GetServiceFilePath(serviceName)
 
 	// Write service file
-	cmd := exec.Command("sudo", "tee", serviceFilePath)
+	cmd := privileged("tee", serviceFilePath)
 	cmd.Stdin = strings.NewReader(serviceContent)
 	cmd.Stdout = nil // Discard tee output
 	if err := cmd.Run(); err != nil {
@@ -127,12 +157,12 @@ func writeSystemdService(serviceName, serviceContent string) error {
 	}
 
 	// Reload systemd daemon
-	if err := exec.Command("sudo", "systemctl", "daemon-reload").Run(); err != nil {
+	if err := privileged("systemctl", "daemon-reload").Run(); err != nil {
 		return fmt.Errorf("reloading systemd daemon: %w", err)
 	}
 
 	// Enable the service
-	if err := exec.Command("sudo", "systemctl", "enable", serviceName).Run(); err != nil {
+	if err := privileged("systemctl", "enable", serviceName).Run(); err != nil {
 		return fmt.Errorf("enabling systemd service: %w", err)
 	}
 
@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tablespaceLink is a symlink in pg_tblspc pointing at a tablespace directory.
+type tablespaceLink struct {
+	OID    string
+	Target string
+}
+
+// listTablespaceLinks returns the tablespace symlinks of a data directory.
+func listTablespaceLinks(dataDir string) ([]tablespaceLink, error) {
+	dir := filepath.Join(dataDir, "pg_tblspc")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing tablespaces in %s: %w", dataDir, err)
+	}
+
+	var links []tablespaceLink
+	for _, entry := range entries {
+		if entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading tablespace %s in %s: %w", entry.Name(), dataDir, err)
+		}
+		links = append(links, tablespaceLink{OID: entry.Name(), Target: target})
+	}
+
+	return links, nil
+}
+
+// checkTemplateTablespaces rejects templates whose tablespaces live outside the
+// template dataset. A clone of such a template would keep writing to the
+// template's tablespace directories, so branching it is refused.
+func checkTemplateTablespaces(templatePath string) error {
+	links, err := listTablespaceLinks(templatePath)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		if !isWithinDir(link.Target, templatePath) {
+			return fmt.Errorf("tablespaces not supported: tablespace %s is stored at %s, outside the template dataset %s", link.OID, link.Target, templatePath)
+		}
+	}
+
+	return nil
+}
+
+// relocateTablespaces re-points tablespace symlinks copied from the template
+// to the matching directories inside the clone.
+func relocateTablespaces(clonePath, templatePath string) error {
+	links, err := listTablespaceLinks(clonePath)
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		rel, err := filepath.Rel(templatePath, link.Target)
+		if err != nil || !isWithinDir(link.Target, templatePath) {
+			return fmt.Errorf("tablespaces not supported: tablespace %s is stored at %s, outside the template dataset %s", link.OID, link.Target, templatePath)
+		}
+
+		linkPath := filepath.Join(clonePath, "pg_tblspc", link.OID)
+		// -T takes exactly a target and a link name, the form sudoers allows
+		cmd := asPostgres("ln", "-sfT", filepath.Join(clonePath, rel), linkPath)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("relocating tablespace %s: %w (output: %s)", link.OID, err, string(output))
+		}
+	}
+
+	return nil
+}
+
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type TemplateInfo struct {
+	InitResult
+	ServiceStatus string
+	Ready         bool
+	BranchCount   int
+	// Host-wide branch count, reported alongside the configured limits
+	HostBranchCount int
+	Limits          BranchLimits
+	Space           DatasetSpace
+	// Replay progress of a point-in-time restore, nil without a target time or
+	// while the template isn't accepting connections
+	Recovery *RecoveryTargetStatus
+}
+
+func (s *AgentService) GetTemplateInfo(ctx context.Context, template string) (*TemplateInfo, error) {
+	templateDataset := GetTemplateDataset(template)
+	if !datasetExists(templateDataset) {
+		return nil, fmt.Errorf("template '%s' not found", template)
+	}
+
+	templatePath, err := GetMountpoint(templateDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	initResult, err := loadTemplateMetadata(templatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	space, err := getDatasetSpace(templateDataset)
+	if err != nil {
+		return nil, err
+	}
+
+	branches, err := s.ListBranches(ctx, template)
+	if err != nil {
+		return nil, fmt.Errorf("listing branches: %w", err)
+	}
+
+	hostBranches, err := s.ListBranches(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("listing host branches: %w", err)
+	}
+
+	info := &TemplateInfo{
+		InitResult:      *initResult,
+		ServiceStatus:   GetServiceStatus(GetTemplateServiceName(template)),
+		Ready:           IsPostgreSQLServerReady(templatePath),
+		BranchCount:     len(branches),
+		HostBranchCount: len(hostBranches),
+		Limits:          s.Config().BranchLimits,
+		Space:           space,
+	}
+
+	if initResult.TargetTime != "" && info.Ready {
+		pid, _ := getPostmasterPid(templatePath)
+		if info.Recovery, err = getRecoveryTargetStatus(pid.Port); err != nil {
+			return nil, err
+		}
+		// Branches are refused until the target is reached
+		info.Ready = info.Recovery.State != RecoveryReplaying
+	}
+
+	return info, nil
+}
+
+func loadTemplateMetadata(templatePath string) (*InitResult, error) {
+	data, err := os.ReadFile(filepath.Join(templatePath, ".quic-init-meta.json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading template metadata: %w", err)
+	}
+
+	var result InitResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("unmarshaling template metadata: %w", err)
+	}
+
+	return &result, nil
+}
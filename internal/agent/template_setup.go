@@ -2,14 +2,18 @@ package agent
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	pb "github.com/quickr-dev/quic/proto"
@@ -23,22 +27,30 @@ type InitResult struct {
 	Port        string `json:"port"`
 	ServiceName string `json:"service_name"`
 	CreatedAt   string `json:"created_at"`
+	// Major version read from the restored PG_VERSION file
+	PgVersion string `json:"pg_version"`
+	// Only Database was restored, branches drop the cluster's other databases
+	OnlyDatabase bool `json:"only_database,omitempty"`
+	// Provenance of the restored data, as reported by pgBackRest
+	Backup BackupProvenance `json:"backup"`
+	// Step durations of the restore
+	Timings []StepTiming `json:"timings,omitempty"`
+	// Template whose restore this one is cloned from, empty when restored from a backup
+	Source string `json:"source,omitempty"`
+	// Recovery target of a point-in-time restore (RFC3339), empty when the
+	// template follows the latest WAL as a standby
+	TargetTime string `json:"target_time,omitempty"`
 }
 
-func (s *AgentService) TemplateSetup(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer) error {
-	s.sendLog(stream, "INFO", "Starting template restore process...")
-
-	// Create pgbackrest config file
-	if err := s.writePgBackRestConfig(req.PgbackrestConfig); err != nil {
-		s.sendError(stream, "pgbackrest_config", fmt.Sprintf("Failed to write pgbackrest config: %v", err))
-		return err
+func (s *AgentService) TemplateSetup(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer, user string) error {
+	var result *InitResult
+	var err error
+	if req.SourceTemplate != "" {
+		result, err = s.templateSetupFromSource(req, stream, user)
+	} else {
+		result, err = s.templateSetupFromBackup(req, stream, user)
 	}
-
-	s.sendLog(stream, "INFO", "✓ pgBackRest configuration written")
-
-	result, err := s.initRestoreWithStreaming(req, stream)
 	if err != nil {
-		s.sendError(stream, "restore", fmt.Sprintf("Template restore failed: %v", err))
 		return err
 	}
 
@@ -51,17 +63,114 @@ func (s *AgentService) TemplateSetup(req *pb.RestoreTemplateRequest, stream pb.Q
 				MountPath:        result.MountPath,
 				Port:             result.Port,
 				ServiceName:      result.ServiceName,
+				Host:             s.PublicHost(stream.Context()),
+				Stanza:           result.Stanza,
+				BackupLabel:      result.Backup.Label,
+				BackupLsnStart:   result.Backup.LSNStart,
+				BackupLsnStop:    result.Backup.LSNStop,
+				RestoredAt:       result.CreatedAt,
+				Timings:          StepTimingsToProto(result.Timings),
 			},
 		},
 	}); err != nil {
 		return fmt.Errorf("failed to send result: %w", err)
 	}
 
+	s.publishEvent(LifecycleEvent{
+		Event:    "template_setup",
+		Template: req.TemplateName,
+		User:     user,
+		Host:     s.PublicHost(stream.Context()),
+		Port:     result.Port,
+	})
+
 	return nil
 }
 
-func (s *AgentService) writePgBackRestConfig(configContent string) error {
-	cmd := exec.Command("sudo", "tee", "/etc/pgbackrest.conf")
+func (s *AgentService) templateSetupFromBackup(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer, user string) (*InitResult, error) {
+	if err := requireBinaries("pgbackrest"); err != nil {
+		return nil, err
+	}
+
+	processMax, err := s.restoreProcessMax(req.ProcessMax)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := restoreRepo(req.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.OnlyDatabase {
+		if err := validateOnlyDatabase(req.Database); err != nil {
+			return nil, err
+		}
+	}
+
+	var targetTime time.Time
+	if req.TargetTime != "" {
+		targetTime, err = ValidateTargetTime(req.TargetTime, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	op, done := s.beginOperation(OpRestoreTemplate, req.TemplateName, user)
+	defer done()
+
+	// Stop waiting for a slot when the client goes away or the operation is cancelled
+	queueCtx, stopQueue := context.WithCancel(stream.Context())
+	defer stopQueue()
+	context.AfterFunc(op.ctx, stopQueue)
+
+	// Restores are I/O heavy, so they run through a bounded queue
+	err = s.restoreQueue.acquire(queueCtx, s.Config().MaxConcurrentRestores, func(position int) {
+		s.setOperationQueued(op, true)
+		s.sendLog(stream, "INFO", fmt.Sprintf("Queued behind other template restores, position %d", position))
+	})
+	s.setOperationQueued(op, false)
+	if op.cancelled() {
+		return nil, ErrOperationCancelled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("waiting for restore slot: %w", err)
+	}
+	defer s.restoreQueue.release()
+
+	s.sendLog(stream, "INFO", "Starting template restore process...")
+
+	// Create pgbackrest config file
+	if err := s.writePgBackRestConfig(req.TemplateName, req.PgbackrestConfig); err != nil {
+		s.sendError(stream, "pgbackrest_config", fmt.Sprintf("Failed to write pgbackrest config: %v", err))
+		return nil, err
+	}
+
+	s.sendLog(stream, "INFO", "✓ pgBackRest configuration written")
+
+	if !targetTime.IsZero() {
+		if err := checkTargetTimeInBackups(GetPgBackRestConfigPath(req.TemplateName), req.BackupToken.Stanza, repo, targetTime); err != nil {
+			s.sendError(stream, "target_time", err.Error())
+			return nil, err
+		}
+	}
+
+	result, err := s.initRestoreWithStreaming(op.ctx, req, processMax, repo, targetTime, stream)
+	if err != nil {
+		s.sendError(stream, "restore", fmt.Sprintf("Template restore failed: %v", err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *AgentService) writePgBackRestConfig(template, configContent string) error {
+	// The name becomes a file name under a directory quicd may write to as root
+	if template == "" || strings.Contains(template, "/") || strings.HasPrefix(template, ".") {
+		return fmt.Errorf("invalid template name '%s'", template)
+	}
+
+	cmd := privileged("tee", GetPgBackRestConfigPath(template))
 	cmd.Stdin = strings.NewReader(configContent)
 
 	if err := cmd.Run(); err != nil {
@@ -71,35 +180,107 @@ func (s *AgentService) writePgBackRestConfig(configContent string) error {
 	return nil
 }
 
-func (s *AgentService) initRestoreWithStreaming(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer) (*InitResult, error) {
+// TemplateRestoreExists reports whether the template's data directory is in
+// place, which a delta restore rewrites.
+func TemplateRestoreExists(template string) bool {
+	_, err := os.Stat(GetTemplateMountpoint(template))
+	return err == nil
+}
+
+func (s *AgentService) initRestoreWithStreaming(ctx context.Context, req *pb.RestoreTemplateRequest, processMax int, repo int, targetTime time.Time, stream pb.QuicService_RestoreTemplateServer) (*InitResult, error) {
 	datasetPath := fmt.Sprintf("%s/%s", ZPool, req.TemplateName)
-	mountPath := fmt.Sprintf("/opt/quic/%s/_restore", req.TemplateName)
+	mountPath := GetTemplateMountpoint(req.TemplateName)
 
 	s.sendLog(stream, "INFO", "Preparing to restore")
+	timer := newStepTimer()
 
-	// Check if directory already exists
-	if _, err := os.Stat(mountPath); !os.IsNotExist(err) {
-		return nil, fmt.Errorf("mount path %s already exists", mountPath)
-	}
+	_, statErr := os.Stat(mountPath)
+	delta := req.Delta && statErr == nil
 
-	// Create ZFS dataset
-	cmd := exec.Command("sudo", "zfs", "create", "-o", fmt.Sprintf("mountpoint=%s", mountPath), datasetPath)
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("creating ZFS dataset: %w", err)
+	if delta {
+		// Branches keep their own snapshots, so the template can be restored in place.
+		// pgBackRest refuses to restore over a running cluster.
+		s.sendLog(stream, "INFO", "Existing template found, restoring changed files only (delta)")
+		if err := StopService(GetTemplateServiceName(req.TemplateName)); err != nil {
+			return nil, err
+		}
+	} else {
+		if !os.IsNotExist(statErr) {
+			return nil, fmt.Errorf("mount path %s already exists. Use --delta to re-restore over it", mountPath)
+		}
+		if err := checkServiceNameFree(req.TemplateName, ""); err != nil {
+			return nil, err
+		}
+
+		// Create ZFS dataset
+		cmd := privileged("zfs", "create", "-o", fmt.Sprintf("mountpoint=%s", mountPath), datasetPath)
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("creating ZFS dataset: %w", err)
+		}
 	}
+	timer.lap("dataset")
 
 	// Perform pgbackrest restore with streaming output
-	s.sendLog(stream, "INFO", "Starting restore...")
+	s.sendLog(stream, "INFO", fmt.Sprintf("Starting restore with %d pgBackRest process(es)...", processMax))
+
+	dbInclude := ""
+	if req.OnlyDatabase {
+		dbInclude = req.Database
+		s.sendLog(stream, "INFO", fmt.Sprintf("Restoring only database %s", dbInclude))
+	}
+
+	logLevel := restoreLogLevel(req.LogLevel)
+	if req.LogLevel != "" && logLevel != strings.ToLower(req.LogLevel) {
+		s.sendLog(stream, "WARN", fmt.Sprintf("Unknown pgBackRest log level '%s', using %s", req.LogLevel, logLevel))
+	}
+
+	if !targetTime.IsZero() {
+		s.sendLog(stream, "INFO", fmt.Sprintf("Restoring to %s, WAL is replayed up to that time", targetTime.UTC().Format(time.RFC3339)))
+	}
 
-	if err := s.runPgBackRestWithStreaming(req.BackupToken.Stanza, mountPath, stream); err != nil {
+	backupLabel, err := s.runPgBackRestWithStreaming(ctx, GetPgBackRestConfigPath(req.TemplateName), req.BackupToken.Stanza, repo, mountPath, processMax, delta, dbInclude, logLevel, targetTime, stream)
+	if ctx.Err() != nil {
+		// A delta restore leaves the template's data half updated either way
+		if !delta {
+			if err := destroyDataset(datasetPath, "-r"); err != nil {
+				log.Printf("Warning: failed to clean up cancelled restore of %s: %v", req.TemplateName, err)
+			}
+			privileged("rmdir", mountPath).Run()
+		}
+		return nil, ErrOperationCancelled
+	}
+	if err != nil {
 		return nil, fmt.Errorf("pgbackrest restore: %w", err)
 	}
 
 	s.sendLog(stream, "INFO", "✓ Restore done")
+	timer.lap("pgbackrest_restore")
+
+	// Trust the restored data over the requested version, the wrong binaries fail to start it
+	pgVersion, err := checkDataDirVersion(mountPath, req.PgVersion)
+	if err != nil {
+		return nil, err
+	}
+	s.sendLog(stream, "INFO", fmt.Sprintf("Detected PostgreSQL %s", pgVersion))
+
+	// pgBackRest restores tablespaces to their original paths, outside the dataset
+	if err := checkTemplateTablespaces(mountPath); err != nil {
+		s.sendLog(stream, "WARN", fmt.Sprintf("Branches can't be created from this template: %v", err))
+	}
+
+	// Provenance is informational, so a failed lookup doesn't fail the restore
+	backup := BackupProvenance{Label: backupLabel}
+	if backupLabel != "" {
+		if info, err := getBackupProvenance(GetPgBackRestConfigPath(req.TemplateName), req.BackupToken.Stanza, repo, backupLabel); err != nil {
+			s.sendLog(stream, "WARN", fmt.Sprintf("Could not read backup details: %v", err))
+		} else {
+			backup = *info
+		}
+	}
 	s.sendLog(stream, "INFO", "Setting up template...")
 
 	// Set ownership
-	if err := exec.Command("sudo", "chown", "-R", "postgres:postgres", mountPath).Run(); err != nil {
+	if err := privileged("chown", "-R", "postgres:postgres", mountPath).Run(); err != nil {
 		return nil, fmt.Errorf("setting ownership: %w", err)
 	}
 
@@ -107,9 +288,10 @@ func (s *AgentService) initRestoreWithStreaming(req *pb.RestoreTemplateRequest,
 	if err := s.updateTemplatePostgresConf(mountPath); err != nil {
 		return nil, fmt.Errorf("updating PostgreSQL config: %w", err)
 	}
+	timer.lap("prepare")
 
 	// Find available port
-	port, err := findAvailablePort()
+	port, err := s.findAvailablePort(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("finding available port: %w", err)
 	}
@@ -122,19 +304,45 @@ func (s *AgentService) initRestoreWithStreaming(req *pb.RestoreTemplateRequest,
 	}
 
 	// Start service
+	started := time.Now()
 	if err := StartService(serviceName); err != nil {
 		return nil, fmt.Errorf("starting PostgreSQL service: %w", err)
 	}
+	timer.lap("service_start")
+
+	var recovery *RecoveryTargetStatus
+	if !targetTime.IsZero() {
+		s.sendLog(stream, "INFO", "Replaying WAL up to the target time...")
+		recovery, err = waitForRecoveryTarget(mountPath, serviceName, started)
+		if err != nil {
+			return nil, err
+		}
+		timer.lap("replay")
+	}
+
+	if req.VerifyChecksums {
+		if err := s.verifyTemplateChecksums(stream, pgVersion, mountPath, serviceName); err != nil {
+			return nil, err
+		}
+		timer.lap("verify_checksums")
+	}
 
 	// Store metadata
 	result := &InitResult{
-		Dirname:     req.TemplateName,
-		Stanza:      req.BackupToken.Stanza,
-		Database:    req.Database,
-		MountPath:   mountPath,
-		Port:        port,
-		ServiceName: serviceName,
-		CreatedAt:   time.Now().Format(time.RFC3339),
+		Dirname:      req.TemplateName,
+		Stanza:       req.BackupToken.Stanza,
+		Database:     req.Database,
+		MountPath:    mountPath,
+		Port:         port,
+		ServiceName:  serviceName,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+		PgVersion:    pgVersion,
+		Backup:       backup,
+		OnlyDatabase: req.OnlyDatabase,
+		Timings:      timer.steps,
+	}
+	if !targetTime.IsZero() {
+		result.TargetTime = targetTime.UTC().Format(time.RFC3339)
 	}
 
 	if err := s.writeMetadataFile(result, mountPath); err != nil {
@@ -146,44 +354,126 @@ func (s *AgentService) initRestoreWithStreaming(req *pb.RestoreTemplateRequest,
 		return nil, fmt.Errorf("getting template path: %w", err)
 	}
 
-	if IsPostgreSQLServerReady(templatePath) {
-		s.sendLog(stream, "INFO", "Template setup complete but not yet ready for branching. For now, you should keep trying to `quic checkout` until it succeeds.")
-	} else {
+	switch {
+	case recovery != nil && recovery.State == RecoveryReplaying:
+		s.sendLog(stream, "INFO", fmt.Sprintf("Template setup complete, still replaying WAL up to the target time (at %s). Branches can be created once `quic template info` shows it was reached.", recovery.LSN))
+	case recovery != nil:
+		s.sendLog(stream, "INFO", fmt.Sprintf("✓ Recovery target reached at %s, template ready for branching", recovery.LSN))
+	case IsPostgreSQLServerReady(templatePath):
 		s.sendLog(stream, "INFO", "✓ Template ready for branching")
+	default:
+		s.sendLog(stream, "INFO", "Template setup complete but not yet ready for branching. For now, you should keep trying to `quic checkout` until it succeeds.")
 	}
 
 	return result, nil
 }
 
-func (s *AgentService) runPgBackRestWithStreaming(stanza, pgDataPath string, stream pb.QuicService_RestoreTemplateServer) error {
-	cmd := exec.Command("sudo", "pgbackrest",
+const pgBackRestStopTimeout = 30 * time.Second
+
+// pgBackRest console log levels, from quietest to most verbose
+var pgBackRestLogLevels = []string{"error", "warn", "info", "detail", "debug"}
+
+// restoreLogLevel resolves the pgBackRest console log level for a restore
+// request. Empty and unknown levels fall back to info.
+func restoreLogLevel(requested string) string {
+	level := strings.ToLower(requested)
+	if !slices.Contains(pgBackRestLogLevels, level) {
+		return "info"
+	}
+	return level
+}
+
+// shownAtLogLevel reports whether a pgBackRest output line is at least as
+// severe as level. Lines without a level, like progress output, are shown.
+func shownAtLogLevel(line, level string) bool {
+	match := pgBackRestLinePattern.FindStringSubmatch(line)
+	if match == nil {
+		return true
+	}
+	lineLevel := slices.Index(pgBackRestLogLevels, strings.ToLower(match[1]))
+	return lineLevel == -1 || lineLevel <= slices.Index(pgBackRestLogLevels, level)
+}
+
+// restoreProcessMax resolves the pgBackRest parallelism for a restore request,
+// falling back to the host setting and then to a single process.
+func (s *AgentService) restoreProcessMax(requested int32) (int, error) {
+	processMax := int(requested)
+	if processMax == 0 {
+		processMax = max(s.Config().RestoreProcessMax, 1)
+	}
+
+	if processMax < 1 || processMax > runtime.NumCPU() {
+		return 0, fmt.Errorf("process max must be between 1 and the host's CPU count (%d), got %d", runtime.NumCPU(), processMax)
+	}
+
+	return processMax, nil
+}
+
+// runPgBackRestWithStreaming restores the latest backup in the stanza's repo and returns
+// the label of the backup set pgBackRest picked. A non-empty dbInclude restores
+// only that database, the others come back as sparse zeroed files. With a
+// targetTime, pgBackRest picks the latest backup before it and recovery
+// pauses once WAL is replayed up to it, instead of following as a standby.
+func (s *AgentService) runPgBackRestWithStreaming(ctx context.Context, configPath, stanza string, repo int, pgDataPath string, processMax int, delta bool, dbInclude, logLevel string, targetTime time.Time, stream pb.QuicService_RestoreTemplateServer) (string, error) {
+	// pgBackRest runs at info or above since the backup set is read from an
+	// info line. Quieter levels are filtered while streaming.
+	consoleLevel := logLevel
+	if slices.Index(pgBackRestLogLevels, logLevel) < slices.Index(pgBackRestLogLevels, "info") {
+		consoleLevel = "info"
+	}
+
+	// archive-mode=off keeps the template from archiving into the source's
+	// repository. The restore_command pgBackRest writes to postgresql.auto.conf
+	// stays, so WAL is fetched from the repository either way.
+	args := []string{"pgbackrest",
 		"restore",
 		"--archive-mode=off",
-		"--stanza="+stanza,
-		"--config=/etc/pgbackrest.conf",
-		"--log-level-console=detail",
-		"--log-level-stderr=detail",
-		"--type=standby",
-		"--pg1-path="+pgDataPath)
+		"--stanza=" + stanza,
+		fmt.Sprintf("--repo=%d", repo),
+		"--config=" + configPath,
+		"--log-level-console=" + consoleLevel,
+		"--log-level-stderr=warn",
+		fmt.Sprintf("--process-max=%d", processMax),
+		"--pg1-path=" + pgDataPath}
+	if targetTime.IsZero() {
+		args = append(args, "--type=standby")
+	} else {
+		// Pausing keeps the template in recovery, read-only like a standby
+		args = append(args, "--type=time", "--target="+pgBackRestTargetTime(targetTime), "--target-action=pause")
+	}
+	if delta {
+		args = append(args, "--delta")
+	}
+	if dbInclude != "" {
+		args = append(args, "--db-include="+dbInclude)
+	}
+	cmd := privilegedContext(ctx, args[0], args[1:]...)
+	// SIGTERM makes pgbackrest stop its worker processes, and sudo relays it.
+	// Killing sudo directly would leave pgbackrest running.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = pgBackRestStopTimeout
 
 	// Get stdout and stderr pipes
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
+		return "", fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("failed to get stderr pipe: %w", err)
+		return "", fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start pgbackrest: %w", err)
+		return "", fmt.Errorf("failed to start pgbackrest: %w", err)
 	}
 
 	// Use WaitGroup to synchronize goroutines
 	var wg sync.WaitGroup
+	var backupLabel string
 	done := make(chan bool)
 
 	// Stream stdout
@@ -193,7 +483,12 @@ func (s *AgentService) runPgBackRestWithStreaming(stanza, pgDataPath string, str
 		scanner := bufio.NewScanner(stdout)
 		for scanner.Scan() {
 			line := scanner.Text()
-			s.sendLog(stream, "INFO", fmt.Sprintf("pgBackRest: %s", line))
+			if match := backupSetPattern.FindStringSubmatch(line); match != nil {
+				backupLabel = match[1]
+			}
+			if shownAtLogLevel(line, logLevel) {
+				s.sendLog(stream, "INFO", fmt.Sprintf("pgBackRest: %s", line))
+			}
 		}
 	}()
 
@@ -204,7 +499,9 @@ func (s *AgentService) runPgBackRestWithStreaming(stanza, pgDataPath string, str
 		scanner := bufio.NewScanner(stderr)
 		for scanner.Scan() {
 			line := scanner.Text()
-			s.sendLog(stream, "WARN", fmt.Sprintf("pgBackRest: %s", line))
+			if shownAtLogLevel(line, logLevel) {
+				s.sendLog(stream, "WARN", fmt.Sprintf("pgBackRest: %s", line))
+			}
 		}
 	}()
 
@@ -231,10 +528,10 @@ func (s *AgentService) runPgBackRestWithStreaming(stanza, pgDataPath string, str
 	wg.Wait()
 
 	if cmdErr != nil {
-		return fmt.Errorf("pgbackrest command failed: %w", cmdErr)
+		return "", fmt.Errorf("pgbackrest command failed: %w", cmdErr)
 	}
 
-	return nil
+	return backupLabel, nil
 }
 
 func (s *AgentService) sendLog(stream pb.QuicService_RestoreTemplateServer, level, message string) {
@@ -264,7 +561,7 @@ func (s *AgentService) updateTemplatePostgresConf(mountPath string) error {
 	confPath := fmt.Sprintf("%s/postgresql.conf", mountPath)
 
 	// Read existing config
-	cmd := exec.Command("sudo", "cat", confPath)
+	cmd := privileged("cat", confPath)
 	data, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("reading postgresql.conf: %w", err)
@@ -277,9 +574,11 @@ func (s *AgentService) updateTemplatePostgresConf(mountPath string) error {
 		"shared_preload_libraries": "''", // Remove pgaudit and other extensions
 		"listen_addresses":         "'127.0.0.1'",
 		"ssl":                      "on",
-		"ssl_cert_file":            "'/etc/quic/certs/server.crt'",
-		"ssl_key_file":             "'/etc/quic/certs/server.key'",
+		"ssl_cert_file":            fmt.Sprintf("'%s'", TLSCertFile),
+		"ssl_key_file":             fmt.Sprintf("'%s'", TLSKeyFile),
 		"ssl_ca_file":              "''",
+		"ssl_min_protocol_version": s.Config().TLS.postgresMinVersion(),
+		"unix_socket_directories":  fmt.Sprintf("'%s'", PgSocketDir),
 	}
 
 	// Update or add each setting
@@ -315,8 +614,8 @@ func (s *AgentService) updateTemplatePostgresConf(mountPath string) error {
 	}
 	config = strings.Join(lines, "\n")
 
-	// Write updated config using sudo
-	cmd = exec.Command("sudo", "tee", confPath)
+	// Write updated config as root
+	cmd = privileged("tee", confPath)
 	cmd.Stdin = strings.NewReader(config)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("writing postgresql.conf: %w", err)
@@ -332,7 +631,7 @@ func (s *AgentService) writeMetadataFile(result *InitResult, mountPath string) e
 		return fmt.Errorf("marshaling metadata: %w", err)
 	}
 
-	cmd := exec.Command("sudo", "tee", metadataPath)
+	cmd := privileged("tee", metadataPath)
 	cmd.Stdin = strings.NewReader(string(metadataBytes))
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("writing metadata: %w", err)
@@ -341,7 +640,13 @@ func (s *AgentService) writeMetadataFile(result *InitResult, mountPath string) e
 	return nil
 }
 
-func findAvailablePort() (string, error) {
+func (s *AgentService) findAvailablePort(ctx context.Context) (string, error) {
+	firewalled, err := firewalledPorts()
+	if err != nil {
+		return "", fmt.Errorf("checking firewall rules: %w", err)
+	}
+	detached := s.detachedBranchPorts(ctx)
+
 	for port := StartPort; port <= EndPort; port++ {
 		conn, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 		if err != nil {
@@ -351,7 +656,12 @@ func findAvailablePort() (string, error) {
 
 		portStr := fmt.Sprintf("%d", port)
 		// Just in case a branch instance is down but it will need the port
-		if hasUFWRule(portStr) {
+		if firewalled[portStr] {
+			continue
+		}
+		// Detached branches are down with their port closed, but attach
+		// starts them on it again
+		if detached[portStr] {
 			continue
 		}
 
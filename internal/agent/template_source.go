@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+// GetTemplateSourceSnapshot names the snapshot of source that template is
+// cloned from. Branch names can't contain a dot, so it never collides with
+// a branch snapshot.
+func GetTemplateSourceSnapshot(source, template string) string {
+	return GetTemplateDataset(source) + "@template." + template
+}
+
+// templateSetupFromSource sets up a template as a ZFS clone of another
+// template's restore, so templates of the same cluster share its data.
+func (s *AgentService) templateSetupFromSource(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer, user string) (*InitResult, error) {
+	op, done := s.beginOperation(OpCloneTemplate, req.TemplateName, user)
+	defer done()
+
+	if !s.lockForOperation(op) {
+		return nil, ErrServiceRestarting
+	}
+	defer s.checkoutMutex.Unlock()
+
+	result, err := s.cloneTemplateFromSource(req, stream)
+	if err != nil {
+		s.sendError(stream, "clone", fmt.Sprintf("Template setup from '%s' failed: %v", req.SourceTemplate, err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *AgentService) cloneTemplateFromSource(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer) (*InitResult, error) {
+	template, source := req.TemplateName, req.SourceTemplate
+	if template == source {
+		return nil, fmt.Errorf("template '%s' can't be its own source", template)
+	}
+
+	sourceMeta, err := loadTemplateMetadata(GetTemplateMountpoint(source))
+	if err != nil {
+		return nil, fmt.Errorf("source template '%s' is not set up on this host: %w", source, err)
+	}
+	if sourceMeta.OnlyDatabase && sourceMeta.Database != req.Database {
+		return nil, fmt.Errorf("source template '%s' only restored database %s, so it can't provide %s", source, sourceMeta.Database, req.Database)
+	}
+
+	dataset := GetTemplateDataset(template)
+	mountPath := GetTemplateMountpoint(template)
+	if datasetExists(dataset) {
+		if !req.Delta {
+			return nil, fmt.Errorf("template '%s' already exists. Use --delta to refresh other templates while keeping it", template)
+		}
+		// Refreshing the source doesn't change its clones, they keep the data they were cloned with
+		s.sendLog(stream, "INFO", fmt.Sprintf("Template already cloned from '%s', keeping its data", source))
+		return loadTemplateMetadata(mountPath)
+	}
+	if _, err := os.Stat(mountPath); !os.IsNotExist(err) {
+		return nil, fmt.Errorf("mount path %s already exists", mountPath)
+	}
+	if err := checkServiceNameFree(template, ""); err != nil {
+		return nil, err
+	}
+
+	s.sendLog(stream, "INFO", fmt.Sprintf("Cloning template from '%s' instead of restoring it", source))
+	timer := newStepTimer()
+
+	// Checkpoint first so the clone starts from recent data, like branches do
+	snapshot := GetTemplateSourceSnapshot(source, template)
+	if !snapshotExists(snapshot) {
+		if pid, running := getPostmasterPid(GetTemplateMountpoint(source)); running {
+			if _, err := ExecPostgresCommand(pid.Port, "postgres", "CHECKPOINT;"); err != nil {
+				return nil, fmt.Errorf("checkpointing source template: %w", err)
+			}
+		}
+		if err := createKindSnapshot(snapshot, SnapshotKindTemplate); err != nil {
+			return nil, err
+		}
+	}
+	timer.lap("snapshot")
+
+	if err := createClone(snapshot, dataset, mountPath); err != nil {
+		return nil, err
+	}
+	timer.lap("clone")
+
+	result, err := s.startClonedTemplate(req, sourceMeta, mountPath, timer)
+	if err != nil {
+		// Nothing depends on the clone yet, so leave no trace of the failed attempt
+		if serviceName := GetTemplateServiceName(template); ServiceExists(serviceName) {
+			if err := DeleteService(serviceName); err != nil {
+				log.Printf("Warning: failed to remove service of %s: %v", template, err)
+			}
+		}
+		if err := destroyDataset(dataset); err != nil {
+			log.Printf("Warning: failed to clean up clone of %s: %v", template, err)
+		} else if err := destroyDataset(snapshot); err != nil {
+			log.Printf("Warning: failed to clean up snapshot %s: %v", snapshot, err)
+		}
+		privileged("rmdir", mountPath).Run()
+		return nil, err
+	}
+
+	if IsPostgreSQLServerReady(mountPath) {
+		s.sendLog(stream, "INFO", "✓ Template ready for branching")
+	} else {
+		s.sendLog(stream, "INFO", "Template setup complete but not yet ready for branching. For now, you should keep trying to `quic checkout` until it succeeds.")
+	}
+
+	return result, nil
+}
+
+// startClonedTemplate gives a cloned template its own service and metadata.
+func (s *AgentService) startClonedTemplate(req *pb.RestoreTemplateRequest, sourceMeta *InitResult, mountPath string, timer *stepTimer) (*InitResult, error) {
+	pgVersion, err := checkDataDirVersion(mountPath, req.PgVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// The source was running when it was snapshotted
+	if err := privileged("rm", "-f", filepath.Join(mountPath, "postmaster.pid")).Run(); err != nil {
+		return nil, fmt.Errorf("removing postmaster.pid: %w", err)
+	}
+
+	if err := s.updateTemplatePostgresConf(mountPath); err != nil {
+		return nil, fmt.Errorf("updating PostgreSQL config: %w", err)
+	}
+	timer.lap("prepare")
+
+	port, err := s.findAvailablePort(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("finding available port: %w", err)
+	}
+
+	serviceName := GetTemplateServiceName(req.TemplateName)
+	if err := CreateTemplateService(req.TemplateName, mountPath, port); err != nil {
+		return nil, fmt.Errorf("creating systemd service: %w", err)
+	}
+	if err := StartService(serviceName); err != nil {
+		return nil, fmt.Errorf("starting PostgreSQL service: %w", err)
+	}
+	timer.lap("service_start")
+
+	result := &InitResult{
+		Dirname:      req.TemplateName,
+		Stanza:       sourceMeta.Stanza,
+		Database:     req.Database,
+		MountPath:    mountPath,
+		Port:         port,
+		ServiceName:  serviceName,
+		CreatedAt:    time.Now().Format(time.RFC3339),
+		PgVersion:    pgVersion,
+		OnlyDatabase: sourceMeta.OnlyDatabase,
+		Backup:       sourceMeta.Backup,
+		Timings:      timer.steps,
+		Source:       req.SourceTemplate,
+		// The clone continues the source's recovery to the same target
+		TargetTime: sourceMeta.TargetTime,
+	}
+
+	// Replaces the source's metadata that came along with the clone
+	if err := s.writeMetadataFile(result, mountPath); err != nil {
+		return nil, fmt.Errorf("writing metadata file: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"time"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+// StepTiming is how long one step of a branch checkout or template restore took.
+type StepTiming struct {
+	Step       string `json:"step"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// stepTimer records consecutive step durations, each measured from the end of
+// the previous step.
+type stepTimer struct {
+	last  time.Time
+	steps []StepTiming
+}
+
+func newStepTimer() *stepTimer {
+	return &stepTimer{last: time.Now()}
+}
+
+// lap ends the current step as step.
+func (t *stepTimer) lap(step string) {
+	now := time.Now()
+	t.steps = append(t.steps, StepTiming{Step: step, DurationMs: now.Sub(t.last).Milliseconds()})
+	t.last = now
+}
+
+func StepTimingsToProto(steps []StepTiming) []*pb.StepTiming {
+	result := make([]*pb.StepTiming, len(steps))
+	for i, s := range steps {
+		result[i] = &pb.StepTiming{Step: s.Step, DurationMs: s.DurationMs}
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+const (
+	TLSCertFile = "/etc/quic/certs/server.crt"
+	TLSKeyFile  = "/etc/quic/certs/server.key"
+)
+
+type TLSConfig struct {
+	MinVersion string `json:"minVersion"` // "1.2" (default) or "1.3"
+}
+
+// Modern AEAD suites for TLS 1.2. TLS 1.3 suites are not configurable in Go.
+var tls12CipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+func (c TLSConfig) validate() error {
+	switch c.MinVersion {
+	case "", "1.2", "1.3":
+		return nil
+	default:
+		return fmt.Errorf("minVersion must be '1.2' or '1.3', got '%s'", c.MinVersion)
+	}
+}
+
+func (c TLSConfig) goMinVersion() uint16 {
+	if c.MinVersion == "1.3" {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
+
+// postgresMinVersion returns the value for ssl_min_protocol_version
+func (c TLSConfig) postgresMinVersion() string {
+	if c.MinVersion == "1.3" {
+		return "'TLSv1.3'"
+	}
+	return "'TLSv1.2'"
+}
+
+func (c TLSConfig) ServerConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS key pair: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   c.goMinVersion(),
+		CipherSuites: tls12CipherSuites,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+	}, nil
+}
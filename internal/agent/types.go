@@ -1,22 +1,98 @@
 package agent
 
 import (
-	"fmt"
+	"net"
+	"net/url"
 	"time"
 )
 
 type BranchInfo struct {
-	ID            int       `json:"id"`
-	TemplateName  string    `json:"template_name"`
-	BranchName    string    `json:"branch_name"`
-	Port          string    `json:"port"`
-	BranchPath    string    `json:"branch_path"`
-	AdminPassword string    `json:"admin_password"`
-	CreatedBy     string    `json:"created_by"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID            int                `json:"id"`
+	TemplateName  string             `json:"template_name"`
+	BranchName    string             `json:"branch_name"`
+	Port          string             `json:"port"`
+	BranchPath    string             `json:"branch_path"`
+	AdminPassword string             `json:"admin_password"`
+	Extensions    []string           `json:"extensions,omitempty"`
+	Promoted      bool               `json:"promoted,omitempty"`
+	RoleMode      string             `json:"role_mode,omitempty"`
+	Source        string             `json:"source,omitempty"` // "import" for branches restored from a dump, "move" for branches moved from another template
+	UsedBytes     int64              `json:"-"`                // Filled in when listing, not stored in metadata
+	Checkpoints   []BranchCheckpoint `json:"checkpoints,omitempty"`
+	Settings      map[string]string  `json:"settings,omitempty"`  // Set in postgresql.auto.conf at checkout
+	Archive       bool               `json:"archive,omitempty"`   // WAL archived to GetBranchWALArchiveDir
+	Timings       []StepTiming       `json:"timings,omitempty"`   // Step durations of the checkout
+	WALReset      string             `json:"wal_reset,omitempty"` // How the clone was started, see ValidateWALReset
+	Detached      bool               `json:"detached,omitempty"`  // Service and firewall removed, dataset kept
+	CreatedBy     string             `json:"created_by"`
+	CreatedAt     time.Time          `json:"created_at"`
+	UpdatedAt     time.Time          `json:"updated_at"`
+	// max_connections, 0 for branches created before it was configurable, see ConnectionLimit
+	MaxConnections int `json:"max_connections,omitempty"`
+	// Configuration profile, see ValidateProfile. Empty for older branches, which use the default
+	Profile string `json:"profile,omitempty"`
+	// ANALYZE ran on every database after startup
+	Analyzed bool `json:"analyzed,omitempty"`
+	// Read-only by default, see FreezeBranch
+	Frozen bool `json:"frozen,omitempty"`
+	// Its template is gone, see branchOrphaned. Filled in when listing and in branch info
+	TemplateMissing bool `json:"-"`
+	// Parent branch this hot standby streams from, see CreateReplica
+	ReplicaOf string `json:"replica_of,omitempty"`
+	// Names of the branch's replicas. Filled in in branch info
+	Replicas []string `json:"-"`
+	// Finish time (RFC3339) of the backup the template was restored from
+	BackupFinishedAt string `json:"backup_finished_at,omitempty"`
+	// Commit time (RFC3339) of the newest source data in the branch, see templateDataAge
+	DataAsOf string `json:"data_as_of,omitempty"`
+	// Last time a quic operation touched the branch, see touchBranch
+	LastAccessedAt time.Time `json:"last_accessed_at"`
 }
 
+// BranchOptions holds optional settings requested when creating a branch.
+type BranchOptions struct {
+	Extensions []string
+	// superuser (default) or app
+	RoleMode string
+	// Duplicate requests with the same key return the first request's result
+	IdempotencyKey string
+	// name=value PostgreSQL settings, see ValidateBranchSettings
+	Settings []string
+	// Archive WAL so the branch can be recovered to a point in time
+	Archive bool
+	// fast or safe, see ValidateWALReset. Empty picks fast when the template
+	// was checkpointed before the snapshot, safe otherwise
+	WALReset string
+	// Supplied admin password, checked against the PasswordPolicy. Empty
+	// generates a random one
+	AdminPassword string
+	// max_connections of the branch, see ValidateMaxConnections. Zero uses
+	// DefaultMaxConnections
+	MaxConnections int
+	// default or performance, see ValidateProfile
+	Profile string
+	// Run ANALYZE on every database once the branch is up
+	Analyze bool
+	// Where the branch dataset mounts instead of GetBranchMountpoint, see
+	// ValidateMountpoint
+	Mountpoint string
+}
+
+// ConnectionLimit returns the branch's max_connections.
+func (c *BranchInfo) ConnectionLimit() int {
+	if c.MaxConnections == 0 {
+		return DefaultMaxConnections
+	}
+	return c.MaxConnections
+}
+
+// ConnectionString returns the admin URL for the branch, with the password escaped.
 func (c *BranchInfo) ConnectionString(host string) string {
-	return fmt.Sprintf("postgresql://admin:%s@%s:%s/postgres", c.AdminPassword, host, c.Port)
+	u := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword("admin", c.AdminPassword),
+		Host:   net.JoinHostPort(host, c.Port),
+		Path:   "/postgres",
+	}
+	return u.String()
 }
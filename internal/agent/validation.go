@@ -6,6 +6,68 @@ import (
 	"strings"
 )
 
+const (
+	RoleModeSuperuser = "superuser"
+	RoleModeApp       = "app"
+)
+
+// How a branch's cloned data directory is brought up. Fast resets the WAL with
+// pg_resetwal, safe lets PostgreSQL run crash recovery on the cloned WAL.
+const (
+	WALResetFast = "fast"
+	WALResetSafe = "safe"
+)
+
+// ValidateWALReset checks a requested WAL reset mode. An empty mode is returned
+// as is, to be picked once the template snapshot is taken.
+func ValidateWALReset(mode string) (string, error) {
+	switch mode {
+	case "", WALResetFast, WALResetSafe:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("WAL reset must be '%s' or '%s'", WALResetFast, WALResetSafe)
+	}
+}
+
+// Configuration profiles of a branch. Default tunes it for short-lived
+// correctness tests, performance keeps autovacuum running so the planner
+// statistics stay current, like in production.
+const (
+	ProfileDefault     = "default"
+	ProfilePerformance = "performance"
+)
+
+func ValidateProfile(profile string) (string, error) {
+	switch profile {
+	case "":
+		return ProfileDefault, nil
+	case ProfileDefault, ProfilePerformance:
+		return profile, nil
+	default:
+		return "", fmt.Errorf("profile must be '%s' or '%s'", ProfileDefault, ProfilePerformance)
+	}
+}
+
+// profileSettings returns the postgresql.conf settings a profile changes from
+// the clone defaults.
+func profileSettings(profile string) map[string]string {
+	if profile == ProfilePerformance {
+		return map[string]string{"autovacuum": "on"}
+	}
+	return nil
+}
+
+func ValidateRoleMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return RoleModeSuperuser, nil
+	case RoleModeSuperuser, RoleModeApp:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("role mode must be '%s' or '%s'", RoleModeSuperuser, RoleModeApp)
+	}
+}
+
 func ValidateBranchName(name string) (string, error) {
 	// Convert to lowercase
 	name = strings.ToLower(name)
@@ -15,9 +77,10 @@ func ValidateBranchName(name string) (string, error) {
 		return "", fmt.Errorf("branch name must be between 1 and 50 characters")
 	}
 
-	// Check for reserved name
-	if name == "_restore" {
-		return "", fmt.Errorf("branch name '_restore' is reserved")
+	// Names starting with an underscore are kept for datasets quic manages
+	// itself, like the template's _restore
+	if strings.HasPrefix(name, "_") {
+		return "", fmt.Errorf("branch name '%s' is reserved, names starting with '_' are for internal use", name)
 	}
 
 	// Check format: only alphanumeric, underscore, dash
@@ -28,3 +91,22 @@ func ValidateBranchName(name string) (string, error) {
 
 	return name, nil
 }
+
+var databaseNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.$-]*$`)
+
+// ValidateDatabaseName only accepts plain database names. pg_dump and friends
+// run as the postgres user, so a conninfo string or URI passed as the database
+// could point them at the template or another cluster.
+func ValidateDatabaseName(name string) error {
+	if len(name) > 63 || !databaseNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid database name '%s': use only letters, numbers, underscore, dot, dollar and dash", name)
+	}
+	return nil
+}
+
+// dbnameConninfo is the --dbname argument selecting database, quoted so that
+// it can never be read as further connection parameters.
+func dbnameConninfo(database string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(database)
+	return "--dbname=dbname='" + escaped + "'"
+}
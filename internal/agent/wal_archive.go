@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"fmt"
+)
+
+// Durable branches archive WAL into a child dataset of the branch. Being a
+// separate dataset, it survives rollbacks of the branch to a checkpoint, and
+// it is destroyed along with the branch.
+const walArchiveName = "wal_archive"
+
+func GetBranchWALArchiveDataset(template, branch string) string {
+	return GetBranchDataset(template, branch) + "/" + walArchiveName
+}
+
+// GetBranchWALArchiveDir returns where the archive dataset mounts, inheriting
+// the branch's mountpoint.
+func GetBranchWALArchiveDir(branchPath string) string {
+	return branchPath + "/" + walArchiveName
+}
+
+// createWALArchive creates the WAL archive dataset of a branch mounted at
+// branchPath and returns its directory.
+func createWALArchive(template, branch, branchPath string) (string, error) {
+	dataset := GetBranchWALArchiveDataset(template, branch)
+	if output, err := privileged("zfs", "create", dataset).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("creating WAL archive dataset %s: %w (output: %s)", dataset, err, output)
+	}
+
+	dir := GetBranchWALArchiveDir(branchPath)
+	if err := privileged("chown", "postgres:postgres", dir).Run(); err != nil {
+		return "", fmt.Errorf("setting WAL archive ownership: %w", err)
+	}
+
+	return dir, nil
+}
+
+// walArchiveConf returns the postgresql.auto.conf lines for archiving into
+// dir, or turning archiving off when dir is empty.
+func walArchiveConf(dir string) string {
+	if dir == "" {
+		return "archive_mode = 'off'\n"
+	}
+
+	// Bounds how much committed work a crash can lose from the archive
+	return fmt.Sprintf(`archive_mode = 'on'
+archive_command = 'test ! -f %[1]s/%%f && cp %%p %[1]s/%%f'
+archive_timeout = '60s'
+`, dir)
+}
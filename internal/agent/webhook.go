@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 3
+)
+
+// WebhookConfig points lifecycle notifications at an HTTP endpoint.
+type WebhookConfig struct {
+	// Receives a JSON POST per event, empty disables webhooks
+	URL string `json:"url"`
+}
+
+func (c WebhookConfig) validate() error {
+	if c.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http(s) URL, got %q", c.URL)
+	}
+	return nil
+}
+
+// notifyWebhook posts event in the background. Delivery failures are logged,
+// never returned, so a slow or broken endpoint can't hold up the operation.
+func (s *AgentService) notifyWebhook(event LifecycleEvent) {
+	webhookURL := s.Config().Webhook.URL
+	if webhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Warning: failed to marshal %s webhook: %v", event.Event, err)
+		return
+	}
+
+	go postWebhook(webhookURL, event.Event, payload)
+}
+
+func postWebhook(webhookURL, event string, payload []byte) {
+	client := &http.Client{Timeout: webhookTimeout}
+
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * 2 * time.Second)
+		}
+
+		resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return
+		}
+		lastErr = fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+
+	log.Printf("Warning: failed to deliver %s webhook after %d attempts: %v", event, webhookAttempts, lastErr)
+}
@@ -1,15 +1,73 @@
 package agent
 
 import (
+	"cmp"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 const (
-	ZPool = "tank"
+	DefaultZPool   = "tank"
+	DefaultDataDir = "/opt/quic"
 )
 
+// ZFS pool and mount directory of templates and branches, and the directory
+// custom branch mountpoints must be under. Set from the agent config at
+// startup, see ApplyStorageConfig.
+var (
+	ZPool          = DefaultZPool
+	DataDir        = DefaultDataDir
+	MountpointRoot = ""
+)
+
+var poolNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.:-]*$`)
+
+// StorageConfig selects where templates and branches are stored.
+type StorageConfig struct {
+	// ZFS pool holding the template and branch datasets
+	Pool string `json:"pool"`
+	// Directory the datasets are mounted under. quic host setup only grants
+	// sudo access to /opt/quic, other directories need matching sudo rules
+	DataDir string `json:"dataDir"`
+	// Directory custom branch mountpoints must be under, set by quic host
+	// setup --mountpoint-root along with its sudo rules. Empty refuses them.
+	MountpointRoot string `json:"mountpointRoot"`
+}
+
+func (c StorageConfig) validate() error {
+	if c.Pool != "" && !poolNamePattern.MatchString(c.Pool) {
+		return fmt.Errorf("pool must be a ZFS pool name, got %q", c.Pool)
+	}
+	if c.DataDir != "" && !filepath.IsAbs(c.DataDir) {
+		return fmt.Errorf("dataDir must be an absolute path, got %q", c.DataDir)
+	}
+	if c.MountpointRoot != "" {
+		if !filepath.IsAbs(c.MountpointRoot) || filepath.Clean(c.MountpointRoot) == "/" {
+			return fmt.Errorf("mountpointRoot must be an absolute path other than /, got %q", c.MountpointRoot)
+		}
+		dataDir := filepath.Clean(cmp.Or(c.DataDir, DefaultDataDir))
+		if root := filepath.Clean(c.MountpointRoot); isWithinDir(root, dataDir) || isWithinDir(dataDir, root) {
+			return fmt.Errorf("mountpointRoot %q and dataDir %q must not contain each other", c.MountpointRoot, dataDir)
+		}
+	}
+	return nil
+}
+
+// ApplyStorageConfig points dataset names and mountpoints at the configured
+// pool and directory. Must be called before serving requests.
+func ApplyStorageConfig(c StorageConfig) {
+	ZPool = cmp.Or(c.Pool, DefaultZPool)
+	DataDir = filepath.Clean(cmp.Or(c.DataDir, DefaultDataDir))
+	MountpointRoot = ""
+	if c.MountpointRoot != "" {
+		MountpointRoot = filepath.Clean(c.MountpointRoot)
+	}
+}
+
 func GetTemplateDataset(template string) string {
 	return ZPool + "/" + template
 }
@@ -22,22 +80,70 @@ func GetSnapshotName(template, branch string) string {
 	return ZPool + "/" + template + "@" + branch
 }
 
+func GetTemplateMountpoint(template string) string {
+	return DataDir + "/" + template + "/_restore"
+}
+
 func GetBranchMountpoint(template, branch string) string {
-	return "/opt/quic/" + template + "/" + branch
+	return DataDir + "/" + template + "/" + branch
+}
+
+// Custom mountpoints end up in systemd units and postgresql.conf, so keep
+// them to plain path characters
+var mountpointPattern = regexp.MustCompile(`^/[A-Za-z0-9_./-]+$`)
+
+// ValidateMountpoint checks a custom branch mountpoint. It must be an
+// absolute, clean path below MountpointRoot, the only directory outside
+// DataDir that quicd has sudo rules for.
+func ValidateMountpoint(path string) error {
+	if !mountpointPattern.MatchString(path) || filepath.Clean(path) != path || path == "/" {
+		return fmt.Errorf("mountpoint must be a clean absolute path of letters, digits, '_', '.', '-' and '/', got '%s'", path)
+	}
+	if path == DataDir || strings.HasPrefix(path, DataDir+"/") {
+		return fmt.Errorf("mountpoint %s is inside %s, where quic places templates and branches. Use a path outside it", path, DataDir)
+	}
+	if MountpointRoot == "" {
+		return fmt.Errorf("custom mountpoints are off on this host. Run quic host setup with --mountpoint-root to allow them")
+	}
+	if !strings.HasPrefix(path, MountpointRoot+"/") {
+		return fmt.Errorf("mountpoint %s is outside %s, the directory this host allows custom mountpoints under", path, MountpointRoot)
+	}
+	return nil
+}
+
+// checkMountpointFree fails when another dataset mounts at or below path, or
+// path is a non-empty directory that ZFS would refuse to mount over.
+func checkMountpointFree(path string) error {
+	output, err := privileged("zfs", "list", "-H", "-o", "name,mountpoint").Output()
+	if err != nil {
+		return fmt.Errorf("listing ZFS mountpoints: %w", err)
+	}
+	for line := range strings.Lines(string(output)) {
+		name, mountpoint, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		if mountpoint == path || strings.HasPrefix(mountpoint, path+"/") {
+			return fmt.Errorf("mountpoint %s is already used by dataset %s", path, name)
+		}
+	}
+
+	entries, err := os.ReadDir(path)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("mountpoint %s is a directory that isn't empty", path)
+	}
+	return nil
 }
 
 func datasetExists(dataset string) bool {
-	cmd := exec.Command("sudo", "zfs", "list", "-H", "-o", "name", dataset)
+	cmd := privileged("zfs", "list", "-H", "-o", "name", dataset)
 	return cmd.Run() == nil
 }
 
 func snapshotExists(snapshot string) bool {
-	cmd := exec.Command("sudo", "zfs", "list", "-H", "-o", "name", "-t", "snapshot", snapshot)
+	cmd := privileged("zfs", "list", "-H", "-o", "name", "-t", "snapshot", snapshot)
 	return cmd.Run() == nil
 }
 
 func GetMountpoint(dataset string) (string, error) {
-	cmd := exec.Command("sudo", "zfs", "get", "-H", "-o", "value", "mountpoint", dataset)
+	cmd := privileged("zfs", "get", "-H", "-o", "value", "mountpoint", dataset)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("getting ZFS mountpoint: %w", err)
@@ -56,7 +162,7 @@ func destroyDataset(dataset string, flags ...string) error {
 	args = append(args, flags...)
 	args = append(args, dataset)
 
-	output, err := exec.Command("sudo", args...).CombinedOutput()
+	output, err := privileged(args[0], args[1:]...).CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("destroying ZFS dataset %s: %s", dataset, output)
 	}
@@ -65,25 +171,186 @@ func destroyDataset(dataset string, flags ...string) error {
 }
 
 func createSnapshot(snapshotName string) error {
-	cmd := exec.Command("sudo", "zfs", "snapshot", snapshotName)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("creating ZFS snapshot %s: %w", snapshotName, err)
+	return zfsSnapshot(snapshotName)
+}
+
+// createKindSnapshot creates a snapshot tagged with its kind, see
+// SnapshotKindProperty.
+func createKindSnapshot(snapshotName, kind string) error {
+	return zfsSnapshot(snapshotName, "-o", SnapshotKindProperty+"="+kind)
+}
+
+func zfsSnapshot(snapshotName string, options ...string) error {
+	args := append([]string{"snapshot"}, options...)
+	cmd := privileged("zfs", append(args, snapshotName)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if isOutOfSpace(string(output)) {
+			return poolFullError()
+		}
+		return fmt.Errorf("creating ZFS snapshot %s: %w (output: %s)", snapshotName, err, output)
 	}
 
 	return nil
 }
 
 func createClone(snapshot string, dataset string, mountpoint string) error {
-	cmd := exec.Command("sudo", "zfs", "clone", "-o", "mountpoint="+mountpoint, snapshot, dataset)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("creating ZFS clone: %w", err)
+	cmd := privileged("zfs", "clone", "-o", "mountpoint="+mountpoint, snapshot, dataset)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if isOutOfSpace(string(output)) {
+			return poolFullError()
+		}
+		return fmt.Errorf("creating ZFS clone: %w (output: %s)", err, output)
 	}
 
 	return nil
 }
 
+// poolFullError reports ZFS running out of space, with the current free space when known.
+func poolFullError() error {
+	free, _ := getPoolFree(ZPool)
+	return &PoolFullError{Pool: ZPool, Free: free}
+}
+
+func promoteDataset(dataset string) error {
+	output, err := privileged("zfs", "promote", dataset).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("promoting ZFS dataset %s: %s", dataset, output)
+	}
+
+	return nil
+}
+
+func getOrigin(dataset string) (string, error) {
+	cmd := privileged("zfs", "get", "-H", "-o", "value", "origin", dataset)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("getting ZFS origin: %w", err)
+	}
+
+	origin := strings.TrimSpace(string(output))
+	if origin == "-" {
+		return "", nil
+	}
+
+	return origin, nil
+}
+
+// getClones returns the datasets cloned from snapshot.
+func getClones(snapshot string) ([]string, error) {
+	cmd := privileged("zfs", "get", "-H", "-o", "value", "clones", snapshot)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("getting ZFS clones of %s: %w", snapshot, err)
+	}
+
+	value := strings.TrimSpace(string(output))
+	if value == "" || value == "-" {
+		return nil, nil
+	}
+
+	return strings.Split(value, ","), nil
+}
+
+func listSnapshots(dataset string) ([]string, error) {
+	cmd := privileged("zfs", "list", "-H", "-o", "name", "-t", "snapshot", "-d", "1", dataset)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing ZFS snapshots of %s: %w", dataset, err)
+	}
+
+	var snapshots []string
+	for line := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			snapshots = append(snapshots, line)
+		}
+	}
+
+	return snapshots, nil
+}
+
+type DatasetSpace struct {
+	Used       int64
+	Referenced int64
+	Available  int64
+	Quota      int64 // 0 when no quota is set
+	// Storage properties, as reported by zfs ("off" when disabled)
+	Encryption    string
+	Compression   string
+	CompressRatio string // e.g. 1.85x
+}
+
+func getDatasetSpace(dataset string) (DatasetSpace, error) {
+	cmd := privileged("zfs", "get", "-Hp", "-o", "property,value",
+		"used,referenced,available,quota,encryption,compression,compressratio", dataset)
+	output, err := cmd.Output()
+	if err != nil {
+		return DatasetSpace{}, fmt.Errorf("getting ZFS space usage for %s: %w", dataset, err)
+	}
+
+	var space DatasetSpace
+	for line := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "encryption":
+			space.Encryption = fields[1]
+			continue
+		case "compression":
+			space.Compression = fields[1]
+			continue
+		case "compressratio":
+			space.CompressRatio = strings.TrimSuffix(fields[1], "x") + "x"
+			continue
+		}
+
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "used":
+			space.Used = value
+		case "referenced":
+			space.Referenced = value
+		case "available":
+			space.Available = value
+		case "quota":
+			space.Quota = value
+		}
+	}
+
+	return space, nil
+}
+
+// listDatasetUsed returns the used bytes of every dataset under root.
+func listDatasetUsed(root string) (map[string]int64, error) {
+	cmd := privileged("zfs", "list", "-Hp", "-o", "name,used", "-r", root)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing ZFS usage under %s: %w", root, err)
+	}
+
+	used := make(map[string]int64)
+	for line := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if value, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			used[fields[0]] = value
+		}
+	}
+
+	return used, nil
+}
+
 func listDatasets(filterByDataset string) ([]string, error) {
-	cmd := exec.Command("sudo", "zfs", "list", "-H", "-o", "name", "-r", filterByDataset)
+	cmd := privileged("zfs", "list", "-H", "-o", "name", "-r", filterByDataset)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("listing ZFS datasets under %s: %s", filterByDataset, output)
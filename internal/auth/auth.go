@@ -1,12 +1,17 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/quickr-dev/quic/internal/db"
 )
 
+// ErrUserDBUnavailable means tokens can't be checked at all, as opposed to
+// a token that doesn't belong to any user.
+var ErrUserDBUnavailable = errors.New("user database unavailable")
+
 func ValidateToken(token string) (string, error) {
 	if token == "" {
 		return "", fmt.Errorf("token is required")
@@ -14,7 +19,7 @@ func ValidateToken(token string) (string, error) {
 
 	database, err := db.InitDB()
 	if err != nil {
-		return "", fmt.Errorf("initializing database: %w", err)
+		return "", fmt.Errorf("%w: %v", ErrUserDBUnavailable, err)
 	}
 	defer database.Close()
 
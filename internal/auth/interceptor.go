@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"google.golang.org/grpc"
@@ -21,31 +22,68 @@ func UnaryAuthInterceptor() grpc.UnaryServerInterceptor {
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing metadata")
-		}
-
-		authHeaders := md.Get("authorization")
-		if len(authHeaders) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "missing authorization header")
+		newCtx, err := authenticate(ctx)
+		if err != nil {
+			return nil, err
 		}
 
-		token := ExtractTokenFromHeader(authHeaders[0])
-		if token == "" {
-			return nil, status.Error(codes.Unauthenticated, "invalid authorization header format")
-		}
+		return handler(newCtx, req)
+	}
+}
 
-		userName, err := ValidateToken(token)
+func StreamAuthInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		newCtx, err := authenticate(ss.Context())
 		if err != nil {
-			log.Printf("Authentication failed for token %s...: %v", token[:min(8, len(token))], err)
-			return nil, status.Error(codes.Unauthenticated, "invalid token")
+			return err
 		}
 
-		newCtx := context.WithValue(ctx, UserContextKey, userName)
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: newCtx})
+	}
+}
 
-		return handler(newCtx, req)
+// authenticatedStream overrides the stream context to carry the authenticated user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context) (context.Context, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
 	}
+
+	authHeaders := md.Get("authorization")
+	if len(authHeaders) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization header")
+	}
+
+	token := ExtractTokenFromHeader(authHeaders[0])
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization header format")
+	}
+
+	userName, err := ValidateToken(token)
+	if errors.Is(err, ErrUserDBUnavailable) {
+		log.Printf("Authentication unavailable: %v", err)
+		return nil, status.Error(codes.Unavailable, "authentication unavailable: the host's user database can't be opened, see quicd logs")
+	}
+	if err != nil {
+		log.Printf("Authentication failed for token %s...: %v", token[:min(8, len(token))], err)
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	return context.WithValue(ctx, UserContextKey, userName), nil
 }
 
 func GetUserFromContext(ctx context.Context) (string, bool) {
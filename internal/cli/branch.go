@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var branchCmd = &cobra.Command{
+	Use:   "branch",
+	Short: "Manage branches",
+}
+
+func init() {
+	branchCmd.AddCommand(branchAttachCmd)
+	branchCmd.AddCommand(branchDescribeCmd)
+	branchCmd.AddCommand(branchDiffCmd)
+	branchCmd.AddCommand(branchExportCmd)
+	branchCmd.AddCommand(branchFreezeCmd)
+	branchCmd.AddCommand(branchImportCmd)
+	branchCmd.AddCommand(branchInfoCmd)
+	branchCmd.AddCommand(branchMoveCmd)
+	branchCmd.AddCommand(branchPromoteCmd)
+	branchCmd.AddCommand(branchReplicaCmd)
+	branchCmd.AddCommand(branchRollbackCmd)
+	branchCmd.AddCommand(branchSetCmd)
+	branchCmd.AddCommand(branchSnapshotCmd)
+	branchCmd.AddCommand(branchUnfreezeCmd)
+	branchCmd.AddCommand(branchURLCmd)
+}
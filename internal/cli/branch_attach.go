@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchAttachCmd = &cobra.Command{
+	Use:   "attach <branch-name>",
+	Short: "Start a branch whose data exists but whose service doesn't",
+	Long: `Start a branch again from its existing dataset and metadata, for example after
+'quic delete --keep-data', a crash or reinstalling quicd.
+
+The branch's service is recreated on its original port, and the port is opened
+in the firewall again. If something else took the port meanwhile, the branch
+gets a new one and the connection string printed reflects it.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchAttach(args[0], cmd)
+	},
+}
+
+func init() {
+	branchAttachCmd.Flags().String("template", "", "Template of the branch")
+	branchAttachCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchAttach(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.AttachBranch(ctx, &pb.AttachBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("attaching branch: %w", err)
+		}
+
+		host := userCfg.SelectedHost
+		if resp.Host != "" {
+			host = resp.Host
+		}
+		if resp.PreviousPort != "" {
+			fmt.Fprintf(os.Stderr, "Port %s is taken, the branch now listens on a new port\n", resp.PreviousPort)
+		}
+
+		fmt.Println(formatConnectionString(resp.ConnectionString, host, template.Database))
+		return nil
+	})
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchDescribeCmd = &cobra.Command{
+	Use:   "describe <branch-name>",
+	Short: "Summarize the databases and largest tables of a branch",
+	Long: `Show the size of each database of a running branch and its largest tables,
+with estimated row counts and index sizes.
+
+Tables are listed from the template's database unless --database is given.
+Row counts are the planner's estimates, so they're only as recent as the last
+ANALYZE. Only catalogs are read, so it's fast on branches of any size.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchDescribe(args[0], cmd)
+	},
+}
+
+func init() {
+	branchDescribeCmd.Flags().String("template", "", "Template of the branch")
+	branchDescribeCmd.Flags().String("database", "", "Database to list tables of (defaults to the template's database)")
+	branchDescribeCmd.Flags().Int("limit", 10, "Number of largest tables to list")
+	branchDescribeCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+// branchDescriptionJSON is the --output json and yaml form of a branch description.
+type branchDescriptionJSON struct {
+	Database   string             `json:"database" yaml:"database"`
+	TableCount int32              `json:"table_count" yaml:"table_count"`
+	Databases  []databaseSizeJSON `json:"databases" yaml:"databases"`
+	Tables     []tableSizeJSON    `json:"tables" yaml:"tables"`
+}
+
+type databaseSizeJSON struct {
+	Name  string `json:"name" yaml:"name"`
+	Bytes int64  `json:"bytes" yaml:"bytes"`
+}
+
+type tableSizeJSON struct {
+	Name          string `json:"name" yaml:"name"`
+	EstimatedRows int64  `json:"estimated_rows" yaml:"estimated_rows"`
+	TotalBytes    int64  `json:"total_bytes" yaml:"total_bytes"`
+	IndexBytes    int64  `json:"index_bytes" yaml:"index_bytes"`
+}
+
+func executeBranchDescribe(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	database, _ := cmd.Flags().GetString("database")
+	limit, _ := cmd.Flags().GetInt("limit")
+	if limit < 1 {
+		return fmt.Errorf("--limit must be at least 1, got %d", limit)
+	}
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+	if database == "" {
+		database = template.Database
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.DescribeBranch(ctx, &pb.DescribeBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+			Database:    database,
+			Limit:       int32(limit),
+		})
+		if err != nil {
+			return fmt.Errorf("describing branch: %w", err)
+		}
+
+		if format != "table" {
+			return renderOutput(format, newBranchDescriptionJSON(resp), nil)
+		}
+
+		fmt.Printf("%-24s %s\n", "DATABASE", "SIZE")
+		for _, db := range resp.Databases {
+			fmt.Printf("%-24s %s\n", db.Name, formatDiffSize(db.Bytes))
+		}
+
+		fmt.Printf("\n%s: %d table(s)", resp.Database, resp.TableCount)
+		if len(resp.Tables) == 0 {
+			fmt.Println()
+			return nil
+		}
+		if int(resp.TableCount) > len(resp.Tables) {
+			fmt.Printf(", %d largest", len(resp.Tables))
+		}
+		fmt.Printf("\n\n%-40s %-12s %-12s %s\n", "TABLE", "ROWS (EST.)", "SIZE", "INDEXES")
+		for _, table := range resp.Tables {
+			rows := fmt.Sprint(table.EstimatedRows)
+			if table.EstimatedRows < 0 {
+				rows = "-"
+			}
+			fmt.Printf("%-40s %-12s %-12s %s\n", table.Name, rows, formatDiffSize(table.TotalBytes), formatDiffSize(table.IndexBytes))
+		}
+		return nil
+	})
+}
+
+func newBranchDescriptionJSON(resp *pb.DescribeBranchResponse) branchDescriptionJSON {
+	result := branchDescriptionJSON{
+		Database:   resp.Database,
+		TableCount: resp.TableCount,
+		Databases:  []databaseSizeJSON{},
+		Tables:     []tableSizeJSON{},
+	}
+	for _, db := range resp.Databases {
+		result.Databases = append(result.Databases, databaseSizeJSON{Name: db.Name, Bytes: db.Bytes})
+	}
+	for _, table := range resp.Tables {
+		result.Tables = append(result.Tables, tableSizeJSON{
+			Name:          table.Name,
+			EstimatedRows: table.EstimatedRows,
+			TotalBytes:    table.TotalBytes,
+			IndexBytes:    table.IndexBytes,
+		})
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchDiffCmd = &cobra.Command{
+	Use:   "diff <branch-name>",
+	Short: "Show how much a branch has changed since it was cloned",
+	Long: `Show the bytes a branch has written since it was cloned from its template,
+and the size of each of its databases compared with the template.
+
+Template sizes are read from the template as it is now, so they include
+changes replayed into the template after the branch was created.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchDiff(args[0], cmd)
+	},
+}
+
+func init() {
+	branchDiffCmd.Flags().String("template", "", "Template of the branch")
+	branchDiffCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchDiff(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		diff, err := client.GetBranchDiff(ctx, &pb.GetBranchDiffRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("getting branch diff: %w", err)
+		}
+
+		fmt.Printf("%-10s %s\n", "Branch:", branchName)
+		fmt.Printf("%-10s %s\n", "Base:", diff.BaseSnapshot)
+		fmt.Printf("%-10s %s since the clone\n", "Written:", formatDiffSize(diff.WrittenBytes))
+
+		if len(diff.Databases) == 0 {
+			fmt.Println("\nDatabase sizes are only compared while the branch and template are running.")
+			return nil
+		}
+
+		fmt.Printf("\n%-24s %-12s %-12s %s\n", "DATABASE", "TEMPLATE", "BRANCH", "CHANGE")
+		for _, db := range diff.Databases {
+			template := formatDiffSize(db.TemplateBytes)
+			if db.TemplateBytes == 0 {
+				template = "-"
+			}
+			fmt.Printf("%-24s %-12s %-12s %s\n", db.Name, template, formatDiffSize(db.BranchBytes), formatSizeChange(db.BranchBytes-db.TemplateBytes))
+		}
+
+		return nil
+	})
+}
+
+// formatDiffSize is formatSize with zero shown as 0B.
+func formatDiffSize(bytes int64) string {
+	if bytes == 0 {
+		return "0B"
+	}
+	return formatSize(bytes)
+}
+
+func formatSizeChange(delta int64) string {
+	switch {
+	case delta > 0:
+		return "+" + formatSize(delta)
+	case delta < 0:
+		return "-" + formatSize(-delta)
+	default:
+		return "unchanged"
+	}
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchExportCmd = &cobra.Command{
+	Use:               "export <branch-name>",
+	Short:             "Export a branch to a pg_dump file",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchExport(args[0], cmd)
+	},
+}
+
+func init() {
+	branchExportCmd.Flags().String("template", "", "Template of the branch")
+	branchExportCmd.Flags().String("database", "", "Database to export (defaults to the template's database)")
+	branchExportCmd.Flags().String("format", "custom", "Dump format: custom or plain")
+	branchExportCmd.Flags().StringP("file", "f", "", "File to write the dump to (defaults to <branch-name>.dump or <branch-name>.sql)")
+	branchExportCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchExport(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	database, _ := cmd.Flags().GetString("database")
+	if database == "" {
+		database = template.Database
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "custom" && format != "plain" {
+		return fmt.Errorf("unsupported format '%s'. Use custom or plain", format)
+	}
+
+	outputPath, _ := cmd.Flags().GetString("file")
+	if outputPath == "" {
+		outputPath = branchName + ".dump"
+		if format == "plain" {
+			outputPath = branchName + ".sql"
+		}
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	// Write to a temp file first so a failed export never leaves a truncated dump behind
+	tmpPath := outputPath + ".partial"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	var written int64
+	err = executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, 120*time.Minute, func(client pb.QuicServiceClient, ctx context.Context) error {
+		stream, err := client.ExportBranch(ctx, &pb.ExportBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+			Database:    database,
+			Format:      format,
+		})
+		if err != nil {
+			return fmt.Errorf("starting export: %w", err)
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("export stream error: %w", err)
+			}
+
+			n, err := file.Write(resp.Data)
+			if err != nil {
+				return fmt.Errorf("writing output file: %w", err)
+			}
+			written += int64(n)
+		}
+	})
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("closing output file: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return fmt.Errorf("saving output file: %w", err)
+	}
+
+	fmt.Printf("Exported branch '%s' (%s) to %s\n", branchName, formatSize(written), outputPath)
+	return nil
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchFreezeCmd = &cobra.Command{
+	Use:   "freeze <branch-name>",
+	Short: "Protect a branch from accidental changes",
+	Long: `Protect a branch, for example a reviewed baseline, from accidental changes.
+
+Sessions on a frozen branch are read-only by default (default_transaction_read_only),
+and the branch restarts so that open sessions end. quic refuses to roll back a
+frozen branch and deletes it only with --force. A session can still opt into
+writing with 'SET default_transaction_read_only = off', so freezing guards
+against mistakes, not against the branch's users.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchFreeze(args[0], false, cmd)
+	},
+}
+
+var branchUnfreezeCmd = &cobra.Command{
+	Use:               "unfreeze <branch-name>",
+	Short:             "Make a frozen branch writable again",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchFreeze(args[0], true, cmd)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{branchFreezeCmd, branchUnfreezeCmd} {
+		cmd.Flags().String("template", "", "Template of the branch")
+		cmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+	}
+}
+
+func executeBranchFreeze(branchName string, unfreeze bool, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	state := "frozen"
+	if unfreeze {
+		state = "unfrozen"
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.FreezeBranch(ctx, &pb.FreezeBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+			Unfreeze:    unfreeze,
+		})
+		if err != nil {
+			return fmt.Errorf("changing branch: %w", err)
+		}
+
+		if resp.Changed {
+			fmt.Printf("Branch '%s' %s\n", branchName, state)
+		} else {
+			fmt.Printf("Branch '%s' is already %s\n", branchName, state)
+		}
+		return nil
+	})
+}
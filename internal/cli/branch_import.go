@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+const importChunkSize = 64 * 1024
+
+var branchImportCmd = &cobra.Command{
+	Use:   "import <branch-name>",
+	Short: "Create a branch from a pg_dump file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchImport(args[0], cmd)
+	},
+}
+
+func init() {
+	branchImportCmd.Flags().String("from", "", "Dump file to import (pg_dump custom format or plain SQL)")
+	branchImportCmd.Flags().String("pg-version", "", "PostgreSQL version the dump needs, refused when the host runs another (defaults to the host's version)")
+	branchImportCmd.Flags().String("template", "", "Template to create the branch under")
+	branchImportCmd.Flags().String("database", "", "Database to restore into (defaults to the template's database)")
+	branchImportCmd.MarkFlagRequired("from")
+	branchImportCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchImport(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	database, _ := cmd.Flags().GetString("database")
+	if database == "" {
+		database = template.Database
+	}
+
+	pgVersion, _ := cmd.Flags().GetString("pg-version")
+	dumpPath, _ := cmd.Flags().GetString("from")
+
+	file, err := os.Open(dumpPath)
+	if err != nil {
+		return fmt.Errorf("opening dump: %w", err)
+	}
+	defer file.Close()
+
+	format, err := detectDumpFormat(file)
+	if err != nil {
+		return err
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	return executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, 120*time.Minute, func(client pb.QuicServiceClient, ctx context.Context) error {
+		stream, err := client.ImportBranch(ctx)
+		if err != nil {
+			return fmt.Errorf("starting import: %w", err)
+		}
+
+		err = stream.Send(&pb.ImportBranchRequest{
+			Message: &pb.ImportBranchRequest_Header{
+				Header: &pb.ImportBranchHeader{
+					CloneName:   branchName,
+					RestoreName: template.Name,
+					PgVersion:   pgVersion,
+					Database:    database,
+					Format:      format,
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("sending import header: %w", err)
+		}
+
+		fmt.Printf("Uploading %s...\n", dumpPath)
+		buf := make([]byte, importChunkSize)
+		for {
+			n, readErr := file.Read(buf)
+			if n > 0 {
+				if err := stream.Send(&pb.ImportBranchRequest{
+					Message: &pb.ImportBranchRequest_Data{Data: buf[:n]},
+				}); err != nil {
+					// The agent rejected the import, its reason comes from Recv below
+					break
+				}
+			}
+			if readErr == io.EOF {
+				break
+			}
+			if readErr != nil {
+				return fmt.Errorf("reading dump: %w", readErr)
+			}
+		}
+
+		if err := stream.CloseSend(); err != nil {
+			return fmt.Errorf("finishing upload: %w", err)
+		}
+
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("import failed: %w", err)
+			}
+
+			switch msg := resp.Message.(type) {
+			case *pb.ImportBranchResponse_Log:
+				fmt.Printf("  %s\n", msg.Log.Line)
+
+			case *pb.ImportBranchResponse_Result:
+				host := userCfg.SelectedHost
+				if msg.Result.Host != "" {
+					host = msg.Result.Host
+				}
+				fmt.Println(formatConnectionString(msg.Result.ConnectionString, host, database))
+			}
+		}
+	})
+}
+
+// detectDumpFormat tells custom-format dumps (which start with "PGDMP") from
+// plain SQL, leaving the file positioned at the start.
+func detectDumpFormat(file *os.File) (string, error) {
+	magic := make([]byte, 5)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("reading dump: %w", err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("reading dump: %w", err)
+	}
+
+	if n == len(magic) && bytes.Equal(magic, []byte("PGDMP")) {
+		return "custom", nil
+	}
+	return "plain", nil
+}
@@ -0,0 +1,228 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/ui"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchInfoCmd = &cobra.Command{
+	Use:   "info <branch-name>",
+	Short: "Show branch status, connections and disk usage",
+	Long: `Show the status of a branch: its service, whether it accepts connections,
+whether it is still in recovery, the number of client connections and the disk
+space it uses.
+
+With --watch the status is refreshed in place until you press q or Ctrl-C.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchInfo(args[0], cmd)
+	},
+}
+
+func init() {
+	branchInfoCmd.Flags().String("template", "", "Template of the branch")
+	branchInfoCmd.Flags().BoolP("watch", "w", false, "Keep refreshing the status until q or Ctrl-C")
+	branchInfoCmd.Flags().Duration("interval", 2*time.Second, "Refresh interval with --watch")
+	branchInfoCmd.Flags().Bool("last-timing", false, "Show how long each step of the branch's checkout took")
+	branchInfoCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchInfo(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	watch, _ := cmd.Flags().GetBool("watch")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	lastTiming, _ := cmd.Flags().GetBool("last-timing")
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	if format != "table" {
+		if watch {
+			return fmt.Errorf("--watch only works with --output table")
+		}
+		return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+			info, err := client.GetBranchInfo(ctx, &pb.GetBranchInfoRequest{
+				CloneName:   branchName,
+				RestoreName: template.Name,
+			})
+			if err != nil {
+				return fmt.Errorf("getting branch info: %w", err)
+			}
+			return renderOutput(format, newBranchInfoView(info, lastTiming), nil)
+		})
+	}
+
+	fetch := func() (string, error) {
+		var output string
+		err := executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+			info, err := client.GetBranchInfo(ctx, &pb.GetBranchInfoRequest{
+				CloneName:   branchName,
+				RestoreName: template.Name,
+			})
+			if err != nil {
+				return fmt.Errorf("getting branch info: %w", err)
+			}
+			output = formatBranchInfo(info)
+			if lastTiming {
+				output += formatTimings(info.Timings)
+			}
+			return nil
+		})
+		return output, err
+	}
+
+	if !watch {
+		output, err := fetch()
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+		return nil
+	}
+
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	// One connection for all refreshes rather than a handshake each interval
+	reuseConnections()
+
+	return ui.RunWatch(fmt.Sprintf("Branch %s (%s)", branchName, template.Name), interval, fetch)
+}
+
+func formatBranchInfo(info *pb.GetBranchInfoResponse) string {
+	var b strings.Builder
+
+	ready := "no"
+	if info.Ready {
+		ready = "yes"
+	}
+
+	recovery := "no"
+	if info.InRecovery {
+		recovery = "yes"
+	}
+
+	disk := fmt.Sprintf("%s used, %s referenced", formatSize(info.UsedBytes), formatSize(info.ReferencedBytes))
+	if info.QuotaBytes > 0 {
+		disk = fmt.Sprintf("%s of %s quota, %s referenced",
+			formatSize(info.UsedBytes), formatSize(info.QuotaBytes), formatSize(info.ReferencedBytes))
+	}
+
+	fmt.Fprintf(&b, "%-12s %s\n", "Branch:", info.CloneName)
+	fmt.Fprintf(&b, "%-12s %s\n", "Template:", info.RestoreName)
+	fmt.Fprintf(&b, "%-12s %s\n", "Created:", fmt.Sprintf("%s by %s", info.CreatedAt, info.CreatedBy))
+	if info.LastAccessedAt != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "Last active:", info.LastAccessedAt)
+	}
+	if info.DataAsOf != "" {
+		dataAsOf := info.DataAsOf
+		if info.BackupFinishedAt != "" && info.BackupFinishedAt != info.DataAsOf {
+			dataAsOf += fmt.Sprintf(" (backup finished %s)", info.BackupFinishedAt)
+		}
+		fmt.Fprintf(&b, "%-12s %s\n", "Data as of:", dataAsOf)
+	}
+	fmt.Fprintf(&b, "%-12s %s\n", "Port:", info.Port)
+	fmt.Fprintf(&b, "%-12s %s\n", "Service:", info.ServiceStatus)
+	fmt.Fprintf(&b, "%-12s %s\n", "Ready:", ready)
+	fmt.Fprintf(&b, "%-12s %s\n", "Recovery:", recovery)
+	connections := fmt.Sprint(info.ConnectionCount)
+	if info.MaxConnections > 0 {
+		connections = fmt.Sprintf("%d of %d max", info.ConnectionCount, info.MaxConnections)
+	}
+	fmt.Fprintf(&b, "%-12s %s\n", "Connections:", connections)
+	fmt.Fprintf(&b, "%-12s %s\n", "Disk:", disk)
+	if info.Mountpoint != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "Mountpoint:", info.Mountpoint)
+	}
+	if info.Encryption != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "Encryption:", info.Encryption)
+		fmt.Fprintf(&b, "%-12s %s\n", "Compression:", formatCompression(info.Compression, info.CompressRatio))
+	}
+	if info.RoleMode != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "Role mode:", info.RoleMode)
+	}
+	if len(info.Extensions) > 0 {
+		fmt.Fprintf(&b, "%-12s %s\n", "Extensions:", strings.Join(info.Extensions, ", "))
+	}
+	if len(info.Settings) > 0 {
+		fmt.Fprintf(&b, "%-12s %s\n", "Settings:", strings.Join(info.Settings, ", "))
+	}
+	if info.Profile != "" && info.Profile != "default" {
+		fmt.Fprintf(&b, "%-12s %s\n", "Profile:", info.Profile)
+	}
+	if info.Analyzed {
+		fmt.Fprintf(&b, "%-12s %s\n", "Analyzed:", "yes, after startup")
+	}
+	if info.WalReset != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "WAL reset:", info.WalReset)
+	}
+	if info.WalArchive != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "WAL archive:", info.WalArchive)
+	}
+	if info.Detached {
+		fmt.Fprintf(&b, "%-12s %s\n", "Detached:", "yes, data kept without a service")
+	}
+	if info.Promoted {
+		fmt.Fprintf(&b, "%-12s %s\n", "Promoted:", "yes")
+	}
+	if info.Frozen {
+		fmt.Fprintf(&b, "%-12s %s\n", "Frozen:", "yes, read-only by default")
+	}
+	if info.TemplateMissing {
+		fmt.Fprintf(&b, "%-12s %s\n", "Template:", "missing, promote the branch to keep it or delete it")
+	}
+	if info.ReplicaOf != "" {
+		fmt.Fprintf(&b, "%-12s %s\n", "Replica of:", info.ReplicaOf+", read-only")
+	}
+	if len(info.Replicas) > 0 {
+		fmt.Fprintf(&b, "%-12s %s\n", "Replicas:", strings.Join(info.Replicas, ", "))
+	}
+	if len(info.Checkpoints) > 0 {
+		b.WriteString("Checkpoints:\n")
+		for _, c := range info.Checkpoints {
+			fmt.Fprintf(&b, "  %-20s %s by %s\n", c.Label, c.CreatedAt, c.CreatedBy)
+		}
+	}
+
+	return b.String()
+}
+
+// formatCompression describes a dataset's compression, e.g. "lz4 (1.85x ratio)".
+func formatCompression(compression, ratio string) string {
+	if compression == "off" || ratio == "" {
+		return compression
+	}
+	return fmt.Sprintf("%s (%s ratio)", compression, ratio)
+}
+
+// formatTimings lists step durations with their total.
+func formatTimings(timings []*pb.StepTiming) string {
+	if len(timings) == 0 {
+		return "Timing:      not recorded\n"
+	}
+
+	var b strings.Builder
+	var total time.Duration
+	b.WriteString("Timing:\n")
+	for _, t := range timings {
+		d := time.Duration(t.DurationMs) * time.Millisecond
+		total += d
+		fmt.Fprintf(&b, "  %-20s %s\n", t.Step, d)
+	}
+	fmt.Fprintf(&b, "  %-20s %s\n", "total", total)
+	return b.String()
+}
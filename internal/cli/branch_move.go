@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchMoveCmd = &cobra.Command{
+	Use:   "move <branch-name> --to-template <template>",
+	Short: "Move a branch to another template, copying its data",
+	Long: `Move a branch created under the wrong template to another one, keeping its
+data, checkpoints, port and password.
+
+A branch is a ZFS clone of its template and can't change templates in place,
+so its data is copied in full with zfs send and recv. The branch is stopped
+during the copy, which takes as long as writing all of its data again and
+needs that much free space. The moved branch no longer shares blocks with
+either template.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchMove(args[0], cmd)
+	},
+}
+
+func init() {
+	branchMoveCmd.Flags().String("template", "", "Template of the branch")
+	branchMoveCmd.Flags().String("to-template", "", "Template to move the branch to")
+	branchMoveCmd.Flags().Bool("force", false, "Move frozen branches")
+	branchMoveCmd.Flags().Bool("ignore-maintenance", false, "Move even if the host maintenance policy blocks destructive operations")
+	branchMoveCmd.MarkFlagRequired("to-template")
+	branchMoveCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+	branchMoveCmd.RegisterFlagCompletionFunc("to-template", completeTemplateNames)
+}
+
+func executeBranchMove(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+	toTemplateFlag, _ := cmd.Flags().GetString("to-template")
+	toTemplate, err := GetTemplate(toTemplateFlag)
+	if err != nil {
+		return err
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	ignoreMaintenance, _ := cmd.Flags().GetBool("ignore-maintenance")
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	// The copy takes as long as writing the whole branch
+	return executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, 120*time.Minute, func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.MoveBranch(ctx, &pb.MoveBranchRequest{
+			CloneName:         branchName,
+			RestoreName:       template.Name,
+			ToRestoreName:     toTemplate.Name,
+			IgnoreMaintenance: ignoreMaintenance,
+			Force:             force,
+		})
+		if err != nil {
+			return fmt.Errorf("moving branch: %w", err)
+		}
+
+		fmt.Printf("Moved branch '%s' from '%s' to '%s', copying %s\n", branchName, template.Name, toTemplate.Name, formatSize(resp.CopiedBytes))
+		if resp.ConnectionString == "" {
+			return nil
+		}
+
+		host := userCfg.SelectedHost
+		if resp.Host != "" {
+			host = resp.Host
+		}
+		fmt.Println(formatConnectionString(resp.ConnectionString, host, toTemplate.Database))
+		return nil
+	})
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchPromoteCmd = &cobra.Command{
+	Use:   "promote <branch-name>",
+	Short: "Make a branch own its template snapshot",
+	Long: `Make a branch own the template snapshot it was cloned from by running
+'zfs promote' on it.
+
+The snapshot, and the data it references, becomes owned by the branch. Space
+that was shared with the template is then accounted to the branch, and the
+template becomes the dependent dataset. Deleting a promoted branch hands the
+snapshot back to the template first.
+
+This doesn't detach the branch: its dataset stays inside the template's, so
+neither can be destroyed without the other.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchPromote(args[0], cmd)
+	},
+}
+
+func init() {
+	branchPromoteCmd.Flags().String("template", "", "Template of the branch")
+	branchPromoteCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchPromote(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.PromoteBranch(ctx, &pb.PromoteBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("promoting branch: %w", err)
+		}
+
+		if resp.Promoted {
+			fmt.Printf("Branch '%s' promoted\n", branchName)
+		} else {
+			fmt.Printf("Branch '%s' is already promoted\n", branchName)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchReplicaCmd = &cobra.Command{
+	Use:   "replica <branch-name>",
+	Short: "Create a read replica of a branch",
+	Long: `Create a read-only hot standby of a branch, streaming its changes.
+
+The replica is a branch of the same template with its own port and connection
+string, e.g. to load test read scaling. It's named <branch-name>-replica-<n>
+unless --name is given. Branches don't stream WAL until their first replica,
+so creating it restarts the branch once.
+
+Deleting the branch deletes its replicas too.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchReplica(args[0], cmd)
+	},
+}
+
+func init() {
+	branchReplicaCmd.Flags().String("template", "", "Template of the branch")
+	branchReplicaCmd.Flags().String("name", "", "Name of the replica (defaults to <branch-name>-replica-<n>)")
+	branchReplicaCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchReplica(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	replicaName, _ := cmd.Flags().GetString("name")
+
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.CreateReplica(ctx, &pb.CreateReplicaRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+			ReplicaName: replicaName,
+		})
+		if err != nil {
+			return fmt.Errorf("creating replica: %w", err)
+		}
+
+		host := userCfg.SelectedHost
+		if resp.Host != "" {
+			host = resp.Host
+		}
+
+		fmt.Println(formatConnectionString(resp.ConnectionString, host, template.Database))
+		fmt.Fprintf(os.Stderr, "Replica '%s' of branch '%s' (read-only)\n", resp.ReplicaName, branchName)
+		if resp.ParentRestarted {
+			fmt.Fprintf(os.Stderr, "Branch '%s' was restarted to enable replication\n", branchName)
+		}
+		return nil
+	})
+}
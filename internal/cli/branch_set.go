@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchSetCmd = &cobra.Command{
+	Use:   "set <branch-name> <name=value>...",
+	Short: "Change PostgreSQL settings of a running branch",
+	Long: `Change PostgreSQL settings of a running branch, like 'quic checkout --set'
+does for a new one. The same settings are allowed.
+
+Settings are written to the branch's postgresql.auto.conf and the configuration
+is reloaded. Settings PostgreSQL only reads at startup restart the branch,
+which ends open sessions.`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchSet(args[0], args[1:], cmd)
+	},
+}
+
+func init() {
+	branchSetCmd.Flags().String("template", "", "Template of the branch")
+	branchSetCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchSet(branchName string, settings []string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.ReconfigureBranch(ctx, &pb.ReconfigureBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+			Settings:    settings,
+		})
+		if err != nil {
+			return fmt.Errorf("changing settings: %w", err)
+		}
+
+		if len(resp.Reloaded) > 0 {
+			fmt.Printf("Applied immediately: %s\n", strings.Join(resp.Reloaded, ", "))
+		}
+		if len(resp.Restarted) > 0 {
+			fmt.Printf("Applied with a restart: %s\n", strings.Join(resp.Restarted, ", "))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchSnapshotCmd = &cobra.Command{
+	Use:   "snapshot <branch-name>",
+	Short: "Save a named checkpoint of a branch",
+	Long: `Save a named checkpoint of a branch as a ZFS snapshot of its dataset.
+
+Use 'quic branch rollback' to return the branch to the checkpoint, e.g. after a
+failed migration. Checkpoints are listed by 'quic branch info'.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchSnapshot(args[0], cmd)
+	},
+}
+
+var branchRollbackCmd = &cobra.Command{
+	Use:   "rollback <branch-name>",
+	Short: "Restore a branch to a checkpoint",
+	Long: `Restore a branch to a checkpoint saved with 'quic branch snapshot'.
+
+The branch is stopped, rolled back and started again. Changes made after the
+checkpoint are lost, and so are checkpoints taken after it.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchRollback(args[0], cmd)
+	},
+}
+
+func init() {
+	branchSnapshotCmd.Flags().String("template", "", "Template of the branch")
+	branchSnapshotCmd.Flags().String("label", "", "Name of the checkpoint")
+	branchSnapshotCmd.MarkFlagRequired("label")
+	branchSnapshotCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+
+	branchRollbackCmd.Flags().String("template", "", "Template of the branch")
+	branchRollbackCmd.Flags().String("to", "", "Checkpoint to roll back to")
+	branchRollbackCmd.Flags().Bool("ignore-maintenance", false, "Roll back even if the host maintenance policy blocks destructive operations")
+	branchRollbackCmd.MarkFlagRequired("to")
+	branchRollbackCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchSnapshot(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	label, _ := cmd.Flags().GetString("label")
+
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.SnapshotBranch(ctx, &pb.SnapshotBranchRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+			Label:       label,
+		})
+		if err != nil {
+			return fmt.Errorf("creating checkpoint: %w", err)
+		}
+
+		fmt.Printf("Checkpoint '%s' saved for branch '%s'\n", resp.Checkpoint.Label, branchName)
+		return nil
+	})
+}
+
+func executeBranchRollback(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	label, _ := cmd.Flags().GetString("to")
+	ignoreMaintenance, _ := cmd.Flags().GetBool("ignore-maintenance")
+
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		_, err := client.RollbackBranch(ctx, &pb.RollbackBranchRequest{
+			CloneName:         branchName,
+			RestoreName:       template.Name,
+			Label:             label,
+			IgnoreMaintenance: ignoreMaintenance,
+		})
+		if err != nil {
+			return fmt.Errorf("rolling back branch: %w", err)
+		}
+
+		fmt.Printf("Branch '%s' rolled back to checkpoint '%s'\n", branchName, label)
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var branchURLCmd = &cobra.Command{
+	Use:   "url <branch-name>",
+	Short: "Print the connection string of a branch",
+	Long: `Print only the connection string of an existing branch, for use in scripts:
+
+  psql "$(quic branch url my-branch)"`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBranchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeBranchURL(args[0], cmd)
+	},
+}
+
+func init() {
+	branchURLCmd.Flags().String("template", "", "Template of the branch")
+	branchURLCmd.Flags().String("database", "", "Database to connect to (defaults to the template's database)")
+	branchURLCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+func executeBranchURL(branchName string, cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	database, _ := cmd.Flags().GetString("database")
+
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+	if database == "" {
+		database = template.Database
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		info, err := client.GetBranchInfo(ctx, &pb.GetBranchInfoRequest{
+			CloneName:   branchName,
+			RestoreName: template.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("getting branch info: %w", err)
+		}
+		if info.ConnectionString == "" {
+			return fmt.Errorf("the agent didn't return a connection string, upgrade the host with quic host upgrade")
+		}
+
+		host := userCfg.SelectedHost
+		if info.Host != "" {
+			host = info.Host
+		}
+
+		fmt.Println(formatConnectionString(info.ConnectionString, host, database))
+		return nil
+	})
+}
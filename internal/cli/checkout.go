@@ -2,26 +2,109 @@ package cli
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/quickr-dev/quic/internal/config"
 	pb "github.com/quickr-dev/quic/proto"
 )
 
+const (
+	analyzeTimeout = 30 * time.Minute
+	// For --verify-connection, on top of the checkout's own timeout
+	connectionCheckTimeout = 10 * time.Second
+)
+
 var checkoutCmd = &cobra.Command{
-	Use:   "checkout <branch-name>",
+	Use:   "checkout [branch-name]",
 	Short: "Create a branch",
-	Args:  cobra.ExactArgs(1),
+	Long: `Create a branch of the template.
+
+Without a branch name, the branch is named after the current git branch,
+lowercased with characters quic doesn't allow, such as '/', replaced by '-'.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return executeCheckout(args[0], cmd)
+		if len(args) == 1 {
+			return executeCheckout(args[0], cmd)
+		}
+
+		gitBranch, err := currentGitBranch()
+		if err != nil {
+			return fmt.Errorf("%w. Pass a branch name: quic checkout <branch-name>", err)
+		}
+		branchName, err := branchNameFromGit(gitBranch)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Using branch name '%s' from git branch '%s'\n", branchName, gitBranch)
+		return executeCheckout(branchName, cmd)
 	},
 }
 
+// Git branch names quic can't use as is, see agent.ValidateBranchName
+var invalidBranchChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// currentGitBranch returns the branch checked out in the working directory's git repository.
+func currentGitBranch() (string, error) {
+	output, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("no branch name given and not inside a git repository")
+	}
+
+	branch := strings.TrimSpace(string(output))
+	if branch == "HEAD" {
+		return "", fmt.Errorf("no branch name given and git is in detached HEAD state")
+	}
+	return branch, nil
+}
+
+// branchNameFromGit turns a git branch like feature/Login into a valid quic
+// branch name like feature-login.
+func branchNameFromGit(gitBranch string) (string, error) {
+	name := invalidBranchChars.ReplaceAllString(strings.ToLower(gitBranch), "-")
+	// A leading '_' is reserved, and a leading '-' reads as a flag
+	name = strings.TrimLeft(name, "_-")
+	if len(name) > 50 {
+		name = name[:50]
+	}
+	name = strings.TrimRight(name, "-")
+
+	if name == "" {
+		return "", fmt.Errorf("can't derive a branch name from git branch '%s'. Pass a branch name: quic checkout <branch-name>", gitBranch)
+	}
+	return name, nil
+}
+
 func init() {
 	checkoutCmd.Flags().String("template", "", "Template to branch from")
+	checkoutCmd.Flags().String("idempotency-key", "", "Key that makes retried checkouts return the original result (defaults to a hash of the request)")
+	checkoutCmd.Flags().String("role-mode", "superuser", "Role created for the branch: superuser, or app (non-superuser with CRUD on the template database)")
+	checkoutCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+	checkoutCmd.Flags().String("extensions", "", "Comma-separated list of extensions to enable (e.g., pg_stat_statements). Defaults to the template's extensions")
+	checkoutCmd.Flags().Bool("archive", false, "Archive WAL so the branch can be recovered to a point in time (slower, uses more disk)")
+	checkoutCmd.Flags().StringArray("set", nil, "PostgreSQL setting for the branch as name=value (e.g., log_statement=all). Repeatable")
+	checkoutCmd.Flags().String("wal-reset", "", "How the branch starts: fast (pg_resetwal) or safe (crash recovery). Defaults to fast when the template could be checkpointed")
+	checkoutCmd.Flags().String("admin-password", "", "Password for the branch's admin role instead of a random one (also read from QUIC_ADMIN_PASSWORD)")
+	checkoutCmd.Flags().String("admin-password-file", "", "File holding the admin password, e.g. written by a secret manager")
+	checkoutCmd.MarkFlagsMutuallyExclusive("admin-password", "admin-password-file")
+	checkoutCmd.Flags().Int("max-connections", 0, "max_connections of the branch, e.g. for a connection pooler (defaults to 50)")
+	checkoutCmd.Flags().String("profile", "default", "Branch configuration: default, or performance (keeps autovacuum on for realistic performance tests)")
+	checkoutCmd.Flags().String("mountpoint", "", "Absolute path to mount the branch's data directory at, outside quic's data directory")
+	checkoutCmd.Flags().Bool("analyze", false, "Run ANALYZE on every database once the branch is up, so the planner has fresh statistics")
+	checkoutCmd.Flags().Int("retries", 3, "Times to retry on transient errors, e.g. while quicd restarts (0 disables retries)")
+	checkoutCmd.Flags().Bool("verify-connection", false, "Connect to the new branch from this machine and fail if that doesn't work, e.g. because of a firewall")
 }
 
 func executeCheckout(branchName string, cmd *cobra.Command) error {
@@ -31,34 +114,257 @@ func executeCheckout(branchName string, cmd *cobra.Command) error {
 		return err
 	}
 
+	extensions := template.Extensions
+	if cmd.Flags().Changed("extensions") {
+		extensionsFlag, _ := cmd.Flags().GetString("extensions")
+		extensions = splitCommaList(extensionsFlag)
+	}
+
+	roleMode, _ := cmd.Flags().GetString("role-mode")
+	if roleMode != "superuser" && roleMode != "app" {
+		return fmt.Errorf("invalid role mode '%s'. Use superuser or app", roleMode)
+	}
+
+	settings, _ := cmd.Flags().GetStringArray("set")
+	archive, _ := cmd.Flags().GetBool("archive")
+
+	walReset, _ := cmd.Flags().GetString("wal-reset")
+	if walReset != "" && walReset != "fast" && walReset != "safe" {
+		return fmt.Errorf("invalid WAL reset '%s'. Use fast or safe", walReset)
+	}
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	adminPassword, err := readAdminPassword(cmd)
+	if err != nil {
+		return err
+	}
+
+	maxConnections, _ := cmd.Flags().GetInt("max-connections")
+	if maxConnections < 0 {
+		return fmt.Errorf("invalid max connections %d", maxConnections)
+	}
+
+	profile, _ := cmd.Flags().GetString("profile")
+	if profile != "default" && profile != "performance" {
+		return fmt.Errorf("invalid profile '%s'. Use default or performance", profile)
+	}
+	analyze, _ := cmd.Flags().GetBool("analyze")
+	verifyConnection, _ := cmd.Flags().GetBool("verify-connection")
+	retries, _ := cmd.Flags().GetInt("retries")
+	if retries < 0 {
+		return fmt.Errorf("--retries must not be negative, got %d", retries)
+	}
+
+	mountpoint, _ := cmd.Flags().GetString("mountpoint")
+	if mountpoint != "" && !filepath.IsAbs(mountpoint) {
+		return fmt.Errorf("mountpoint must be an absolute path, got '%s'", mountpoint)
+	}
+
+	// ANALYZE reads every table, so allow for large databases
+	timeout := DefaultTimeout
+	if analyze {
+		timeout = analyzeTimeout
+	}
+
+	idempotencyKey, _ := cmd.Flags().GetString("idempotency-key")
+
 	userCfg, err := config.LoadUserConfig()
 	if err != nil {
 		return fmt.Errorf("loading user config: %w", err)
 	}
 
-	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+	return executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, timeout, func(client pb.QuicServiceClient, ctx context.Context) error {
 		req := &pb.CreateCheckoutRequest{
-			CloneName:   branchName,
-			RestoreName: template.Name,
+			CloneName:      branchName,
+			RestoreName:    template.Name,
+			Extensions:     extensions,
+			IdempotencyKey: idempotencyKey,
+			RoleMode:       roleMode,
+			Settings:       settings,
+			Archive:        archive,
+			WalReset:       walReset,
+			AdminPassword:  adminPassword,
+			MaxConnections: int32(maxConnections),
+			Profile:        profile,
+			Analyze:        analyze,
+			Mountpoint:     mountpoint,
+		}
+		if req.IdempotencyKey == "" {
+			key, err := checkoutIdempotencyKey(req)
+			if err != nil {
+				return err
+			}
+			req.IdempotencyKey = key
 		}
 
-		resp, err := client.CreateCheckout(ctx, req)
+		// The idempotency key makes a retry return the branch if an
+		// attempt created it after all
+		var resp *pb.CreateCheckoutResponse
+		err := retryTransient(ctx, retries, func() error {
+			var err error
+			resp, err = client.CreateCheckout(ctx, req)
+			return err
+		})
 		if err != nil {
 			return fmt.Errorf("creating checkout: %w", err)
 		}
 
-		connectionString := formatConnectionString(resp.ConnectionString, userCfg.SelectedHost, template.Database)
+		// Prefer the host the agent advertises; older agents don't send one
+		host := userCfg.SelectedHost
+		if resp.Host != "" {
+			host = resp.Host
+		}
+
+		connectionString := formatConnectionString(resp.ConnectionString, host, template.Database)
+
+		var check *connectionCheck
+		if verifyConnection {
+			check = checkBranchConnection(connectionString)
+		}
+
+		if format != "table" {
+			if err := renderOutput(format, newCheckoutJSON(connectionString, resp, check), nil); err != nil {
+				return err
+			}
+			return check.failure()
+		}
+
 		fmt.Println(connectionString)
-		return nil
+		if resp.RoleMode == "app" {
+			fmt.Fprintln(os.Stderr, "Role mode: app (admin is not a superuser)")
+		}
+		// Only for people, scripts capturing both streams expect just the URL
+		if resp.MaxConnections > 0 && isTerminal(os.Stderr) {
+			fmt.Fprintf(os.Stderr, "Max %d connections\n", resp.MaxConnections)
+		}
+		if check != nil && check.OK {
+			fmt.Fprintf(os.Stderr, "✓ Connected from this machine in %dms\n", check.LatencyMs)
+		}
+		return check.failure()
 	})
 }
 
-func formatConnectionString(original, hostname, database string) string {
-	// Replace hostname
-	result := strings.Replace(original, "@localhost:", fmt.Sprintf("@%s:", hostname), 1)
+// connectionCheck is the outcome of connecting to a new branch with --verify-connection.
+type connectionCheck struct {
+	OK        bool   `json:"ok" yaml:"ok"`
+	LatencyMs int64  `json:"latency_ms,omitempty" yaml:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// checkBranchConnection connects to a branch from this machine the way an
+// application would, first over plain TCP so that a blocked port is told
+// apart from PostgreSQL refusing the session.
+func checkBranchConnection(connectionString string) *connectionCheck {
+	u, err := url.Parse(connectionString)
+	if err != nil {
+		return &connectionCheck{Error: fmt.Sprintf("parsing connection string: %v", err)}
+	}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", u.Host, connectionCheckTimeout)
+	if err != nil {
+		return &connectionCheck{Error: fmt.Sprintf("can't reach %s: %v. A firewall, VPN or routing between this machine and the host may block the port", u.Host, err)}
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectionCheckTimeout)
+	defer cancel()
+	if err := selfTestQuery(ctx, connectionString); err != nil {
+		message := fmt.Sprintf("reached %s, but PostgreSQL refused the session: %v", u.Host, err)
+		if strings.Contains(err.Error(), "SSL") {
+			message += ". Check the sslmode of the connection string against the host's TLS setup"
+		}
+		return &connectionCheck{Error: message}
+	}
+
+	return &connectionCheck{OK: true, LatencyMs: time.Since(start).Milliseconds()}
+}
+
+// failure returns the check's error, nil when it passed or didn't run.
+func (c *connectionCheck) failure() error {
+	if c == nil || c.OK {
+		return nil
+	}
+	return fmt.Errorf("branch created, but connecting to it from this machine failed: %s", c.Error)
+}
 
-	// Replace database
-	result = strings.Replace(result, "/postgres", "/"+database, 1)
+// readAdminPassword returns the supplied admin password, from the flag, the
+// password file or QUIC_ADMIN_PASSWORD in that order. Empty lets quicd generate one.
+func readAdminPassword(cmd *cobra.Command) (string, error) {
+	if password, _ := cmd.Flags().GetString("admin-password"); password != "" {
+		return password, nil
+	}
 
+	if path, _ := cmd.Flags().GetString("admin-password-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading admin password file: %w", err)
+		}
+		// Files written by editors and secret managers often end with a newline
+		password := strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("admin password file %s is empty", path)
+		}
+		return password, nil
+	}
+
+	return os.Getenv("QUIC_ADMIN_PASSWORD"), nil
+}
+
+type checkoutJSON struct {
+	ConnectionString string           `json:"connection_string" yaml:"connection_string"`
+	RoleMode         string           `json:"role_mode" yaml:"role_mode"`
+	MaxConnections   int32            `json:"max_connections,omitempty" yaml:"max_connections,omitempty"`
+	Timings          []stepTimingJSON `json:"timings" yaml:"timings"`
+	// With --verify-connection
+	ConnectionCheck *connectionCheck `json:"connection_check,omitempty" yaml:"connection_check,omitempty"`
+}
+
+type stepTimingJSON struct {
+	Step       string `json:"step" yaml:"step"`
+	DurationMs int64  `json:"duration_ms" yaml:"duration_ms"`
+}
+
+func newCheckoutJSON(connectionString string, resp *pb.CreateCheckoutResponse, check *connectionCheck) checkoutJSON {
+	result := checkoutJSON{
+		ConnectionString: connectionString,
+		RoleMode:         resp.RoleMode,
+		MaxConnections:   resp.MaxConnections,
+		Timings:          []stepTimingJSON{},
+		ConnectionCheck:  check,
+	}
+	for _, t := range resp.Timings {
+		result.Timings = append(result.Timings, stepTimingJSON{Step: t.Step, DurationMs: t.DurationMs})
+	}
 	return result
 }
+
+// formatConnectionString points a connection string returned by the agent at
+// hostname and database, keeping its escaping intact.
+func formatConnectionString(original, hostname, database string) string {
+	u, err := url.Parse(original)
+	if err != nil {
+		return original
+	}
+
+	u.Host = net.JoinHostPort(hostname, u.Port())
+	u.Path = "/" + database
+
+	return u.String()
+}
+
+// checkoutIdempotencyKey derives a key from the request, so running the same
+// checkout again, from a script retrying a failed step, returns the branch an
+// earlier attempt created rather than racing it.
+func checkoutIdempotencyKey(req *pb.CreateCheckoutRequest) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("deriving idempotency key: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16]), nil
+}
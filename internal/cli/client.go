@@ -6,12 +6,17 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"net"
+	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/quickr-dev/quic/internal/config"
 	pb "github.com/quickr-dev/quic/proto"
@@ -19,6 +24,69 @@ import (
 
 const DefaultTimeout = 60 * time.Second
 
+const (
+	retryInitialBackoff = time.Second
+	retryMaxBackoff     = 10 * time.Second
+)
+
+var (
+	connCacheMu sync.Mutex
+	// Open connections by host, kept for the rest of the command. nil unless
+	// the command called reuseConnections.
+	connCache map[string]*grpc.ClientConn
+)
+
+// reuseConnections makes executeWithClientOnHost keep one connection per
+// host for the rest of the command, instead of a TLS handshake per call. For
+// commands making several calls; Execute closes the connections at exit.
+func reuseConnections() {
+	connCacheMu.Lock()
+	defer connCacheMu.Unlock()
+	if connCache == nil {
+		connCache = make(map[string]*grpc.ClientConn)
+	}
+}
+
+func closeConnections() {
+	connCacheMu.Lock()
+	defer connCacheMu.Unlock()
+	for _, conn := range connCache {
+		conn.Close()
+	}
+	connCache = nil
+}
+
+// retryTransient calls fn until it succeeds, fails with an error a retry
+// can't fix, or retries run out, doubling the wait between attempts.
+// Unavailable covers quicd restarting and the host being unreachable, and
+// ResourceExhausted a full pool or branch quota, which a deletion in progress
+// may free. fn must be safe to repeat.
+func retryTransient(ctx context.Context, retries int, fn func() error) error {
+	backoff := retryInitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "%s, retrying in %v (%d/%d)\n", status.Convert(err).Message(), backoff, attempt+1, retries)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff = min(backoff*2, retryMaxBackoff)
+	}
+}
+
+func isTransient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
 func executeWithClient(fn func(pb.QuicServiceClient, context.Context) error) error {
 	cfg, err := config.LoadUserConfig()
 	if err != nil {
@@ -29,18 +97,60 @@ func executeWithClient(fn func(pb.QuicServiceClient, context.Context) error) err
 }
 
 func executeWithClientOnHost(host, authToken string, timeout time.Duration, fn func(pb.QuicServiceClient, context.Context) error) error {
+	conn, release, err := hostConnection(host)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	md := metadata.New(map[string]string{
+		"authorization": "Bearer " + authToken,
+	})
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	// Zero means no timeout, for commands that run until interrupted
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	client := pb.NewQuicServiceClient(conn)
+	return fn(client, ctx)
+}
+
+// hostConnection returns the cached connection to host, see
+// reuseConnections, or a new one. release closes it unless it's cached.
+func hostConnection(host string) (conn *grpc.ClientConn, release func(), err error) {
+	connCacheMu.Lock()
+	defer connCacheMu.Unlock()
+	if conn, ok := connCache[host]; ok {
+		return conn, func() {}, nil
+	}
+
+	conn, err = dialHost(host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if connCache == nil {
+		return conn, func() { conn.Close() }, nil
+	}
+	connCache[host] = conn
+	return conn, func() {}, nil
+}
+
+func dialHost(host string) (*grpc.ClientConn, error) {
 	projectConfig, err := config.LoadProjectConfig()
 	if err != nil {
-		return fmt.Errorf("failed to load project config: %w", err)
+		return nil, fmt.Errorf("failed to load project config: %w", err)
 	}
 
 	hostConfig := projectConfig.GetHostByIP(host)
 	if hostConfig == nil {
-		return fmt.Errorf("host %s not found in configuration", host)
+		return nil, fmt.Errorf("host %s not found in configuration", host)
 	}
 
 	if hostConfig.CertificateFingerprint == "" {
-		return fmt.Errorf("no certificate fingerprint configured for host %s. Please run 'quic host setup' first", host)
+		return nil, fmt.Errorf("no certificate fingerprint configured for host %s. Please run 'quic host setup' first", host)
 	}
 
 	tlsConfig := &tls.Config{
@@ -51,23 +161,13 @@ func executeWithClientOnHost(host, authToken string, timeout time.Duration, fn f
 	}
 
 	conn, err := grpc.Dial(
-		host+":8443",
+		net.JoinHostPort(host, "8443"),
 		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
 	)
 	if err != nil {
-		return fmt.Errorf("connecting to server %s: %w", host, err)
+		return nil, fmt.Errorf("connecting to server %s: %w", host, err)
 	}
-	defer conn.Close()
-
-	md := metadata.New(map[string]string{
-		"authorization": "Bearer " + authToken,
-	})
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	client := pb.NewQuicServiceClient(conn)
-	return fn(client, ctx)
+	return conn, nil
 }
 
 // verifyCertificateFingerprint compares certificate fingerprints.
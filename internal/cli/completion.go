@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+const (
+	completionTimeout  = 3 * time.Second
+	completionCacheTTL = 30 * time.Second
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish]",
+	Short: "Generate shell completion script",
+	Long: `Generate a shell completion script for quic.
+
+  bash: source <(quic completion bash)
+  zsh:  quic completion zsh > "${fpath[1]}/_quic"
+  fish: quic completion fish | source`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish"},
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return cmd.Root().GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return cmd.Root().GenZshCompletion(os.Stdout)
+		case "fish":
+			return cmd.Root().GenFishCompletion(os.Stdout, true)
+		default:
+			return fmt.Errorf("unsupported shell %q. Use bash, zsh or fish", args[0])
+		}
+	},
+}
+
+func init() {
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+}
+
+func completeTemplateNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	projectCfg, err := config.LoadProjectConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, template := range projectCfg.Templates {
+		if strings.HasPrefix(template.Name, toComplete) {
+			names = append(names, template.Name)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeBranchNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	branches, err := listBranchNamesCached(template.Name)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var names []string
+	for _, branch := range branches {
+		if strings.HasPrefix(branch, toComplete) {
+			names = append(names, branch)
+		}
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+type branchCompletionCache struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Branches  []string  `json:"branches"`
+}
+
+func listBranchNamesCached(templateName string) ([]string, error) {
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath, err := getCompletionCachePath(userCfg.SelectedHost, templateName)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(cachePath); err == nil {
+		var cache branchCompletionCache
+		if err := json.Unmarshal(data, &cache); err == nil && time.Since(cache.FetchedAt) < completionCacheTTL {
+			return cache.Branches, nil
+		}
+	}
+
+	var branches []string
+	err = executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, completionTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.ListCheckouts(ctx, &pb.ListCheckoutsRequest{RestoreName: templateName})
+		if err != nil {
+			return err
+		}
+		for _, checkout := range resp.Checkouts {
+			branches = append(branches, checkout.CloneName)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(branchCompletionCache{FetchedAt: time.Now(), Branches: branches})
+	if err == nil {
+		os.MkdirAll(filepath.Dir(cachePath), 0755)
+		os.WriteFile(cachePath, data, 0644)
+	}
+
+	return branches, nil
+}
+
+func getCompletionCachePath(host, templateName string) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	fileName := fmt.Sprintf("branches-%s-%s.json", host, templateName)
+	return filepath.Join(cacheDir, "quic", "completion", fileName), nil
+}
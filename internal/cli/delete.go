@@ -2,43 +2,289 @@ package cli
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/quickr-dev/quic/internal/config"
 	pb "github.com/quickr-dev/quic/proto"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete <branch-name>",
-	Short: "Delete a branch",
-	Args:  cobra.ExactArgs(1),
+	Use:   "delete <branch-name>...",
+	Short: "Delete branches",
+	Long: `Delete one or more branches.
+
+With several branches, --parallel deletes up to N at a time and a summary
+reports which deletions failed.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeBranchNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return executeDelete(args[0], cmd)
+		return executeDelete(args, cmd)
 	},
 }
 
 func init() {
 	deleteCmd.Flags().String("template", "", "Template from which to delete the branch")
+	deleteCmd.Flags().Bool("force", false, "Delete frozen branches")
+	deleteCmd.Flags().Bool("ignore-maintenance", false, "Delete even if the host maintenance policy blocks destructive operations")
+	deleteCmd.Flags().Bool("dry-run", false, "Show what would be removed without deleting anything")
+	deleteCmd.Flags().Bool("keep-data", false, "Remove the branch's service and firewall rule but keep its data")
+	deleteCmd.Flags().Int("parallel", 1, "Number of branches to delete at a time")
+	deleteCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+// deleteResult is the outcome of deleting one branch.
+type deleteResult struct {
+	branchName string
+	resp       *pb.DeleteCheckoutResponse
+	err        error
+}
+
+// found reports whether the branch existed on the host. Without data to plan
+// for, a dry run found nothing either.
+func (r deleteResult) found(dryRun bool) bool {
+	if dryRun {
+		plan := r.resp.Plan
+		return plan != nil && (plan.Dataset != "" || plan.Snapshot != "" || plan.ServiceName != "")
+	}
+	return r.resp.Deleted
 }
 
-func executeDelete(branchName string, cmd *cobra.Command) error {
+func executeDelete(branchNames []string, cmd *cobra.Command) error {
 	templateFlag, _ := cmd.Flags().GetString("template")
-	template, err := GetTemplate(templateFlag)
+	if templateFlag != "" {
+		if _, err := GetTemplate(templateFlag); err != nil {
+			return err
+		}
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	ignoreMaintenance, _ := cmd.Flags().GetBool("ignore-maintenance")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	keepData, _ := cmd.Flags().GetBool("keep-data")
+	if dryRun && keepData {
+		return fmt.Errorf("--dry-run and --keep-data can't be combined")
+	}
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	if parallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1, got %d", parallel)
+	}
+
+	userCfg, err := config.LoadUserConfig()
 	if err != nil {
-		return err
+		return fmt.Errorf("loading config: %w", err)
 	}
 
-	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
-		req := &pb.DeleteCheckoutRequest{
-			CloneName:   branchName,
-			RestoreName: template.Name,
+	// Deletions queue up behind the parallel ones, so give each its own share of time
+	timeout := DefaultTimeout * time.Duration((len(branchNames)+parallel-1)/parallel)
+
+	return executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, timeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		// Listed once rather than per branch, listing walks every branch on the host
+		var branchTemplates map[string][]string
+		if templateFlag == "" {
+			branchTemplates, err = listBranchTemplates(ctx, client)
+			if err != nil {
+				return err
+			}
 		}
 
-		_, err := client.DeleteCheckout(ctx, req)
-		if err != nil {
-			return err
+		deleteOne := func(branchName string) (*pb.DeleteCheckoutResponse, error) {
+			templateName, err := resolveDeleteTemplate(branchTemplates, templateFlag, branchName)
+			if err != nil {
+				return nil, err
+			}
+			return client.DeleteCheckout(ctx, &pb.DeleteCheckoutRequest{
+				CloneName:         branchName,
+				RestoreName:       templateName,
+				Force:             force,
+				IgnoreMaintenance: ignoreMaintenance,
+				DryRun:            dryRun,
+				KeepData:          keepData,
+			})
 		}
 
-		return nil
+		if len(branchNames) == 1 {
+			resp, err := deleteOne(branchNames[0])
+			if err != nil {
+				return err
+			}
+			printDeleteResult(branchNames[0], resp, dryRun, keepData)
+			return nil
+		}
+
+		// quicd serializes the host-wide steps of each deletion, firewall
+		// and systemd changes, so workers only overlap on ZFS work
+		results := make([]deleteResult, len(branchNames))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for range min(parallel, len(branchNames)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					resp, err := deleteOne(branchNames[i])
+					results[i] = deleteResult{branchName: branchNames[i], resp: resp, err: err}
+				}
+			}()
+		}
+		for i := range branchNames {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		return summarizeDeletes(results, dryRun, keepData)
 	})
 }
+
+// resolveDeleteTemplate picks the template of a branch to delete: the
+// --template flag, else the template holding the branch on the host, else
+// the default template. branchTemplates is from listBranchTemplates.
+func resolveDeleteTemplate(branchTemplates map[string][]string, templateFlag, branchName string) (string, error) {
+	if templateFlag != "" {
+		return templateFlag, nil
+	}
+
+	found, err := findBranchTemplate(branchTemplates, branchName)
+	if err != nil {
+		return "", err
+	}
+	if found != "" {
+		return found, nil
+	}
+
+	template, err := GetTemplate("")
+	if err != nil {
+		return "", err
+	}
+	return template.Name, nil
+}
+
+func printDeleteResult(branchName string, resp *pb.DeleteCheckoutResponse, dryRun, keepData bool) {
+	if dryRun {
+		printDeletePlan(branchName, resp.Plan)
+	}
+	if keepData && resp.Deleted {
+		fmt.Printf("Detached branch '%s'. Its data is kept, run `quic branch attach %s` to start it again\n", branchName, branchName)
+	}
+	if len(resp.DeletedReplicas) > 0 {
+		fmt.Printf("Deleted replicas of '%s': %s\n", branchName, strings.Join(resp.DeletedReplicas, ", "))
+	}
+}
+
+// summarizeDeletes prints the outcome of a bulk delete in the order the
+// branches were given, and fails if any deletion did. Branches that didn't
+// exist, or with --keep-data were already detached, are listed apart.
+func summarizeDeletes(results []deleteResult, dryRun, keepData bool) error {
+	var failed []deleteResult
+	var skipped []string
+	for _, result := range results {
+		if result.err != nil {
+			failed = append(failed, result)
+			continue
+		}
+		if dryRun {
+			printDeleteResult(result.branchName, result.resp, dryRun, keepData)
+			fmt.Println()
+		}
+		if !result.found(dryRun) {
+			skipped = append(skipped, result.branchName)
+		}
+	}
+
+	verb := "Deleted"
+	skippedAs := "Not found"
+	switch {
+	case dryRun:
+		verb = "Planned deletion of"
+	case keepData:
+		verb = "Detached"
+		skippedAs = "Already detached"
+	}
+	fmt.Printf("%s %d of %d branches\n", verb, len(results)-len(failed)-len(skipped), len(results))
+	if len(skipped) > 0 {
+		fmt.Printf("%s: %s\n", skippedAs, strings.Join(skipped, ", "))
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+	fmt.Println("\nFailed:")
+	for _, result := range failed {
+		fmt.Printf("  %s: %v\n", result.branchName, result.err)
+	}
+	return fmt.Errorf("%d of %d branches failed", len(failed), len(results))
+}
+
+// listBranchTemplates maps the name of each branch on the host to the
+// templates holding a branch of that name.
+func listBranchTemplates(ctx context.Context, client pb.QuicServiceClient) (map[string][]string, error) {
+	resp, err := client.ListCheckouts(ctx, &pb.ListCheckoutsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("listing branches: %w", err)
+	}
+
+	branchTemplates := make(map[string][]string)
+	for _, checkout := range resp.Checkouts {
+		branchTemplates[checkout.CloneName] = append(branchTemplates[checkout.CloneName], checkout.RestoreName)
+	}
+	return branchTemplates, nil
+}
+
+// findBranchTemplate returns the template holding branchName on the host, or
+// "" if there's none. A name used under several templates is an error rather
+// than a guess, since deleting the wrong branch can't be undone.
+func findBranchTemplate(branchTemplates map[string][]string, branchName string) (string, error) {
+	matches := branchTemplates[branchName]
+	switch len(matches) {
+	case 0:
+		return "", nil
+	case 1:
+		return matches[0], nil
+	}
+
+	pairs := make([]string, len(matches))
+	for i, template := range matches {
+		pairs[i] = template + "/" + branchName
+	}
+	return "", fmt.Errorf("branch '%s' exists in several templates: %s. Use --template to pick one", branchName, strings.Join(pairs, ", "))
+}
+
+func printDeletePlan(branchName string, plan *pb.DeletePlan) {
+	if plan == nil || (plan.Dataset == "" && plan.Snapshot == "" && plan.ServiceName == "" && plan.Mountpoint == "") {
+		fmt.Printf("Nothing to delete for branch '%s'\n", branchName)
+		return
+	}
+
+	orNone := func(value string) string {
+		if value == "" {
+			return "-"
+		}
+		return value
+	}
+
+	fmt.Printf("Deleting branch '%s' would remove:\n", branchName)
+	fmt.Printf("  %-12s %s\n", "Dataset:", orNone(plan.Dataset))
+	fmt.Printf("  %-12s %s\n", "Snapshot:", orNone(plan.Snapshot))
+	fmt.Printf("  %-12s %s\n", "Service:", orNone(plan.ServiceName))
+	fmt.Printf("  %-12s %s\n", "Port:", orNone(plan.Port))
+	fmt.Printf("  %-12s %s\n", "Mountpoint:", orNone(plan.Mountpoint))
+
+	if plan.Promoted {
+		fmt.Println("\nThe branch is promoted; the template will take back its snapshot first.")
+	}
+
+	if len(plan.Replicas) > 0 {
+		fmt.Printf("\nReplicas that would also be deleted:\n  %s\n", strings.Join(plan.Replicas, "\n  "))
+	}
+
+	if len(plan.DependentClones) > 0 {
+		fmt.Printf("\nDependent clones that would also be destroyed:\n  %s\n", strings.Join(plan.DependentClones, "\n  "))
+	}
+
+	fmt.Println("\nDry run: nothing was deleted.")
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream branch and template lifecycle events from a host",
+	Long: `Stream branch and template lifecycle events from a host as they happen,
+until interrupted with Ctrl-C.
+
+Events are only delivered while connected. If this client falls behind,
+the host drops events and reports how many were missed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeEvents(cmd)
+	},
+}
+
+func init() {
+	eventsCmd.Flags().String("host", "", "Host IP to stream events from (default: selected host)")
+}
+
+func executeEvents(cmd *cobra.Command) error {
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	host, _ := cmd.Flags().GetString("host")
+	if host == "" {
+		host = userCfg.SelectedHost
+	}
+
+	return executeWithClientOnHost(host, userCfg.AuthToken, 0, func(client pb.QuicServiceClient, ctx context.Context) error {
+		stream, err := client.StreamEvents(ctx, &pb.StreamEventsRequest{})
+		if err != nil {
+			return fmt.Errorf("streaming events: %w", err)
+		}
+
+		for {
+			event, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("receiving events: %w", err)
+			}
+
+			if event.Dropped > 0 {
+				fmt.Printf("(%d event(s) dropped)\n", event.Dropped)
+			}
+			fmt.Println(formatEvent(event))
+		}
+	})
+}
+
+func formatEvent(event *pb.LifecycleEvent) string {
+	target := event.Template
+	if event.Branch != "" {
+		target = fmt.Sprintf("%s/%s", event.Template, event.Branch)
+	}
+
+	line := fmt.Sprintf("%-20s %-15s %-30s %s", event.Timestamp, event.Event, target, event.User)
+	if event.Port != "" {
+		line += fmt.Sprintf(" (%s:%s)", event.Host, event.Port)
+	}
+	return line
+}
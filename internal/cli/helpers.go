@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/quickr-dev/quic/internal/config"
 )
@@ -45,3 +47,20 @@ func GetTemplate(templateFlag string) (*config.Template, error) {
 
 	return nil, fmt.Errorf("template '%s' not found in project config", templateName)
 }
+
+func splitCommaList(value string) []string {
+	var items []string
+	for item := range strings.SplitSeq(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
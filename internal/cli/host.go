@@ -10,6 +10,13 @@ var hostCmd = &cobra.Command{
 }
 
 func init() {
+	hostCmd.AddCommand(hostAuditCmd)
+	hostCmd.AddCommand(hostCancelCmd)
+	hostCmd.AddCommand(hostExpandCmd)
+	hostCmd.AddCommand(hostFingerprintCmd)
 	hostCmd.AddCommand(hostNewCmd)
+	hostCmd.AddCommand(hostOpsCmd)
 	hostCmd.AddCommand(hostSetupCmd)
+	hostCmd.AddCommand(hostUpgradeCmd)
+	hostCmd.AddCommand(hostVerifyCmd)
 }
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var hostAuditCmd = &cobra.Command{
+	Use:   "audit <ip>",
+	Short: "List a host's audit log entries",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeHostAudit(args[0], cmd)
+	},
+}
+
+func init() {
+	hostAuditCmd.Flags().String("since", "1h", "Only show entries from this long ago onward (e.g. 30m, 1h, 7d)")
+}
+
+// auditEntryView is an audit log entry as printed with --output json or yaml.
+type auditEntryView struct {
+	Timestamp string `json:"timestamp" yaml:"timestamp"`
+	EventType string `json:"event_type" yaml:"event_type"`
+	Details   any    `json:"details" yaml:"details"`
+}
+
+func executeHostAudit(host string, cmd *cobra.Command) error {
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	age, err := parseAge(sinceFlag)
+	if err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	return executeWithClientOnHost(host, userCfg.AuthToken, DefaultTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.ListAuditEntries(ctx, &pb.ListAuditEntriesRequest{
+			Since: time.Now().Add(-age).UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return fmt.Errorf("listing audit entries: %w", err)
+		}
+
+		views := make([]auditEntryView, 0, len(resp.Entries))
+		for _, entry := range resp.Entries {
+			view := auditEntryView{Timestamp: entry.Timestamp, EventType: entry.EventType}
+			if err := json.Unmarshal([]byte(entry.Details), &view.Details); err != nil {
+				return fmt.Errorf("decoding audit details: %w", err)
+			}
+			views = append(views, view)
+		}
+
+		return renderOutput(format, views, func() error {
+			if len(resp.Entries) == 0 {
+				fmt.Printf("No audit entries in the last %s.\n", sinceFlag)
+				return nil
+			}
+			fmt.Printf("%-22s %-24s %s\n", "TIME", "EVENT", "DETAILS")
+			for _, entry := range resp.Entries {
+				fmt.Printf("%-22s %-24s %s\n", entry.Timestamp, entry.EventType, entry.Details)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var hostCancelCmd = &cobra.Command{
+	Use:   "cancel <op-id>",
+	Short: "Cancel a running template restore or branch creation",
+	Long: `Cancel a running template restore or branch creation listed by 'quic host ops'.
+
+Partially created branches and templates are removed. You can cancel your own
+operations; cancelling someone else's requires being listed in "admins" in the
+host's /etc/quic/quicd.json.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeHostCancel(args[0], cmd)
+	},
+}
+
+func init() {
+	hostCancelCmd.Flags().String("host", "", "Host IP running the operation (default: selected host)")
+}
+
+func executeHostCancel(opID string, cmd *cobra.Command) error {
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	host, _ := cmd.Flags().GetString("host")
+	if host == "" {
+		host = userCfg.SelectedHost
+	}
+
+	return executeWithClientOnHost(host, userCfg.AuthToken, DefaultTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		if _, err := client.CancelOperation(ctx, &pb.CancelOperationRequest{Id: opID}); err != nil {
+			return fmt.Errorf("cancelling operation: %w", err)
+		}
+
+		fmt.Printf("Operation %s cancelled\n", opID)
+		return nil
+	})
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/quickr-dev/quic/internal/config"
+	"github.com/quickr-dev/quic/internal/ssh"
+	"github.com/quickr-dev/quic/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var hostExpandCmd = &cobra.Command{
+	Use:   "expand <ip>",
+	Short: "[admin] Add devices to an existing host's ZFS pool",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runHostExpand,
+}
+
+func init() {
+	hostExpandCmd.Flags().String("devices", "", "Comma-separated list of device paths to add (e.g., /dev/nvme1n1,/dev/nvme2n1)")
+}
+
+func runHostExpand(cmd *cobra.Command, args []string) error {
+	ip := args[0]
+
+	quicConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load quic config: %w", err)
+	}
+
+	host := quicConfig.GetHostByIP(ip)
+	if host == nil {
+		return fmt.Errorf("host %s not found in quic.json. Add it with: quic host new %s", ip, ip)
+	}
+	resolved := quicConfig.ResolveHost(*host)
+	host = &resolved
+
+	client, err := ssh.NewClient(host.IP, host.SSHJump)
+	if err != nil {
+		return fmt.Errorf("failed to connect to host %s: %w", host.IP, err)
+	}
+
+	if err := client.VerifyRootAccess(); err != nil {
+		return fmt.Errorf("root access verification failed: %w", err)
+	}
+
+	pool, err := hostPool(client)
+	if err != nil {
+		return err
+	}
+	if _, err := client.RunCommand("sudo zpool list -H " + pool); err != nil {
+		return fmt.Errorf("ZFS pool '%s' not found on %s. Run 'quic host setup' first", pool, host.IP)
+	}
+
+	devices, err := client.ListBlockDevices()
+	if err != nil {
+		return fmt.Errorf("failed to discover block devices: %w", err)
+	}
+
+	devicesFlag, _ := cmd.Flags().GetString("devices")
+	var selectedDevices []string
+
+	if devicesFlag != "" {
+		for device := range strings.SplitSeq(devicesFlag, ",") {
+			device = strings.TrimSpace(device)
+			if err := client.TestPath(device); err != nil {
+				return fmt.Errorf("device path '%s' not found or not accessible: %w", device, err)
+			}
+			selectedDevices = append(selectedDevices, device)
+		}
+	} else {
+		if len(client.GetAvailableDevices(devices)) == 0 {
+			fmt.Println("\nNo available devices. Please, unmount or add storage devices.")
+			fmt.Println("\nDiscovered devices:")
+			printDeviceTable(devices)
+			return nil
+		}
+
+		selectedDevices, err = ui.RunDeviceSelector(devices)
+		if err != nil {
+			return fmt.Errorf("device selection failed: %w", err)
+		}
+
+		if len(selectedDevices) == 0 {
+			fmt.Println("No devices selected. Exiting.")
+			return nil
+		}
+	}
+
+	for _, device := range selectedDevices {
+		if err := validateExpandDevice(host, devices, device); err != nil {
+			return err
+		}
+	}
+
+	if !confirmPoolExpand(host, pool, selectedDevices) {
+		fmt.Println("Expand aborted.")
+		return nil
+	}
+
+	output, err := client.RunArgs(append([]string{"sudo", "zpool", "add", pool}, selectedDevices...)...)
+	if err != nil {
+		return fmt.Errorf("zpool add failed: %w\n%s", err, output)
+	}
+
+	if err := quicConfig.AddHostDevices(host.IP, selectedDevices); err != nil {
+		return fmt.Errorf("failed to update quic.json: %w", err)
+	}
+
+	fmt.Printf("Added %s to pool '%s' on %s (%s)\n", strings.Join(selectedDevices, ", "), pool, host.Alias, host.IP)
+	return nil
+}
+
+// validateExpandDevice checks a device isn't part of the pool already and,
+// when lsblk knows it, is neither mounted nor a system disk.
+func validateExpandDevice(host *config.QuicHost, devices []ssh.BlockDevice, device string) error {
+	name := filepath.Base(device)
+	if slices.ContainsFunc(host.Devices, func(d string) bool { return filepath.Base(d) == name }) {
+		return fmt.Errorf("device '%s' is already part of the pool", device)
+	}
+
+	for _, d := range devices {
+		if d.Name == name && d.Status != ssh.Available {
+			return fmt.Errorf("device '%s' is not available: %s", device, d.Reason)
+		}
+	}
+
+	return nil
+}
+
+func confirmPoolExpand(host *config.QuicHost, pool string, devices []string) bool {
+	fmt.Printf("WARNING: This will add %s to pool '%s' on %s. Devices can't be removed from the pool afterwards and their data will be lost.\n", strings.Join(devices, ", "), pool, host.IP)
+	fmt.Print("Type 'ack' to proceed: ")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Scan()
+	return scanner.Text() == "ack"
+}
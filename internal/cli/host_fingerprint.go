@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/quickr-dev/quic/internal/config"
+	"github.com/quickr-dev/quic/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+var hostFingerprintCmd = &cobra.Command{
+	Use:   "fingerprint <host>",
+	Short: "[admin] Fetch a host's TLS certificate fingerprint into quic.json",
+	Long: `Reads the fingerprint of quicd's TLS certificate over SSH and stores it in
+quic.json, which the CLI needs to connect to the host.
+
+'quic host setup' does this as its last step. Run it on its own when that step
+failed, e.g. because SSH wasn't back yet after a reboot, or after the host's
+certificate was regenerated. Pass the host's alias or IP.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHostFingerprint,
+}
+
+// Delays between attempts to fetch a fingerprint, for hosts whose SSH isn't
+// back yet after setup rebooted them
+var fingerprintRetryDelays = []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second}
+
+func runHostFingerprint(cmd *cobra.Command, args []string) error {
+	quicConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load quic config: %w", err)
+	}
+
+	hosts, err := filterHosts(cmd, quicConfig, args[0])
+	if err != nil {
+		return err
+	}
+	if hosts == nil {
+		return fmt.Errorf("host '%s' not found in quic.json", args[0])
+	}
+	host := hosts[0]
+
+	previous := host.CertificateFingerprint
+	fingerprint, err := retrieveAndStoreCertificateFingerprint(quicConfig, host)
+	if err != nil {
+		return err
+	}
+
+	switch previous {
+	case fingerprint:
+		fmt.Printf("Certificate fingerprint of %s is unchanged: %s\n", host.IP, fingerprint)
+	case "":
+		fmt.Printf("✓ Stored certificate fingerprint of %s: %s\n", host.IP, fingerprint)
+	default:
+		fmt.Printf("✓ Certificate fingerprint of %s changed from %s to %s\n", host.IP, previous, fingerprint)
+	}
+	return nil
+}
+
+// retrieveAndStoreCertificateFingerprint fetches the host's certificate
+// fingerprint, retrying with backoff, and saves it to quic.json.
+func retrieveAndStoreCertificateFingerprint(projectConfig *config.ProjectConfig, host config.QuicHost) (string, error) {
+	var fingerprint string
+	var err error
+	for attempt := 0; ; attempt++ {
+		fingerprint, err = fetchCertificateFingerprint(host)
+		if err == nil || attempt == len(fingerprintRetryDelays) {
+			break
+		}
+		delay := fingerprintRetryDelays[attempt]
+		fmt.Fprintf(os.Stderr, "Fetching certificate fingerprint of %s failed, retrying in %s: %v\n", host.IP, delay, err)
+		time.Sleep(delay)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// update the host certificate fingerprint
+	if err := projectConfig.SetHostCertificateFingerprint(host.IP, fingerprint); err != nil {
+		return "", fmt.Errorf("failed to save updated configuration: %w", err)
+	}
+
+	return fingerprint, nil
+}
+
+func fetchCertificateFingerprint(host config.QuicHost) (string, error) {
+	client, err := ssh.NewClient(host.IP, host.SSHJump)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect via SSH: %w", err)
+	}
+
+	// Extract certificate fingerprint using OpenSSL
+	fingerprintCmd := "openssl x509 -in /etc/quic/certs/server.crt -noout -fingerprint -sha256 | cut -d'=' -f2"
+	output, err := client.RunCommand(fingerprintCmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract certificate fingerprint: %w", err)
+	}
+
+	fingerprint := strings.TrimSpace(string(output))
+	if fingerprint == "" {
+		return "", fmt.Errorf("certificate fingerprint is empty")
+	}
+
+	return fingerprint, nil
+}
@@ -2,6 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/quickr-dev/quic/internal/config"
@@ -20,16 +24,37 @@ var hostNewCmd = &cobra.Command{
 func init() {
 	hostNewCmd.Flags().String("devices", "", "Comma-separated list of device paths (e.g., /dev/nvme0n1,/path/to/disk)")
 	hostNewCmd.Flags().String("alias", "default", "Host alias. Makes it easier to specify hosts in other commands (default: 'default')")
+	hostNewCmd.Flags().String("ssh-jump", "", "SSH bastion to reach the host through (e.g., user@bastion:22)")
+	hostNewCmd.Flags().Bool("update", false, "Update the devices and alias of a host already in quic.json instead of failing")
+}
+
+// hostNewJSON is the result of host new with --output json or yaml, the host
+// as saved in quic.json.
+type hostNewJSON struct {
+	// added or updated
+	Action string          `json:"action" yaml:"action"`
+	Host   config.QuicHost `json:"host" yaml:"host"`
 }
 
 func runHostNew(cmd *cobra.Command, args []string) error {
-	ip := args[0]
+	sshJump, _ := cmd.Flags().GetString("ssh-jump")
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	// The interactive device selector needs a person at the terminal
+	if devices, _ := cmd.Flags().GetString("devices"); format != "table" && devices == "" {
+		return fmt.Errorf("--output %s needs --devices", format)
+	}
 
-	if ip == "" {
-		return fmt.Errorf("host IP cannot be empty")
+	// Hosts behind a bastion may only resolve from the bastion itself
+	ip, err := normalizeHostAddress(args[0], sshJump == "")
+	if err != nil {
+		return err
 	}
 
-	client, err := ssh.NewClient(ip)
+	client, err := ssh.NewClient(ip, sshJump)
 	if err != nil {
 		return fmt.Errorf("failed to connect to host %s: %w\n\nTroubleshooting:\n• Ensure the host is reachable\n• Verify SSH is running on port 22\n• Check SSH agent is running: ssh-add -l\n• Verify root access: ssh root@%s", ip, err, ip)
 	}
@@ -90,15 +115,42 @@ func runHostNew(cmd *cobra.Command, args []string) error {
 	}
 
 	aliasFlag, _ := cmd.Flags().GetString("alias")
+	update, _ := cmd.Flags().GetBool("update")
 
 	host := config.QuicHost{
 		IP:               ip,
 		Alias:            aliasFlag,
 		EncryptionAtRest: "localFile",
 		Devices:          selectedDevices,
+		SSHJump:          sshJump,
 	}
 
-	if err := quicConfig.AddHost(host); err != nil {
+	existing := quicConfig.GetHostByIP(ip)
+	if update && existing != nil {
+		// Devices already in the pool show up as in use, only new ones must be free
+		for _, device := range selectedDevices {
+			if slices.Contains(existing.Devices, device) {
+				continue
+			}
+			if err := validateExpandDevice(existing, devices, device); err != nil {
+				return err
+			}
+		}
+
+		if !cmd.Flags().Changed("alias") {
+			host.Alias = existing.Alias
+		}
+		if !cmd.Flags().Changed("ssh-jump") {
+			host.SSHJump = existing.SSHJump
+		}
+
+		if err := quicConfig.UpdateHost(host); err != nil {
+			return fmt.Errorf("failed to update host: %w", err)
+		}
+	} else if err := quicConfig.AddHost(host); err != nil {
+		if existing != nil {
+			return fmt.Errorf("failed to add host: %w. Pass --update to change its devices or alias", err)
+		}
 		return fmt.Errorf("failed to add host: %w", err)
 	}
 
@@ -112,11 +164,72 @@ func runHostNew(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to set selected host: %w", err)
 	}
 
+	if format != "table" {
+		result := hostNewJSON{Action: "added", Host: host}
+		if update && existing != nil {
+			result.Action = "updated"
+			if saved := quicConfig.GetHostByIP(ip); saved != nil {
+				result.Host = *saved
+			}
+		}
+		return renderOutput(format, result, nil)
+	}
+
+	if update && existing != nil {
+		fmt.Printf("Updated host '%s' (%s) in quic.json and set as selected host\n", host.Alias, ip)
+		return nil
+	}
+
 	fmt.Printf("Added host '%s' (%s) to quic.json and set as selected host\n", host.Alias, ip)
 
 	return nil
 }
 
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// normalizeHostAddress accepts an IP or a resolvable hostname, optionally
+// given as a URL, and returns the bare address. Ports are rejected because
+// quic always uses 22 for SSH and 8443 for quicd. Hostnames are only looked
+// up when resolve is set.
+func normalizeHostAddress(input string, resolve bool) (string, error) {
+	address := strings.TrimSpace(input)
+	if address == "" {
+		return "", fmt.Errorf("host address cannot be empty")
+	}
+
+	if strings.Contains(address, "://") {
+		u, err := url.Parse(address)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid host URL '%s'. Pass an IP or hostname, e.g. 10.0.0.5", input)
+		}
+		if u.Path != "" && u.Path != "/" {
+			return "", fmt.Errorf("host '%s' must not include a path", input)
+		}
+		address = u.Host
+	}
+
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		return "", fmt.Errorf("host '%s' must not include a port (:%s). quic connects on 22 for SSH and 8443 for quicd, pass just %s", input, port, host)
+	}
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+
+	if net.ParseIP(address) != nil {
+		return address, nil
+	}
+
+	if !hostnamePattern.MatchString(address) {
+		return "", fmt.Errorf("'%s' is not a valid IP address or hostname", input)
+	}
+	if !resolve {
+		return address, nil
+	}
+	if _, err := net.LookupHost(address); err != nil {
+		return "", fmt.Errorf("cannot resolve host '%s': %w", address, err)
+	}
+
+	return address, nil
+}
+
 func printDeviceTable(devices []ssh.BlockDevice) {
 	fmt.Printf("  %-20s %-10s %-10s %-15s\n", "NAME", "SIZE", "USED", "STATUS")
 	for _, device := range devices {
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var hostOpsCmd = &cobra.Command{
+	Use:   "ops <ip>",
+	Short: "List operations a host is running or has queued",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeHostOps(args[0])
+	},
+}
+
+func executeHostOps(host string) error {
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	return executeWithClientOnHost(host, userCfg.AuthToken, DefaultTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.ListOperations(ctx, &pb.ListOperationsRequest{})
+		if err != nil {
+			return fmt.Errorf("listing operations: %w", err)
+		}
+
+		if len(resp.Operations) == 0 {
+			fmt.Println("No operations in progress.")
+			return nil
+		}
+
+		fmt.Printf("%-6s %-18s %-30s %-15s %-10s %s\n", "ID", "TYPE", "TARGET", "USER", "ELAPSED", "STATE")
+		for _, op := range resp.Operations {
+			state := "running"
+			if op.Queued {
+				state = "queued"
+			}
+			elapsed := time.Duration(op.ElapsedSeconds) * time.Second
+			fmt.Printf("%-6s %-18s %-30s %-15s %-10s %s\n", op.Id, op.Type, op.Target, op.User, elapsed, state)
+		}
+
+		return nil
+	})
+}
@@ -2,12 +2,18 @@ package cli
 
 import (
 	"bufio"
+	"bytes"
 	_ "embed"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/quickr-dev/quic/internal/config"
@@ -28,7 +34,69 @@ var hostSetupCmd = &cobra.Command{
 }
 
 func init() {
-	hostSetupCmd.Flags().String("hosts", "", "Comma-separated list of host aliases, IPs, or 'all'")
+	hostSetupCmd.Flags().String("hosts", "", "Comma-separated list of host aliases, IPs, @groups, or 'all'")
+	hostSetupCmd.Flags().Bool("verify", false, "Check each host is ready after setup, like 'quic host verify'")
+	hostSetupCmd.Flags().String("pg-version", "16", "PostgreSQL to install: a major version like 17, or a minor version like 17.2 to pin it")
+	hostSetupCmd.Flags().String("pg-repo", "default", "Where PostgreSQL packages come from: default (the distribution) or pgdg (apt.postgresql.org)")
+	hostSetupCmd.Flags().String("mountpoint-root", "", "Directory quicd may mount branches under with checkout --mountpoint, e.g. /srv (default: custom mountpoints are refused)")
+	hostSetupCmd.RegisterFlagCompletionFunc("pg-repo", cobra.FixedCompletions([]string{"default", "pgdg"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+var pgInstallVersionPattern = regexp.MustCompile(`^(\d+)(\.\d+)?$`)
+
+// postgresInstall selects the PostgreSQL packages the playbook installs.
+type postgresInstall struct {
+	// Major version, e.g. 17
+	Major string
+	// Minor version to pin, e.g. 17.2, empty for the repository's latest
+	Minor string
+	// default or pgdg
+	Repo string
+}
+
+// Oldest major version with standby.signal and pg_checksums --check
+const minPgInstallVersion = 12
+
+func parsePostgresInstall(version, repo string) (postgresInstall, error) {
+	match := pgInstallVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return postgresInstall{}, fmt.Errorf("invalid --pg-version '%s'. Use a major version like 17 or a minor version like 17.2", version)
+	}
+	if major, _ := strconv.Atoi(match[1]); major < minPgInstallVersion {
+		return postgresInstall{}, fmt.Errorf("--pg-version %s is too old, quic needs PostgreSQL %d or later", version, minPgInstallVersion)
+	}
+	if repo != "default" && repo != "pgdg" {
+		return postgresInstall{}, fmt.Errorf("invalid --pg-repo '%s'. Use default or pgdg", repo)
+	}
+
+	install := postgresInstall{Major: match[1], Repo: repo}
+	if match[2] != "" {
+		install.Minor = version
+	}
+	return install, nil
+}
+
+func (p postgresInstall) extraVars() string {
+	return fmt.Sprintf("pg_version=%s pg_package_version=%s pg_repo=%s", p.Major, p.Minor, p.Repo)
+}
+
+// The root ends up in sudoers rules and extra vars, so keep it to plain path
+// characters
+var mountpointRootPattern = regexp.MustCompile(`^/[A-Za-z0-9_./-]+$`)
+
+// validateMountpointRoot checks --mountpoint-root. Empty leaves custom
+// mountpoints off.
+func validateMountpointRoot(root string) error {
+	if root == "" {
+		return nil
+	}
+	if !mountpointRootPattern.MatchString(root) || filepath.Clean(root) != root || root == "/" {
+		return fmt.Errorf("invalid --mountpoint-root '%s'. Use a clean absolute path other than /, like /srv", root)
+	}
+	if root == "/opt/quic" || strings.HasPrefix(root, "/opt/quic/") {
+		return fmt.Errorf("--mountpoint-root %s is inside /opt/quic, where quic places templates and branches", root)
+	}
+	return nil
 }
 
 func runHostSetup(cmd *cobra.Command, args []string) error {
@@ -50,6 +118,28 @@ func runHostSetup(cmd *cobra.Command, args []string) error {
 	}
 
 	hostsFlag, _ := cmd.Flags().GetString("hosts")
+	verify, _ := cmd.Flags().GetBool("verify")
+
+	pgVersion, _ := cmd.Flags().GetString("pg-version")
+	pgRepo, _ := cmd.Flags().GetString("pg-repo")
+	postgres, err := parsePostgresInstall(pgVersion, pgRepo)
+	if err != nil {
+		return err
+	}
+	mountpointRoot, _ := cmd.Flags().GetString("mountpoint-root")
+	if err := validateMountpointRoot(mountpointRoot); err != nil {
+		return err
+	}
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	// Progress stays readable while stdout only gets the per-host results
+	out := io.Writer(os.Stdout)
+	if format != "table" {
+		out = os.Stderr
+	}
 
 	if len(quicConfig.Hosts) > 1 && hostsFlag == "" {
 		cmd.PrintErrln("For safety, please specify the hosts to setup, for example:")
@@ -59,7 +149,7 @@ func runHostSetup(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	targetHosts, err := filterHosts(cmd, quicConfig.Hosts, hostsFlag)
+	targetHosts, err := filterHosts(cmd, quicConfig, hostsFlag)
 	if err != nil {
 		return err
 	}
@@ -69,35 +159,88 @@ func runHostSetup(cmd *cobra.Command, args []string) error {
 
 	hostUsernames := make(map[string]string)
 	for _, host := range targetHosts {
-		client, err := ssh.NewClient(host.IP)
+		client, err := ssh.NewClient(host.IP, host.SSHJump)
 		if err != nil {
 			return fmt.Errorf("failed to connect to host %s: %w", host.IP, err)
 		}
 		hostUsernames[host.IP] = client.Username()
 	}
 
-	if !confirmDestructiveSetup() {
-		fmt.Println("Setup aborted.")
+	if !confirmDestructiveSetup(out) {
+		fmt.Fprintln(out, "Setup aborted.")
 		return nil
 	}
 
+	// Several hosts only go to their log files, so the terminal shows
+	// progress. Ansible's output would mix with the JSON or YAML.
+	stream := len(targetHosts) == 1 && format == "table"
+
 	successCount := 0
+	var missingFingerprints []config.QuicHost
+	summaries := make([]string, 0, len(targetHosts))
+	results := make([]hostSetupResult, 0, len(targetHosts))
 	for _, host := range targetHosts {
-		fmt.Printf("\nSetting up host %s (%s)...\n", host.IP, host.Alias)
+		fmt.Fprintf(out, "\nSetting up host %s (%s)...\n", host.IP, host.Alias)
 		username := hostUsernames[host.IP]
-		if err := setupHost(host, username); err != nil {
-			fmt.Printf("Host %s setup failed: %v\n", host.IP, err)
+		recap, logPath, err := setupHost(host, username, postgres, mountpointRoot, stream)
+		if logPath != "" && !stream {
+			fmt.Fprintf(out, "Logging to %s\n", logPath)
+		}
+		result := hostSetupResult{IP: host.IP, Alias: host.Alias, Recap: recap, Log: logPath}
+		if err != nil {
+			fmt.Fprintf(out, "Host %s setup failed: %v\n", host.IP, err)
+			summary := fmt.Sprintf("  %s (%s): failed, %s", host.Alias, host.IP, recap.describe())
+			if logPath != "" {
+				summary += fmt.Sprintf(", see %s", logPath)
+			}
+			summaries = append(summaries, summary)
+			result.Status, result.Error = hostSetupFailed, err.Error()
+			results = append(results, result)
+			continue
+		}
+		// The host itself is set up, only the CLI can't connect to it yet
+		if _, err := retrieveAndStoreCertificateFingerprint(quicConfig, host); err != nil {
+			fmt.Fprintf(out, "Warning: Failed to retrieve certificate fingerprint for %s: %v\n", host.IP, err)
+			summaries = append(summaries, fmt.Sprintf("  %s (%s): %s, certificate fingerprint missing", host.Alias, host.IP, recap.describe()))
+			missingFingerprints = append(missingFingerprints, host)
+			result.Status, result.Error = hostSetupMissingFingerprint, err.Error()
+			results = append(results, result)
 			continue
 		}
-		if err := retrieveAndStoreCertificateFingerprint(quicConfig, host); err != nil {
-			fmt.Printf("Warning: Failed to retrieve certificate fingerprint for %s: %v\n", host.IP, err)
+		if verify && !verifyHost(out, host) {
+			summaries = append(summaries, fmt.Sprintf("  %s (%s): %s, failed verification", host.Alias, host.IP, recap.describe()))
+			result.Status = hostSetupFailedVerification
+			results = append(results, result)
 			continue
 		}
+		summaries = append(summaries, fmt.Sprintf("  %s (%s): %s", host.Alias, host.IP, recap.describe()))
 		successCount++
+		result.Status = hostSetupOK
+		results = append(results, result)
 	}
 
-	failedCount := len(targetHosts) - successCount
-	fmt.Printf("\nSetup completed: %d successful, %d failed\n", successCount, failedCount)
+	if format != "table" {
+		return renderOutput(format, map[string][]hostSetupResult{"hosts": results}, nil)
+	}
+
+	fmt.Println("\nSummary:")
+	for _, summary := range summaries {
+		fmt.Println(summary)
+	}
+
+	failedCount := len(targetHosts) - successCount - len(missingFingerprints)
+	fmt.Printf("\nSetup completed: %d successful, %d failed", successCount, failedCount)
+	if len(missingFingerprints) > 0 {
+		fmt.Printf(", %d missing a certificate fingerprint", len(missingFingerprints))
+	}
+	fmt.Println()
+
+	if len(missingFingerprints) > 0 {
+		fmt.Println("\nThe CLI can't connect to these hosts until their certificate fingerprint is stored. To finish, run:")
+		for _, host := range missingFingerprints {
+			fmt.Printf("  $ quic host fingerprint %s\n", host.IP)
+		}
+	}
 	return nil
 }
 
@@ -112,46 +255,160 @@ func checkAnsibleInstalled() error {
 	return nil
 }
 
-func confirmDestructiveSetup() bool {
-	fmt.Println("WARNING: This will format devices and permanently delete all of their data.")
-	fmt.Print("Type 'ack' to proceed: ")
+func confirmDestructiveSetup(out io.Writer) bool {
+	fmt.Fprintln(out, "WARNING: This will format devices and permanently delete all of their data.")
+	fmt.Fprint(out, "Type 'ack' to proceed: ")
 
 	scanner := bufio.NewScanner(os.Stdin)
 	scanner.Scan()
 	return scanner.Text() == "ack"
 }
 
-func setupHost(host config.QuicHost, username string) error {
+// setupHost runs the playbook against host, writing its output to a per-host
+// log file and, when stream is set, to the terminal. It returns the host's line
+// from the play recap when ansible printed one, and the log file path.
+func setupHost(host config.QuicHost, username string, postgres postgresInstall, mountpointRoot string, stream bool) (*ansibleRecap, string, error) {
 	playbookFile, err := writePlaybookToTemp()
 	if err != nil {
-		return fmt.Errorf("failed to write playbook: %w", err)
+		return nil, "", fmt.Errorf("failed to write playbook: %w", err)
 	}
 	defer os.Remove(playbookFile)
 
 	configFile, err := writeAnsibleConfigToTemp()
 	if err != nil {
-		return fmt.Errorf("failed to write ansible config: %w", err)
+		return nil, "", fmt.Errorf("failed to write ansible config: %w", err)
 	}
 	defer os.Remove(configFile)
 
 	inventoryFile, err := createInventoryFile(host, username)
 	if err != nil {
-		return fmt.Errorf("failed to create inventory: %w", err)
+		return nil, "", fmt.Errorf("failed to create inventory: %w", err)
 	}
 	defer os.Remove(inventoryFile)
 
-	extraVars := fmt.Sprintf("zfs_devices=%s pg_version=16", strings.Join(host.Devices, ","))
+	logFile, err := createSetupLogFile(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create setup log: %w", err)
+	}
+	defer logFile.Close()
+
+	extraVars := fmt.Sprintf("zfs_devices=%s %s mountpoint_root=%s", strings.Join(host.Devices, ","), postgres.extraVars(), mountpointRoot)
 
 	cmd := exec.Command("ansible-playbook",
 		"-i", inventoryFile,
 		"--extra-vars", extraVars,
 		playbookFile)
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Capture the output for the recap and the log, keeping it live when streaming
+	var output bytes.Buffer
+	stdout := io.MultiWriter(logFile, &output)
+	stderr := io.Writer(logFile)
+	if stream {
+		stdout = io.MultiWriter(os.Stdout, stdout)
+		stderr = io.MultiWriter(os.Stderr, stderr)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = append(os.Environ(), "ANSIBLE_CONFIG="+configFile)
 
-	return cmd.Run()
+	err = cmd.Run()
+	return parseAnsibleRecap(output.String(), host.IP), logFile.Name(), err
+}
+
+// createSetupLogFile opens a new log file for a host's setup run,
+// e.g. ~/.config/quic/logs/setup-10.0.0.5-20250101T120000.log
+func createSetupLogFile(host config.QuicHost) (*os.File, error) {
+	logsDir, err := config.LogsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("setup-%s-%s.log", host.IP, time.Now().Format("20060102T150405"))
+	return os.Create(filepath.Join(logsDir, name))
+}
+
+// ansibleRecap holds a host's task counts from the PLAY RECAP section.
+type ansibleRecap struct {
+	OK          int `json:"ok" yaml:"ok"`
+	Changed     int `json:"changed" yaml:"changed"`
+	Unreachable int `json:"unreachable" yaml:"unreachable"`
+	Failed      int `json:"failed" yaml:"failed"`
+}
+
+// Statuses of a host in host setup's JSON and YAML output
+const (
+	hostSetupOK                 = "ok"
+	hostSetupFailed             = "failed"
+	hostSetupMissingFingerprint = "missing_fingerprint"
+	hostSetupFailedVerification = "failed_verification"
+)
+
+// hostSetupResult is a host's outcome in host setup's JSON and YAML output.
+type hostSetupResult struct {
+	IP     string `json:"ip" yaml:"ip"`
+	Alias  string `json:"alias" yaml:"alias"`
+	Status string `json:"status" yaml:"status"`
+	// Task counts, null when ansible printed no recap for the host
+	Recap *ansibleRecap `json:"recap" yaml:"recap"`
+	Error string        `json:"error,omitempty" yaml:"error,omitempty"`
+	Log   string        `json:"log,omitempty" yaml:"log,omitempty"`
+}
+
+// parseAnsibleRecap finds the recap line for host, e.g.
+// "10.0.0.5 : ok=20 changed=3 unreachable=0 failed=0 skipped=2 rescued=0 ignored=0".
+func parseAnsibleRecap(output, host string) *ansibleRecap {
+	inRecap := false
+	for line := range strings.SplitSeq(output, "\n") {
+		if strings.HasPrefix(line, "PLAY RECAP") {
+			inRecap = true
+			continue
+		}
+		if !inRecap {
+			continue
+		}
+
+		name, counts, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(name) != host {
+			continue
+		}
+
+		recap := &ansibleRecap{}
+		for field := range strings.FieldsSeq(counts) {
+			key, value, _ := strings.Cut(field, "=")
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "ok":
+				recap.OK = n
+			case "changed":
+				recap.Changed = n
+			case "unreachable":
+				recap.Unreachable = n
+			case "failed":
+				recap.Failed = n
+			}
+		}
+		return recap
+	}
+
+	return nil
+}
+
+func (r *ansibleRecap) describe() string {
+	switch {
+	case r == nil:
+		return "no play recap found"
+	case r.Unreachable > 0:
+		return "host unreachable"
+	case r.Failed > 0:
+		return fmt.Sprintf("%d task(s) failed, %d changed", r.Failed, r.Changed)
+	case r.Changed > 0:
+		return fmt.Sprintf("changed (%d of %d tasks)", r.Changed, r.OK)
+	default:
+		return "unchanged"
+	}
 }
 
 func writePlaybookToTemp() (string, error) {
@@ -166,6 +423,9 @@ func writeAnsibleConfigToTemp() (string, error) {
 
 func createInventoryFile(host config.QuicHost, username string) (string, error) {
 	sshArgs := "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"
+	if host.SSHJump != "" {
+		sshArgs += " -o ProxyJump=" + host.SSHJump
+	}
 
 	inventoryContent := fmt.Sprintf(`[quic_hosts]
 %s ansible_user=%s ansible_become=yes ansible_ssh_common_args='%s'
@@ -186,7 +446,18 @@ func validateQuicJSON(cmd *cobra.Command, quicConfig *config.ProjectConfig) erro
 	return nil
 }
 
-func filterHosts(cmd *cobra.Command, allHosts []config.QuicHost, hostsFlag string) ([]config.QuicHost, error) {
+// filterHosts selects hosts by alias, IP, @group or all, with their group
+// defaults applied.
+func filterHosts(cmd *cobra.Command, quicConfig *config.ProjectConfig, hostsFlag string) ([]config.QuicHost, error) {
+	if err := quicConfig.ValidateHostGroups(); err != nil {
+		return nil, fmt.Errorf("invalid quic.json: %w", err)
+	}
+
+	var allHosts []config.QuicHost
+	for _, host := range quicConfig.Hosts {
+		allHosts = append(allHosts, quicConfig.ResolveHost(host))
+	}
+
 	if hostsFlag == "" {
 		return allHosts, nil
 	}
@@ -202,6 +473,31 @@ func filterHosts(cmd *cobra.Command, allHosts []config.QuicHost, hostsFlag strin
 		spec = strings.TrimSpace(spec)
 		found := false
 
+		if group, ok := strings.CutPrefix(spec, "@"); ok {
+			if quicConfig.GetGroup(group) == nil {
+				cmd.PrintErrf("Group '%s' not found in quic.json.\n", group)
+				cmd.PrintErrln("Available groups:")
+				for _, g := range quicConfig.Groups {
+					cmd.PrintErrf("  @%s\n", g.Name)
+				}
+				return nil, nil
+			}
+			for _, host := range allHosts {
+				if host.Group != group {
+					continue
+				}
+				found = true
+				if !slices.ContainsFunc(targetHosts, func(h config.QuicHost) bool { return h.IP == host.IP }) {
+					targetHosts = append(targetHosts, host)
+				}
+			}
+			if !found {
+				cmd.PrintErrf("Group '%s' has no hosts in quic.json.\n", group)
+				return nil, nil
+			}
+			continue
+		}
+
 		for _, host := range allHosts {
 			if host.Alias == spec || host.IP == spec {
 				targetHosts = append(targetHosts, host)
@@ -222,29 +518,3 @@ func filterHosts(cmd *cobra.Command, allHosts []config.QuicHost, hostsFlag strin
 
 	return targetHosts, nil
 }
-
-func retrieveAndStoreCertificateFingerprint(projectConfig *config.ProjectConfig, host config.QuicHost) error {
-	client, err := ssh.NewClient(host.IP)
-	if err != nil {
-		return fmt.Errorf("failed to connect via SSH: %w", err)
-	}
-
-	// Extract certificate fingerprint using OpenSSL
-	fingerprintCmd := "openssl x509 -in /etc/quic/certs/server.crt -noout -fingerprint -sha256 | cut -d'=' -f2"
-	output, err := client.RunCommand(fingerprintCmd)
-	if err != nil {
-		return fmt.Errorf("failed to extract certificate fingerprint: %w", err)
-	}
-
-	fingerprint := strings.TrimSpace(string(output))
-	if fingerprint == "" {
-		return fmt.Errorf("certificate fingerprint is empty")
-	}
-
-	// update the host certificate fingerprint
-	if err := projectConfig.SetHostCertificateFingerprint(host.IP, fingerprint); err != nil {
-		return fmt.Errorf("failed to save updated configuration: %w", err)
-	}
-
-	return nil
-}
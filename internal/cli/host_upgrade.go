@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	"github.com/quickr-dev/quic/internal/ssh"
+	"github.com/quickr-dev/quic/internal/version"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+const (
+	quicdBinaryPath        = "/usr/local/bin/quicd"
+	quicdHealthWaitTimeout = 30 * time.Second
+)
+
+var hostUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "[admin] Upgrade quicd on configured hosts",
+	RunE:  runHostUpgrade,
+}
+
+func init() {
+	hostUpgradeCmd.Flags().String("hosts", "", "Comma-separated list of host aliases, IPs, @groups, or 'all'")
+	hostUpgradeCmd.Flags().String("version", "latest", "quicd release to install (e.g., v1.2.3)")
+	hostUpgradeCmd.Flags().String("binary", "", "Path to a local quicd linux binary to install instead of a release")
+}
+
+func runHostUpgrade(cmd *cobra.Command, args []string) error {
+	quicConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load quic config: %w", err)
+	}
+
+	if len(quicConfig.Hosts) == 0 {
+		return fmt.Errorf("no hosts configured in quic.json")
+	}
+
+	hostsFlag, _ := cmd.Flags().GetString("hosts")
+	if len(quicConfig.Hosts) > 1 && hostsFlag == "" {
+		cmd.PrintErrln("For safety, please specify the hosts to upgrade, for example:")
+		cmd.PrintErrf("  $ quic host upgrade --hosts %s\n", quicConfig.Hosts[0].Alias)
+		cmd.PrintErrln("  $ quic host upgrade --hosts all")
+		return nil
+	}
+
+	targetHosts, err := filterHosts(cmd, quicConfig, hostsFlag)
+	if err != nil {
+		return err
+	}
+	if targetHosts == nil {
+		return nil
+	}
+
+	versionFlag, _ := cmd.Flags().GetString("version")
+	binaryFlag, _ := cmd.Flags().GetString("binary")
+
+	// Resolve the version we expect hosts to report after the upgrade. The
+	// binary is downloaded by that tag, so a release published meanwhile
+	// can't make the version check fail.
+	expectedVersion := ""
+	if binaryFlag == "" {
+		expectedVersion = versionFlag
+		if versionFlag == "latest" {
+			latest, err := version.GetLatestTag()
+			if err != nil {
+				return fmt.Errorf("failed to resolve latest version: %w", err)
+			}
+			expectedVersion = latest
+		}
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	// Upgrade one host at a time and stop at the first failure
+	for _, host := range targetHosts {
+		fmt.Printf("\nUpgrading quicd on host %s (%s)...\n", host.IP, host.Alias)
+		if err := upgradeHost(host, userCfg.AuthToken, binaryFlag, expectedVersion); err != nil {
+			return fmt.Errorf("upgrade failed on host %s: %w", host.Alias, err)
+		}
+	}
+
+	fmt.Printf("\n✓ Upgraded %d host(s)\n", len(targetHosts))
+	return nil
+}
+
+func upgradeHost(host config.QuicHost, authToken, binaryFlag, expectedVersion string) error {
+	client, err := ssh.NewClient(host.IP, host.SSHJump)
+	if err != nil {
+		return fmt.Errorf("failed to connect via SSH: %w", err)
+	}
+
+	binaryPath := binaryFlag
+	if binaryPath == "" {
+		arch, err := getHostArch(client)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("  Downloading quicd %s (linux/%s)...\n", expectedVersion, arch)
+		binaryPath, err = version.DownloadQuicd(expectedVersion, arch)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(binaryPath)
+	}
+
+	fmt.Println("  Transferring binary...")
+	if err := client.WriteFile(binaryPath, quicdBinaryPath+".new"); err != nil {
+		return err
+	}
+
+	steps := []string{
+		fmt.Sprintf("chown root:root %s.new", quicdBinaryPath),
+		fmt.Sprintf("chmod 0755 %s.new", quicdBinaryPath),
+		fmt.Sprintf("cp -p %s %s.bak", quicdBinaryPath, quicdBinaryPath),
+		fmt.Sprintf("mv %s.new %s", quicdBinaryPath, quicdBinaryPath),
+		"systemctl restart quicd",
+	}
+	for _, step := range steps {
+		if _, err := client.RunCommand(step); err != nil {
+			rollbackHost(client)
+			return fmt.Errorf("running '%s': %w", step, err)
+		}
+	}
+
+	fmt.Println("  Waiting for quicd to become healthy...")
+	runningVersion, err := waitForQuicdVersion(host.IP, authToken, expectedVersion)
+	if err != nil {
+		rollbackHost(client)
+		return err
+	}
+
+	client.RunCommand(fmt.Sprintf("rm -f %s.bak", quicdBinaryPath))
+	fmt.Printf("✓ Host %s running quicd %s\n", host.Alias, runningVersion)
+	return nil
+}
+
+func rollbackHost(client *ssh.Client) {
+	fmt.Println("  Rolling back to the previous quicd binary...")
+	if _, err := client.RunCommand(fmt.Sprintf("test -f %s.bak", quicdBinaryPath)); err != nil {
+		fmt.Println("  No backup binary found, skipping rollback")
+		return
+	}
+	if _, err := client.RunCommand(fmt.Sprintf("mv %s.bak %s", quicdBinaryPath, quicdBinaryPath)); err != nil {
+		fmt.Printf("  Warning: failed to restore previous binary: %v\n", err)
+		return
+	}
+	if _, err := client.RunCommand("systemctl restart quicd"); err != nil {
+		fmt.Printf("  Warning: failed to restart quicd after rollback: %v\n", err)
+	}
+}
+
+func getHostArch(client *ssh.Client) (string, error) {
+	output, err := client.RunCommand("uname -m")
+	if err != nil {
+		return "", fmt.Errorf("detecting host architecture: %w", err)
+	}
+
+	switch arch := strings.TrimSpace(string(output)); arch {
+	case "x86_64":
+		return "amd64", nil
+	case "aarch64", "arm64":
+		return "arm64", nil
+	default:
+		return "", fmt.Errorf("unsupported host architecture: %s", arch)
+	}
+}
+
+// waitForQuicdVersion polls the Health RPC until quicd answers, and checks it
+// reports expectedVersion when one is given.
+func waitForQuicdVersion(host, authToken, expectedVersion string) (string, error) {
+	deadline := time.Now().Add(quicdHealthWaitTimeout)
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		var runningVersion string
+		lastErr = executeWithClientOnHost(host, authToken, 5*time.Second, func(client pb.QuicServiceClient, ctx context.Context) error {
+			resp, err := client.Health(ctx, &pb.HealthRequest{})
+			if err != nil {
+				return err
+			}
+			runningVersion = resp.Version
+			return nil
+		})
+
+		if lastErr == nil {
+			if expectedVersion != "" && strings.TrimPrefix(runningVersion, "v") != strings.TrimPrefix(expectedVersion, "v") {
+				return "", fmt.Errorf("quicd reports version %s, expected %s", runningVersion, expectedVersion)
+			}
+			return runningVersion, nil
+		}
+
+		time.Sleep(time.Second)
+	}
+
+	return "", fmt.Errorf("quicd did not become healthy within %v: %w", quicdHealthWaitTimeout, lastErr)
+}
@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/quickr-dev/quic/internal/config"
+	"github.com/quickr-dev/quic/internal/db"
+	"github.com/quickr-dev/quic/internal/ssh"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var hostVerifyCmd = &cobra.Command{
+	Use:   "verify [hosts]",
+	Short: "[admin] Check that set up hosts are ready for templates",
+	Long: `Checks each host's quicd service, its TLS certificate against the fingerprint
+in quic.json, the encrypted ZFS pool and the users database.
+
+Without an argument, all hosts in quic.json are checked. Otherwise pass a
+comma-separated list of host aliases, IPs or @groups.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runHostVerify,
+}
+
+const verifyTimeout = 10 * time.Second
+
+type verifyCheck struct {
+	name   string
+	detail string
+	err    error
+}
+
+func runHostVerify(cmd *cobra.Command, args []string) error {
+	quicConfig, err := config.LoadProjectConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load quic config: %w", err)
+	}
+	if len(quicConfig.Hosts) == 0 {
+		return fmt.Errorf("no hosts configured in quic.json")
+	}
+
+	spec := ""
+	if len(args) == 1 {
+		spec = args[0]
+	}
+	hosts, err := filterHosts(cmd, quicConfig, spec)
+	if err != nil {
+		return err
+	}
+	if hosts == nil {
+		return nil
+	}
+
+	failed := 0
+	for _, host := range hosts {
+		if !verifyHost(os.Stdout, host) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d hosts failed verification", failed, len(hosts))
+	}
+	return nil
+}
+
+// verifyHost prints a pass/fail report for host and returns whether all checks passed.
+func verifyHost(out io.Writer, host config.QuicHost) bool {
+	fmt.Fprintf(out, "\nVerifying host %s (%s)...\n", host.Alias, host.IP)
+
+	checks := verifyQuicd(host)
+	checks = append(checks, verifyHostState(host)...)
+
+	passed := true
+	for _, check := range checks {
+		if check.err != nil {
+			fmt.Fprintf(out, "  ✗ %s: %v\n", check.name, check.err)
+			passed = false
+			continue
+		}
+		fmt.Fprintf(out, "  ✓ %s: %s\n", check.name, check.detail)
+	}
+
+	if passed {
+		fmt.Fprintf(out, "Host %s is ready\n", host.IP)
+	} else {
+		fmt.Fprintf(out, "Host %s is not ready\n", host.IP)
+	}
+	return passed
+}
+
+// verifyQuicd calls Health over TLS with the fingerprint from quic.json, and
+// reports the branch port conflicts quicd found.
+func verifyQuicd(host config.QuicHost) []verifyCheck {
+	check := verifyCheck{name: "quicd"}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		check.err = fmt.Errorf("loading user config: %w", err)
+		return []verifyCheck{check}
+	}
+
+	var resp *pb.HealthResponse
+	var sent, received time.Time
+	err = executeWithClientOnHost(host.IP, userCfg.AuthToken, verifyTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		sent = time.Now()
+		resp, err = client.Health(ctx, &pb.HealthRequest{})
+		received = time.Now()
+		return err
+	})
+
+	switch {
+	case err == nil:
+		check.detail = fmt.Sprintf("healthy, version %s", resp.Version)
+	case status.Code(err) == codes.Unauthenticated:
+		// The TLS handshake and fingerprint check passed for quicd to reject the token
+		check.detail = "reachable, certificate matches (no user token for this host yet)"
+	default:
+		check.err = err
+	}
+	if resp == nil {
+		return []verifyCheck{check}
+	}
+
+	ports := verifyCheck{name: "branch ports", detail: "no conflicts"}
+	if len(resp.PortConflicts) > 0 {
+		var conflicts []string
+		for _, conflict := range resp.PortConflicts {
+			conflicts = append(conflicts, fmt.Sprintf("%s used by %s", conflict.Port, strings.Join(conflict.Branches, ", ")))
+		}
+		ports.err = fmt.Errorf("%s. Delete all but one branch of each port", strings.Join(conflicts, "; "))
+	}
+
+	skew, ok := clockSkew(resp, sent, received)
+	if !ok {
+		return []verifyCheck{check, ports}
+	}
+	clock := verifyCheck{name: "clock", detail: formatClockSkew(skew) + " this machine"}
+	if skew.Abs() > maxClockSkew {
+		clock.err = fmt.Errorf("%s this machine's, more than %s. Branch times and audit timestamps will look off, enable NTP on both (sudo timedatectl set-ntp true)",
+			formatClockSkew(skew), maxClockSkew)
+	}
+	return []verifyCheck{check, ports, clock}
+}
+
+// verifyHostState checks the quicd service, pool and users database over SSH.
+func verifyHostState(host config.QuicHost) []verifyCheck {
+	client, err := ssh.NewClient(host.IP, host.SSHJump)
+	if err != nil {
+		return []verifyCheck{{name: "ssh", err: fmt.Errorf("failed to connect: %w", err)}}
+	}
+
+	service := verifyCheck{name: "quicd service"}
+	if output, err := client.RunCommand("systemctl is-active quicd"); err != nil {
+		service.err = fmt.Errorf("not active (%s)", strings.TrimSpace(string(output)))
+	} else {
+		service.detail = "active"
+	}
+
+	pool := verifyCheck{name: "ZFS pool"}
+	poolName, err := hostPool(client)
+	if err != nil {
+		pool.err = err
+	} else {
+		output, err := client.RunCommand("sudo zfs get -H -o value encryption,keystatus,mounted " + poolName)
+		values := strings.Fields(string(output))
+		switch {
+		case err != nil:
+			pool.err = fmt.Errorf("pool '%s' not found, run 'quic host setup'", poolName)
+		case len(values) != 3:
+			pool.err = fmt.Errorf("unexpected zfs output: %q", strings.TrimSpace(string(output)))
+		case values[0] == "off":
+			pool.err = fmt.Errorf("pool '%s' is not encrypted", poolName)
+		case values[1] != "available":
+			pool.err = fmt.Errorf("encryption key is not loaded (keystatus %s)", values[1])
+		case values[2] != "yes":
+			pool.err = fmt.Errorf("pool '%s' is not mounted", poolName)
+		default:
+			pool.detail = fmt.Sprintf("%s encrypted with %s and mounted", poolName, values[0])
+		}
+	}
+
+	users := verifyCheck{name: "users database"}
+	output, err := client.RunCommand(fmt.Sprintf(`sudo sqlite3 %s "SELECT count(*) FROM users"`, db.DBPath))
+	if err != nil {
+		users.err = fmt.Errorf("%s is missing or unreadable", db.DBPath)
+	} else {
+		users.detail = fmt.Sprintf("%s users", strings.TrimSpace(string(output)))
+	}
+
+	return []verifyCheck{service, pool, users}
+}
+
+// Same rule quicd applies to storage.pool, which also keeps the name safe in
+// a shell command
+var hostPoolPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.:-]*$`)
+
+// hostPool returns the ZFS pool quicd on the host stores branches in, from
+// storage.pool of its quicd.json. Like quicd it falls back to tank.
+func hostPool(client *ssh.Client) (string, error) {
+	output, err := client.RunCommand("sudo cat /etc/quic/quicd.json 2>/dev/null || true")
+	if err != nil {
+		return "", fmt.Errorf("reading /etc/quic/quicd.json: %w", err)
+	}
+
+	var agentConfig struct {
+		Storage struct {
+			Pool string `json:"pool"`
+		} `json:"storage"`
+	}
+	if len(strings.TrimSpace(string(output))) > 0 {
+		if err := json.Unmarshal(output, &agentConfig); err != nil {
+			return "", fmt.Errorf("parsing /etc/quic/quicd.json: %w", err)
+		}
+	}
+
+	pool := cmp.Or(agentConfig.Storage.Pool, "tank")
+	if !hostPoolPattern.MatchString(pool) {
+		return "", fmt.Errorf("storage.pool in /etc/quic/quicd.json must be a ZFS pool name, got %q", pool)
+	}
+	return pool, nil
+}
@@ -3,6 +3,9 @@ package cli
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -20,6 +23,11 @@ var lsCmd = &cobra.Command{
 }
 
 func executeList(cmd *cobra.Command) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
 	userCfg, err := config.LoadUserConfig()
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
@@ -30,9 +38,26 @@ func executeList(cmd *cobra.Command) error {
 		templateName = userCfg.SelectedTemplate
 	}
 
+	sortBy, _ := cmd.Flags().GetString("sort")
+	limit, _ := cmd.Flags().GetInt("limit")
+	if limit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+
+	var staleFor time.Duration
+	if stale, _ := cmd.Flags().GetString("stale"); stale != "" {
+		staleFor, err = parseAge(stale)
+		if err != nil {
+			return fmt.Errorf("invalid --stale value: %w", err)
+		}
+	}
+
 	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
 		req := &pb.ListCheckoutsRequest{
-			RestoreName: templateName,
+			RestoreName:  templateName,
+			Sort:         sortBy,
+			Limit:        int32(limit),
+			StaleSeconds: int64(staleFor / time.Second),
 		}
 
 		resp, err := client.ListCheckouts(ctx, req)
@@ -40,28 +65,75 @@ func executeList(cmd *cobra.Command) error {
 			return fmt.Errorf("listing checkouts: %w", err)
 		}
 
-		if len(resp.Checkouts) == 0 {
-			fmt.Println("No checkouts found.")
+		return renderOutput(format, newBranchSummaryViews(resp.Checkouts), func() error {
+			printBranchTable(resp.Checkouts, templateName == "")
 			return nil
+		})
+	})
+}
+
+// printBranchTable prints branches as a fixed-width table, with a template
+// column when they come from several templates.
+func printBranchTable(checkouts []*pb.CheckoutSummary, allTemplates bool) {
+	if len(checkouts) == 0 {
+		fmt.Println("No checkouts found.")
+		return
+	}
+
+	// Branch names can repeat across templates, so show which is which
+	templateColumn := func(value string) string {
+		if !allTemplates {
+			return ""
+		}
+		return fmt.Sprintf("%-20s ", value)
+	}
+
+	// Print header
+	fmt.Printf("%s%-20s %-15s %-20s %-20s %s\n", templateColumn("TEMPLATE"), "BRANCH", "CREATED BY", "CREATED AT", "LAST ACTIVE", "SIZE")
+	fmt.Printf("%s%-20s %-15s %-20s %-20s %s\n", templateColumn("--------"), "----------", "----------", "----------", "-----------", "----")
+
+	// Print each checkout
+	for _, checkout := range checkouts {
+		size := formatSize(checkout.UsedBytes)
+		if checkout.TemplateMissing {
+			size += " (template missing)"
 		}
+		if checkout.ReplicaOf != "" {
+			size += fmt.Sprintf(" (replica of %s)", checkout.ReplicaOf)
+		}
+		fmt.Printf("%s%-20s %-15s %-20s %-20s %s\n",
+			templateColumn(checkout.RestoreName),
+			checkout.CloneName,
+			checkout.CreatedBy,
+			checkout.CreatedAt,
+			checkout.LastAccessedAt,
+			size,
+		)
+	}
+}
 
-		// Print header
-		fmt.Printf("%-20s %-15s %-20s\n", "BRANCH", "CREATED BY", "CREATED AT")
-		fmt.Printf("%-20s %-15s %-20s\n", "----------", "----------", "----------")
-
-		// Print each checkout
-		for _, checkout := range resp.Checkouts {
-			fmt.Printf("%-20s %-15s %-20s\n",
-				checkout.CloneName,
-				checkout.CreatedBy,
-				checkout.CreatedAt,
-			)
+// parseAge parses a duration that may also be given in whole days, e.g. "7d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("'%s' must be a positive number of days like 7d", s)
 		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
 
-		return nil
-	})
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("'%s' must be a positive duration like 7d or 12h", s)
+	}
+	return d, nil
 }
 
 func init() {
 	lsCmd.Flags().String("template", "", "Name of the template template to list checkouts from (optional - lists all if not specified)")
+	lsCmd.Flags().String("sort", "", "Sort by created (newest first), name, size (largest first), or used-by")
+	lsCmd.Flags().Int("limit", 0, "Show at most N branches")
+	lsCmd.Flags().String("stale", "", "Only show branches with no activity for at least this long (e.g. 7d, 12h)")
+	lsCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+	lsCmd.RegisterFlagCompletionFunc("sort", cobra.FixedCompletions([]string{"created", "name", "size", "used-by"}, cobra.ShellCompDirectiveNoFileComp))
 }
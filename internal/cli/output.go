@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the global --output flag. table is for people, json and yaml
+// print the command's view struct for scripts.
+var outputFormats = []string{"table", "json", "yaml"}
+
+// outputFormat returns the validated --output of cmd. text is what checkout,
+// branch describe and the host commands called table before they used the
+// global flag, it's still accepted for existing scripts.
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("output")
+	if format == "text" {
+		format = "table"
+	}
+	if !slices.Contains(outputFormats, format) {
+		return "", fmt.Errorf("invalid output format '%s'. Use table, json or yaml", format)
+	}
+	return format, nil
+}
+
+// renderOutput prints view to stdout as JSON or YAML. For the table format
+// it calls table instead, which prints the same data for people.
+func renderOutput(format string, view any, table func() error) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(view)
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(view); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return table()
+	}
+}
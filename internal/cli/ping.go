@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+const pingTimeout = 5 * time.Second
+
+var pingCmd = &cobra.Command{
+	Use:   "ping [host]",
+	Short: "Measure latency to configured hosts",
+	Long: `Measure TCP connect and quicd health check latency to each host in
+quic.json, or only to the given host alias, IP or @group.
+
+With --select, the reachable host with the lowest health check latency
+becomes the selected host.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec := ""
+		if len(args) > 0 {
+			spec = args[0]
+		}
+		return executePing(cmd, spec)
+	},
+}
+
+func init() {
+	pingCmd.Flags().Bool("select", false, "Select the host with the lowest latency")
+}
+
+type pingResult struct {
+	host config.QuicHost
+	tcp  time.Duration
+	rpc  time.Duration
+	// How far the host's clock is ahead of this machine's, when quicd reports its time
+	skew      time.Duration
+	skewKnown bool
+	err       error
+}
+
+// maxClockSkew is how far a host's clock may be off before ping and host
+// verify warn. Branch creation and activity times and the audit log are
+// written with the host's clock and read next to this machine's.
+const maxClockSkew = 5 * time.Second
+
+// clockSkew estimates how far the host's clock is ahead of this machine's from
+// a Health response to a call sent and answered at the given times, taking
+// the host's time as of the call's midpoint. ok is false when quicd is too old
+// to report its time.
+func clockSkew(resp *pb.HealthResponse, sent, received time.Time) (skew time.Duration, ok bool) {
+	if resp.ServerTimeUnixMs == 0 {
+		return 0, false
+	}
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	return time.UnixMilli(resp.ServerTimeUnixMs).Sub(midpoint), true
+}
+
+// formatClockSkew describes a skew, e.g. "42s ahead of".
+func formatClockSkew(skew time.Duration) string {
+	if skew < 0 {
+		return fmt.Sprintf("%s behind", (-skew).Round(100*time.Millisecond))
+	}
+	return fmt.Sprintf("%s ahead of", skew.Round(100*time.Millisecond))
+}
+
+func executePing(cmd *cobra.Command, spec string) error {
+	projectCfg, err := config.LoadProjectConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load quic config: %w", err)
+	}
+	if len(projectCfg.Hosts) == 0 {
+		return fmt.Errorf("no hosts configured in quic.json")
+	}
+
+	hosts, err := filterHosts(cmd, projectCfg, spec)
+	if err != nil {
+		return err
+	}
+	if hosts == nil {
+		return nil
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	var best *pingResult
+	var results []pingResult
+	fmt.Printf("  %-20s %-15s %-10s %-10s %s\n", "HOST", "IP", "TCP", "HEALTH", "STATUS")
+	for _, host := range hosts {
+		result := pingHost(host, userCfg.AuthToken)
+		results = append(results, result)
+
+		marker := " "
+		if host.IP == userCfg.SelectedHost {
+			marker = "*"
+		}
+		if result.err != nil {
+			fmt.Printf("%s %-20s %-15s %-10s %-10s %v\n", marker, host.Alias, host.IP, "-", "-", result.err)
+			continue
+		}
+		fmt.Printf("%s %-20s %-15s %-10s %-10s %s\n", marker, host.Alias, host.IP, formatLatency(result.tcp), formatLatency(result.rpc), "ok")
+
+		if best == nil || result.rpc < best.rpc {
+			best = &result
+		}
+	}
+
+	for _, result := range results {
+		if result.skewKnown && result.skew.Abs() > maxClockSkew {
+			fmt.Fprintf(os.Stderr, "\nWarning: the clock of %s is %s this machine's. Branch times and audit timestamps will look off, enable NTP on both (sudo timedatectl set-ntp true)\n",
+				result.host.Alias, formatClockSkew(result.skew))
+		}
+	}
+
+	if selectHost, _ := cmd.Flags().GetBool("select"); selectHost {
+		if best == nil {
+			return fmt.Errorf("no reachable hosts to select")
+		}
+		if err := userCfg.SetSelectedHost(best.host.IP); err != nil {
+			return fmt.Errorf("failed to set selected host: %w", err)
+		}
+		fmt.Printf("\nSelected host '%s' (%s)\n", best.host.Alias, best.host.IP)
+	}
+
+	return nil
+}
+
+// pingHost times a bare TCP connect to quicd and a Health RPC, which adds
+// the TLS handshake and authentication.
+func pingHost(host config.QuicHost, authToken string) pingResult {
+	result := pingResult{host: host}
+
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host.IP, "8443"), pingTimeout)
+	if err != nil {
+		result.err = fmt.Errorf("unreachable: %w", err)
+		return result
+	}
+	result.tcp = time.Since(start)
+	conn.Close()
+
+	result.err = executeWithClientOnHost(host.IP, authToken, pingTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		start := time.Now()
+		resp, err := client.Health(ctx, &pb.HealthRequest{})
+		if err != nil {
+			return fmt.Errorf("health check failed: %w", err)
+		}
+		result.rpc = time.Since(start)
+		result.skew, result.skewKnown = clockSkew(resp, start, start.Add(result.rpc))
+		return nil
+	})
+
+	return result
+}
+
+func formatLatency(d time.Duration) string {
+	return fmt.Sprintf("%.1fms", float64(d.Microseconds())/1000)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/quickr-dev/quic/internal/config"
 	"github.com/quickr-dev/quic/internal/version"
 	"github.com/spf13/cobra"
 )
@@ -12,25 +13,44 @@ var rootCmd = &cobra.Command{
 	Use:   "quic",
 	Short: "Database branching",
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		configPath, _ := cmd.Flags().GetString("config")
+		config.SetProjectConfigPath(configPath)
+
+		// Keep generated scripts and completion results free of update notices
+		switch cmd.Name() {
+		case "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return
+		}
 		version.CheckForUpdateNotification()
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	closeConnections()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("config", "", "Path to quic.json (default: $QUIC_CONFIG, or the nearest quic.json in this or a parent directory)")
+	rootCmd.PersistentFlags().String("output", "table", "Output format: table, json or yaml")
+
+	rootCmd.AddCommand(branchCmd)
 	rootCmd.AddCommand(checkoutCmd)
+	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(eventsCmd)
 	rootCmd.AddCommand(hostCmd)
 	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(pingCmd)
+	rootCmd.AddCommand(selfTestCmd)
 	rootCmd.AddCommand(templateCmd)
 	rootCmd.AddCommand(userCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(whoamiCmd)
 	rootCmd.AddCommand(updateCmd)
 }
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/spf13/cobra"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+// How long the whole self-test may take, checkout included
+const selfTestTimeout = 3 * time.Minute
+
+var selfTestCmd = &cobra.Command{
+	Use:   "self-test",
+	Short: "Check a host end to end by creating, querying and deleting a branch",
+	Long: `Create a throwaway branch of the template, connect to it and run a query,
+then delete it, reporting the time each step took.
+
+The branch is deleted even when a step fails, so it's safe to run in CI, for
+example to check a host after setup or an upgrade before using it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeSelfTest(cmd)
+	},
+}
+
+func init() {
+	selfTestCmd.Flags().String("host", "", "Host alias or IP to test (defaults to the selected host)")
+	selfTestCmd.Flags().String("template", "", "Template to branch from")
+	selfTestCmd.RegisterFlagCompletionFunc("template", completeTemplateNames)
+}
+
+type selfTestStep struct {
+	name     string
+	duration time.Duration
+	err      error
+}
+
+func executeSelfTest(cmd *cobra.Command) error {
+	templateFlag, _ := cmd.Flags().GetString("template")
+	template, err := GetTemplate(templateFlag)
+	if err != nil {
+		return err
+	}
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	host := userCfg.SelectedHost
+	if spec, _ := cmd.Flags().GetString("host"); spec != "" {
+		projectCfg, err := config.LoadProjectConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load quic config: %w", err)
+		}
+		found := false
+		for _, h := range projectCfg.Hosts {
+			if h.Alias == spec || h.IP == spec {
+				host, found = h.IP, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("host '%s' not found in quic.json", spec)
+		}
+	}
+
+	// Self-tests started in the same second, e.g. by parallel CI jobs, get their own branch
+	branchName := fmt.Sprintf("selftest-%d-%s", time.Now().Unix(), uuid.New().String()[:8])
+	fmt.Printf("Self-testing host %s with template %s (branch %s)...\n", host, template.Name, branchName)
+
+	var steps []selfTestStep
+	run := func(name string, fn func() error) error {
+		start := time.Now()
+		err := fn()
+		steps = append(steps, selfTestStep{name: name, duration: time.Since(start), err: err})
+		return err
+	}
+
+	err = executeWithClientOnHost(host, userCfg.AuthToken, selfTestTimeout, func(client pb.QuicServiceClient, ctx context.Context) error {
+		// Delete even after a failed or timed out checkout, which may have
+		// left a partial branch. The request keeps the auth metadata of ctx
+		// but not its deadline.
+		defer func() {
+			cleanupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultTimeout)
+			defer cancel()
+			run("delete", func() error {
+				_, err := client.DeleteCheckout(cleanupCtx, &pb.DeleteCheckoutRequest{
+					CloneName:   branchName,
+					RestoreName: template.Name,
+					// The branch is ours, a maintenance freeze mustn't make it linger
+					IgnoreMaintenance: true,
+				})
+				return err
+			})
+		}()
+
+		var connectionString string
+		err := run("checkout", func() error {
+			resp, err := client.CreateCheckout(ctx, &pb.CreateCheckoutRequest{
+				CloneName:      branchName,
+				RestoreName:    template.Name,
+				IdempotencyKey: uuid.New().String(),
+			})
+			if err != nil {
+				return err
+			}
+			branchHost := host
+			if resp.Host != "" {
+				branchHost = resp.Host
+			}
+			connectionString = formatConnectionString(resp.ConnectionString, branchHost, template.Database)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return run("query", func() error {
+			return selfTestQuery(ctx, connectionString)
+		})
+	})
+
+	fmt.Println()
+	for _, step := range steps {
+		if step.err != nil {
+			fmt.Printf("  ✗ %-10s %-8s %v\n", step.name, step.duration.Round(time.Millisecond), step.err)
+			continue
+		}
+		fmt.Printf("  ✓ %-10s %s\n", step.name, step.duration.Round(time.Millisecond))
+	}
+
+	for _, step := range steps {
+		if step.err != nil {
+			if step.name == "delete" {
+				return fmt.Errorf("self-test failed: branch %s may be left behind, remove it with `quic delete %s --template %s`", branchName, branchName, template.Name)
+			}
+			return fmt.Errorf("self-test failed at %s", step.name)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("self-test failed: %w", err)
+	}
+
+	fmt.Printf("\nSelf-test passed\n")
+	return nil
+}
+
+// selfTestQuery connects to the branch the way clients do, over the network
+// with the admin credentials, and checks a trivial query's result.
+func selfTestQuery(ctx context.Context, connectionString string) error {
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return fmt.Errorf("connecting to branch: %w", err)
+	}
+	defer db.Close()
+
+	var result int
+	if err := db.QueryRowContext(ctx, "SELECT 1 + 1").Scan(&result); err != nil {
+		return fmt.Errorf("querying branch: %w", err)
+	}
+	if result != 2 {
+		return fmt.Errorf("expected 2 from SELECT 1 + 1, got %d", result)
+	}
+	return nil
+}
@@ -10,6 +10,8 @@ var templateCmd = &cobra.Command{
 }
 
 func init() {
+	templateCmd.AddCommand(templateGCCmd)
+	templateCmd.AddCommand(templateInfoCmd)
 	templateCmd.AddCommand(templateNewCmd)
 	templateCmd.AddCommand(templateSetupCmd)
 }
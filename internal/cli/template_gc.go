@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var templateGCCmd = &cobra.Command{
+	Use:   "gc <name>",
+	Short: "Destroy template snapshots no branch depends on anymore",
+	Long: `Destroy the template's snapshots that no branch or template is cloned from,
+e.g. left behind by a branch deletion that failed halfway, and report the
+space reclaimed.
+
+Snapshots with a clone are always kept, and snapshots created outside quic are
+never touched. quicd can also do this periodically, see snapshotGC in the host
+configuration.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTemplateNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeTemplateGC(args[0], cmd)
+	},
+}
+
+func init() {
+	templateGCCmd.Flags().Bool("dry-run", false, "Show what would be destroyed without destroying anything")
+}
+
+func executeTemplateGC(templateName string, cmd *cobra.Command) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	template, err := GetTemplate(templateName)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err := client.CollectTemplateSnapshots(ctx, &pb.CollectTemplateSnapshotsRequest{
+			TemplateName: template.Name,
+			DryRun:       dryRun,
+		})
+		if err != nil {
+			return fmt.Errorf("collecting template snapshots: %w", err)
+		}
+
+		if len(resp.Snapshots) == 0 {
+			fmt.Printf("No orphaned snapshots, %d in use\n", resp.InUse)
+			return nil
+		}
+
+		fmt.Printf("%-40s %-10s %s\n", "SNAPSHOT", "KIND", "USED")
+		for _, snapshot := range resp.Snapshots {
+			fmt.Printf("%-40s %-10s %s\n", snapshot.Name, snapshot.Kind, formatDiffSize(snapshot.UsedBytes))
+		}
+
+		if dryRun {
+			fmt.Printf("\nDry run: %d snapshots would be destroyed, reclaiming %s. %d in use.\n",
+				len(resp.Snapshots), formatDiffSize(resp.ReclaimedBytes), resp.InUse)
+			return nil
+		}
+
+		fmt.Printf("\nDestroyed %d snapshots, reclaimed %s. %d in use.\n",
+			len(resp.Snapshots), formatDiffSize(resp.ReclaimedBytes), resp.InUse)
+		return nil
+	})
+}
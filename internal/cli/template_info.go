@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var templateInfoCmd = &cobra.Command{
+	Use:               "info <name>",
+	Short:             "Show template details and restore provenance",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeTemplateNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return executeTemplateInfo(args[0], cmd)
+	},
+}
+
+func executeTemplateInfo(templateName string, cmd *cobra.Command) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	template, err := GetTemplate(templateName)
+	if err != nil {
+		return err
+	}
+
+	return executeWithClient(func(client pb.QuicServiceClient, ctx context.Context) error {
+		info, err := client.GetTemplateInfo(ctx, &pb.GetTemplateInfoRequest{
+			TemplateName: template.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("getting template info: %w", err)
+		}
+
+		return renderOutput(format, newTemplateInfoView(info), func() error {
+			printTemplateInfo(info)
+			return nil
+		})
+	})
+}
+
+func printTemplateInfo(info *pb.GetTemplateInfoResponse) {
+	ready := "no (still recovering)"
+	if info.Ready {
+		ready = "yes"
+	}
+
+	fmt.Printf("%-12s %s\n", "Template:", info.TemplateName)
+	fmt.Printf("%-12s %s\n", "Database:", info.Database)
+	fmt.Printf("%-12s %s\n", "Stanza:", info.Stanza)
+	if info.SourceTemplate != "" {
+		fmt.Printf("%-12s cloned from %s\n", "Source:", info.SourceTemplate)
+	}
+	fmt.Printf("%-12s %s\n", "Restored at:", info.CreatedAt)
+	if info.TargetTime != "" {
+		fmt.Printf("%-12s %s\n", "Target time:", info.TargetTime)
+	}
+	if info.RecoveryState != "" {
+		fmt.Printf("%-12s %s at %s\n", "Recovery:", formatRecoveryState(info.RecoveryState), info.ReplayLsn)
+	}
+	if info.BackupLabel != "" {
+		fmt.Printf("%-12s %s (finished %s)\n", "Backup:", info.BackupLabel, info.BackupFinishedAt)
+		fmt.Printf("%-12s %s - %s\n", "Backup LSN:", info.BackupLsnStart, info.BackupLsnStop)
+	}
+	fmt.Printf("%-12s %s (%s)\n", "Service:", info.ServiceName, info.ServiceStatus)
+	fmt.Printf("%-12s %s\n", "Port:", info.Port)
+	fmt.Printf("%-12s %s\n", "Ready:", ready)
+	fmt.Printf("%-12s %s\n", "Branches:", formatBranchUsage(info.BranchCount, info.BranchLimit))
+	fmt.Printf("%-12s %s\n", "Host total:", formatBranchUsage(info.HostBranchCount, info.HostBranchLimit))
+	fmt.Printf("%-12s %s restored, %s with branches, %s free\n", "Storage:",
+		formatSize(info.ReferencedBytes), formatSize(info.UsedBytes), formatSize(info.AvailableBytes))
+	if info.Encryption != "" {
+		fmt.Printf("%-12s %s\n", "Encryption:", info.Encryption)
+		fmt.Printf("%-12s %s\n", "Compression:", formatCompression(info.Compression, info.CompressRatio))
+	}
+}
+
+func formatRecoveryState(state string) string {
+	switch state {
+	case "paused":
+		return "target reached, paused"
+	case "replaying":
+		return "replaying WAL up to the target"
+	default:
+		return state
+	}
+}
+
+func formatBranchUsage(count, limit int32) string {
+	if limit == 0 {
+		return fmt.Sprintf("%d", count)
+	}
+	return fmt.Sprintf("%d of %d allowed", count, limit)
+}
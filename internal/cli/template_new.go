@@ -22,6 +22,7 @@ func init() {
 	templateNewCmd.Flags().String("provider", "crunchybridge", "Template provider (currently only crunchybridge)")
 	templateNewCmd.Flags().String("cluster-name", "", "CrunchyBridge's cluster name")
 	templateNewCmd.Flags().String("database", "", "Database name to branch from")
+	templateNewCmd.Flags().String("extensions", "", "Comma-separated list of extensions enabled by default on branches (e.g., pg_stat_statements)")
 }
 
 func runTemplateNew(cmd *cobra.Command, args []string) error {
@@ -36,6 +37,7 @@ func runTemplateNew(cmd *cobra.Command, args []string) error {
 	providerName, _ := cmd.Flags().GetString("provider")
 	clusterName, _ := cmd.Flags().GetString("cluster-name")
 	database, _ := cmd.Flags().GetString("database")
+	extensionsFlag, _ := cmd.Flags().GetString("extensions")
 
 	// If cluster-name or database flag is not provided, use interactive prompts
 	if clusterName == "" || database == "" {
@@ -96,6 +98,7 @@ func runTemplateNew(cmd *cobra.Command, args []string) error {
 			Name:        providerName,
 			ClusterName: clusterName,
 		},
+		Extensions: splitCommaList(extensionsFlag),
 	}
 
 	if err := quicConfig.AddTemplate(template); err != nil {
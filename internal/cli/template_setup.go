@@ -4,9 +4,15 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+
 	"github.com/quickr-dev/quic/internal/config"
 	"github.com/quickr-dev/quic/internal/providers"
 	pb "github.com/quickr-dev/quic/proto"
@@ -20,6 +26,30 @@ var templateSetupCmd = &cobra.Command{
 	RunE:  runTemplateSetup,
 }
 
+func init() {
+	templateSetupCmd.Flags().Bool("compress", false, "Compress the restore log stream with gzip (useful on slow links)")
+	templateSetupCmd.Flags().Bool("delta", false, "Re-restore existing templates, copying only changed files (the data directory must be from the same cluster)")
+	templateSetupCmd.Flags().Bool("ignore-maintenance", false, "Delta restore existing templates even if the host maintenance policy blocks destructive operations")
+	templateSetupCmd.Flags().Bool("only-database", false, "Restore only the template's database, skipping the cluster's other databases")
+	templateSetupCmd.Flags().String("log-level", "info", "pgBackRest output shown during the restore: error, warn, info, detail or debug")
+	templateSetupCmd.Flags().Int("process-max", 0, "Parallel pgBackRest restore processes (default: host setting, at most the host's CPU count)")
+	templateSetupCmd.Flags().Bool("verify-checksums", false, "Check every data page with pg_checksums after the restore and fail on corruption (clusters with data checksums only)")
+	templateSetupCmd.Flags().String("target-time", "", "Restore to a point in time (RFC3339, e.g. 2024-01-02T15:04:05Z) instead of following the latest WAL")
+}
+
+type templateSetupOptions struct {
+	Compress     bool
+	ProcessMax   int
+	Delta        bool
+	OnlyDatabase bool
+	LogLevel     string
+	TargetTime   string
+	// Restored templates only, clones share their source's data
+	VerifyChecksums bool
+	// Lets --delta rewrite existing templates despite the maintenance policy
+	IgnoreMaintenance bool
+}
+
 func runTemplateSetup(cmd *cobra.Command, args []string) error {
 	quicConfig, err := config.LoadProjectConfig()
 	if err != nil {
@@ -34,17 +64,70 @@ func runTemplateSetup(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("no templates configured. Run 'quic template new' first")
 	}
 
-	// CrunchyBridge integration
-	apiKey := os.Getenv("CB_API_KEY")
-	if apiKey == "" {
-		return fmt.Errorf("CrunchyBridge API key not found. Please provide it (https://www.crunchybridge.com/account/api-keys):\n$ CB_API_KEY=<YOUR_KEY> quic template setup")
+	if err := quicConfig.ValidateTemplateSources(); err != nil {
+		return fmt.Errorf("invalid quic.json: %w", err)
+	}
+
+	// Templates with a source clone it, so they must come after the restores
+	var restored, cloned []config.Template
+	for _, template := range quicConfig.Templates {
+		if template.Source == "" {
+			restored = append(restored, template)
+		} else {
+			cloned = append(cloned, template)
+		}
 	}
 
-	client := providers.NewCrunchyBridgeClient(apiKey)
+	// CrunchyBridge integration, resolving each API key once before any restore starts
+	clients := make(map[string]*providers.CrunchyBridgeClient)
+	for _, template := range restored {
+		ref := template.Provider.APIKeyRef()
+		if _, ok := clients[ref]; ok {
+			continue
+		}
+		apiKey, err := config.ResolveSecret(ref)
+		if err != nil && ref == config.DefaultCrunchyBridgeAPIKey {
+			return fmt.Errorf("CrunchyBridge API key not found. Please provide it (https://www.crunchybridge.com/account/api-keys):\n$ CB_API_KEY=<YOUR_KEY> quic template setup\nor point the template's provider.apiKey at a file or secret manager command")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read API key of template '%s': %w", template.Name, err)
+		}
+		clients[ref] = providers.NewCrunchyBridgeClient(apiKey)
+	}
+
+	compress, _ := cmd.Flags().GetBool("compress")
+	processMax, _ := cmd.Flags().GetInt("process-max")
+	if processMax < 0 {
+		return fmt.Errorf("--process-max must not be negative")
+	}
+	delta, _ := cmd.Flags().GetBool("delta")
+	ignoreMaintenance, _ := cmd.Flags().GetBool("ignore-maintenance")
+	onlyDatabase, _ := cmd.Flags().GetBool("only-database")
+	logLevel, _ := cmd.Flags().GetString("log-level")
+	if !slices.Contains([]string{"error", "warn", "info", "detail", "debug"}, logLevel) {
+		return fmt.Errorf("invalid --log-level '%s'. Use error, warn, info, detail or debug", logLevel)
+	}
+	targetTime, _ := cmd.Flags().GetString("target-time")
+	if targetTime != "" {
+		parsed, err := time.Parse(time.RFC3339, targetTime)
+		if err != nil {
+			return fmt.Errorf("invalid --target-time '%s'. Use RFC3339, e.g. 2024-01-02T15:04:05Z", targetTime)
+		}
+		if parsed.After(time.Now()) {
+			return fmt.Errorf("--target-time %s is in the future", targetTime)
+		}
+	}
+	verifyChecksums, _ := cmd.Flags().GetBool("verify-checksums")
+	opts := templateSetupOptions{Compress: compress, ProcessMax: processMax, Delta: delta, IgnoreMaintenance: ignoreMaintenance, OnlyDatabase: onlyDatabase, LogLevel: logLevel, TargetTime: targetTime, VerifyChecksums: verifyChecksums}
 
 	// Setup each template
-	for _, template := range quicConfig.Templates {
-		if err := setupTemplate(template, client, quicConfig.Hosts); err != nil {
+	for _, template := range restored {
+		if err := setupTemplate(template, clients[template.Provider.APIKeyRef()], quicConfig.Hosts, opts); err != nil {
+			return fmt.Errorf("failed to setup template '%s': %w", template.Name, err)
+		}
+	}
+	for _, template := range cloned {
+		if err := setupTemplateFromSource(template, quicConfig.Hosts, opts); err != nil {
 			return fmt.Errorf("failed to setup template '%s': %w", template.Name, err)
 		}
 	}
@@ -53,7 +136,7 @@ func runTemplateSetup(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func setupTemplate(template config.Template, client *providers.CrunchyBridgeClient, hosts []config.QuicHost) error {
+func setupTemplate(template config.Template, client *providers.CrunchyBridgeClient, hosts []config.QuicHost, opts templateSetupOptions) error {
 	fmt.Printf("\n🔄 Setting up template '%s'...\n", template.Name)
 
 	// Validate template provider
@@ -85,13 +168,13 @@ func setupTemplate(template config.Template, client *providers.CrunchyBridgeClie
 
 	// Generate pgbackrest config
 	pgDataPath := fmt.Sprintf("/opt/quic/%s/_restore", template.Name)
-	pgbackrestConfig := backupToken.GeneratePgBackRestConfig(backupToken.Stanza, pgDataPath)
+	pgbackrestConfig := backupToken.GeneratePgBackRestConfig(backupToken.Stanza, pgDataPath, template.Provider.RepoIndex())
 
 	// Setup template on each host
 	for _, host := range hosts {
 		fmt.Printf("\n📡 Setting up template '%s' on host %s (%s)...\n", template.Name, host.Alias, host.IP)
 
-		if err := setupTemplateOnHost(template, backupToken, pgbackrestConfig, host); err != nil {
+		if err := setupTemplateOnHost(template, backupToken, pgbackrestConfig, host, opts); err != nil {
 			return fmt.Errorf("failed to setup template on host %s: %w", host.Alias, err)
 		}
 
@@ -101,7 +184,7 @@ func setupTemplate(template config.Template, client *providers.CrunchyBridgeClie
 	return nil
 }
 
-func setupTemplateOnHost(template config.Template, backupToken *providers.BackupToken, pgbackrestConfig string, host config.QuicHost) error {
+func setupTemplateOnHost(template config.Template, backupToken *providers.BackupToken, pgbackrestConfig string, host config.QuicHost, opts templateSetupOptions) error {
 	// Load user config for authentication
 	userCfg, err := config.LoadUserConfig()
 	if err != nil {
@@ -113,47 +196,120 @@ func setupTemplateOnHost(template config.Template, backupToken *providers.Backup
 
 	// Create restore request
 	req := &pb.RestoreTemplateRequest{
-		TemplateName:     template.Name,
-		Database:         template.Database,
-		PgVersion:        template.PGVersion,
-		BackupToken:      pbBackupToken,
-		PgbackrestConfig: pgbackrestConfig,
+		TemplateName:      template.Name,
+		Database:          template.Database,
+		PgVersion:         template.PGVersion,
+		BackupToken:       pbBackupToken,
+		PgbackrestConfig:  pgbackrestConfig,
+		ProcessMax:        int32(opts.ProcessMax),
+		Delta:             opts.Delta,
+		IgnoreMaintenance: opts.IgnoreMaintenance,
+		OnlyDatabase:      opts.OnlyDatabase,
+		LogLevel:          opts.LogLevel,
+		TargetTime:        opts.TargetTime,
+		VerifyChecksums:   opts.VerifyChecksums,
+		Repo:              int32(template.Provider.RepoIndex()),
 	}
 
-	return executeWithClientOnHost(host.IP, userCfg.AuthToken, 120*time.Minute, func(client pb.QuicServiceClient, ctx context.Context) error {
-		stream, err := client.RestoreTemplate(ctx, req)
-		if err != nil {
-			return fmt.Errorf("failed to start restore: %w", err)
+	return runTemplateSetupOnHost(req, host, userCfg.AuthToken, opts)
+}
+
+// setupTemplateFromSource sets up the template on each host as a clone of its
+// source template's restore.
+func setupTemplateFromSource(template config.Template, hosts []config.QuicHost, opts templateSetupOptions) error {
+	fmt.Printf("\n🔄 Setting up template '%s' from '%s'...\n", template.Name, template.Source)
+
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	req := &pb.RestoreTemplateRequest{
+		TemplateName:   template.Name,
+		Database:       template.Database,
+		PgVersion:      template.PGVersion,
+		SourceTemplate: template.Source,
+		Delta:          opts.Delta,
+	}
+
+	for _, host := range hosts {
+		fmt.Printf("\n📡 Setting up template '%s' on host %s (%s)...\n", template.Name, host.Alias, host.IP)
+
+		if err := runTemplateSetupOnHost(req, host, userCfg.AuthToken, opts); err != nil {
+			return fmt.Errorf("failed to setup template on host %s: %w", host.Alias, err)
 		}
 
-		// Process streaming responses
-		for {
-			resp, err := stream.Recv()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				return fmt.Errorf("restore stream error: %w", err)
+		fmt.Printf("✓ Template '%s' setup complete on host %s\n", template.Name, host.Alias)
+	}
+
+	return nil
+}
+
+func runTemplateSetupOnHost(req *pb.RestoreTemplateRequest, host config.QuicHost, authToken string, opts templateSetupOptions) error {
+	return executeWithClientOnHost(host.IP, authToken, 120*time.Minute, func(client pb.QuicServiceClient, ctx context.Context) error {
+		if opts.Compress {
+			received, err := streamTemplateRestore(ctx, client, req, grpc.UseCompressor(gzip.Name))
+			// Agents without the gzip compressor reject the call before restoring anything
+			if received || status.Code(err) != codes.Unimplemented {
+				return err
 			}
+			fmt.Println("  Host does not support compression, continuing uncompressed")
+		}
 
-			switch msg := resp.Message.(type) {
-			case *pb.RestoreTemplateResponse_Log:
-				// Print pgbackrest logs in real-time
-				fmt.Printf("  %s\n", msg.Log.Line)
+		_, err := streamTemplateRestore(ctx, client, req)
+		return err
+	})
+}
 
-			case *pb.RestoreTemplateResponse_Result:
-				fmt.Printf("✓ Restore completed successfully!\n")
-				fmt.Printf("  Connection: %s\n", msg.Result.ConnectionString)
-				fmt.Printf("  Service: %s\n", msg.Result.ServiceName)
-				fmt.Printf("  Port: %s\n", msg.Result.Port)
+// streamTemplateRestore runs the restore and prints its progress. received
+// reports whether any message arrived from the agent.
+func streamTemplateRestore(ctx context.Context, client pb.QuicServiceClient, req *pb.RestoreTemplateRequest, opts ...grpc.CallOption) (received bool, err error) {
+	stream, err := client.RestoreTemplate(ctx, req, opts...)
+	if err != nil {
+		return false, fmt.Errorf("failed to start restore: %w", err)
+	}
 
-			case *pb.RestoreTemplateResponse_Error:
-				return fmt.Errorf("restore failed at step '%s': %s", msg.Error.Step, msg.Error.ErrorMessage)
+	// Process streaming responses
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return received, fmt.Errorf("restore stream error: %w", err)
+		}
+		received = true
+
+		switch msg := resp.Message.(type) {
+		case *pb.RestoreTemplateResponse_Log:
+			// Print pgbackrest logs in real-time
+			fmt.Printf("  %s\n", msg.Log.Line)
+
+		case *pb.RestoreTemplateResponse_Result:
+			fmt.Printf("✓ Restore completed successfully!\n")
+			fmt.Printf("  Connection: %s\n", msg.Result.ConnectionString)
+			fmt.Printf("  Service: %s\n", msg.Result.ServiceName)
+			fmt.Printf("  Port: %s\n", msg.Result.Port)
+			if msg.Result.Host != "" {
+				fmt.Printf("  Host: %s\n", msg.Result.Host)
+			}
+			if msg.Result.BackupLabel != "" {
+				fmt.Printf("  Backup: %s (stanza %s, LSN %s - %s)\n", msg.Result.BackupLabel, msg.Result.Stanza, msg.Result.BackupLsnStart, msg.Result.BackupLsnStop)
+			}
+			if len(msg.Result.Timings) > 0 {
+				var steps []string
+				for _, t := range msg.Result.Timings {
+					steps = append(steps, fmt.Sprintf("%s %s", t.Step, time.Duration(t.DurationMs)*time.Millisecond))
+				}
+				fmt.Printf("  Timing: %s\n", strings.Join(steps, ", "))
 			}
+
+		case *pb.RestoreTemplateResponse_Error:
+			return received, fmt.Errorf("restore failed at step '%s': %s", msg.Error.Step, msg.Error.ErrorMessage)
 		}
+	}
 
-		return nil
-	})
+	return received, nil
 }
 
 func convertBackupTokenToPB(token *providers.BackupToken) *pb.BackupToken {
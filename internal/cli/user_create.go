@@ -45,7 +45,7 @@ func runUserCreate(cmd *cobra.Command, args []string) error {
 	// Create user on all configured hosts (idempotent)
 	var failedHosts []string
 	for _, host := range quicConfig.Hosts {
-		if err := createUserOnHost(host, name, token); err != nil {
+		if err := createUserOnHost(quicConfig.ResolveHost(host), name, token); err != nil {
 			failedHosts = append(failedHosts, fmt.Sprintf("%s (%s): %v", host.Alias, host.IP, err))
 		}
 	}
@@ -63,7 +63,7 @@ func runUserCreate(cmd *cobra.Command, args []string) error {
 }
 
 func createUserOnHost(host config.QuicHost, name, token string) error {
-	client, err := ssh.NewClient(host.IP)
+	client, err := ssh.NewClient(host.IP, host.SSHJump)
 	if err != nil {
 		return fmt.Errorf("failed to connect to host %s: %w", host.IP, err)
 	}
@@ -0,0 +1,188 @@
+package cli
+
+import pb "github.com/quickr-dev/quic/proto"
+
+// Views are what --output json and yaml print. Field names follow the
+// agent's metadata, and optional fields are left out when empty.
+
+type branchSummaryView struct {
+	Template        string `json:"template" yaml:"template"`
+	Branch          string `json:"branch" yaml:"branch"`
+	CreatedBy       string `json:"created_by" yaml:"created_by"`
+	CreatedAt       string `json:"created_at" yaml:"created_at"`
+	LastAccessedAt  string `json:"last_accessed_at,omitempty" yaml:"last_accessed_at,omitempty"`
+	Port            string `json:"port" yaml:"port"`
+	UsedBytes       int64  `json:"used_bytes" yaml:"used_bytes"`
+	TemplateMissing bool   `json:"template_missing,omitempty" yaml:"template_missing,omitempty"`
+	ReplicaOf       string `json:"replica_of,omitempty" yaml:"replica_of,omitempty"`
+}
+
+func newBranchSummaryViews(checkouts []*pb.CheckoutSummary) []branchSummaryView {
+	views := []branchSummaryView{}
+	for _, c := range checkouts {
+		views = append(views, branchSummaryView{
+			Template:        c.RestoreName,
+			Branch:          c.CloneName,
+			CreatedBy:       c.CreatedBy,
+			CreatedAt:       c.CreatedAt,
+			LastAccessedAt:  c.LastAccessedAt,
+			Port:            c.Port,
+			UsedBytes:       c.UsedBytes,
+			TemplateMissing: c.TemplateMissing,
+			ReplicaOf:       c.ReplicaOf,
+		})
+	}
+	return views
+}
+
+type branchInfoView struct {
+	Branch           string           `json:"branch" yaml:"branch"`
+	Template         string           `json:"template" yaml:"template"`
+	CreatedBy        string           `json:"created_by" yaml:"created_by"`
+	CreatedAt        string           `json:"created_at" yaml:"created_at"`
+	LastAccessedAt   string           `json:"last_accessed_at,omitempty" yaml:"last_accessed_at,omitempty"`
+	DataAsOf         string           `json:"data_as_of,omitempty" yaml:"data_as_of,omitempty"`
+	BackupFinishedAt string           `json:"backup_finished_at,omitempty" yaml:"backup_finished_at,omitempty"`
+	Port             string           `json:"port" yaml:"port"`
+	ServiceStatus    string           `json:"service_status" yaml:"service_status"`
+	Ready            bool             `json:"ready" yaml:"ready"`
+	InRecovery       bool             `json:"in_recovery" yaml:"in_recovery"`
+	ConnectionCount  int32            `json:"connection_count" yaml:"connection_count"`
+	MaxConnections   int32            `json:"max_connections,omitempty" yaml:"max_connections,omitempty"`
+	UsedBytes        int64            `json:"used_bytes" yaml:"used_bytes"`
+	ReferencedBytes  int64            `json:"referenced_bytes" yaml:"referenced_bytes"`
+	QuotaBytes       int64            `json:"quota_bytes,omitempty" yaml:"quota_bytes,omitempty"`
+	Mountpoint       string           `json:"mountpoint,omitempty" yaml:"mountpoint,omitempty"`
+	Encryption       string           `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	Compression      string           `json:"compression,omitempty" yaml:"compression,omitempty"`
+	CompressRatio    string           `json:"compress_ratio,omitempty" yaml:"compress_ratio,omitempty"`
+	RoleMode         string           `json:"role_mode,omitempty" yaml:"role_mode,omitempty"`
+	Extensions       []string         `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+	Settings         []string         `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Profile          string           `json:"profile,omitempty" yaml:"profile,omitempty"`
+	Analyzed         bool             `json:"analyzed,omitempty" yaml:"analyzed,omitempty"`
+	WALReset         string           `json:"wal_reset,omitempty" yaml:"wal_reset,omitempty"`
+	WALArchive       string           `json:"wal_archive,omitempty" yaml:"wal_archive,omitempty"`
+	Detached         bool             `json:"detached,omitempty" yaml:"detached,omitempty"`
+	Promoted         bool             `json:"promoted,omitempty" yaml:"promoted,omitempty"`
+	Frozen           bool             `json:"frozen,omitempty" yaml:"frozen,omitempty"`
+	TemplateMissing  bool             `json:"template_missing,omitempty" yaml:"template_missing,omitempty"`
+	ReplicaOf        string           `json:"replica_of,omitempty" yaml:"replica_of,omitempty"`
+	Replicas         []string         `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	Checkpoints      []checkpointView `json:"checkpoints,omitempty" yaml:"checkpoints,omitempty"`
+	// With --last-timing
+	Timings []stepTimingJSON `json:"timings,omitempty" yaml:"timings,omitempty"`
+}
+
+type checkpointView struct {
+	Label     string `json:"label" yaml:"label"`
+	CreatedBy string `json:"created_by" yaml:"created_by"`
+	CreatedAt string `json:"created_at" yaml:"created_at"`
+}
+
+func newBranchInfoView(info *pb.GetBranchInfoResponse, withTimings bool) branchInfoView {
+	view := branchInfoView{
+		Branch:           info.CloneName,
+		Template:         info.RestoreName,
+		CreatedBy:        info.CreatedBy,
+		CreatedAt:        info.CreatedAt,
+		LastAccessedAt:   info.LastAccessedAt,
+		DataAsOf:         info.DataAsOf,
+		BackupFinishedAt: info.BackupFinishedAt,
+		Port:             info.Port,
+		ServiceStatus:    info.ServiceStatus,
+		Ready:            info.Ready,
+		InRecovery:       info.InRecovery,
+		ConnectionCount:  info.ConnectionCount,
+		MaxConnections:   info.MaxConnections,
+		UsedBytes:        info.UsedBytes,
+		ReferencedBytes:  info.ReferencedBytes,
+		QuotaBytes:       info.QuotaBytes,
+		Mountpoint:       info.Mountpoint,
+		Encryption:       info.Encryption,
+		Compression:      info.Compression,
+		CompressRatio:    info.CompressRatio,
+		RoleMode:         info.RoleMode,
+		Extensions:       info.Extensions,
+		Settings:         info.Settings,
+		Profile:          info.Profile,
+		Analyzed:         info.Analyzed,
+		WALReset:         info.WalReset,
+		WALArchive:       info.WalArchive,
+		Detached:         info.Detached,
+		Promoted:         info.Promoted,
+		Frozen:           info.Frozen,
+		TemplateMissing:  info.TemplateMissing,
+		ReplicaOf:        info.ReplicaOf,
+		Replicas:         info.Replicas,
+	}
+	for _, c := range info.Checkpoints {
+		view.Checkpoints = append(view.Checkpoints, checkpointView{Label: c.Label, CreatedBy: c.CreatedBy, CreatedAt: c.CreatedAt})
+	}
+	if withTimings {
+		for _, t := range info.Timings {
+			view.Timings = append(view.Timings, stepTimingJSON{Step: t.Step, DurationMs: t.DurationMs})
+		}
+	}
+	return view
+}
+
+type templateInfoView struct {
+	Template         string `json:"template" yaml:"template"`
+	Database         string `json:"database" yaml:"database"`
+	Stanza           string `json:"stanza" yaml:"stanza"`
+	SourceTemplate   string `json:"source_template,omitempty" yaml:"source_template,omitempty"`
+	CreatedAt        string `json:"created_at" yaml:"created_at"`
+	TargetTime       string `json:"target_time,omitempty" yaml:"target_time,omitempty"`
+	RecoveryState    string `json:"recovery_state,omitempty" yaml:"recovery_state,omitempty"`
+	ReplayLSN        string `json:"replay_lsn,omitempty" yaml:"replay_lsn,omitempty"`
+	BackupLabel      string `json:"backup_label,omitempty" yaml:"backup_label,omitempty"`
+	BackupFinishedAt string `json:"backup_finished_at,omitempty" yaml:"backup_finished_at,omitempty"`
+	BackupLSNStart   string `json:"backup_lsn_start,omitempty" yaml:"backup_lsn_start,omitempty"`
+	BackupLSNStop    string `json:"backup_lsn_stop,omitempty" yaml:"backup_lsn_stop,omitempty"`
+	ServiceName      string `json:"service_name" yaml:"service_name"`
+	ServiceStatus    string `json:"service_status" yaml:"service_status"`
+	Port             string `json:"port" yaml:"port"`
+	Ready            bool   `json:"ready" yaml:"ready"`
+	BranchCount      int32  `json:"branch_count" yaml:"branch_count"`
+	BranchLimit      int32  `json:"branch_limit,omitempty" yaml:"branch_limit,omitempty"`
+	HostBranchCount  int32  `json:"host_branch_count" yaml:"host_branch_count"`
+	HostBranchLimit  int32  `json:"host_branch_limit,omitempty" yaml:"host_branch_limit,omitempty"`
+	ReferencedBytes  int64  `json:"referenced_bytes" yaml:"referenced_bytes"`
+	UsedBytes        int64  `json:"used_bytes" yaml:"used_bytes"`
+	AvailableBytes   int64  `json:"available_bytes" yaml:"available_bytes"`
+	Encryption       string `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	Compression      string `json:"compression,omitempty" yaml:"compression,omitempty"`
+	CompressRatio    string `json:"compress_ratio,omitempty" yaml:"compress_ratio,omitempty"`
+}
+
+func newTemplateInfoView(info *pb.GetTemplateInfoResponse) templateInfoView {
+	return templateInfoView{
+		Template:         info.TemplateName,
+		Database:         info.Database,
+		Stanza:           info.Stanza,
+		SourceTemplate:   info.SourceTemplate,
+		CreatedAt:        info.CreatedAt,
+		TargetTime:       info.TargetTime,
+		RecoveryState:    info.RecoveryState,
+		ReplayLSN:        info.ReplayLsn,
+		BackupLabel:      info.BackupLabel,
+		BackupFinishedAt: info.BackupFinishedAt,
+		BackupLSNStart:   info.BackupLsnStart,
+		BackupLSNStop:    info.BackupLsnStop,
+		ServiceName:      info.ServiceName,
+		ServiceStatus:    info.ServiceStatus,
+		Port:             info.Port,
+		Ready:            info.Ready,
+		BranchCount:      info.BranchCount,
+		BranchLimit:      info.BranchLimit,
+		HostBranchCount:  info.HostBranchCount,
+		HostBranchLimit:  info.HostBranchLimit,
+		ReferencedBytes:  info.ReferencedBytes,
+		UsedBytes:        info.UsedBytes,
+		AvailableBytes:   info.AvailableBytes,
+		Encryption:       info.Encryption,
+		Compression:      info.Compression,
+		CompressRatio:    info.CompressRatio,
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/quickr-dev/quic/internal/config"
+	pb "github.com/quickr-dev/quic/proto"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the current user, host, and template",
+	RunE:  runWhoAmI,
+}
+
+func runWhoAmI(cmd *cobra.Command, args []string) error {
+	userCfg, err := config.LoadUserConfig()
+	if err != nil {
+		return fmt.Errorf("loading user config: %w", err)
+	}
+
+	projectCfg, err := config.LoadProjectConfig()
+	if err != nil {
+		return fmt.Errorf("loading project config: %w", err)
+	}
+
+	hostLabel := "none selected"
+	if userCfg.SelectedHost != "" {
+		hostLabel = userCfg.SelectedHost
+		if host := projectCfg.GetHostByIP(userCfg.SelectedHost); host != nil {
+			hostLabel = fmt.Sprintf("%s (%s)", host.Alias, host.IP)
+		} else {
+			hostLabel += " (not in quic.json)"
+		}
+	}
+
+	templateLabel := "none selected"
+	if template, err := GetTemplate(""); err == nil {
+		templateLabel = template.Name
+	} else if userCfg.SelectedTemplate != "" {
+		templateLabel = fmt.Sprintf("%s (%v)", userCfg.SelectedTemplate, err)
+	}
+
+	tokenLabel := "not set, run 'quic login'"
+	if userCfg.AuthToken != "" {
+		tokenLabel = "set"
+	}
+
+	fmt.Printf("%-10s %s\n", "Host:", hostLabel)
+	fmt.Printf("%-10s %s\n", "Template:", templateLabel)
+	fmt.Printf("%-10s %s\n", "Token:", tokenLabel)
+
+	if userCfg.SelectedHost == "" || userCfg.AuthToken == "" {
+		return nil
+	}
+
+	// Live details are best effort so the command stays useful offline
+	var resp *pb.WhoAmIResponse
+	err = executeWithClientOnHost(userCfg.SelectedHost, userCfg.AuthToken, 5*time.Second, func(client pb.QuicServiceClient, ctx context.Context) error {
+		resp, err = client.WhoAmI(ctx, &pb.WhoAmIRequest{})
+		return err
+	})
+
+	switch {
+	case err == nil:
+		fmt.Printf("%-10s %s\n", "User:", resp.UserName)
+		fmt.Printf("%-10s %s\n", "quicd:", resp.Version)
+	case status.Code(err) == codes.Unauthenticated:
+		fmt.Printf("%-10s %s\n", "User:", "token rejected by host, run 'quic login'")
+	default:
+		fmt.Printf("%-10s %s\n", "User:", fmt.Sprintf("unknown, host unreachable: %v", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in s with the variable's value.
+// Referencing an unset variable is an error.
+func expandEnv(s string) (string, error) {
+	var missing []string
+	expanded := envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+
+	return expanded, nil
+}
+
+// expandEnvTree expands the strings of a decoded JSON value. path names the
+// value in error messages.
+func expandEnvTree(v any, path string) (any, error) {
+	switch v := v.(type) {
+	case string:
+		expanded, err := expandEnv(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return expanded, nil
+
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			expanded, err := expandEnvTree(item, strings.TrimPrefix(path+"."+key, "."))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = expanded
+		}
+		return result, nil
+
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			expanded, err := expandEnvTree(item, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = expanded
+		}
+		return result, nil
+	}
+
+	return v, nil
+}
+
+// restoreEnvRefs puts the ${VAR} references of raw back into v wherever they
+// still expand to v's value, so saving a config never writes secrets to disk.
+func restoreEnvRefs(v, raw any) any {
+	switch v := v.(type) {
+	case string:
+		if ref, ok := raw.(string); ok && envRefPattern.MatchString(ref) {
+			if expanded, err := expandEnv(ref); err == nil && expanded == v {
+				return ref
+			}
+		}
+
+	case map[string]any:
+		rawMap, _ := raw.(map[string]any)
+		for key, item := range v {
+			v[key] = restoreEnvRefs(item, rawMap[key])
+		}
+
+	case []any:
+		rawSlice, _ := raw.([]any)
+		for i, item := range v {
+			if i < len(rawSlice) {
+				v[i] = restoreEnvRefs(item, rawSlice[i])
+			}
+		}
+	}
+
+	return v
+}
+
+// hasEnvRefs reports whether any string in a decoded JSON value references a variable.
+func hasEnvRefs(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return envRefPattern.MatchString(v)
+	case map[string]any:
+		for _, item := range v {
+			if hasEnvRefs(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if hasEnvRefs(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
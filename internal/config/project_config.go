@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 )
 
 const (
@@ -16,26 +17,68 @@ type ProjectConfig struct {
 	Schema    string     `json:"$schema"`
 	Hosts     []QuicHost `json:"hosts"`
 	Templates []Template `json:"templates"`
+	// Defaults for hosts naming the group, see ResolveHost
+	Groups []HostGroup `json:"groups,omitempty"`
+
+	// quic.json as written, with ${VAR} references unexpanded
+	raw any
 }
 
 type QuicHost struct {
-	IP                     string   `json:"ip"`
-	Alias                  string   `json:"alias"`
-	EncryptionAtRest       string   `json:"encryptionAtRest"`
-	Devices                []string `json:"devices"`
-	CertificateFingerprint string   `json:"certificateFingerprint,omitempty"`
+	IP                     string   `json:"ip" yaml:"ip"`
+	Alias                  string   `json:"alias" yaml:"alias"`
+	EncryptionAtRest       string   `json:"encryptionAtRest" yaml:"encryptionAtRest"`
+	Devices                []string `json:"devices" yaml:"devices"`
+	CertificateFingerprint string   `json:"certificateFingerprint,omitempty" yaml:"certificateFingerprint,omitempty"`
+	SSHJump                string   `json:"sshJump,omitempty" yaml:"sshJump,omitempty"` // Bastion for SSH access, e.g. user@bastion:22
+	// Name of a group in quic.json, selected with @name in host commands
+	Group string `json:"group,omitempty" yaml:"group,omitempty"`
+}
+
+// HostGroup holds settings shared by its member hosts. A host's own settings
+// take precedence.
+type HostGroup struct {
+	Name             string   `json:"name"`
+	EncryptionAtRest string   `json:"encryptionAtRest,omitempty"`
+	Devices          []string `json:"devices,omitempty"`
+	SSHJump          string   `json:"sshJump,omitempty"`
 }
 
 type Template struct {
-	Name      string           `json:"name"`
-	PGVersion string           `json:"pgVersion"`
-	Database  string           `json:"database"`
-	Provider  TemplateProvider `json:"provider"`
+	Name       string           `json:"name"`
+	PGVersion  string           `json:"pgVersion"`
+	Database   string           `json:"database"`
+	Provider   TemplateProvider `json:"provider"`
+	Extensions []string         `json:"extensions,omitempty"`
+	// Another template whose restore this one clones instead of restoring its own
+	Source string `json:"source,omitempty"`
 }
 
 type TemplateProvider struct {
 	Name        string `json:"name"`
 	ClusterName string `json:"clusterName"`
+	// Where the provider's API key is read from, see ResolveSecret. Defaults
+	// to DefaultCrunchyBridgeAPIKey.
+	APIKey string `json:"apiKey,omitempty"`
+	// pgBackRest repo index to restore from, for clusters backing up to
+	// several repositories. Defaults to 1.
+	Repo int `json:"repo,omitempty"`
+}
+
+// RepoIndex returns the pgBackRest repo index the provider restores from.
+func (p TemplateProvider) RepoIndex() int {
+	if p.Repo == 0 {
+		return 1
+	}
+	return p.Repo
+}
+
+// APIKeyRef returns the secret reference of the provider's API key.
+func (p TemplateProvider) APIKeyRef() string {
+	if p.APIKey == "" {
+		return DefaultCrunchyBridgeAPIKey
+	}
+	return p.APIKey
 }
 
 func LoadProjectConfig() (*ProjectConfig, error) {
@@ -50,10 +93,31 @@ func LoadProjectConfig() (*ProjectConfig, error) {
 		return nil, fmt.Errorf("failed to read quic.json: %w", err)
 	}
 
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse quic.json: %w", err)
+	}
+	if err := validateProjectConfig(data); err != nil {
+		return nil, fmt.Errorf("invalid quic.json: %w", err)
+	}
+
+	expanded, err := expandEnvTree(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand quic.json: %w", err)
+	}
+
+	expandedData, err := json.Marshal(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand quic.json: %w", err)
+	}
+
 	var config ProjectConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := json.Unmarshal(expandedData, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse quic.json: %w", err)
 	}
+	if hasEnvRefs(raw) {
+		config.raw = raw
+	}
 
 	return &config, nil
 }
@@ -61,7 +125,7 @@ func LoadProjectConfig() (*ProjectConfig, error) {
 func (c *ProjectConfig) save() error {
 	configPath := getQuicConfigPath()
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := c.marshal()
 	if err != nil {
 		return fmt.Errorf("failed to marshal quic.json: %w", err)
 	}
@@ -73,6 +137,25 @@ func (c *ProjectConfig) save() error {
 	return nil
 }
 
+// marshal encodes the config, keeping ${VAR} references from the loaded file.
+func (c *ProjectConfig) marshal() ([]byte, error) {
+	if c.raw == nil {
+		return json.MarshalIndent(c, "", "  ")
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(restoreEnvRefs(tree, c.raw), "", "  ")
+}
+
 func (c *ProjectConfig) AddHost(host QuicHost) error {
 	if err := c.validateHost(host); err != nil {
 		return err
@@ -82,6 +165,34 @@ func (c *ProjectConfig) AddHost(host QuicHost) error {
 	return c.save()
 }
 
+// UpdateHost replaces the alias, devices and SSH jump of the host with the
+// same IP, keeping its certificate fingerprint and encryption setting.
+func (c *ProjectConfig) UpdateHost(host QuicHost) error {
+	if host.Alias == "" {
+		return fmt.Errorf("host alias cannot be empty")
+	}
+
+	if len(host.Devices) == 0 {
+		return fmt.Errorf("host must have at least one device")
+	}
+
+	for _, existingHost := range c.Hosts {
+		if existingHost.IP != host.IP && existingHost.Alias == host.Alias {
+			return fmt.Errorf("host with alias %s already exists", host.Alias)
+		}
+	}
+
+	for i := range c.Hosts {
+		if c.Hosts[i].IP == host.IP {
+			c.Hosts[i].Alias = host.Alias
+			c.Hosts[i].Devices = host.Devices
+			c.Hosts[i].SSHJump = host.SSHJump
+			return c.save()
+		}
+	}
+	return fmt.Errorf("host with IP %s not found", host.IP)
+}
+
 func (c *ProjectConfig) AddTemplate(template Template) error {
 	if err := c.validateTemplate(template); err != nil {
 		return err
@@ -101,6 +212,18 @@ func (c *ProjectConfig) SetHostCertificateFingerprint(ip, fingerprint string) er
 	return fmt.Errorf("host with IP %s not found", ip)
 }
 
+// AddHostDevices adds devices to a host. A host using its group's devices
+// gets its own list, starting with those.
+func (c *ProjectConfig) AddHostDevices(ip string, devices []string) error {
+	for i := range c.Hosts {
+		if c.Hosts[i].IP == ip {
+			c.Hosts[i].Devices = slices.Concat(c.ResolveHost(c.Hosts[i]).Devices, devices)
+			return c.save()
+		}
+	}
+	return fmt.Errorf("host with IP %s not found", ip)
+}
+
 func (c *ProjectConfig) validateHost(host QuicHost) error {
 	if host.IP == "" {
 		return fmt.Errorf("host IP cannot be empty")
@@ -140,6 +263,66 @@ func (c *ProjectConfig) GetHostByIP(ip string) *QuicHost {
 	return nil
 }
 
+func (c *ProjectConfig) GetGroup(name string) *HostGroup {
+	for i := range c.Groups {
+		if c.Groups[i].Name == name {
+			return &c.Groups[i]
+		}
+	}
+	return nil
+}
+
+// ResolveHost returns host with the settings it leaves empty taken from its
+// group.
+func (c *ProjectConfig) ResolveHost(host QuicHost) QuicHost {
+	group := c.GetGroup(host.Group)
+	if group == nil {
+		return host
+	}
+
+	if host.EncryptionAtRest == "" {
+		host.EncryptionAtRest = group.EncryptionAtRest
+	}
+	if len(host.Devices) == 0 {
+		host.Devices = group.Devices
+	}
+	if host.SSHJump == "" {
+		host.SSHJump = group.SSHJump
+	}
+	return host
+}
+
+// ValidateHostGroups checks that group names are unique and that hosts only
+// name groups defined in quic.json.
+func (c *ProjectConfig) ValidateHostGroups() error {
+	names := make(map[string]bool)
+	for _, group := range c.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("host group name cannot be empty")
+		}
+		if names[group.Name] {
+			return fmt.Errorf("host group with name %s already exists", group.Name)
+		}
+		names[group.Name] = true
+	}
+
+	for _, host := range c.Hosts {
+		if host.Group != "" && !names[host.Group] {
+			return fmt.Errorf("group '%s' of host %s is not a group in quic.json", host.Group, host.Alias)
+		}
+	}
+	return nil
+}
+
+func (c *ProjectConfig) GetTemplate(name string) *Template {
+	for i := range c.Templates {
+		if c.Templates[i].Name == name {
+			return &c.Templates[i]
+		}
+	}
+	return nil
+}
+
 func (c *ProjectConfig) validateTemplate(template Template) error {
 	if template.Name == "" {
 		return fmt.Errorf("template name cannot be empty")
@@ -171,12 +354,67 @@ func (c *ProjectConfig) validateTemplate(template Template) error {
 	return nil
 }
 
+// ValidateTemplateSources checks that each template source names another
+// template restored from a backup.
+func (c *ProjectConfig) ValidateTemplateSources() error {
+	for _, template := range c.Templates {
+		if template.Source == "" {
+			continue
+		}
+		if template.Source == template.Name {
+			return fmt.Errorf("template '%s' can't be its own source", template.Name)
+		}
+		source := c.GetTemplate(template.Source)
+		if source == nil {
+			return fmt.Errorf("source '%s' of template '%s' is not a template in quic.json", template.Source, template.Name)
+		}
+		if source.Source != "" {
+			return fmt.Errorf("source '%s' of template '%s' has a source itself, point to '%s' instead", template.Source, template.Name, source.Source)
+		}
+	}
+	return nil
+}
+
+// Set by the --config flag, see SetProjectConfigPath
+var projectConfigPath string
+
+// SetProjectConfigPath makes the CLI use the quic.json at path instead of
+// looking for one.
+func SetProjectConfigPath(path string) {
+	projectConfigPath = path
+}
+
+// getQuicConfigPath returns the --config path, then QUIC_CONFIG, then the
+// nearest quic.json in the current directory or its parents. Without any, a
+// new quic.json goes in the current directory.
 func getQuicConfigPath() string {
-	return filepath.Join(".", QuicConfigFileName)
+	if projectConfigPath != "" {
+		return projectConfigPath
+	}
+	if path := os.Getenv("QUIC_CONFIG"); path != "" {
+		return path
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return filepath.Join(".", QuicConfigFileName)
+	}
+	for {
+		path := filepath.Join(dir, QuicConfigFileName)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return filepath.Join(".", QuicConfigFileName)
+		}
+		dir = parent
+	}
 }
 
 func createDefaultQuicConfig() (*ProjectConfig, error) {
-	fmt.Println("Initializing quic.json")
+	// On stderr, commands may print JSON
+	fmt.Fprintln(os.Stderr, "Initializing quic.json")
 
 	config := &ProjectConfig{
 		Schema:    QuicSchemaURL,
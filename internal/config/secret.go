@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultCrunchyBridgeAPIKey is where the CrunchyBridge API key is read from
+// when a template doesn't say.
+const DefaultCrunchyBridgeAPIKey = "env:CB_API_KEY"
+
+// ResolveSecret reads a credential from where ref points:
+//
+//	env:NAME      the environment variable NAME
+//	file:PATH     the contents of PATH, without the trailing newline
+//	cmd:COMMAND   the output of COMMAND run by sh, e.g. cmd:op read op://vault/cb/key
+//
+// so secrets can come from a secret manager without being exported or written
+// to quic.json.
+func ResolveSecret(ref string) (string, error) {
+	scheme, value, ok := strings.Cut(ref, ":")
+	if !ok || value == "" {
+		return "", fmt.Errorf("secret reference '%s' must be env:NAME, file:PATH or cmd:COMMAND", ref)
+	}
+
+	var secret string
+	switch scheme {
+	case "env":
+		secret = os.Getenv(value)
+		if secret == "" {
+			return "", fmt.Errorf("environment variable %s is not set", value)
+		}
+
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("reading secret file: %w", err)
+		}
+		secret = strings.TrimRight(string(data), "\r\n")
+
+	case "cmd":
+		cmd := exec.Command("sh", "-c", value)
+		cmd.Stderr = os.Stderr // Let secret managers prompt or explain failures
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("running secret command '%s': %w", value, err)
+		}
+		secret = strings.TrimRight(string(output), "\r\n")
+
+	default:
+		return "", fmt.Errorf("unknown secret source '%s' in '%s', use env, file or cmd", scheme, ref)
+	}
+
+	if secret == "" {
+		return "", fmt.Errorf("secret from '%s' is empty", ref)
+	}
+	return secret, nil
+}
@@ -16,6 +16,7 @@ type UserConfig struct {
 const (
 	configDirName  = "quic"
 	configFileName = "config.json"
+	logsDirName    = "logs"
 )
 
 func LoadUserConfig() (*UserConfig, error) {
@@ -101,6 +102,20 @@ func getConfigPath() (string, error) {
 	return filepath.Join(configDir, configFileName), nil
 }
 
+// LogsDir returns the directory for CLI log files, creating it if needed.
+func LogsDir() (string, error) {
+	configDir, err := getConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	logsDir := filepath.Join(configDir, logsDirName)
+	if err := os.MkdirAll(logsDir, 0755); err != nil {
+		return "", err
+	}
+	return logsDir, nil
+}
+
 func createDefaultConfig() *UserConfig {
 	config := &UserConfig{}
 
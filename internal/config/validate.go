@@ -0,0 +1,195 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// validateProjectConfig checks quic.json against the ProjectConfig structure.
+// Unlike json.Unmarshal it rejects unknown fields and keys that only match a
+// field case-insensitively, so typos like "pgversion" don't go unnoticed.
+func validateProjectConfig(data []byte) error {
+	v := &configValidator{
+		dec:  json.NewDecoder(bytes.NewReader(data)),
+		data: data,
+	}
+	return v.value(reflect.TypeOf(ProjectConfig{}), "")
+}
+
+type configValidator struct {
+	dec  *json.Decoder
+	data []byte
+}
+
+// line returns the 1-based line of the last token read.
+func (v *configValidator) line() int {
+	return bytes.Count(v.data[:v.dec.InputOffset()], []byte("\n")) + 1
+}
+
+func (v *configValidator) value(t reflect.Type, path string) error {
+	tok, err := v.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// Types decoding themselves define their own shape
+	if reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		return v.skip(tok)
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if tok != json.Delim('{') {
+			return v.mismatch(tok, path, "an object")
+		}
+		for v.dec.More() {
+			keyTok, err := v.dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			keyPath := strings.TrimPrefix(path+"."+key, ".")
+
+			field, ok := jsonField(t, key)
+			if !ok {
+				return v.unknownField(t, key, keyPath)
+			}
+			if err := v.value(field.Type, keyPath); err != nil {
+				return err
+			}
+		}
+		_, err := v.dec.Token()
+		return err
+
+	case reflect.Slice:
+		if tok != json.Delim('[') {
+			return v.mismatch(tok, path, "an array")
+		}
+		for i := 0; v.dec.More(); i++ {
+			if err := v.value(t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		_, err := v.dec.Token()
+		return err
+
+	case reflect.Map:
+		if tok != json.Delim('{') {
+			return v.mismatch(tok, path, "an object")
+		}
+		for v.dec.More() {
+			keyTok, err := v.dec.Token()
+			if err != nil {
+				return err
+			}
+			keyPath := strings.TrimPrefix(path+"."+keyTok.(string), ".")
+			if err := v.value(t.Elem(), keyPath); err != nil {
+				return err
+			}
+		}
+		_, err := v.dec.Token()
+		return err
+
+	case reflect.String:
+		if _, ok := tok.(string); !ok {
+			return v.mismatch(tok, path, "a string")
+		}
+
+	case reflect.Bool:
+		if _, ok := tok.(bool); !ok {
+			return v.mismatch(tok, path, "a boolean")
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, ok := tok.(float64); !ok {
+			return v.mismatch(tok, path, "a number")
+		}
+
+	default:
+		// Anything else, like an interface, takes any value
+		return v.skip(tok)
+	}
+
+	return nil
+}
+
+var jsonUnmarshaler = reflect.TypeFor[json.Unmarshaler]()
+
+// skip reads past the rest of the value starting with tok.
+func (v *configValidator) skip(tok json.Token) error {
+	if tok != json.Delim('{') && tok != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := v.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+func (v *configValidator) mismatch(tok json.Token, path, want string) error {
+	got := "a number"
+	switch tok := tok.(type) {
+	case json.Delim:
+		got = "an object"
+		if tok == '[' {
+			got = "an array"
+		}
+	case string:
+		got = "a string"
+	case bool:
+		got = "a boolean"
+	}
+	return fmt.Errorf("line %d: %s must be %s, got %s", v.line(), path, want, got)
+}
+
+func (v *configValidator) unknownField(t reflect.Type, key, path string) error {
+	for i := range t.NumField() {
+		if name := jsonName(t.Field(i)); name != "" && strings.EqualFold(name, key) {
+			return fmt.Errorf("line %d: unknown field %s (did you mean %q?)", v.line(), path, name)
+		}
+	}
+	return fmt.Errorf("line %d: unknown field %s", v.line(), path)
+}
+
+// jsonField finds the struct field encoded under key, matching case exactly.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		if field := t.Field(i); jsonName(field) == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
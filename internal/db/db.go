@@ -2,7 +2,9 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -12,6 +14,10 @@ const (
 	DBPath = "/etc/quic/db.sqlite"
 )
 
+// ErrCorrupt means the database file exists but SQLite can't read it.
+// Unlike a locked database, it won't recover without intervention.
+var ErrCorrupt = errors.New("user database is corrupt")
+
 type DB struct {
 	*sql.DB
 }
@@ -31,6 +37,11 @@ func InitDB() (*DB, error) {
 
 	dbWrapper := &DB{DB: db}
 
+	if err := dbWrapper.checkIntegrity(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	if err := dbWrapper.createTables(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("creating tables: %w", err)
@@ -39,6 +50,27 @@ func InitDB() (*DB, error) {
 	return dbWrapper, nil
 }
 
+func (db *DB) checkIntegrity() error {
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		if isCorruptError(err) {
+			return fmt.Errorf("%w: %v", ErrCorrupt, err)
+		}
+		return fmt.Errorf("checking database: %w", err)
+	}
+
+	if result != "ok" {
+		return fmt.Errorf("%w: %s", ErrCorrupt, result)
+	}
+
+	return nil
+}
+
+func isCorruptError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "file is not a database") || strings.Contains(msg, "malformed")
+}
+
 func (db *DB) createTables() error {
 	query := `
 	CREATE TABLE IF NOT EXISTS users (
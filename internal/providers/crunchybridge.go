@@ -322,8 +322,11 @@ func (c *CrunchyBridgeClient) makeRequest(method, url string, body []byte) ([]by
 	return responseBody, nil
 }
 
-func (t *BackupToken) GeneratePgBackRestConfig(stanzaName, pgDataPath string) string {
+// GeneratePgBackRestConfig writes the token's repository as pgBackRest repo
+// index repo, the one the restore reads from.
+func (t *BackupToken) GeneratePgBackRestConfig(stanzaName, pgDataPath string, repo int) string {
 	var config strings.Builder
+	prefix := fmt.Sprintf("repo%d-", repo)
 
 	config.WriteString("[global]\n")
 	config.WriteString("log-path=/var/log/pgbackrest\n")
@@ -333,31 +336,31 @@ func (t *BackupToken) GeneratePgBackRestConfig(stanzaName, pgDataPath string) st
 
 	config.WriteString(fmt.Sprintf("[%s]\n", stanzaName))
 	config.WriteString(fmt.Sprintf("pg1-path=%s\n", pgDataPath))
-	config.WriteString(fmt.Sprintf("repo1-path=%s\n", t.RepoPath))
+	config.WriteString(fmt.Sprintf(prefix+"path=%s\n", t.RepoPath))
 
 	switch t.Type {
 	case "s3":
 		if t.AWS != nil {
-			config.WriteString("repo1-type=s3\n")
-			config.WriteString(fmt.Sprintf("repo1-s3-bucket=%s\n", t.AWS.S3Bucket))
-			config.WriteString(fmt.Sprintf("repo1-s3-key=%s\n", t.AWS.S3Key))
-			config.WriteString(fmt.Sprintf("repo1-s3-key-secret=%s\n", t.AWS.S3KeySecret))
-			config.WriteString(fmt.Sprintf("repo1-s3-region=%s\n", t.AWS.S3Region))
-			config.WriteString("repo1-s3-endpoint=s3.amazonaws.com\n")            // CrunchyBridge S3 endpoint
-			config.WriteString(fmt.Sprintf("repo1-s3-token=%s\n", t.AWS.S3Token)) // STS session token
+			config.WriteString(prefix + "type=s3\n")
+			config.WriteString(fmt.Sprintf(prefix+"s3-bucket=%s\n", t.AWS.S3Bucket))
+			config.WriteString(fmt.Sprintf(prefix+"s3-key=%s\n", t.AWS.S3Key))
+			config.WriteString(fmt.Sprintf(prefix+"s3-key-secret=%s\n", t.AWS.S3KeySecret))
+			config.WriteString(fmt.Sprintf(prefix+"s3-region=%s\n", t.AWS.S3Region))
+			config.WriteString(prefix + "s3-endpoint=s3.amazonaws.com\n")          // CrunchyBridge S3 endpoint
+			config.WriteString(fmt.Sprintf(prefix+"s3-token=%s\n", t.AWS.S3Token)) // STS session token
 		}
 	case "azure":
 		if t.Azure != nil {
-			config.WriteString("repo1-type=azure\n")
-			config.WriteString(fmt.Sprintf("repo1-azure-account=%s\n", t.Azure.StorageAccount))
-			config.WriteString(fmt.Sprintf("repo1-azure-key=%s\n", t.Azure.StorageKey))
-			config.WriteString(fmt.Sprintf("repo1-azure-container=%s\n", t.Azure.Container))
+			config.WriteString(prefix + "type=azure\n")
+			config.WriteString(fmt.Sprintf(prefix+"azure-account=%s\n", t.Azure.StorageAccount))
+			config.WriteString(fmt.Sprintf(prefix+"azure-key=%s\n", t.Azure.StorageKey))
+			config.WriteString(fmt.Sprintf(prefix+"azure-container=%s\n", t.Azure.Container))
 		}
 	case "gcs", "gcp":
 		if t.GCP != nil {
-			config.WriteString("repo1-type=gcs\n")
-			config.WriteString(fmt.Sprintf("repo1-gcs-bucket=%s\n", t.GCP.Bucket))
-			config.WriteString(fmt.Sprintf("repo1-gcs-key=%s\n", t.GCP.ServiceAccountKey))
+			config.WriteString(prefix + "type=gcs\n")
+			config.WriteString(fmt.Sprintf(prefix+"gcs-bucket=%s\n", t.GCP.Bucket))
+			config.WriteString(fmt.Sprintf(prefix+"gcs-key=%s\n", t.GCP.ServiceAccountKey))
 		}
 	}
 
@@ -2,11 +2,20 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/quickr-dev/quic/internal/agent"
 	"github.com/quickr-dev/quic/internal/auth"
+	"github.com/quickr-dev/quic/internal/version"
 	pb "github.com/quickr-dev/quic/proto"
 )
 
@@ -27,34 +36,104 @@ func (s *QuicServer) CreateCheckout(ctx context.Context, req *pb.CreateCheckoutR
 		return nil, fmt.Errorf("user not found in context")
 	}
 
-	checkout, err := s.agentService.CreateBranch(ctx, req.CloneName, req.RestoreName, user)
+	opts := agent.BranchOptions{
+		Extensions:     req.Extensions,
+		IdempotencyKey: req.IdempotencyKey,
+		RoleMode:       req.RoleMode,
+		Settings:       req.Settings,
+		Archive:        req.Archive,
+		WALReset:       req.WalReset,
+		AdminPassword:  req.AdminPassword,
+		MaxConnections: int(req.MaxConnections),
+		Profile:        req.Profile,
+		Analyze:        req.Analyze,
+		Mountpoint:     req.Mountpoint,
+	}
+
+	checkout, err := s.agentService.CreateBranch(ctx, req.CloneName, req.RestoreName, user, opts)
 	if err != nil {
+		if isResourceExhausted(err) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		if errors.Is(err, agent.ErrOperationCancelled) {
+			return nil, status.Error(codes.Canceled, err.Error())
+		}
+		if errors.Is(err, agent.ErrServiceRestarting) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
 		return nil, err
 	}
 
 	return &pb.CreateCheckoutResponse{
 		ConnectionString: checkout.ConnectionString("localhost"),
+		RoleMode:         checkout.RoleMode,
+		Host:             s.agentService.PublicHost(ctx),
+		Timings:          agent.StepTimingsToProto(checkout.Timings),
+		MaxConnections:   int32(checkout.ConnectionLimit()),
 	}, nil
 }
 
 func (s *QuicServer) DeleteCheckout(ctx context.Context, req *pb.DeleteCheckoutRequest) (*pb.DeleteCheckoutResponse, error) {
-	// user, ok := auth.GetUserFromContext(ctx)
-	// if !ok {
-	// 	return nil, fmt.Errorf("user not found in context")
-	// }
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	if req.DryRun {
+		plan, err := s.agentService.PlanBranchDeletion(ctx, req.RestoreName, req.CloneName)
+		if err != nil {
+			return nil, err
+		}
 
-	deleted, err := s.agentService.DeleteBranch(ctx, req.RestoreName, req.CloneName)
+		return &pb.DeleteCheckoutResponse{
+			Plan: &pb.DeletePlan{
+				Dataset:         plan.Dataset,
+				Snapshot:        plan.Snapshot,
+				Mountpoint:      plan.Mountpoint,
+				ServiceName:     plan.ServiceName,
+				Port:            plan.Port,
+				Promoted:        plan.Promoted,
+				DependentClones: plan.DependentClones,
+				Replicas:        plan.Replicas,
+			},
+		}, nil
+	}
+
+	// Detaching keeps the data, so the maintenance policy doesn't apply
+	if req.KeepData {
+		detached, err := s.agentService.DetachBranch(ctx, req.RestoreName, req.CloneName, user)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.DeleteCheckoutResponse{Deleted: detached}, nil
+	}
+
+	if err := s.agentService.CheckDestructiveAllowed("delete", req.IgnoreMaintenance); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	deleted, replicas, err := s.agentService.DeleteBranch(ctx, req.RestoreName, req.CloneName, user, req.Force)
 	if err != nil {
 		return nil, err
 	}
 
 	return &pb.DeleteCheckoutResponse{
-		Deleted: deleted,
+		Deleted:         deleted,
+		DeletedReplicas: replicas,
 	}, nil
 }
 
 func (s *QuicServer) ListCheckouts(ctx context.Context, req *pb.ListCheckoutsRequest) (*pb.ListCheckoutsResponse, error) {
-	checkouts, err := s.agentService.ListBranches(ctx, req.RestoreName)
+	opts := agent.ListOptions{
+		Sort:     req.Sort,
+		Limit:    int(req.Limit),
+		StaleFor: time.Duration(req.StaleSeconds) * time.Second,
+	}
+	if err := agent.ValidateListOptions(opts); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	checkouts, err := s.agentService.ListBranchesWithOptions(ctx, req.RestoreName, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -62,10 +141,15 @@ func (s *QuicServer) ListCheckouts(ctx context.Context, req *pb.ListCheckoutsReq
 	var pbCheckouts []*pb.CheckoutSummary
 	for _, checkout := range checkouts {
 		pbCheckout := &pb.CheckoutSummary{
-			CloneName: checkout.BranchName,
-			CreatedBy: checkout.CreatedBy,
-			CreatedAt: checkout.CreatedAt.Format("2006-01-02 15:04:05"),
-			Port:      checkout.Port,
+			CloneName:       checkout.BranchName,
+			CreatedBy:       checkout.CreatedBy,
+			CreatedAt:       checkout.CreatedAt.Format("2006-01-02 15:04:05"),
+			Port:            checkout.Port,
+			UsedBytes:       checkout.UsedBytes,
+			LastAccessedAt:  checkout.LastActivity().Format("2006-01-02 15:04:05"),
+			RestoreName:     checkout.TemplateName,
+			TemplateMissing: checkout.TemplateMissing,
+			ReplicaOf:       checkout.ReplicaOf,
 		}
 		pbCheckouts = append(pbCheckouts, pbCheckout)
 	}
@@ -76,7 +160,509 @@ func (s *QuicServer) ListCheckouts(ctx context.Context, req *pb.ListCheckoutsReq
 }
 
 func (s *QuicServer) RestoreTemplate(req *pb.RestoreTemplateRequest, stream pb.QuicService_RestoreTemplateServer) error {
+	user, ok := auth.GetUserFromContext(stream.Context())
+	if !ok {
+		return fmt.Errorf("user not found in context")
+	}
+
 	log.Printf("Restoring template: %s", req.TemplateName)
 
-	return s.agentService.TemplateSetup(req, stream)
+	// A delta restore stops the template and rewrites its data in place, a
+	// cancelled one leaves it half updated. Clones keep an existing template.
+	if req.Delta && req.SourceTemplate == "" && agent.TemplateRestoreExists(req.TemplateName) {
+		if err := s.agentService.CheckDestructiveAllowed("delta restore", req.IgnoreMaintenance); err != nil {
+			return status.Error(codes.FailedPrecondition, err.Error())
+		}
+	}
+
+	err := s.agentService.TemplateSetup(req, stream, user)
+	if errors.Is(err, agent.ErrOperationCancelled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return err
+}
+
+func (s *QuicServer) ExportBranch(req *pb.ExportBranchRequest, stream pb.QuicService_ExportBranchServer) error {
+	user, ok := auth.GetUserFromContext(stream.Context())
+	if !ok {
+		return fmt.Errorf("user not found in context")
+	}
+
+	return s.agentService.ExportBranch(req, stream, user)
+}
+
+func (s *QuicServer) ImportBranch(stream pb.QuicService_ImportBranchServer) error {
+	user, ok := auth.GetUserFromContext(stream.Context())
+	if !ok {
+		return fmt.Errorf("user not found in context")
+	}
+
+	err := s.agentService.ImportBranch(stream, user)
+	if isResourceExhausted(err) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return err
+}
+
+// isResourceExhausted reports branch limit and out of space errors.
+func isResourceExhausted(err error) bool {
+	var quotaErr *agent.QuotaExceededError
+	var poolErr *agent.PoolFullError
+	return errors.As(err, &quotaErr) || errors.As(err, &poolErr)
+}
+
+func (s *QuicServer) AttachBranch(ctx context.Context, req *pb.AttachBranchRequest) (*pb.AttachBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	result, err := s.agentService.AttachBranch(ctx, req.RestoreName, req.CloneName, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.AttachBranchResponse{
+		ConnectionString: result.ConnectionString("localhost"),
+		Host:             s.agentService.PublicHost(ctx),
+		PreviousPort:     result.PreviousPort,
+	}, nil
+}
+
+func (s *QuicServer) MoveBranch(ctx context.Context, req *pb.MoveBranchRequest) (*pb.MoveBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	// Moving stops the branch and removes it from its template
+	if err := s.agentService.CheckDestructiveAllowed("move", req.IgnoreMaintenance); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	result, err := s.agentService.MoveBranch(ctx, req.RestoreName, req.CloneName, req.ToRestoreName, user, req.Force)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.MoveBranchResponse{
+		Host:        s.agentService.PublicHost(ctx),
+		CopiedBytes: result.CopiedBytes,
+	}
+	if !result.Detached {
+		resp.ConnectionString = result.ConnectionString("localhost")
+	}
+	return resp, nil
+}
+
+func (s *QuicServer) PromoteBranch(ctx context.Context, req *pb.PromoteBranchRequest) (*pb.PromoteBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	promoted, err := s.agentService.PromoteBranch(ctx, req.RestoreName, req.CloneName, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.PromoteBranchResponse{
+		Promoted: promoted,
+	}, nil
+}
+
+func (s *QuicServer) CreateReplica(ctx context.Context, req *pb.CreateReplicaRequest) (*pb.CreateReplicaResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	replica, err := s.agentService.CreateReplica(ctx, req.RestoreName, req.CloneName, req.ReplicaName, user)
+	if err != nil {
+		if isResourceExhausted(err) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return nil, err
+	}
+
+	return &pb.CreateReplicaResponse{
+		ReplicaName:      replica.BranchName,
+		ConnectionString: replica.ConnectionString("localhost"),
+		Host:             s.agentService.PublicHost(ctx),
+		ParentRestarted:  replica.ParentRestarted,
+	}, nil
+}
+
+func (s *QuicServer) FreezeBranch(ctx context.Context, req *pb.FreezeBranchRequest) (*pb.FreezeBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	changed, err := s.agentService.FreezeBranch(ctx, req.RestoreName, req.CloneName, !req.Unfreeze, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FreezeBranchResponse{
+		Changed: changed,
+	}, nil
+}
+
+func (s *QuicServer) ReconfigureBranch(ctx context.Context, req *pb.ReconfigureBranchRequest) (*pb.ReconfigureBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	change, err := s.agentService.ReconfigureBranch(ctx, req.RestoreName, req.CloneName, req.Settings, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.ReconfigureBranchResponse{
+		Reloaded:  change.Reloaded,
+		Restarted: change.Restarted,
+	}, nil
+}
+
+func (s *QuicServer) GetBranchDiff(ctx context.Context, req *pb.GetBranchDiffRequest) (*pb.GetBranchDiffResponse, error) {
+	diff, err := s.agentService.GetBranchDiff(ctx, req.RestoreName, req.CloneName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.GetBranchDiffResponse{
+		BaseSnapshot: diff.BaseSnapshot,
+		WrittenBytes: diff.WrittenBytes,
+	}
+	for _, db := range diff.Databases {
+		resp.Databases = append(resp.Databases, &pb.DatabaseSizeDiff{
+			Name:          db.Name,
+			TemplateBytes: db.TemplateBytes,
+			BranchBytes:   db.BranchBytes,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *QuicServer) DescribeBranch(ctx context.Context, req *pb.DescribeBranchRequest) (*pb.DescribeBranchResponse, error) {
+	description, err := s.agentService.DescribeBranch(ctx, req.RestoreName, req.CloneName, req.Database, int(req.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.DescribeBranchResponse{
+		Database:   description.Database,
+		TableCount: int32(description.TableCount),
+	}
+	for _, db := range description.Databases {
+		resp.Databases = append(resp.Databases, &pb.DatabaseSize{
+			Name:  db.Name,
+			Bytes: db.Bytes,
+		})
+	}
+	for _, table := range description.Tables {
+		resp.Tables = append(resp.Tables, &pb.TableSize{
+			Name:          table.Name,
+			EstimatedRows: table.EstimatedRows,
+			TotalBytes:    table.TotalBytes,
+			IndexBytes:    table.IndexBytes,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *QuicServer) GetBranchInfo(ctx context.Context, req *pb.GetBranchInfoRequest) (*pb.GetBranchInfoResponse, error) {
+	info, err := s.agentService.GetBranchInfo(ctx, req.RestoreName, req.CloneName)
+	if err != nil {
+		return nil, err
+	}
+
+	var walArchive string
+	if info.Archive {
+		walArchive = agent.GetBranchWALArchiveDir(info.BranchPath)
+	}
+
+	return &pb.GetBranchInfoResponse{
+		CloneName:        info.BranchName,
+		RestoreName:      info.TemplateName,
+		Port:             info.Port,
+		CreatedBy:        info.CreatedBy,
+		CreatedAt:        info.CreatedAt.Format(time.RFC3339),
+		ServiceStatus:    info.ServiceStatus,
+		Ready:            info.Ready,
+		InRecovery:       info.InRecovery,
+		ConnectionCount:  int32(info.ConnectionCount),
+		UsedBytes:        info.Space.Used,
+		ReferencedBytes:  info.Space.Referenced,
+		QuotaBytes:       info.Space.Quota,
+		RoleMode:         info.RoleMode,
+		Extensions:       info.Extensions,
+		Promoted:         info.Promoted,
+		Checkpoints:      checkpointsToProto(info.Checkpoints),
+		LastAccessedAt:   info.LastActivity().Format(time.RFC3339),
+		Settings:         settingsToProto(info.Settings),
+		WalArchive:       walArchive,
+		ConnectionString: info.ConnectionString("localhost"),
+		Host:             s.agentService.PublicHost(ctx),
+		Encryption:       info.Space.Encryption,
+		Compression:      info.Space.Compression,
+		CompressRatio:    info.Space.CompressRatio,
+		Timings:          agent.StepTimingsToProto(info.Timings),
+		WalReset:         info.WALReset,
+		Detached:         info.Detached,
+		MaxConnections:   int32(info.ConnectionLimit()),
+		Profile:          info.Profile,
+		Analyzed:         info.Analyzed,
+		Mountpoint:       info.BranchPath,
+		Frozen:           info.Frozen,
+		TemplateMissing:  info.TemplateMissing,
+		ReplicaOf:        info.ReplicaOf,
+		Replicas:         info.Replicas,
+		DataAsOf:         info.DataAsOf,
+		BackupFinishedAt: info.BackupFinishedAt,
+	}, nil
+}
+
+func (s *QuicServer) SnapshotBranch(ctx context.Context, req *pb.SnapshotBranchRequest) (*pb.SnapshotBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	checkpoint, err := s.agentService.SnapshotBranch(ctx, req.RestoreName, req.CloneName, req.Label, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.SnapshotBranchResponse{
+		Checkpoint: checkpointsToProto([]agent.BranchCheckpoint{*checkpoint})[0],
+	}, nil
+}
+
+func (s *QuicServer) RollbackBranch(ctx context.Context, req *pb.RollbackBranchRequest) (*pb.RollbackBranchResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	// Rolling back discards the branch's changes since the checkpoint
+	if err := s.agentService.CheckDestructiveAllowed("rollback", req.IgnoreMaintenance); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err := s.agentService.RollbackBranch(ctx, req.RestoreName, req.CloneName, req.Label, user); err != nil {
+		return nil, err
+	}
+
+	return &pb.RollbackBranchResponse{}, nil
+}
+
+func settingsToProto(settings map[string]string) []string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		pairs = append(pairs, name+"="+settings[name])
+	}
+	return pairs
+}
+
+func checkpointsToProto(checkpoints []agent.BranchCheckpoint) []*pb.BranchCheckpoint {
+	result := make([]*pb.BranchCheckpoint, 0, len(checkpoints))
+	for _, c := range checkpoints {
+		result = append(result, &pb.BranchCheckpoint{
+			Label:     c.Label,
+			CreatedBy: c.CreatedBy,
+			CreatedAt: c.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return result
+}
+
+func (s *QuicServer) ListOperations(ctx context.Context, req *pb.ListOperationsRequest) (*pb.ListOperationsResponse, error) {
+	now := time.Now()
+
+	var operations []*pb.Operation
+	for _, op := range s.agentService.ListOperations() {
+		operations = append(operations, &pb.Operation{
+			Id:             op.ID,
+			Type:           op.Type,
+			Target:         op.Target,
+			User:           op.User,
+			StartedAt:      op.StartedAt.Format(time.RFC3339),
+			ElapsedSeconds: int64(now.Sub(op.StartedAt).Seconds()),
+			Queued:         op.Queued,
+			Cancelable:     op.Cancelable,
+		})
+	}
+
+	return &pb.ListOperationsResponse{
+		Operations: operations,
+	}, nil
+}
+
+func (s *QuicServer) StreamEvents(req *pb.StreamEventsRequest, stream pb.QuicService_StreamEventsServer) error {
+	sub := s.agentService.SubscribeEvents()
+	defer s.agentService.UnsubscribeEvents(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-sub.C:
+			if err := stream.Send(&pb.LifecycleEvent{
+				Event:     event.Event,
+				Template:  event.Template,
+				Branch:    event.Branch,
+				User:      event.User,
+				Timestamp: event.Timestamp,
+				Host:      event.Host,
+				Port:      event.Port,
+				Dropped:   int32(event.Dropped),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *QuicServer) CancelOperation(ctx context.Context, req *pb.CancelOperationRequest) (*pb.CancelOperationResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	err := s.agentService.CancelOperation(req.Id, user)
+	switch {
+	case errors.Is(err, agent.ErrOperationNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, agent.ErrOperationNotCancelable):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, agent.ErrCancelNotAllowed):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return nil, err
+	}
+
+	return &pb.CancelOperationResponse{}, nil
+}
+
+func (s *QuicServer) ListAuditEntries(ctx context.Context, req *pb.ListAuditEntriesRequest) (*pb.ListAuditEntriesResponse, error) {
+	since, err := time.Parse(time.RFC3339, req.Since)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid since '%s': %v", req.Since, err))
+	}
+
+	entries, err := s.agentService.AuditEntriesSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ListAuditEntriesResponse{}
+	for _, entry := range entries {
+		details, err := json.Marshal(entry["details"])
+		if err != nil {
+			return nil, fmt.Errorf("marshaling audit details: %w", err)
+		}
+		resp.Entries = append(resp.Entries, &pb.AuditEntry{
+			Timestamp: fmt.Sprint(entry["timestamp"]),
+			EventType: fmt.Sprint(entry["event_type"]),
+			Details:   string(details),
+		})
+	}
+	return resp, nil
+}
+
+func (s *QuicServer) GetTemplateInfo(ctx context.Context, req *pb.GetTemplateInfoRequest) (*pb.GetTemplateInfoResponse, error) {
+	info, err := s.agentService.GetTemplateInfo(ctx, req.TemplateName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.GetTemplateInfoResponse{
+		TemplateName:     req.TemplateName,
+		Stanza:           info.Stanza,
+		Database:         info.Database,
+		Port:             info.Port,
+		ServiceName:      info.ServiceName,
+		ServiceStatus:    info.ServiceStatus,
+		CreatedAt:        info.CreatedAt,
+		Ready:            info.Ready,
+		BranchCount:      int32(info.BranchCount),
+		BranchLimit:      int32(info.Limits.PerTemplate),
+		HostBranchCount:  int32(info.HostBranchCount),
+		HostBranchLimit:  int32(info.Limits.PerHost),
+		BackupLabel:      info.Backup.Label,
+		BackupLsnStart:   info.Backup.LSNStart,
+		BackupLsnStop:    info.Backup.LSNStop,
+		BackupFinishedAt: info.Backup.FinishedAt,
+		UsedBytes:        info.Space.Used,
+		ReferencedBytes:  info.Space.Referenced,
+		AvailableBytes:   info.Space.Available,
+		Encryption:       info.Space.Encryption,
+		Compression:      info.Space.Compression,
+		CompressRatio:    info.Space.CompressRatio,
+		SourceTemplate:   info.Source,
+		TargetTime:       info.TargetTime,
+	}
+	if info.Recovery != nil {
+		resp.RecoveryState = info.Recovery.State
+		resp.ReplayLsn = info.Recovery.LSN
+	}
+
+	return resp, nil
+}
+
+func (s *QuicServer) CollectTemplateSnapshots(ctx context.Context, req *pb.CollectTemplateSnapshotsRequest) (*pb.CollectTemplateSnapshotsResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, fmt.Errorf("user not found in context")
+	}
+
+	result, err := s.agentService.CollectTemplateSnapshots(ctx, req.TemplateName, req.DryRun, user)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.CollectTemplateSnapshotsResponse{
+		InUse:          int32(result.InUse),
+		ReclaimedBytes: result.ReclaimedBytes,
+	}
+	for _, snapshot := range result.Orphans {
+		resp.Snapshots = append(resp.Snapshots, &pb.OrphanSnapshot{
+			Name:      snapshot.Name,
+			Kind:      snapshot.Kind,
+			UsedBytes: snapshot.UsedBytes,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *QuicServer) Health(ctx context.Context, req *pb.HealthRequest) (*pb.HealthResponse, error) {
+	resp := &pb.HealthResponse{
+		Version:          version.Version,
+		ServerTimeUnixMs: time.Now().UnixMilli(),
+	}
+	for _, conflict := range s.agentService.PortConflicts() {
+		resp.PortConflicts = append(resp.PortConflicts, &pb.PortConflict{
+			Port:     conflict.Port,
+			Branches: conflict.Branches,
+		})
+	}
+	return resp, nil
+}
+
+func (s *QuicServer) WhoAmI(ctx context.Context, req *pb.WhoAmIRequest) (*pb.WhoAmIResponse, error) {
+	user, ok := auth.GetUserFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "user not found in context")
+	}
+
+	return &pb.WhoAmIResponse{
+		UserName: user,
+		Version:  version.Version,
+	}, nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"sort"
 	"strconv"
@@ -73,7 +74,9 @@ type lsblkOutput struct {
 	Blockdevices []BlockDevice `json:"blockdevices"`
 }
 
-func NewClient(host string) (*Client, error) {
+// NewClient connects to host, through jumpHost (ssh -J syntax, e.g.
+// user@bastion:22) when it is not empty.
+func NewClient(host, jumpHost string) (*Client, error) {
 	// Try connecting as different users
 	users := []string{"ec2-user", "ubuntu", "root"}
 
@@ -84,6 +87,9 @@ func NewClient(host string) (*Client, error) {
 		"-o", "BatchMode=yes", // Don't prompt for passwords
 		"-o", "LogLevel=ERROR", // Suppress SSH warnings
 	}
+	if jumpHost != "" {
+		baseSSHArgs = append(baseSSHArgs, "-J", jumpHost)
+	}
 
 	for _, user := range users {
 		sshArgs := append(baseSSHArgs, "-l", user)
@@ -104,6 +110,9 @@ func NewClient(host string) (*Client, error) {
 		}
 	}
 
+	if jumpHost != "" {
+		return nil, fmt.Errorf("failed to ssh to %s via %s. Tried users: %s", host, jumpHost, strings.Join(users, ", "))
+	}
 	return nil, fmt.Errorf("failed to ssh to %s. Tried users: %s", host, strings.Join(users, ", "))
 }
 
@@ -127,6 +136,20 @@ func (c *Client) RunCommand(cmd string) ([]byte, error) {
 	return c.runCommandWithStderr(cmd, false)
 }
 
+// RunArgs runs a command given as separate arguments. Each is quoted for the
+// remote shell, so a path with spaces or shell characters stays one argument.
+func (c *Client) RunArgs(args ...string) ([]byte, error) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return c.RunCommand(strings.Join(quoted, " "))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func (c *Client) runCommandWithStderr(cmd string, includeStderr bool) ([]byte, error) {
 	if c.useSudo {
 		cmd = "sudo " + cmd
@@ -145,6 +168,31 @@ func (c *Client) runCommandWithStderr(cmd string, includeStderr bool) ([]byte, e
 	}
 }
 
+// WriteFile streams a local file to remotePath on the host
+func (c *Client) WriteFile(localPath, remotePath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", localPath, err)
+	}
+	defer file.Close()
+
+	cmd := fmt.Sprintf("tee %s > /dev/null", remotePath)
+	if c.useSudo {
+		cmd = "sudo " + cmd
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	sshCmd := exec.CommandContext(ctx, "ssh", append(c.sshArgs, c.host, cmd)...)
+	sshCmd.Stdin = file
+	if output, err := sshCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("writing %s: %w (output: %s)", remotePath, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
 func (c *Client) VerifyRootAccess() error {
 	// Test basic connectivity
 	output, err := c.RunCommand("whoami")
@@ -268,7 +316,7 @@ func (c *Client) GetAvailableDevices(devices []BlockDevice) []BlockDevice {
 }
 
 func (c *Client) TestPath(path string) error {
-	_, err := c.RunCommand(fmt.Sprintf("test -e %s", path))
+	_, err := c.RunArgs("test", "-e", path)
 	if err != nil {
 		return fmt.Errorf("path does not exist or is not accessible")
 	}
@@ -0,0 +1,98 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Watch periodically re-renders the output of a fetch function until the user quits.
+type Watch struct {
+	title    string
+	interval time.Duration
+	fetch    func() (string, error)
+	body     string
+	err      error
+	updated  time.Time
+}
+
+type watchTickMsg struct{}
+
+type watchResultMsg struct {
+	body string
+	err  error
+}
+
+func NewWatch(title string, interval time.Duration, fetch func() (string, error)) *Watch {
+	return &Watch{
+		title:    title,
+		interval: interval,
+		fetch:    fetch,
+	}
+}
+
+func (m *Watch) Init() tea.Cmd {
+	return m.refresh
+}
+
+func (m *Watch) refresh() tea.Msg {
+	body, err := m.fetch()
+	return watchResultMsg{body: body, err: err}
+}
+
+func (m *Watch) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			return m, tea.Quit
+		}
+
+	case watchResultMsg:
+		// Keep showing the last good output when a refresh fails
+		if msg.err == nil {
+			m.body = msg.body
+		}
+		m.err = msg.err
+		m.updated = time.Now()
+		return m, tea.Tick(m.interval, func(time.Time) tea.Msg { return watchTickMsg{} })
+
+	case watchTickMsg:
+		return m, m.refresh
+	}
+
+	return m, nil
+}
+
+func (m *Watch) View() string {
+	var b strings.Builder
+
+	b.WriteString(boldStyle.Render(m.title))
+	b.WriteString("\n\n")
+
+	if m.updated.IsZero() {
+		b.WriteString("Loading...\n")
+	} else {
+		b.WriteString(m.body)
+		if m.err != nil {
+			b.WriteString(fmt.Sprintf("\nRefresh failed: %v\n", m.err))
+		}
+		b.WriteString(helpStyle.Render(fmt.Sprintf("\nUpdated %s, every %s", m.updated.Format("15:04:05"), m.interval)))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(helpStyle.Render("q: quit"))
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+func RunWatch(title string, interval time.Duration, fetch func() (string, error)) error {
+	p := tea.NewProgram(NewWatch(title, interval, fetch))
+	if _, err := p.Run(); err != nil {
+		return fmt.Errorf("failed to run watch: %w", err)
+	}
+	return nil
+}
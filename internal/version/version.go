@@ -23,6 +23,15 @@ type GitHubRelease struct {
 }
 
 func GetLatestVersion() (string, error) {
+	tag, err := GetLatestTag()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(tag, "v"), nil
+}
+
+// GetLatestTag returns the tag of the latest release, e.g. v1.2.3.
+func GetLatestTag() (string, error) {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	req, err := http.NewRequest("GET", "https://api.github.com/repos/quickr-dev/quic/releases/latest", nil)
@@ -55,7 +64,7 @@ func GetLatestVersion() (string, error) {
 		return "", fmt.Errorf("no release tag found")
 	}
 
-	return strings.TrimPrefix(release.TagName, "v"), nil
+	return release.TagName, nil
 }
 
 func IsNewerVersion(current, latest string) bool {
@@ -160,6 +169,41 @@ func SelfUpdate() error {
 	return nil
 }
 
+// DownloadQuicd downloads the quicd linux binary for arch from the given
+// release tag ("latest" for the most recent release) into a temp file.
+func DownloadQuicd(tag, arch string) (string, error) {
+	binaryName := fmt.Sprintf("quicd-linux-%s", arch)
+
+	downloadURL := fmt.Sprintf("https://github.com/quickr-dev/quic/releases/download/%s/%s", tag, binaryName)
+	if tag == "latest" {
+		downloadURL = fmt.Sprintf("https://github.com/quickr-dev/quic/releases/latest/download/%s", binaryName)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(downloadURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download quicd: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to download quicd %s: HTTP %d", tag, resp.StatusCode)
+	}
+
+	f, err := os.CreateTemp("", binaryName+"-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write quicd: %v", err)
+	}
+
+	return f.Name(), nil
+}
+
 func CheckForUpdateNotification() {
 	latest, err := GetLatestVersion()
 	if err != nil {